@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/commands"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/util"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
@@ -21,47 +25,109 @@ func keyFunc(secretKey string, logger *zerolog.Logger) jwt.Keyfunc {
 	}
 }
 
-func jwtAuthMiddleware(configuration *models.Configuration, db *gorm.DB, logger *zerolog.Logger) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if configuration == nil {
-			logger.Error().Msg("jwtAuthMiddleware: had an error when parsing jwt token")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Token could not be parsed"})
-			c.Abort()
-			return
+// errRateLimited is returned by meterUsage when the customer is over the rate
+// limit of its plan.
+var errRateLimited = errors.New("Too Many Requests - Rate limit of the plan is reached")
+
+// authenticateJwt parses the jwt and checks that the customer it was issued to
+// exists. Its errors are meant for the caller.
+func authenticateJwt(configuration *models.Configuration, db *gorm.DB, logger *zerolog.Logger, tokenString string) (jwt.MapClaims, error) {
+	if configuration == nil {
+		logger.Error().Msg("jwtAuthMiddleware: had an error when parsing jwt token")
+		return nil, errors.New("Unauthorized - Token could not be parsed")
+	}
+
+	token, err := jwt.Parse(tokenString, keyFunc(configuration.Secret, logger))
+	// Tokens signed before the secret was rotated stay valid until they expire
+	for i := 0; err != nil && i < len(configuration.PreviousSecrets); i++ {
+		if validationErr, ok := err.(*jwt.ValidationError); !ok || validationErr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
 		}
-		tokenString := c.GetHeader("Authorization")
+		token, err = jwt.Parse(tokenString, keyFunc(configuration.PreviousSecrets[i], logger))
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("jwtAuthMiddleware: had an error when parsing jwt token")
+		return nil, errors.New("Unauthorized - Token could not be parsed")
+	}
+	if !token.Valid {
+		logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: token is not valid: %v", tokenString))
+		return nil, errors.New("Unauthorized - Token is not valid")
+	}
 
-		token, err := jwt.Parse(tokenString, keyFunc(configuration.Secret, logger))
-		if err != nil {
-			logger.Error().Err(err).Msg("jwtAuthMiddleware: had an error when parsing jwt token")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Token could not be parsed"})
-			c.Abort()
-			return
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: claims are not valid: %v", tokenString))
+		return nil, errors.New("Unauthorized - Claims are not valid")
+	}
+	var customer models.Customer
+	res := db.First(&customer, "id = ?", claims["customerId"])
+	if res.Error != nil {
+		logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: claims are not valid: %v", tokenString))
+		return nil, errors.New("Unauthorized - No customer with given id")
+	}
+	return claims, nil
+}
+
+// authenticateApiKey finds the valid api token matching the pair. Its errors
+// are meant for the caller.
+func authenticateApiKey(db *gorm.DB, logger *zerolog.Logger, apiToken, apiSecret string) (models.ApiToken, error) {
+	var secret models.Secret
+	res := db.Where("value = ?", apiSecret).First(&secret)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			logger.Debug().Msg(fmt.Sprintf("apiKeyAuthMiddleware: secret is not valid: %v", apiSecret))
+			return models.ApiToken{}, errors.New("Unauthorized - Cant match given secret")
 		}
-		if !token.Valid {
-			logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: token is not valid: %v", tokenString))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Token is not valid"})
-			c.Abort()
-			return
+		logger.Err(res.Error).Msg(fmt.Sprintf("apiKeyAuthMiddleware: an error occured: %s", res.Error.Error()))
+		return models.ApiToken{}, errors.New("Unauthorized - An error occured")
+	}
+
+	var token models.ApiToken
+	res = db.Where("customer_id = ? AND token = ? AND valid_until > ?", secret.CustomerID, apiToken, time.Now()).First(&token)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			logger.Debug().Msg(fmt.Sprintf("apiKeyAuthMiddleware: token is not valid: %v", apiToken))
+			return models.ApiToken{}, errors.New("Unauthorized - Cant match given api token")
 		}
+		logger.Err(res.Error).Msg(fmt.Sprintf("apiKeyAuthMiddleware: an error occured: %s", res.Error.Error()))
+		return models.ApiToken{}, errors.New("Unauthorized - An error occured")
+	}
+	return token, nil
+}
+
+// meterUsage enforces the rate limit of the plan of the customer and counts
+// the call. It returns errRateLimited when the call is over the limit.
+func meterUsage(db *gorm.DB, logger *zerolog.Logger, limiter *util.RateLimiter, customerId string) error {
+	now := time.Now()
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			var customer models.Customer
-			res := db.First(&customer, claims["customerId"])
-			if res.Error != nil {
-				logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: claims are not valid: %v", tokenString))
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - No customer with given id"})
-				c.Abort()
-				return
-			}
-			c.Set("claims", claims)
-		} else {
-			logger.Debug().Msg(fmt.Sprintf("jwtAuthMiddleware: claims are not valid: %v", tokenString))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Claims are not valid"})
+	plan, err := commands.CustomerPlan(db, customerId)
+	if err != nil {
+		logger.Err(err).Msg(fmt.Sprintf("usageMiddleware: an error occured: %s", err.Error()))
+		return err
+	}
+
+	if plan.RateLimitPerMinute > 0 && !limiter.Allow(customerId, plan.RateLimitPerMinute, now) {
+		logger.Debug().Msg(fmt.Sprintf("usageMiddleware: customer %s is over its rate limit", customerId))
+		return errRateLimited
+	}
+
+	_, err = commands.RecordUsage(db, customerId, models.UsageMetricApiCalls, now, 1)
+	if err != nil {
+		logger.Err(err).Msg(fmt.Sprintf("usageMiddleware: could not meter api call: %s", err.Error()))
+	}
+	return nil
+}
+
+func jwtAuthMiddleware(configuration *models.Configuration, db *gorm.DB, logger *zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := authenticateJwt(configuration, db, logger, c.GetHeader("Authorization"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
@@ -70,33 +136,37 @@ func apiKeyAuthMiddleware(db *gorm.DB, logger *zerolog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		apiSecret := c.GetHeader("x-api-secret")
 		apiToken := c.GetHeader("x-api-token")
+		// Calendar clients can only send basic auth, the token is the user
+		// name and the secret the password.
+		if user, password, ok := c.Request.BasicAuth(); ok && apiToken == "" {
+			apiToken, apiSecret = user, password
+		}
 
-		var secret models.Secret
-		res := db.Where("value = ?", apiSecret).First(&secret)
-		if res.Error != nil {
-			if res.Error == gorm.ErrRecordNotFound {
-				logger.Debug().Msg(fmt.Sprintf("apiKeyAuthMiddleware: secret is not valid: %v", apiSecret))
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Cant match given secret"})
-				c.Abort()
-				return
-			}
-			logger.Err(res.Error).Msg(fmt.Sprintf("apiKeyAuthMiddleware: an error occured: %s", res.Error.Error()))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - An error occured"})
+		token, err := authenticateApiKey(db, logger, apiToken, apiSecret)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		var token models.ApiToken
-		res = db.Where("customerId = ? AND token = ?", secret.CustomerID, apiToken).First(&token)
-		if res.Error != nil {
-			if res.Error == gorm.ErrRecordNotFound {
-				logger.Debug().Msg(fmt.Sprintf("apiKeyAuthMiddleware: token is not valid: %v", apiToken))
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - Cant match given api token"})
-				c.Abort()
-				return
-			}
-			logger.Err(res.Error).Msg(fmt.Sprintf("apiKeyAuthMiddleware: an error occured: %s", res.Error.Error()))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - An error occured"})
+		c.Set("customerId", token.CustomerID)
+		c.Set("sourceId", token.SourceID)
+		c.Next()
+	}
+}
+
+// usageMiddleware meters the api calls of the customer authenticated by
+// apiKeyAuthMiddleware and enforces the rate limit of its plan.
+func usageMiddleware(db *gorm.DB, logger *zerolog.Logger, limiter *util.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := meterUsage(db, logger, limiter, c.GetString("customerId"))
+		if err == errRateLimited {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
 			c.Abort()
 			return
 		}
@@ -104,3 +174,18 @@ func apiKeyAuthMiddleware(db *gorm.DB, logger *zerolog.Logger) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// claimedCustomerID returns the customer id from the claims of the jwt that
+// authenticated the request, or an empty string when there is none.
+func claimedCustomerID(c *gin.Context) string {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return ""
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	customerId, _ := mapClaims["customerId"].(string)
+	return customerId
+}
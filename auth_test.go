@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/client"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+	"github.com/lghtr35/reservation-engine/rpc"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+)
+
+// newTestHandler serves the routes from a migrated SQLite database.
+func newTestHandler(t *testing.T) (*Handler, *gin.Engine) {
+	t.Helper()
+	db := repotest.SQLite(t)
+	logger := zerolog.Nop()
+	configuration := models.DefaultConfiguration()
+	configuration.Secret = "test"
+	hasher, err := util.NewHasher(&configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{logger: &logger, db: db, store: gormstore.New(db), hasher: hasher, configuration: &configuration}
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	registerRoutes(g, h, util.NewRateLimiter())
+	return h, g
+}
+
+// TestJwtRoute calls a jwt route with a jwt of an existing customer and of
+// one that does not exist.
+func TestJwtRoute(t *testing.T) {
+	h, g := newTestHandler(t)
+	customer := models.Customer{Name: "alice", Company: "alice Ltd", Email: "alice@example.com"}
+	if err := h.db.Create(&customer).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		customerId string
+		status     int
+	}{
+		{name: "existing customer", customerId: customer.ID, status: http.StatusOK},
+		{name: "unknown customer", customerId: models.NewUUID(), status: http.StatusUnauthorized},
+	} {
+		token, err := client.SignJWT(h.configuration.Secret, test.customerId, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/customers/"+customer.ID, nil)
+		request.Header.Set("Authorization", token)
+		response := httptest.NewRecorder()
+		g.ServeHTTP(response, request)
+
+		if response.Code != test.status {
+			t.Fatalf("%s: answered %d %s", test.name, response.Code, response.Body)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var read models.Customer
+		if err := json.Unmarshal(response.Body.Bytes(), &read); err != nil {
+			t.Fatal(err)
+		}
+		if read.ID != customer.ID || read.Name != "alice" {
+			t.Errorf("%s: read %+v", test.name, read)
+		}
+	}
+
+	// The gRPC calls of jwtMethods are authenticated the same way
+	s := &grpcServer{h: h, limiter: util.NewRateLimiter()}
+	token, err := client.SignJWT(h.configuration.Secret, customer.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", token))
+	if _, err = s.authenticate(ctx, rpc.ReservationEngine_ListCustomers_FullMethodName); err != nil {
+		t.Errorf("gRPC: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Billing of customers from their plan and metered usage.
+ */
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// SourceUsage is what one source of a customer was used for in a period.
+type SourceUsage struct {
+	SourceID     string
+	Name         string
+	Reservations int64
+}
+
+// PeriodBounds returns the first instant of the month and of the month after.
+func PeriodBounds(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse(models.UsagePeriodFormat, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("period %q is not in the YYYY-MM format", period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// InvoiceLines bills the plan, every source and the reservations beyond the
+// ones included in the plan.
+func InvoiceLines(plan models.Plan, sources []SourceUsage, reservations int64) []models.PriceLine {
+	lines := []models.PriceLine{{
+		Description: fmt.Sprintf("Plan %s", plan.Name),
+		Quantity:    1,
+		Unit:        "month",
+		UnitAmount:  plan.MonthlyPriceMinor,
+		Amount:      plan.MonthlyPriceMinor,
+	}}
+
+	for _, source := range sources {
+		lines = append(lines, models.PriceLine{
+			Description: fmt.Sprintf("Source %s (%d reservations)", source.Name, source.Reservations),
+			Quantity:    1,
+			Unit:        "month",
+			UnitAmount:  plan.SourcePriceMinor,
+			Amount:      plan.SourcePriceMinor,
+		})
+	}
+
+	overage := reservations - int64(plan.IncludedReservations)
+	if overage > 0 && plan.OveragePriceMinor > 0 {
+		lines = append(lines, models.PriceLine{
+			Description: fmt.Sprintf("Reservations beyond the %d included", plan.IncludedReservations),
+			Quantity:    overage,
+			Unit:        "reservation",
+			UnitAmount:  plan.OveragePriceMinor,
+			Amount:      overage * plan.OveragePriceMinor,
+		})
+	}
+	return lines
+}
+
+// TaxOf returns the tax on the amount, rounded half away from zero so that a
+// credit note mirrors the invoice it corrects.
+func TaxOf(amount int64, basisPoints int) int64 {
+	tax := amount * int64(basisPoints)
+	if tax < 0 {
+		return -((-tax + 5000) / 10000)
+	}
+	return (tax + 5000) / 10000
+}
+
+// ApplyTotals computes subtotal, tax and total of the invoice from its lines.
+func ApplyTotals(invoice *models.Invoice) {
+	var subtotal int64
+	for _, line := range invoice.Lines {
+		subtotal += line.Amount
+	}
+	invoice.Subtotal = subtotal
+	invoice.TaxAmount = TaxOf(subtotal, invoice.TaxRateBasisPoints)
+	invoice.Total = invoice.Subtotal + invoice.TaxAmount
+}
+
+// WriteCSV writes the invoice as one row per line followed by the totals.
+func WriteCSV(w io.Writer, invoice models.Invoice) error {
+	number := ""
+	if invoice.Number != nil {
+		number = *invoice.Number
+	}
+
+	writer := csv.NewWriter(w)
+	rows := [][]string{
+		{"number", "kind", "period", "currency", "description", "quantity", "unit", "unit_amount", "amount"},
+	}
+	for _, line := range invoice.Lines {
+		rows = append(rows, []string{
+			number, invoice.Kind, invoice.Period, invoice.Currency,
+			line.Description, strconv.FormatInt(line.Quantity, 10), line.Unit,
+			strconv.FormatInt(line.UnitAmount, 10), strconv.FormatInt(line.Amount, 10),
+		})
+	}
+	rows = append(rows,
+		[]string{number, invoice.Kind, invoice.Period, invoice.Currency, "Subtotal", "", "", "", strconv.FormatInt(invoice.Subtotal, 10)},
+		[]string{number, invoice.Kind, invoice.Period, invoice.Currency, fmt.Sprintf("Tax (%d bp)", invoice.TaxRateBasisPoints), "", "", "", strconv.FormatInt(invoice.TaxAmount, 10)},
+		[]string{number, invoice.Kind, invoice.Period, invoice.Currency, "Total", "", "", "", strconv.FormatInt(invoice.Total, 10)},
+	)
+
+	err := writer.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,239 @@
+// Package caldav reads and writes the XML bodies of the WebDAV and CalDAV
+// methods (RFC 4918, RFC 4791) the calendar collections of sources answer.
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+	// NamespaceEngine holds the preconditions of the reservation rules.
+	NamespaceEngine = "urn:reservation-engine"
+)
+
+var prefixes = map[string]string{
+	NamespaceDAV:            "D",
+	NamespaceCalDAV:         "C",
+	NamespaceCalendarServer: "CS",
+	NamespaceEngine:         "E",
+}
+
+func DAV(local string) xml.Name    { return xml.Name{Space: NamespaceDAV, Local: local} }
+func CalDAV(local string) xml.Name { return xml.Name{Space: NamespaceCalDAV, Local: local} }
+
+var (
+	CalendarQuery    = CalDAV("calendar-query")
+	CalendarMultiget = CalDAV("calendar-multiget")
+)
+
+// Property is a WebDAV property with its value as XML, see Href and Text.
+type Property struct {
+	Name  xml.Name
+	Value string
+}
+
+// Response is the part of a multistatus about one resource. Missing lists
+// the requested properties the resource does not have, a response that is
+// NotFound has neither.
+type Response struct {
+	Href       string
+	Properties []Property
+	Missing    []xml.Name
+	NotFound   bool
+}
+
+// Text escapes a property value.
+func Text(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// Href is a property value pointing to path.
+func Href(path string) string {
+	return "<D:href>" + Text(path) + "</D:href>"
+}
+
+// Element is an empty element, as in resource types and privileges.
+func Element(name xml.Name) string {
+	open, _ := tag(name)
+	return "<" + open + "/>"
+}
+
+// tag returns the opening and the closing tag of name, declaring namespaces
+// that have no prefix of their own on the element.
+func tag(name xml.Name) (string, string) {
+	prefix, ok := prefixes[name.Space]
+	if !ok {
+		qualified := "X:" + name.Local
+		return qualified + ` xmlns:X="` + Text(name.Space) + `"`, qualified
+	}
+	qualified := prefix + ":" + name.Local
+	return qualified, qualified
+}
+
+func writeProperties(b *strings.Builder, status string, properties []Property, names []xml.Name) {
+	b.WriteString("<D:propstat><D:prop>")
+	for _, property := range properties {
+		open, close := tag(property.Name)
+		fmt.Fprintf(b, "<%s>%s</%s>", open, property.Value, close)
+	}
+	for _, name := range names {
+		b.WriteString(Element(name))
+	}
+	fmt.Fprintf(b, "</D:prop><D:status>HTTP/1.1 %s</D:status></D:propstat>", status)
+}
+
+// WriteMultistatus writes a 207 body.
+func WriteMultistatus(w io.Writer, responses []Response) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + NamespaceCalDAV + `" xmlns:CS="` + NamespaceCalendarServer + `">`)
+	for _, response := range responses {
+		b.WriteString("<D:response>" + Href(response.Href))
+		if response.NotFound {
+			b.WriteString("<D:status>HTTP/1.1 404 Not Found</D:status>")
+		} else {
+			if len(response.Properties) > 0 || len(response.Missing) == 0 {
+				writeProperties(&b, "200 OK", response.Properties, nil)
+			}
+			if len(response.Missing) > 0 {
+				writeProperties(&b, "404 Not Found", nil, response.Missing)
+			}
+		}
+		b.WriteString("</D:response>")
+	}
+	b.WriteString("</D:multistatus>")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteError writes a DAV:error body naming the precondition that failed,
+// with a description for humans.
+func WriteError(w io.Writer, precondition xml.Name, value, description string) error {
+	open, close := tag(precondition)
+	_, err := fmt.Fprintf(w, `%s<D:error xmlns:D="DAV:" xmlns:C="%s" xmlns:E="%s"><%s>%s</%s><E:description>%s</E:description></D:error>`,
+		xml.Header, NamespaceCalDAV, NamespaceEngine, open, value, close, Text(description))
+	return err
+}
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+type propElement struct {
+	Properties []anyElement `xml:",any"`
+}
+
+func (p *propElement) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(p.Properties))
+	for _, property := range p.Properties {
+		names = append(names, property.XMLName)
+	}
+	return names
+}
+
+// Propfind is the body of a PROPFIND, AllProp is set for an empty body.
+type Propfind struct {
+	AllProp bool
+	Names   []xml.Name
+}
+
+func ReadPropfind(r io.Reader) (Propfind, error) {
+	var body struct {
+		XMLName xml.Name     `xml:"DAV: propfind"`
+		AllProp *struct{}    `xml:"DAV: allprop"`
+		Prop    *propElement `xml:"DAV: prop"`
+	}
+	err := xml.NewDecoder(r).Decode(&body)
+	if errors.Is(err, io.EOF) {
+		return Propfind{AllProp: true}, nil
+	}
+	if err != nil {
+		return Propfind{}, err
+	}
+	return Propfind{AllProp: body.AllProp != nil || body.Prop == nil, Names: body.Prop.names()}, nil
+}
+
+type compFilter struct {
+	Name      string `xml:"name,attr"`
+	TimeRange *struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// Report is the body of a calendar-query or a calendar-multiget REPORT.
+// Start and End bound the events a calendar-query asks for, Hrefs are the
+// resources a calendar-multiget asks for.
+type Report struct {
+	Kind    xml.Name
+	AllProp bool
+	Names   []xml.Name
+	Start   *time.Time
+	End     *time.Time
+	Hrefs   []string
+}
+
+func ReadReport(r io.Reader) (Report, error) {
+	var body struct {
+		XMLName xml.Name
+		AllProp *struct{}    `xml:"DAV: allprop"`
+		Prop    *propElement `xml:"DAV: prop"`
+		Hrefs   []string     `xml:"DAV: href"`
+		Filter  *struct {
+			CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+		} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	}
+	err := xml.NewDecoder(r).Decode(&body)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Kind: body.XMLName, AllProp: body.AllProp != nil || body.Prop == nil, Names: body.Prop.names()}
+	for _, href := range body.Hrefs {
+		report.Hrefs = append(report.Hrefs, strings.TrimSpace(href))
+	}
+	if body.Filter == nil {
+		return report, nil
+	}
+	for _, calendar := range body.Filter.CompFilters {
+		for _, event := range calendar.CompFilters {
+			if event.Name != "VEVENT" || event.TimeRange == nil {
+				continue
+			}
+			report.Start, err = parseUTC(event.TimeRange.Start)
+			if err != nil {
+				return report, err
+			}
+			report.End, err = parseUTC(event.TimeRange.End)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+func parseUTC(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time-range %q", value)
+	}
+	return &t, nil
+}
@@ -0,0 +1,273 @@
+/*
+ * iCalendar (RFC 5545) output of reservations.
+ */
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+const (
+	utcFormat   = "20060102T150405Z"
+	localFormat = "20060102T150405"
+	prodID      = "-//reservation-engine//Calendar Feed//EN"
+)
+
+// Event is one VEVENT. Start and End are written in Location, which has to
+// be the location of a VTIMEZONE of the calendar unless it is UTC.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Location    *time.Location
+	Sequence    int
+	Status      string
+	Created     time.Time
+	Modified    time.Time
+}
+
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// UID is the stable identifier of the reservation's event. It never changes,
+// so calendar clients update the event instead of adding a new one.
+func UID(reservationId string) string {
+	return reservationId + "@reservation-engine"
+}
+
+// ReservationEvent turns a reservation of the source into an event in the
+// timezone of the source. Released reservations stay in the feed as
+// cancelled events, so that subscribed calendars remove them.
+func ReservationEvent(reservation models.Reservation, source models.Source) (Event, error) {
+	timezone := source.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return Event{}, fmt.Errorf("source %s has an invalid timezone %q: %w", source.ID, timezone, err)
+	}
+
+	status := StatusConfirmed
+	switch {
+	case reservation.IsReleased():
+		status = StatusCancelled
+	case reservation.Status == models.ReservationStatusPendingApproval, reservation.Status == models.ReservationStatusPendingPayment:
+		status = StatusTentative
+	}
+
+	description := fmt.Sprintf("Reservation %s (%s)", reservation.ID, strings.ReplaceAll(reservation.Status, "_", " "))
+	if reservation.DecisionComment != "" {
+		description += "\n" + reservation.DecisionComment
+	}
+
+	return Event{
+		UID:         UID(reservation.ID),
+		Summary:     source.Name,
+		Description: description,
+		Start:       reservation.From,
+		End:         reservation.To,
+		Location:    loc,
+		Sequence:    reservation.Sequence,
+		Status:      status,
+		Created:     reservation.CreatedAt,
+		Modified:    reservation.UpdatedAt,
+	}, nil
+}
+
+// Write writes a calendar named name with the events and the VTIMEZONE of
+// every location they use.
+func Write(w io.Writer, name string, events []Event, now time.Time) error {
+	out := &writer{w: bufio.NewWriter(w)}
+	out.line("BEGIN:VCALENDAR")
+	out.line("VERSION:2.0")
+	out.line("PRODID:" + prodID)
+	out.line("CALSCALE:GREGORIAN")
+	out.line("METHOD:PUBLISH")
+	out.line("X-WR-CALNAME:" + escape(name))
+
+	for _, tz := range timezones(events) {
+		writeTimezone(out, tz.loc, tz.from, tz.to)
+	}
+
+	for _, event := range events {
+		out.line("BEGIN:VEVENT")
+		out.line("UID:" + escape(event.UID))
+		out.line("DTSTAMP:" + now.UTC().Format(utcFormat))
+		out.line(dateTime("DTSTART", event.Start, event.Location))
+		out.line(dateTime("DTEND", event.End, event.Location))
+		out.line(fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		out.line("STATUS:" + event.Status)
+		out.line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			out.line("DESCRIPTION:" + escape(event.Description))
+		}
+		if !event.Created.IsZero() {
+			out.line("CREATED:" + event.Created.UTC().Format(utcFormat))
+		}
+		if !event.Modified.IsZero() {
+			out.line("LAST-MODIFIED:" + event.Modified.UTC().Format(utcFormat))
+		}
+		out.line("END:VEVENT")
+	}
+
+	out.line("END:VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+func dateTime(property string, t time.Time, loc *time.Location) string {
+	if loc == nil || loc == time.UTC || loc.String() == "UTC" {
+		return property + ":" + t.UTC().Format(utcFormat)
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", property, loc.String(), t.In(loc).Format(localFormat))
+}
+
+type timezoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// timezones returns every non UTC location of the events with the span of
+// time its VTIMEZONE has to cover, from a year before the first event to a
+// year after the last one.
+func timezones(events []Event) []timezoneRange {
+	ranges := make(map[string]*timezoneRange)
+	for _, event := range events {
+		if event.Location == nil || event.Location.String() == "UTC" {
+			continue
+		}
+		r, ok := ranges[event.Location.String()]
+		if !ok {
+			r = &timezoneRange{loc: event.Location, from: event.Start, to: event.End}
+			ranges[event.Location.String()] = r
+		}
+		if event.Start.Before(r.from) {
+			r.from = event.Start
+		}
+		if event.End.After(r.to) {
+			r.to = event.End
+		}
+	}
+
+	result := make([]timezoneRange, 0, len(ranges))
+	for _, r := range ranges {
+		from := time.Date(r.from.In(r.loc).Year()-1, time.January, 1, 0, 0, 0, 0, r.loc)
+		to := time.Date(r.to.In(r.loc).Year()+2, time.January, 1, 0, 0, 0, 0, r.loc)
+		result = append(result, timezoneRange{loc: r.loc, from: from, to: to})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].loc.String() < result[j].loc.String() })
+	return result
+}
+
+// writeTimezone describes the offsets of loc between from and to. Go does
+// not expose the rules of a zone, so every transition in the span is found
+// by scanning and written as its own observance.
+func writeTimezone(out *writer, loc *time.Location, from, to time.Time) {
+	out.line("BEGIN:VTIMEZONE")
+	out.line("TZID:" + loc.String())
+
+	name, offset := from.Zone()
+	observance(out, from.IsDST(), from, offset, offset, name)
+
+	for t := from; t.Before(to); {
+		next := t.AddDate(0, 0, 1)
+		_, nextOffset := next.In(loc).Zone()
+		if nextOffset != offset {
+			// Narrow the transition down to the second.
+			lo, hi := t, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, o := mid.In(loc).Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			at := hi.In(loc)
+			name, _ = at.Zone()
+			observance(out, at.IsDST(), at, offset, nextOffset, name)
+			offset = nextOffset
+		}
+		t = next
+	}
+
+	out.line("END:VTIMEZONE")
+}
+
+// observance writes a STANDARD or DAYLIGHT component starting at the
+// transition at, whose DTSTART is local time in the offset before it.
+func observance(out *writer, dst bool, at time.Time, offsetFrom, offsetTo int, name string) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	out.line("BEGIN:" + kind)
+	out.line("DTSTART:" + at.UTC().Add(time.Duration(offsetFrom)*time.Second).Format(localFormat))
+	out.line("TZOFFSETFROM:" + formatOffset(offsetFrom))
+	out.line("TZOFFSETTO:" + formatOffset(offsetTo))
+	if name != "" {
+		out.line("TZNAME:" + escape(name))
+	}
+	out.line("END:" + kind)
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	result := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		result += fmt.Sprintf("%02d", seconds%60)
+	}
+	return result
+}
+
+// escape escapes a TEXT value.
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// writer writes content lines folded at 75 octets and ended by CRLF. The
+// first error is kept and stops further writes.
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) line(content string) {
+	if w.err != nil {
+		return
+	}
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		if _, w.err = w.w.WriteString(content[:cut] + "\r\n "); w.err != nil {
+			return
+		}
+		content = content[cut:]
+		// The leading space of a continuation line counts towards its length.
+		limit = 74
+	}
+	_, w.err = w.w.WriteString(content + "\r\n")
+}
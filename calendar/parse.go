@@ -0,0 +1,369 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences caps the occurrences a single recurring event expands to.
+const MaxOccurrences = 1000
+
+// Occurrence is one reservation-sized instance of an imported event. Err is
+// set when the event could not be read, its times are zero then.
+type Occurrence struct {
+	UID       string
+	Summary   string
+	Start     time.Time
+	End       time.Time
+	Cancelled bool
+	Recurring bool
+	// Organizer and Attendees are the mail addresses of the event.
+	Organizer string
+	Attendees []string
+	Err       error
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+type component struct {
+	name       string
+	properties []property
+	children   []*component
+}
+
+func (c *component) get(name string) (property, bool) {
+	for _, p := range c.properties {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return property{}, false
+}
+
+func (c *component) all(name string) []property {
+	var result []property
+	for _, p := range c.properties {
+		if p.name == name {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Read parses an iCalendar document and expands its VEVENTs into
+// occurrences, ordered as in the document. Floating times and dates are read
+// in loc. Recurrences without an end stop at horizon.
+func Read(r io.Reader, loc *time.Location, horizon time.Time) ([]Occurrence, error) {
+	root, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Occurrence
+	overrides := make(map[string][]*component)
+	var masters []*component
+	for _, calendar := range root.children {
+		if calendar.name != "VCALENDAR" {
+			continue
+		}
+		for _, event := range calendar.children {
+			if event.name != "VEVENT" {
+				continue
+			}
+			uid, _ := event.get("UID")
+			if _, ok := event.get("RECURRENCE-ID"); ok {
+				overrides[uid.value] = append(overrides[uid.value], event)
+				continue
+			}
+			masters = append(masters, event)
+		}
+	}
+	if len(masters) == 0 && len(overrides) == 0 {
+		return nil, errors.New("the document has no VEVENT")
+	}
+
+	for _, event := range masters {
+		uid, _ := event.get("UID")
+		result = append(result, expand(event, overrides[uid.value], loc, horizon)...)
+		delete(overrides, uid.value)
+	}
+	// Overrides of events that are not in the document stand on their own.
+	for _, events := range overrides {
+		for _, event := range events {
+			result = append(result, single(event, loc))
+		}
+	}
+	return result, nil
+}
+
+func parse(r io.Reader) (*component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &component{}
+	stack := []*component{root}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch p.name {
+		case "BEGIN":
+			child := &component{name: strings.ToUpper(p.value)}
+			top.children = append(top.children, child)
+			stack = append(stack, child)
+		case "END":
+			if len(stack) == 1 || top.name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("unexpected END:%s", p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.properties = append(top.properties, p)
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("%s is not closed", stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+// unfold joins continuation lines, which start with a space or a tab.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseProperty(line string) (property, error) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	p := property{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return p, nil
+}
+
+func unescape(text string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(text)
+}
+
+// parseTime reads a DATE or DATE-TIME value. The bool tells whether it was
+// a DATE.
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	value := p.value
+	if p.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcFormat, value)
+		return t, false, err
+	}
+	if tzid, ok := p.params["TZID"]; ok {
+		zone, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %s", tzid)
+		}
+		loc = zone
+	}
+	t, err := time.ParseInLocation(localFormat, value, loc)
+	return t, false, err
+}
+
+// times reads the start and the end of an event, an event without end lasts
+// a day when it starts on a date and is empty otherwise.
+func times(event *component, loc *time.Location) (time.Time, time.Time, error) {
+	dtstart, ok := event.get("DTSTART")
+	if !ok {
+		return time.Time{}, time.Time{}, errors.New("DTSTART is missing")
+	}
+	start, isDate, err := parseTime(dtstart, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid DTSTART: %w", err)
+	}
+
+	if dtend, ok := event.get("DTEND"); ok {
+		end, _, err := parseTime(dtend, start.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid DTEND: %w", err)
+		}
+		return start, end, nil
+	}
+	if duration, ok := event.get("DURATION"); ok {
+		d, err := parseDuration(duration.value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid DURATION: %w", err)
+		}
+		return start, start.Add(d), nil
+	}
+	if isDate {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	return start, start, nil
+}
+
+// parseDuration reads a dur-value such as "PT1H30M" or "-P1D".
+func parseDuration(value string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign, value = -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("%q does not start with P", value)
+	}
+
+	var total time.Duration
+	number := 0
+	hasNumber := false
+	for _, r := range value[1:] {
+		if r >= '0' && r <= '9' {
+			number = number*10 + int(r-'0')
+			hasNumber = true
+			continue
+		}
+		unit := map[rune]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}[r]
+		if r == 'T' {
+			continue
+		}
+		if unit == 0 || !hasNumber {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		total += time.Duration(number) * unit
+		number, hasNumber = 0, false
+	}
+	return sign * total, nil
+}
+
+func single(event *component, loc *time.Location) Occurrence {
+	o := header(event)
+	o.Start, o.End, o.Err = times(event, loc)
+	return o
+}
+
+func header(event *component) Occurrence {
+	uid, _ := event.get("UID")
+	summary, _ := event.get("SUMMARY")
+	status, _ := event.get("STATUS")
+	organizer, _ := event.get("ORGANIZER")
+	_, recurring := event.get("RRULE")
+	o := Occurrence{
+		UID:       uid.value,
+		Summary:   unescape(summary.value),
+		Cancelled: strings.EqualFold(status.value, StatusCancelled),
+		Recurring: recurring,
+		Organizer: mailAddress(organizer.value),
+	}
+	for _, attendee := range event.all("ATTENDEE") {
+		if address := mailAddress(attendee.value); address != "" {
+			o.Attendees = append(o.Attendees, address)
+		}
+	}
+	return o
+}
+
+// mailAddress returns the address of a mailto: CAL-ADDRESS, and an empty
+// string for any other one.
+func mailAddress(value string) string {
+	if len(value) < 7 || !strings.EqualFold(value[:7], "mailto:") {
+		return ""
+	}
+	return value[7:]
+}
+
+// expand returns the occurrences of the event, without its EXDATEs and with
+// the instances replaced by overrides.
+func expand(event *component, overrides []*component, loc *time.Location, horizon time.Time) []Occurrence {
+	rrule, recurring := event.get("RRULE")
+	if !recurring {
+		return []Occurrence{single(event, loc)}
+	}
+
+	o := header(event)
+	start, end, err := times(event, loc)
+	if err != nil {
+		o.Err = err
+		return []Occurrence{o}
+	}
+	rule, err := parseRule(rrule.value, start.Location())
+	if err != nil {
+		o.Err = fmt.Errorf("invalid RRULE: %w", err)
+		return []Occurrence{o}
+	}
+
+	excluded := make(map[int64]bool)
+	for _, exdate := range event.all("EXDATE") {
+		for _, value := range strings.Split(exdate.value, ",") {
+			t, _, err := parseTime(property{params: exdate.params, value: value}, start.Location())
+			if err != nil {
+				o.Err = fmt.Errorf("invalid EXDATE: %w", err)
+				return []Occurrence{o}
+			}
+			excluded[t.Unix()] = true
+		}
+	}
+	replaced := make(map[int64]*component)
+	for _, override := range overrides {
+		recurrenceId, _ := override.get("RECURRENCE-ID")
+		t, _, err := parseTime(recurrenceId, start.Location())
+		if err == nil {
+			replaced[t.Unix()] = override
+		}
+	}
+
+	var result []Occurrence
+	for _, at := range rule.occurrences(start, horizon) {
+		if excluded[at.Unix()] {
+			continue
+		}
+		if override, ok := replaced[at.Unix()]; ok {
+			result = append(result, single(override, loc))
+			continue
+		}
+		occurrence := o
+		occurrence.Start = at
+		occurrence.End = at.Add(end.Sub(start))
+		result = append(result, occurrence)
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// document wraps the lines of one VEVENT into a calendar.
+func document(lines ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:event"}, lines...), "END:VEVENT", "END:VCALENDAR"), "\r\n")
+}
+
+func utc(month time.Month, day, hour int) time.Time {
+	year := 2026
+	if month < time.October {
+		year = 2027
+	}
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestReadExpandsRecurrences(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	horizon := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// 5 October 2026 is a Monday.
+	start := []string{"DTSTART:20261005T090000Z", "DTEND:20261005T100000Z"}
+
+	for _, test := range []struct {
+		name  string
+		lines []string
+		want  []time.Time
+	}{
+		{
+			name:  "count",
+			lines: append(start, "RRULE:FREQ=DAILY;COUNT=3"),
+			want:  []time.Time{utc(time.October, 5, 9), utc(time.October, 6, 9), utc(time.October, 7, 9)},
+		},
+		{
+			name:  "until is inclusive",
+			lines: append(start, "RRULE:FREQ=WEEKLY;UNTIL=20261026T090000Z"),
+			want:  []time.Time{utc(time.October, 5, 9), utc(time.October, 12, 9), utc(time.October, 19, 9), utc(time.October, 26, 9)},
+		},
+		{
+			name:  "weekdays",
+			lines: append(start, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4"),
+			want:  []time.Time{utc(time.October, 5, 9), utc(time.October, 7, 9), utc(time.October, 12, 9), utc(time.October, 14, 9)},
+		},
+		{
+			name:  "interval",
+			lines: append(start, "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3"),
+			want:  []time.Time{utc(time.October, 5, 9), utc(time.October, 19, 9), utc(time.November, 2, 9)},
+		},
+		{
+			name:  "last friday of the month",
+			lines: append(start, "RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"),
+			want:  []time.Time{utc(time.October, 30, 9), utc(time.November, 27, 9), utc(time.December, 25, 9)},
+		},
+		{
+			name:  "days a month does not have are skipped",
+			lines: append(start, "RRULE:FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3"),
+			want:  []time.Time{utc(time.October, 31, 9), utc(time.December, 31, 9), utc(time.January, 31, 9)},
+		},
+		{
+			name:  "leap days",
+			lines: append(start, "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;COUNT=2"),
+			want:  []time.Time{time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC), time.Date(2032, time.February, 29, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "excluded dates still count",
+			lines: append(start, "RRULE:FREQ=DAILY;COUNT=4", "EXDATE:20261006T090000Z,20261008T090000Z"),
+			want:  []time.Time{utc(time.October, 5, 9), utc(time.October, 7, 9)},
+		},
+		{
+			name:  "without an end stops at the horizon",
+			lines: []string{"DTSTART:20261229T090000Z", "DURATION:PT1H", "RRULE:FREQ=DAILY"},
+			want:  []time.Time{utc(time.December, 29, 9), utc(time.December, 30, 9), utc(time.December, 31, 9)},
+		},
+		{
+			name:  "keeps the local time across daylight saving time",
+			lines: []string{"DTSTART;TZID=Europe/Berlin:20261024T090000", "DURATION:PT1H", "RRULE:FREQ=DAILY;COUNT=2"},
+			want:  []time.Time{time.Date(2026, time.October, 24, 9, 0, 0, 0, berlin), time.Date(2026, time.October, 25, 9, 0, 0, 0, berlin)},
+		},
+	} {
+		occurrences, err := Read(strings.NewReader(document(test.lines...)), time.UTC, horizon)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var starts []time.Time
+		for _, occurrence := range occurrences {
+			if occurrence.Err != nil {
+				t.Errorf("%s: %v", test.name, occurrence.Err)
+			}
+			if !occurrence.Recurring || occurrence.End.Sub(occurrence.Start) != time.Hour {
+				t.Errorf("%s: expanded into %+v", test.name, occurrence)
+			}
+			starts = append(starts, occurrence.Start)
+		}
+		if len(starts) != len(test.want) {
+			t.Errorf("%s: expanded to %v, want %v", test.name, starts, test.want)
+			continue
+		}
+		for i := range starts {
+			if !starts[i].Equal(test.want[i]) {
+				t.Errorf("%s: expanded to %v, want %v", test.name, starts, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReadLimitsRecurrences(t *testing.T) {
+	occurrences, err := Read(strings.NewReader(document("DTSTART:20261005T090000Z", "DURATION:PT1H", "RRULE:FREQ=DAILY")), time.UTC, time.Date(2036, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != MaxOccurrences {
+		t.Errorf("expanded to %d occurrences, want at most %d", len(occurrences), MaxOccurrences)
+	}
+
+	occurrences, err = Read(strings.NewReader(document("DTSTART:20261005T090000Z", "DURATION:PT1H", "RRULE:FREQ=DAILY;COUNT=5000")), time.UTC, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != MaxOccurrences {
+		t.Errorf("expanded a COUNT of 5000 to %d occurrences, want at most %d", len(occurrences), MaxOccurrences)
+	}
+}
+
+func TestReadReplacesOverriddenInstances(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT", "UID:weekly", "SUMMARY:Standup", "DTSTART:20261005T090000Z", "DURATION:PT1H", "RRULE:FREQ=WEEKLY;COUNT=3", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:weekly", "RECURRENCE-ID:20261012T090000Z", "SUMMARY:Moved", "DTSTART:20261013T140000Z", "DURATION:PT1H", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:weekly", "RECURRENCE-ID:20261019T090000Z", "STATUS:CANCELLED", "DTSTART:20261019T090000Z", "DURATION:PT1H", "END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	occurrences, err := Read(strings.NewReader(calendar), time.UTC, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 3 {
+		t.Fatalf("expanded to %+v", occurrences)
+	}
+	if o := occurrences[1]; o.Summary != "Moved" || !o.Start.Equal(utc(time.October, 13, 14)) {
+		t.Errorf("overridden instance is %+v", o)
+	}
+	if o := occurrences[2]; !o.Cancelled {
+		t.Errorf("cancelled instance is %+v", o)
+	}
+}
+
+func TestReadReportsInvalidEvents(t *testing.T) {
+	for _, rrule := range []string{"FREQ=HOURLY", "COUNT=2", "FREQ=DAILY;COUNT=0", "FREQ=WEEKLY;BYDAY=XX", "FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=DAILY;BYSETPOS=1"} {
+		occurrences, err := Read(strings.NewReader(document("DTSTART:20261005T090000Z", "DURATION:PT1H", "RRULE:"+rrule)), time.UTC, time.Time{})
+		if err != nil {
+			t.Errorf("%s: %v", rrule, err)
+			continue
+		}
+		if len(occurrences) != 1 || occurrences[0].Err == nil {
+			t.Errorf("%s: expanded to %+v", rrule, occurrences)
+		}
+	}
+
+	occurrences, err := Read(strings.NewReader(document("DTSTART:20261005T090000Z", "RRULE:FREQ=DAILY;COUNT=2", "EXDATE:yesterday")), time.UTC, time.Time{})
+	if err != nil || len(occurrences) != 1 || occurrences[0].Err == nil {
+		t.Errorf("read an invalid EXDATE into %+v: %v", occurrences, err)
+	}
+
+	for _, calendar := range []string{"BEGIN:VCALENDAR\r\nEND:VCALENDAR", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR", "not a calendar"} {
+		if _, err := Read(strings.NewReader(calendar), time.UTC, time.Time{}); err == nil {
+			t.Errorf("read %q", calendar)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// weekdayNum is a BYDAY entry, n is the ordinal in the month or the year and
+// zero for every such weekday.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+// rule is the part of an RRULE the import understands: FREQ, INTERVAL,
+// COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH.
+type rule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+func parseRule(value string, loc *time.Location) (rule, error) {
+	r := rule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+			if err == nil && r.count < 1 {
+				err = fmt.Errorf("COUNT must be positive")
+			}
+		case "UNTIL":
+			r.until, _, err = parseTime(property{params: map[string]string{}, value: value}, loc)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day[max(len(day)-2, 0):])]
+				if !ok {
+					return r, fmt.Errorf("invalid BYDAY %s", day)
+				}
+				n := 0
+				if len(day) > 2 {
+					n, err = strconv.Atoi(day[:len(day)-2])
+				}
+				r.byDay = append(r.byDay, weekdayNum{n: n, day: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				var n int
+				n, err = strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return r, fmt.Errorf("invalid BYMONTHDAY %s", day)
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				var n int
+				n, err = strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return r, fmt.Errorf("invalid BYMONTH %s", month)
+				}
+				r.byMonth = append(r.byMonth, time.Month(n))
+			}
+		case "WKST":
+		default:
+			return r, fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return r, fmt.Errorf("FREQ is missing")
+	default:
+		return r, fmt.Errorf("FREQ=%s is not supported", r.freq)
+	}
+	return r, nil
+}
+
+// occurrences returns the start of every instance of the rule, at the wall
+// clock time of start so instances keep their local time across DST. Rules
+// without COUNT or UNTIL stop at horizon, and none go past MaxOccurrences.
+func (r rule) occurrences(start, horizon time.Time) []time.Time {
+	end := horizon
+	if !r.until.IsZero() {
+		end = r.until
+	} else if r.count > 0 {
+		end = start.AddDate(100, 0, 0)
+	}
+
+	var result []time.Time
+	for period := 0; ; period++ {
+		candidates, first := r.period(start, period)
+		if first.After(end) {
+			break
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			if candidate.After(end) || (r.count > 0 && len(result) == r.count) || len(result) == MaxOccurrences {
+				return result
+			}
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+// period returns the sorted candidates of the nth period of the rule and the
+// time the period begins.
+func (r rule) period(start time.Time, n int) ([]time.Time, time.Time) {
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	}
+
+	var candidates []time.Time
+	var first time.Time
+	switch r.freq {
+	case "DAILY":
+		day := start.AddDate(0, 0, n*r.interval)
+		first = at(day.Year(), day.Month(), day.Day())
+		if r.matchesMonth(first.Month()) && r.matchesWeekday(first.Weekday()) && r.matchesMonthDay(first) {
+			candidates = append(candidates, first)
+		}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) + 6) % 7
+		monday := start.AddDate(0, 0, n*r.interval*7-offset)
+		first = at(monday.Year(), monday.Month(), monday.Day())
+		days := r.byDay
+		if len(days) == 0 {
+			days = []weekdayNum{{day: start.Weekday()}}
+		}
+		for _, day := range days {
+			candidate := first.AddDate(0, 0, (int(day.day)+6)%7)
+			candidate = at(candidate.Year(), candidate.Month(), candidate.Day())
+			if r.matchesMonth(candidate.Month()) {
+				candidates = append(candidates, candidate)
+			}
+		}
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(n*r.interval), 1, 0, 0, 0, 0, loc)
+		first = at(month.Year(), month.Month(), 1)
+		if r.matchesMonth(month.Month()) {
+			candidates = r.inMonth(start, month.Year(), month.Month(), at)
+		}
+	case "YEARLY":
+		year := start.Year() + n*r.interval
+		first = at(year, time.January, 1)
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, month := range months {
+			candidates = append(candidates, r.inMonth(start, year, month, at)...)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates, first
+}
+
+// inMonth returns the days of the month selected by BYMONTHDAY or BYDAY, or
+// the day of the month of start when neither is given.
+func (r rule) inMonth(start time.Time, year int, month time.Month, at func(int, time.Month, int) time.Time) []time.Time {
+	length := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	var days []int
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, day := range r.byMonthDay {
+			if day < 0 {
+				day = length + day + 1
+			}
+			days = append(days, day)
+		}
+	case len(r.byDay) > 0:
+		firstWeekday := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		for _, weekday := range r.byDay {
+			var matching []int
+			for day := 1 + (int(weekday.day)-int(firstWeekday)+7)%7; day <= length; day += 7 {
+				matching = append(matching, day)
+			}
+			switch {
+			case weekday.n == 0:
+				days = append(days, matching...)
+			case weekday.n > 0 && weekday.n <= len(matching):
+				days = append(days, matching[weekday.n-1])
+			case weekday.n < 0 && -weekday.n <= len(matching):
+				days = append(days, matching[len(matching)+weekday.n])
+			}
+		}
+	default:
+		days = []int{start.Day()}
+	}
+
+	var result []time.Time
+	for _, day := range days {
+		// Days the month does not have, such as the 31st of April, are skipped.
+		if day >= 1 && day <= length {
+			result = append(result, at(year, month, day))
+		}
+	}
+	return result
+}
+
+func (r rule) matchesMonth(month time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, m := range r.byMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, day := range r.byDay {
+		if day.day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rule) matchesMonthDay(t time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, day := range r.byMonthDay {
+		if day == t.Day() || length+day+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+// Package client is the Go client of the REST API under /api/v1. Requests
+// and responses are the types of the models package. Customers are managed
+// with a jwt, sources and reservations with the api token and secret of a
+// source, see JWT and APIKey.
+//
+// Failed calls are retried when the server could not serve them, mutations
+// are sent with an idempotency key so that a retried one is applied once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+)
+
+// Auth authenticates the requests of a client.
+type Auth interface {
+	Authenticate(r *http.Request)
+}
+
+type jwtAuth string
+
+func (a jwtAuth) Authenticate(r *http.Request) {
+	r.Header.Set("Authorization", string(a))
+}
+
+// JWT authenticates with a jwt, which the customer routes require.
+func JWT(token string) Auth {
+	return jwtAuth(token)
+}
+
+type apiKeyAuth struct {
+	token  string
+	secret string
+}
+
+func (a apiKeyAuth) Authenticate(r *http.Request) {
+	r.Header.Set("x-api-token", a.token)
+	r.Header.Set("x-api-secret", a.secret)
+}
+
+// APIKey authenticates with the api token of a source and the secret of its
+// customer, which the source and reservation routes require.
+func APIKey(token, secret string) Auth {
+	return apiKeyAuth{token: token, secret: secret}
+}
+
+// SignJWT issues a jwt for the customer, signed with the secret the engine
+// is configured with.
+func SignJWT(secret, customerId string, lifetime time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"customerId": customerId,
+		"exp":        time.Now().Add(lifetime).Unix(),
+	})
+	return token.SignedString([]byte(secret))
+}
+
+type Client struct {
+	baseURL    string
+	auth       Auth
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the client instead of
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries retries a call up to maxRetries times, waiting backoff before
+// the first retry and twice as long before every next one. Zero retries
+// turns retrying off.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client of the engine at baseURL, "https://engine.example.com".
+func New(baseURL string, auth Auth, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		auth:       auth,
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// newIdempotencyKey returns a random key, the same one is sent with every
+// attempt of a call.
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// retryable tells whether a response with the status may succeed when sent
+// again.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// wait sleeps before the attempt, honouring the Retry-After of the last
+// response.
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter string) error {
+	delay := c.backoff << (attempt - 1)
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	}
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	// Jitter keeps clients that failed together from retrying together.
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(delay/2)+1))
+	if err == nil {
+		delay = delay/2 + time.Duration(jitter.Int64())
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do sends the call and decodes its JSON response into out, when out is not
+// nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var idempotencyKey string
+	if method != http.MethodGet {
+		var err error
+		idempotencyKey, err = newIdempotencyKey()
+		if err != nil {
+			return err
+		}
+	}
+
+	var lastErr error
+	var retryAfter string
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			err := c.wait(ctx, attempt, retryAfter)
+			if err != nil {
+				return errors.Join(err, lastErr)
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		if c.auth != nil {
+			c.auth.Authenticate(req)
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// The call may or may not have reached the server, the
+			// idempotency key makes sending it again safe.
+			lastErr = err
+			retryAfter = ""
+			continue
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			lastErr = err
+			retryAfter = ""
+			continue
+		}
+
+		if res.StatusCode >= http.StatusBadRequest {
+			lastErr = newError(method, path, res.StatusCode, data)
+			if retryable(res.StatusCode) {
+				retryAfter = res.Header.Get("Retry-After")
+				continue
+			}
+			return lastErr
+		}
+
+		if out == nil || len(data) == 0 {
+			return nil
+		}
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			return fmt.Errorf("client: could not decode the response of %s %s: %w", method, path, err)
+		}
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// attempts records the requests a test server received.
+type attempts struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (a *attempts) add(r *http.Request) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, r)
+	return len(a.requests)
+}
+
+func (a *attempts) all() []*http.Request {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests
+}
+
+// newServer serves handle, which gets the number of the attempt.
+func newServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, attempt int)) (*httptest.Server, *attempts) {
+	t.Helper()
+	a := &attempts{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, a.add(r))
+	}))
+	t.Cleanup(server.Close)
+	return server, a
+}
+
+func reply(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestClientRetries(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		// fail answers the failed attempts, nil drops the connection.
+		fail     func(w http.ResponseWriter)
+		failures int
+		want     error
+	}{
+		{name: "unavailable", failures: 2, fail: func(w http.ResponseWriter) { reply(w, http.StatusServiceUnavailable, nil) }},
+		{name: "rate limited", failures: 3, fail: func(w http.ResponseWriter) { reply(w, http.StatusTooManyRequests, nil) }},
+		{name: "gateway timeout", failures: 1, fail: func(w http.ResponseWriter) { reply(w, http.StatusGatewayTimeout, nil) }},
+		{name: "dropped connection", failures: 2},
+		{name: "out of retries", failures: 4, fail: func(w http.ResponseWriter) { reply(w, http.StatusBadGateway, nil) }, want: ErrServer},
+		{name: "rate limited out of retries", failures: 4, fail: func(w http.ResponseWriter) { reply(w, http.StatusTooManyRequests, nil) }, want: ErrRateLimited},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, attempts := newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+				if attempt > test.failures {
+					reply(w, http.StatusOK, "reservation")
+					return
+				}
+				if test.fail != nil {
+					test.fail(w)
+					return
+				}
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.Close()
+			})
+			c := New(server.URL, nil, WithRetries(3, time.Millisecond))
+
+			id, err := c.CreateReservation(context.Background(), models.CreateReservation{SourceID: "room"})
+			if test.want != nil {
+				if !errors.Is(err, test.want) {
+					t.Errorf("failed with %v, want %v", err, test.want)
+				}
+			} else if err != nil || id != "reservation" {
+				t.Errorf("created %q: %v", id, err)
+			}
+
+			requests := attempts.all()
+			if want := min(test.failures+1, 4); len(requests) != want {
+				t.Fatalf("sent %d attempts, want %d", len(requests), want)
+			}
+			// Every attempt of the call carries the same idempotency key, so
+			// that the engine applies it once.
+			key := requests[0].Header.Get("Idempotency-Key")
+			if len(key) != 32 {
+				t.Errorf("sent the idempotency key %q", key)
+			}
+			for _, r := range requests {
+				if r.Header.Get("Idempotency-Key") != key {
+					t.Errorf("retried with the idempotency key %q, first sent %q", r.Header.Get("Idempotency-Key"), key)
+				}
+			}
+		})
+	}
+}
+
+func TestClientIdempotencyKeys(t *testing.T) {
+	server, attempts := newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if r.Method == http.MethodGet {
+			reply(w, http.StatusOK, models.Reservation{})
+			return
+		}
+		reply(w, http.StatusOK, "id")
+	})
+	c := New(server.URL, nil)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := c.CreateReservation(ctx, models.CreateReservation{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.GetReservation(ctx, "id"); err != nil {
+		t.Fatal(err)
+	}
+	requests := attempts.all()
+	if first, second := requests[0].Header.Get("Idempotency-Key"), requests[1].Header.Get("Idempotency-Key"); first == second {
+		t.Errorf("sent the idempotency key %q with two calls", first)
+	}
+	if key := requests[2].Header.Get("Idempotency-Key"); key != "" {
+		t.Errorf("sent the idempotency key %q with a read", key)
+	}
+}
+
+func TestClientStopsRetryingWithItsContext(t *testing.T) {
+	server, attempts := newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		reply(w, http.StatusServiceUnavailable, nil)
+	})
+	c := New(server.URL, nil, WithRetries(3, time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetSource(ctx, "room")
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrServer) {
+		t.Errorf("failed with %v", err)
+	}
+	if len(attempts.all()) != 1 {
+		t.Errorf("sent %d attempts", len(attempts.all()))
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	kinds := []error{ErrBadRequest, ErrUnauthorized, ErrNotFound, ErrConflict, ErrRateLimited, ErrServer}
+	for _, test := range []struct {
+		status  int
+		body    string
+		want    error
+		message string
+	}{
+		{status: http.StatusBadRequest, body: `{"error":"the slot is taken"}`, want: ErrBadRequest, message: "the slot is taken"},
+		{status: http.StatusUnprocessableEntity, body: `{"error":"from is after to"}`, want: ErrBadRequest, message: "from is after to"},
+		{status: http.StatusUnauthorized, want: ErrUnauthorized},
+		{status: http.StatusForbidden, body: "forbidden\n", want: ErrUnauthorized, message: "forbidden"},
+		{status: http.StatusNotFound, body: `{"message":"no such source"}`, want: ErrNotFound, message: `{"message":"no such source"}`},
+		{status: http.StatusConflict, want: ErrConflict},
+		{status: http.StatusInternalServerError, body: `{"error":"database"}`, want: ErrServer, message: "database"},
+		{status: http.StatusNotImplemented, want: ErrServer},
+	} {
+		t.Run(strconv.Itoa(test.status), func(t *testing.T) {
+			server, attempts := newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			})
+			c := New(server.URL, nil, WithRetries(3, time.Millisecond))
+
+			err := c.DeleteSource(context.Background(), "room")
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("failed with %v", err)
+			}
+			if e.StatusCode != test.status || e.Method != http.MethodDelete || e.Path != "/api/v1/sources/room" || e.Message != test.message {
+				t.Errorf("failed with %+v", e)
+			}
+			for _, kind := range kinds {
+				if errors.Is(err, kind) != (kind == test.want) {
+					t.Errorf("%v matches %v: %t", err, kind, errors.Is(err, kind))
+				}
+			}
+			// Only statuses that may pass when sent again are retried.
+			if len(attempts.all()) != 1 {
+				t.Errorf("sent %d attempts", len(attempts.all()))
+			}
+		})
+	}
+}
+
+func TestClientAuthenticates(t *testing.T) {
+	server, attempts := newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		reply(w, http.StatusOK, models.Source{Name: "room"})
+	})
+	ctx := context.Background()
+
+	if _, err := New(server.URL, JWT("jwt")).GetSource(ctx, "room"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(server.URL+"/", APIKey("token", "secret")).GetSource(ctx, "room"); err != nil {
+		t.Fatal(err)
+	}
+	requests := attempts.all()
+	if r := requests[0]; r.Header.Get("Authorization") != "jwt" || r.Header.Get("x-api-token") != "" {
+		t.Errorf("authenticated with %v", r.Header)
+	}
+	if r := requests[1]; r.URL.Path != "/api/v1/sources/room" || r.Header.Get("x-api-token") != "token" || r.Header.Get("x-api-secret") != "secret" || r.Header.Get("Authorization") != "" {
+		t.Errorf("authenticated %s with %v", r.URL.Path, r.Header)
+	}
+}
+
+// sourcePages serves total sources a page at a time, it fails the page
+// failing is set to.
+func sourcePages(t *testing.T, total, failing int) (*httptest.Server, *attempts) {
+	return newServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("Page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("Size"))
+		if page == failing {
+			reply(w, http.StatusForbidden, map[string]string{"error": "the token expired"})
+			return
+		}
+		var sources []models.Source
+		for i := (page - 1) * size; i < min(page*size, total); i++ {
+			sources = append(sources, models.Source{Name: strconv.Itoa(i)})
+		}
+		reply(w, http.StatusOK, models.NewPaginationResponse(sources, int64(total), uint32(page)))
+	})
+}
+
+func TestClientPages(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name       string
+		total      int
+		pagination models.Pagination
+		pages      int
+		first      int
+	}{
+		{name: "default size", total: 250, pages: 3},
+		{name: "full last page", total: 20, pagination: models.Pagination{Size: 10}, pages: 2},
+		{name: "from a later page", total: 25, pagination: models.Pagination{Page: 2, Size: 10}, pages: 2, first: 10},
+		{name: "empty", total: 0, pages: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, attempts := sourcePages(t, test.total, 0)
+			c := New(server.URL, APIKey("token", "secret"))
+
+			next := test.first
+			for source, err := range c.Sources(ctx, models.ReadAllSources{Pagination: test.pagination}) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if source.Name != strconv.Itoa(next) {
+					t.Fatalf("read %s, want %d", source.Name, next)
+				}
+				next++
+			}
+			if next != test.total {
+				t.Errorf("read up to %d of %d sources", next, test.total)
+			}
+			if len(attempts.all()) != test.pages {
+				t.Errorf("read %d pages, want %d", len(attempts.all()), test.pages)
+			}
+		})
+	}
+
+	t.Run("stops reading when the loop stops", func(t *testing.T) {
+		server, attempts := sourcePages(t, 250, 0)
+		c := New(server.URL, APIKey("token", "secret"))
+		read := 0
+		for _, err := range c.Sources(ctx, models.ReadAllSources{Pagination: models.Pagination{Size: 10}}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read++; read == 15 {
+				break
+			}
+		}
+		if len(attempts.all()) != 2 {
+			t.Errorf("read %d pages for 15 sources", len(attempts.all()))
+		}
+	})
+
+	t.Run("yields the failure of a page", func(t *testing.T) {
+		server, _ := sourcePages(t, 250, 2)
+		c := New(server.URL, APIKey("token", "secret"))
+		read := 0
+		var failure error
+		for _, err := range c.Sources(ctx, models.ReadAllSources{}) {
+			if err != nil {
+				failure = err
+				continue
+			}
+			read++
+		}
+		if read != 100 || !errors.Is(failure, ErrUnauthorized) {
+			t.Errorf("read %d sources and failed with %v", read, failure)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// Customer calls need a client authenticated with a jwt.
+
+func (c *Client) ListCustomers(ctx context.Context, filter models.ReadAllCustomers) (models.PaginationResponse[models.Customer], error) {
+	var res models.PaginationResponse[models.Customer]
+	err := c.do(ctx, http.MethodGet, "/api/v1/customers", encodeQuery(filter), nil, &res)
+	return res, err
+}
+
+// Customers iterates over the customers matching the filter, from the page
+// of its pagination on.
+func (c *Client) Customers(ctx context.Context, filter models.ReadAllCustomers) iter.Seq2[models.Customer, error] {
+	return pages(ctx, filter.Pagination, func(ctx context.Context, pagination models.Pagination) (models.PaginationResponse[models.Customer], error) {
+		filter.Pagination = pagination
+		return c.ListCustomers(ctx, filter)
+	})
+}
+
+func (c *Client) GetCustomer(ctx context.Context, id string) (models.Customer, error) {
+	var res models.Customer
+	err := c.do(ctx, http.MethodGet, "/api/v1/customers/"+url.PathEscape(id), nil, nil, &res)
+	return res, err
+}
+
+// CreateCustomer creates the customer and its secret and returns its id.
+func (c *Client) CreateCustomer(ctx context.Context, request models.CreateCustomer) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/customers", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) UpdateCustomer(ctx context.Context, request models.UpdateCustomer) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPatch, "/api/v1/customers", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) DeleteCustomer(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/customers/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// AssignPlan moves the customer onto a plan, or back to the default one.
+func (c *Client) AssignPlan(ctx context.Context, request models.AssignPlan) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPatch, "/api/v1/customers/plan", nil, request, &id)
+	return id, err
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The kinds of failures, match them with errors.Is.
+var (
+	// ErrBadRequest is returned when the engine refused the call, because it
+	// was not valid or could not be carried out, an overlapping reservation
+	// for instance.
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is returned when the jwt or api key is missing or not
+	// valid.
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	// ErrConflict is returned while a call with the same idempotency key is
+	// still being served.
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited is returned when the rate limit of the plan of the
+	// customer is reached and retrying did not help.
+	ErrRateLimited = errors.New("rate limited")
+	ErrServer      = errors.New("server error")
+)
+
+// Error is a call the engine answered with an error status. Message is the
+// reason the engine gave, the api does not give one for every failure.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func newError(method, path string, statusCode int, body []byte) *Error {
+	e := &Error{Method: method, Path: path, StatusCode: statusCode}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		e.Message = payload.Error
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("client: %s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Is matches the kind of the failure.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"iter"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// defaultPageSize is the size of the pages iterators read when the filter
+// does not set one.
+const defaultPageSize = 100
+
+// pages iterates over the items of every page from the one in pagination on,
+// reading the next page only when the iteration gets to it.
+func pages[T models.Source | models.Reservation | models.Customer | models.ApiToken](ctx context.Context, pagination models.Pagination, readPage func(context.Context, models.Pagination) (models.PaginationResponse[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if pagination.Page == 0 {
+			pagination.Page = 1
+		}
+		if pagination.Size == 0 {
+			pagination.Size = defaultPageSize
+		}
+		for {
+			page, err := readPage(ctx, pagination)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Content {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			read := int64(pagination.Page-1)*int64(pagination.Size) + int64(len(page.Content))
+			if len(page.Content) < int(pagination.Size) || read >= page.Total {
+				return
+			}
+			pagination.Page++
+		}
+	}
+}
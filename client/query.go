@@ -0,0 +1,80 @@
+package client
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeQuery encodes a request the engine binds from the query string the
+// way it binds it, by the form tag of a field or else its name, descending
+// into structs. Nil and zero values are left out.
+func encodeQuery(request any) url.Values {
+	values := url.Values{}
+	encodeFields(reflect.ValueOf(request), values)
+	return values
+}
+
+func encodeFields(v reflect.Value, values url.Values) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		value := v.Field(i)
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				break
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Pointer {
+			continue
+		}
+		if name == "" && value.Kind() == reflect.Struct && value.Type() != timeType {
+			encodeFields(value, values)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if value.Kind() == reflect.Slice {
+			for j := 0; j < value.Len(); j++ {
+				values.Add(name, format(value.Index(j)))
+			}
+			continue
+		}
+		if value.IsZero() && v.Field(i).Kind() != reflect.Pointer {
+			continue
+		}
+		values.Set(name, format(value))
+	}
+}
+
+func format(v reflect.Value) string {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return v.String()
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// Reservation calls need a client authenticated with an api key, except for
+// the admin ones which need a jwt.
+
+func (c *Client) ListReservations(ctx context.Context, filter models.ReadAllReservations) (models.PaginationResponse[models.Reservation], error) {
+	var res models.PaginationResponse[models.Reservation]
+	err := c.do(ctx, http.MethodGet, "/api/v1/reservations", encodeQuery(filter), nil, &res)
+	return res, err
+}
+
+// Reservations iterates over the reservations matching the filter, from the
+// page of its pagination on.
+func (c *Client) Reservations(ctx context.Context, filter models.ReadAllReservations) iter.Seq2[models.Reservation, error] {
+	return pages(ctx, filter.Pagination, func(ctx context.Context, pagination models.Pagination) (models.PaginationResponse[models.Reservation], error) {
+		filter.Pagination = pagination
+		return c.ListReservations(ctx, filter)
+	})
+}
+
+func (c *Client) GetReservation(ctx context.Context, id string) (models.Reservation, error) {
+	var res models.Reservation
+	err := c.do(ctx, http.MethodGet, "/api/v1/reservations/"+url.PathEscape(id), nil, nil, &res)
+	return res, err
+}
+
+// CreateReservation returns the id of the new reservation. It fails with
+// ErrBadRequest when the slot is taken.
+func (c *Client) CreateReservation(ctx context.Context, request models.CreateReservation) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/reservations", nil, request, &id)
+	return id, err
+}
+
+// UpdateReservation reschedules the reservation and returns the fee the
+// cancellation policy of its source charged, if any.
+func (c *Client) UpdateReservation(ctx context.Context, request models.UpdateReservation) (models.ReservationChange, error) {
+	var res models.ReservationChange
+	err := c.do(ctx, http.MethodPatch, "/api/v1/reservations", nil, request, &res)
+	return res, err
+}
+
+// CancelReservation cancels the reservation and returns the fee the
+// cancellation policy of its source charged, if any.
+func (c *Client) CancelReservation(ctx context.Context, id string) (models.ReservationChange, error) {
+	var res models.ReservationChange
+	err := c.do(ctx, http.MethodDelete, "/api/v1/reservations/"+url.PathEscape(id), nil, nil, &res)
+	return res, err
+}
+
+// ImportReservations reports what importing the calendar creates, and
+// creates it when the request commits.
+func (c *Client) ImportReservations(ctx context.Context, request models.ImportReservations) (models.ImportReport, error) {
+	var res models.ImportReport
+	err := c.do(ctx, http.MethodPost, "/api/v1/reservations/import", nil, request, &res)
+	return res, err
+}
+
+func (c *Client) ApproveReservation(ctx context.Context, request models.ReservationDecision) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/reservations/approve", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) RejectReservation(ctx context.Context, request models.ReservationDecision) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/reservations/reject", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) AssignApprover(ctx context.Context, request models.AssignApprover) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/reservations/approver", nil, request, &id)
+	return id, err
+}
+
+// AdminUpdateReservation reschedules the reservation charging the fee of the
+// override instead of the one of the policy.
+func (c *Client) AdminUpdateReservation(ctx context.Context, request models.AdminUpdateReservation) (models.ReservationChange, error) {
+	var res models.ReservationChange
+	err := c.do(ctx, http.MethodPatch, "/api/v1/admin/reservations", nil, request, &res)
+	return res, err
+}
+
+// AdminCancelReservation cancels the reservation charging the fee of the
+// override instead of the one of the policy.
+func (c *Client) AdminCancelReservation(ctx context.Context, id string, override models.FeeOverride) (models.ReservationChange, error) {
+	var res models.ReservationChange
+	err := c.do(ctx, http.MethodDelete, "/api/v1/admin/reservations/"+url.PathEscape(id), encodeQuery(override), nil, &res)
+	return res, err
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// Source calls need a client authenticated with an api key.
+
+func (c *Client) ListSources(ctx context.Context, filter models.ReadAllSources) (models.PaginationResponse[models.Source], error) {
+	var res models.PaginationResponse[models.Source]
+	err := c.do(ctx, http.MethodGet, "/api/v1/sources", encodeQuery(filter), nil, &res)
+	return res, err
+}
+
+// Sources iterates over the sources matching the filter, from the page of its
+// pagination on.
+func (c *Client) Sources(ctx context.Context, filter models.ReadAllSources) iter.Seq2[models.Source, error] {
+	return pages(ctx, filter.Pagination, func(ctx context.Context, pagination models.Pagination) (models.PaginationResponse[models.Source], error) {
+		filter.Pagination = pagination
+		return c.ListSources(ctx, filter)
+	})
+}
+
+func (c *Client) GetSource(ctx context.Context, id string) (models.Source, error) {
+	var res models.Source
+	err := c.do(ctx, http.MethodGet, "/api/v1/sources/"+url.PathEscape(id), nil, nil, &res)
+	return res, err
+}
+
+// CreateSource creates the source and an api token for it and returns its
+// id.
+func (c *Client) CreateSource(ctx context.Context, request models.CreateSource) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPost, "/api/v1/sources", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) UpdateSource(ctx context.Context, request models.UpdateSource) (string, error) {
+	var id string
+	err := c.do(ctx, http.MethodPatch, "/api/v1/sources", nil, request, &id)
+	return id, err
+}
+
+func (c *Client) DeleteSource(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/sources/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Availability returns the busy and free intervals of a source in a window.
+func (c *Client) Availability(ctx context.Context, request models.ReadAvailability) (models.Availability, error) {
+	var res models.Availability
+	err := c.do(ctx, http.MethodGet, "/api/v1/availability", encodeQuery(request), nil, &res)
+	return res, err
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// Api token calls need a client authenticated with a jwt.
+
+func (c *Client) ListApiTokens(ctx context.Context, filter models.ReadAllApiTokens) (models.PaginationResponse[models.ApiToken], error) {
+	var res models.PaginationResponse[models.ApiToken]
+	err := c.do(ctx, http.MethodGet, "/api/v1/api-tokens", encodeQuery(filter), nil, &res)
+	return res, err
+}
+
+// ApiTokens iterates over the api tokens matching the filter, from the page
+// of its pagination on.
+func (c *Client) ApiTokens(ctx context.Context, filter models.ReadAllApiTokens) iter.Seq2[models.ApiToken, error] {
+	return pages(ctx, filter.Pagination, func(ctx context.Context, pagination models.Pagination) (models.PaginationResponse[models.ApiToken], error) {
+		filter.Pagination = pagination
+		return c.ListApiTokens(ctx, filter)
+	})
+}
+
+// CreateApiToken issues another api token for a source of the customer.
+func (c *Client) CreateApiToken(ctx context.Context, request models.CreateApiToken) (models.ApiToken, error) {
+	var res models.ApiToken
+	err := c.do(ctx, http.MethodPost, "/api/v1/api-tokens", nil, request, &res)
+	return res, err
+}
+
+func (c *Client) RevokeApiToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/api-tokens/"+url.PathEscape(id), nil, nil, nil)
+}
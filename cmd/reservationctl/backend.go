@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/client"
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/queries"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// errDirectOnly is returned by operations the HTTP API does not offer.
+var errDirectOnly = errors.New("only available against the database, drop --remote")
+
+// backend carries out the operations that work both against the database and
+// against the HTTP API.
+type backend interface {
+	createCustomer(ctx context.Context, request models.CreateCustomer) (string, error)
+	listCustomers(ctx context.Context, filter models.ReadAllCustomers) (models.PaginationResponse[models.Customer], error)
+	getCustomer(ctx context.Context, id string) (models.Customer, error)
+	deleteCustomer(ctx context.Context, id string) error
+	createSource(ctx context.Context, request models.CreateSource) (string, error)
+	createApiToken(ctx context.Context, request models.CreateApiToken) (models.ApiToken, error)
+	listApiTokens(ctx context.Context, filter models.ReadAllApiTokens) (models.PaginationResponse[models.ApiToken], error)
+	revokeApiToken(ctx context.Context, id string) error
+	listReservations(ctx context.Context, filter models.ReadAllReservations) (models.PaginationResponse[models.Reservation], error)
+	getReservation(ctx context.Context, id string) (models.Reservation, error)
+	cancelReservation(ctx context.Context, id string, override *models.FeeOverride) (models.ReservationChange, error)
+}
+
+// direct runs the commands and queries of the engine on its database.
+type direct struct {
+	db       *gorm.DB
+	store    repository.Store
+	logger   *zerolog.Logger
+	hasher   *util.Hasher
+	provider payments.PaymentProvider
+	// apiTokenLifetime is how long the tokens the ctl issues are valid.
+	apiTokenLifetime time.Duration
+}
+
+func (d *direct) createCustomer(ctx context.Context, request models.CreateCustomer) (string, error) {
+	q := commands.NewCreateCustomerCommand(d.store, d.logger, request.Name, request.Company, request.Email)
+	id, err := q.Execute()
+	if err != nil {
+		return "", err
+	}
+	_, err = commands.NewCreateSecretCommand(d.store, d.logger, d.hasher, id).Execute()
+	return id, err
+}
+
+func (d *direct) listCustomers(ctx context.Context, filter models.ReadAllCustomers) (models.PaginationResponse[models.Customer], error) {
+	res, err := queries.NewFilterCustomersQuery(d.store, d.logger, filter.IDs, filter.Name, filter.Pagination).Execute()
+	if err != nil {
+		return models.PaginationResponse[models.Customer]{}, err
+	}
+	return res.(models.PaginationResponse[models.Customer]), nil
+}
+
+func (d *direct) getCustomer(ctx context.Context, id string) (models.Customer, error) {
+	res, err := queries.NewReadCustomerQuery(d.store, d.logger, id).Execute()
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return res.(models.Customer), nil
+}
+
+func (d *direct) deleteCustomer(ctx context.Context, id string) error {
+	_, err := commands.NewDeleteCustomerCommand(d.store, d.logger, id).Execute()
+	return err
+}
+
+// createSource creates the source and an api token for it, as the HTTP API
+// does.
+func (d *direct) createSource(ctx context.Context, request models.CreateSource) (string, error) {
+	q := commands.NewCreateSourceCommand(d.store, d.logger, request.Name, request.MaxPossibleDuration, request.CustomerID, request.RequiresApproval, request.ApproverID, request.ApprovalTimeout, request.CancellationPolicyID, request.Pricing)
+	id, err := q.Execute()
+	if err != nil {
+		return "", err
+	}
+	_, err = commands.NewCreateApiTokenCommand(d.store, d.logger, d.hasher, request.CustomerID, id, d.apiTokenLifetime).Execute()
+	return id, err
+}
+
+func (d *direct) createApiToken(ctx context.Context, request models.CreateApiToken) (models.ApiToken, error) {
+	q := commands.NewCreateApiTokenCommand(d.store, d.logger, d.hasher, request.CustomerID, request.SourceID, d.apiTokenLifetime)
+	_, err := q.Execute()
+	return q.Token(), err
+}
+
+func (d *direct) listApiTokens(ctx context.Context, filter models.ReadAllApiTokens) (models.PaginationResponse[models.ApiToken], error) {
+	res, err := queries.NewFilterApiTokensQuery(d.store, d.logger, filter.CustomerID, filter.SourceID, filter.Valid, now(), filter.Pagination).Execute()
+	if err != nil {
+		return models.PaginationResponse[models.ApiToken]{}, err
+	}
+	return res.(models.PaginationResponse[models.ApiToken]), nil
+}
+
+func (d *direct) revokeApiToken(ctx context.Context, id string) error {
+	_, err := commands.NewRevokeApiTokenCommand(d.store, d.logger, id, now()).Execute()
+	return err
+}
+
+func (d *direct) listReservations(ctx context.Context, filter models.ReadAllReservations) (models.PaginationResponse[models.Reservation], error) {
+	res, err := queries.NewFilterReservationsQuery(d.store, d.logger, filter.IDs, filter.ReserveeID, filter.ReserverID, filter.SourceID, filter.ParticipantID, filter.Status, filter.ApproverID, filter.Pagination).Execute()
+	if err != nil {
+		return models.PaginationResponse[models.Reservation]{}, err
+	}
+	return res.(models.PaginationResponse[models.Reservation]), nil
+}
+
+func (d *direct) getReservation(ctx context.Context, id string) (models.Reservation, error) {
+	res, err := queries.NewReadReservationQuery(d.store, d.logger, id).Execute()
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return res.(models.Reservation), nil
+}
+
+func (d *direct) cancelReservation(ctx context.Context, id string, override *models.FeeOverride) (models.ReservationChange, error) {
+	q := commands.NewDeleteReservationCommand(d.store, d.logger, d.provider, id, override)
+	res, err := q.Execute()
+	if err != nil {
+		return models.ReservationChange{}, err
+	}
+	return models.ReservationChange{ID: res, Fee: q.Fee()}, nil
+}
+
+// remote calls the HTTP API of a running engine. Customers and api tokens
+// need a jwt, reservations the api token and secret of a source unless a fee
+// override makes the cancellation an admin one.
+type remote struct {
+	jwt    *client.Client
+	apiKey *client.Client
+}
+
+// errNoJwt and errNoApiKey tell which credentials a remote call is missing.
+var (
+	errNoJwt    = errors.New("the call needs --jwt, or a config whose secret can sign one with --as")
+	errNoApiKey = errors.New("the call needs --api-token and --api-secret")
+)
+
+func (r *remote) jwtClient() (*client.Client, error) {
+	if r.jwt == nil {
+		return nil, errNoJwt
+	}
+	return r.jwt, nil
+}
+
+func (r *remote) apiKeyClient() (*client.Client, error) {
+	if r.apiKey == nil {
+		return nil, errNoApiKey
+	}
+	return r.apiKey, nil
+}
+
+func (r *remote) createCustomer(ctx context.Context, request models.CreateCustomer) (string, error) {
+	c, err := r.jwtClient()
+	if err != nil {
+		return "", err
+	}
+	return c.CreateCustomer(ctx, request)
+}
+
+func (r *remote) listCustomers(ctx context.Context, filter models.ReadAllCustomers) (models.PaginationResponse[models.Customer], error) {
+	c, err := r.jwtClient()
+	if err != nil {
+		return models.PaginationResponse[models.Customer]{}, err
+	}
+	return c.ListCustomers(ctx, filter)
+}
+
+func (r *remote) getCustomer(ctx context.Context, id string) (models.Customer, error) {
+	c, err := r.jwtClient()
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return c.GetCustomer(ctx, id)
+}
+
+func (r *remote) deleteCustomer(ctx context.Context, id string) error {
+	c, err := r.jwtClient()
+	if err != nil {
+		return err
+	}
+	return c.DeleteCustomer(ctx, id)
+}
+
+func (r *remote) createSource(ctx context.Context, request models.CreateSource) (string, error) {
+	c, err := r.apiKeyClient()
+	if err != nil {
+		return "", err
+	}
+	return c.CreateSource(ctx, request)
+}
+
+func (r *remote) createApiToken(ctx context.Context, request models.CreateApiToken) (models.ApiToken, error) {
+	c, err := r.jwtClient()
+	if err != nil {
+		return models.ApiToken{}, err
+	}
+	return c.CreateApiToken(ctx, request)
+}
+
+func (r *remote) listApiTokens(ctx context.Context, filter models.ReadAllApiTokens) (models.PaginationResponse[models.ApiToken], error) {
+	c, err := r.jwtClient()
+	if err != nil {
+		return models.PaginationResponse[models.ApiToken]{}, err
+	}
+	return c.ListApiTokens(ctx, filter)
+}
+
+func (r *remote) revokeApiToken(ctx context.Context, id string) error {
+	c, err := r.jwtClient()
+	if err != nil {
+		return err
+	}
+	return c.RevokeApiToken(ctx, id)
+}
+
+func (r *remote) listReservations(ctx context.Context, filter models.ReadAllReservations) (models.PaginationResponse[models.Reservation], error) {
+	c, err := r.apiKeyClient()
+	if err != nil {
+		return models.PaginationResponse[models.Reservation]{}, err
+	}
+	return c.ListReservations(ctx, filter)
+}
+
+func (r *remote) getReservation(ctx context.Context, id string) (models.Reservation, error) {
+	c, err := r.apiKeyClient()
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return c.GetReservation(ctx, id)
+}
+
+func (r *remote) cancelReservation(ctx context.Context, id string, override *models.FeeOverride) (models.ReservationChange, error) {
+	if override != nil {
+		c, err := r.jwtClient()
+		if err != nil {
+			return models.ReservationChange{}, err
+		}
+		return c.AdminCancelReservation(ctx, id, *override)
+	}
+	c, err := r.apiKeyClient()
+	if err != nil {
+		return models.ReservationChange{}, err
+	}
+	return c.CancelReservation(ctx, id)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lghtr35/reservation-engine/client"
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/migrations"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/queries"
+)
+
+func (c *ctl) migrate(ctx context.Context, args []string) error {
+	d, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	m, err := migrations.New(d.db, d.logger)
+	if err != nil {
+		return err
+	}
+	return migrations.Command(ctx, m, models.Entities(), args, os.Stdout)
+}
+
+func (c *ctl) config(args []string) error {
+	configuration, err := c.loadConfiguration()
+	if err != nil {
+		return err
+	}
+	return models.ConfigurationCommand(*configuration, args, os.Stdout)
+}
+
+func (c *ctl) createCustomer(ctx context.Context, args []string) error {
+	var request models.CreateCustomer
+	flags := flag.NewFlagSet("customers create", flag.ExitOnError)
+	flags.StringVar(&request.Name, "name", "", "name of the customer")
+	flags.StringVar(&request.Company, "company", "", "company of the customer")
+	flags.StringVar(&request.Email, "email", "", "email of the customer")
+	flags.Parse(args)
+	if request.Name == "" || request.Company == "" || request.Email == "" {
+		return errors.New("customers create needs --name, --company and --email")
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	id, err := b.createCustomer(ctx, request)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]string{"id": id})
+}
+
+func (c *ctl) listCustomers(ctx context.Context, args []string) error {
+	var filter models.ReadAllCustomers
+	flags := flag.NewFlagSet("customers list", flag.ExitOnError)
+	name := flags.String("name", "", "part of the name of the customers")
+	page := pagination(flags)
+	flags.Parse(args)
+	filter.Name, filter.Pagination = optional(*name), *page
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.listCustomers(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) getCustomer(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("customers get", flag.ExitOnError)
+	flags.Parse(args)
+	id, err := argument(flags, "customer id")
+	if err != nil {
+		return err
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.getCustomer(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) deleteCustomer(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("customers delete", flag.ExitOnError)
+	flags.Parse(args)
+	id, err := argument(flags, "customer id")
+	if err != nil {
+		return err
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	err = b.deleteCustomer(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]string{"deleted": id})
+}
+
+// createSource creates a source of the customer and an api token for it, so
+// that a new customer can be set up from the ctl alone.
+func (c *ctl) createSource(ctx context.Context, args []string) error {
+	var request models.CreateSource
+	flags := flag.NewFlagSet("sources create", flag.ExitOnError)
+	flags.StringVar(&request.CustomerID, "customer", "", "id of the customer")
+	flags.StringVar(&request.Name, "name", "", "name of the source")
+	flags.StringVar(&request.MaxPossibleDuration, "max-duration", "", "longest possible reservation, e.g. 2h")
+	flags.BoolVar(&request.RequiresApproval, "requires-approval", false, "reservations wait for an approver")
+	approverId := flags.String("approver", "", "id of the person who approves reservations")
+	flags.StringVar(&request.ApprovalTimeout, "approval-timeout", "", "how long a reservation waits for approval, e.g. 24h")
+	policyId := flags.String("policy", "", "id of the cancellation policy")
+	flags.StringVar(&request.Pricing.Currency, "currency", "", "currency of the prices")
+	flags.StringVar(&request.Pricing.Timezone, "timezone", "", "timezone of the prices")
+	flags.IntVar(&request.Pricing.WeekendSurchargePercent, "weekend-surcharge", 0, "surcharge on weekends in percent")
+	flags.IntVar(&request.Pricing.Capacity, "capacity", 0, "reservations at the same time")
+	flags.BoolVar(&request.Pricing.RequiresPayment, "requires-payment", false, "reservations are paid for")
+	flags.Parse(args)
+	if request.CustomerID == "" || request.Name == "" || request.MaxPossibleDuration == "" {
+		return errors.New("sources create needs --customer, --name and --max-duration")
+	}
+	request.ApproverID, request.CancellationPolicyID = optional(*approverId), optional(*policyId)
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	id, err := b.createSource(ctx, request)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]string{"id": id})
+}
+
+// issueSecret adds a secret to the customer. The ones it had keep working,
+// so that clients can move to the new one before the old ones are deleted.
+func (c *ctl) issueSecret(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("secrets issue", flag.ExitOnError)
+	customerId := flags.String("customer", "", "id of the customer")
+	flags.Parse(args)
+	if *customerId == "" {
+		return errors.New("secrets issue needs --customer")
+	}
+	d, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	id, err := commands.NewCreateSecretCommand(d.store, d.logger, d.hasher, *customerId).Execute()
+	if err != nil {
+		return err
+	}
+	secret, err := d.store.Secrets().Get(id)
+	if err != nil {
+		return err
+	}
+	return printJSON(secret)
+}
+
+func (c *ctl) issueToken(ctx context.Context, args []string) error {
+	var request models.CreateApiToken
+	flags := flag.NewFlagSet("tokens issue", flag.ExitOnError)
+	flags.StringVar(&request.CustomerID, "customer", "", "id of the customer")
+	flags.StringVar(&request.SourceID, "source", "", "id of the source of the customer")
+	flags.Parse(args)
+	if request.CustomerID == "" || request.SourceID == "" {
+		return errors.New("tokens issue needs --customer and --source")
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.createApiToken(ctx, request)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) listTokens(ctx context.Context, args []string) error {
+	var filter models.ReadAllApiTokens
+	flags := flag.NewFlagSet("tokens list", flag.ExitOnError)
+	customerId := flags.String("customer", "", "id of the customer")
+	sourceId := flags.String("source", "", "id of the source")
+	flags.BoolVar(&filter.Valid, "valid", false, "leave out expired and revoked tokens")
+	page := pagination(flags)
+	flags.Parse(args)
+	filter.CustomerID, filter.SourceID, filter.Pagination = optional(*customerId), optional(*sourceId), *page
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.listApiTokens(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) revokeToken(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("tokens revoke", flag.ExitOnError)
+	flags.Parse(args)
+	id, err := argument(flags, "api token id")
+	if err != nil {
+		return err
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	err = b.revokeApiToken(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]string{"revoked": id})
+}
+
+func (c *ctl) listReservations(ctx context.Context, args []string) error {
+	var filter models.ReadAllReservations
+	flags := flag.NewFlagSet("reservations list", flag.ExitOnError)
+	sourceId := flags.String("source", "", "id of the source")
+	status := flags.String("status", "", "status of the reservations")
+	reserverId := flags.String("reserver", "", "id of the reserver")
+	reserveeId := flags.String("reservee", "", "id of the reservee")
+	page := pagination(flags)
+	flags.Parse(args)
+	filter.SourceID, filter.Status, filter.Pagination = optional(*sourceId), optional(*status), *page
+	filter.ReserverID, filter.ReserveeID = optional(*reserverId), optional(*reserveeId)
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.listReservations(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) getReservation(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reservations get", flag.ExitOnError)
+	flags.Parse(args)
+	id, err := argument(flags, "reservation id")
+	if err != nil {
+		return err
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.getReservation(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+// cancelReservation charges the fee of the cancellation policy of the
+// source, or with --reason the fee given by --fee-percent.
+func (c *ctl) cancelReservation(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reservations cancel", flag.ExitOnError)
+	reason := flags.String("reason", "", "why the fee of the policy is overridden")
+	feePercent := flags.Int("fee-percent", 0, "fee charged instead of the one of the policy, needs --reason")
+	by := flags.String("by", "reservationctl", "who overrides the fee, recorded with it")
+	flags.Parse(args)
+	id, err := argument(flags, "reservation id")
+	if err != nil {
+		return err
+	}
+	var override *models.FeeOverride
+	if *reason != "" {
+		override = &models.FeeOverride{FeePercent: *feePercent, Reason: *reason, By: *by}
+	}
+	b, err := c.openBackend()
+	if err != nil {
+		return err
+	}
+	res, err := b.cancelReservation(ctx, id, override)
+	if err != nil {
+		return err
+	}
+	return printJSON(res)
+}
+
+func (c *ctl) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	customerIds := flags.String("customers", "", "comma separated ids of the customers, every customer when empty")
+	out := flags.String("out", "", "file to write the export to, stdout when empty")
+	flags.Parse(args)
+	d, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	var ids []string
+	if *customerIds != "" {
+		ids = strings.Split(*customerIds, ",")
+	}
+	res, err := queries.NewExportQuery(d.db, d.logger, ids, now()).Execute()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return printJSON(res)
+	}
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The export holds secrets and api tokens.
+	return os.WriteFile(*out, data, 0600)
+}
+
+func (c *ctl) importData(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	in := flags.String("in", "", "file to read the export from, stdin when empty")
+	flags.Parse(args)
+	var data []byte
+	var err error
+	if *in == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		return err
+	}
+	var export models.Export
+	err = json.Unmarshal(data, &export)
+	if err != nil {
+		return err
+	}
+	d, err := c.openDirect()
+	if err != nil {
+		return err
+	}
+	q := commands.NewImportDataCommand(d.db, d.logger, export)
+	_, err = q.Execute()
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"imported": q.Imported()})
+}
+
+func (c *ctl) issueJwt(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("jwt issue", flag.ExitOnError)
+	customerId := flags.String("customer", "", "id of the customer the jwt is for")
+	ttl := flags.Duration("ttl", 0, "lifetime of the jwt (default the jwtLifetime of the configuration)")
+	flags.Parse(args)
+	if *customerId == "" {
+		return errors.New("jwt issue needs --customer")
+	}
+	configuration, err := c.loadConfiguration()
+	if err != nil {
+		return err
+	}
+	if *ttl == 0 {
+		*ttl = configuration.JwtLifetime.Duration()
+	}
+	token, err := client.SignJWT(configuration.Secret, *customerId, *ttl)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]string{"token": token})
+}
+
+// rotateJwt writes a new random secret into the configuration file and
+// keeps the replaced one as a previous secret, so that jwt signed with it
+// work until they expire. Engines pick it up when they are restarted.
+func (c *ctl) rotateJwt(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("jwt rotate", flag.ExitOnError)
+	keep := flags.Int("keep", 1, "number of previous secrets that stay valid")
+	flags.Parse(args)
+	if *keep < 0 {
+		return errors.New("jwt rotate needs a --keep of 0 or more")
+	}
+
+	path, _ := models.ConfigurationPath(c.configPath, os.LookupEnv)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// The file is edited as a map so that settings this binary does not know
+	// about are kept.
+	var configuration map[string]any
+	err = models.DecodeConfigurationFile(path, data, &configuration)
+	if err != nil {
+		return err
+	}
+
+	previous := []any{}
+	if current, ok := configuration["secret"].(string); ok && current != "" {
+		previous = append(previous, current)
+	}
+	if secrets, ok := configuration["previousSecrets"].([]any); ok {
+		previous = append(previous, secrets...)
+	}
+	if len(previous) > *keep {
+		previous = previous[:*keep]
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	configuration["secret"] = hex.EncodeToString(secret)
+	configuration["previousSecrets"] = previous
+
+	data, err = models.EncodeConfigurationFile(path, configuration)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, data, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"rotated": true, "previousSecrets": len(previous)})
+}
@@ -0,0 +1,285 @@
+// Command reservationctl operates a reservation engine: it migrates its
+// database, manages customers, secrets and api tokens, inspects and cancels
+// reservations, moves data between engines and rotates the jwt secret.
+//
+// It runs the commands and queries of the engine on the database named by
+// its configuration, or with --remote calls the HTTP API of a running
+// engine. Results are written to stdout as JSON.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/client"
+	"github.com/lghtr35/reservation-engine/database"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const usage = `usage: reservationctl [global flags] <command> [flags] [args]
+
+Commands:
+  migrate [up | down [n] | status | drift]  apply, revert or inspect migrations
+  config print                              print the configuration, secrets redacted
+  customers create --name --company --email create a customer and its secret
+  customers list [--name] [--page] [--size]
+  customers get <id>
+  customers delete <id>
+  secrets issue --customer <id>             issue another secret for a customer
+  sources create --customer <id> --name --max-duration [--capacity] [--currency] ...
+                                            create a source and an api token for it
+  tokens issue --customer <id> --source <id>
+  tokens list [--customer] [--source] [--valid]
+  tokens revoke <id>
+  reservations list [--source] [--status] [--reserver] [--reservee]
+  reservations get <id>
+  reservations cancel [--reason --fee-percent] <id>
+  export [--customers id,id] [--out file]   export customers and their data
+  import [--in file]                        import an export
+  jwt issue --customer <id> [--ttl]         sign a jwt with the configured secret
+  jwt rotate [--keep 1]                     replace the configured jwt secret
+
+Global flags:
+`
+
+// globals are the flags that come before the command.
+type globals struct {
+	configPath string
+	remote     string
+	jwt        string
+	as         string
+	apiToken   string
+	apiSecret  string
+	timeout    time.Duration
+	verbose    bool
+}
+
+func main() {
+	var g globals
+	flags := flag.NewFlagSet("reservationctl", flag.ExitOnError)
+	flags.StringVar(&g.configPath, "config", "", "configuration of the engine, JSON or YAML (default $RESERVATION_ENGINE_CONFIG or "+models.DefaultConfigurationPath+")")
+	flags.StringVar(&g.remote, "remote", "", "base URL of a running engine, the database is used when empty")
+	flags.StringVar(&g.jwt, "jwt", os.Getenv("RESERVATIONCTL_JWT"), "jwt for the customer and api token calls of --remote")
+	flags.StringVar(&g.as, "as", "", "customer id to sign a jwt for with the configured secret, instead of --jwt")
+	flags.StringVar(&g.apiToken, "api-token", os.Getenv("RESERVATIONCTL_API_TOKEN"), "api token for the reservation calls of --remote")
+	flags.StringVar(&g.apiSecret, "api-secret", os.Getenv("RESERVATIONCTL_API_SECRET"), "api secret for the reservation calls of --remote")
+	flags.DurationVar(&g.timeout, "timeout", time.Minute, "time limit of the command")
+	flags.BoolVar(&g.verbose, "verbose", false, "log what the engine does")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	err := newCtl(g).run(ctx, flags.Args())
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reservationctl:", err)
+		os.Exit(1)
+	}
+}
+
+// ctl opens the configuration, the database and the clients the command
+// needs when it first needs them.
+type ctl struct {
+	globals
+	logger        zerolog.Logger
+	configuration *models.Configuration
+	direct        *direct
+}
+
+func newCtl(g globals) *ctl {
+	level := zerolog.WarnLevel
+	if g.verbose {
+		level = zerolog.DebugLevel
+	}
+	return &ctl{globals: g, logger: zerolog.New(os.Stderr).Level(level)}
+}
+
+// loadConfiguration reads the configuration of the engine from its file and
+// the environment, the flags of the engine are not read.
+func (c *ctl) loadConfiguration() (*models.Configuration, error) {
+	if c.configuration == nil {
+		path, required := models.ConfigurationPath(c.configPath, os.LookupEnv)
+		configuration, err := models.ReadConfiguration(path, required, os.LookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		c.configuration = &configuration
+	}
+	return c.configuration, nil
+}
+
+// openDirect connects to the database of the engine, commands that only work
+// there fail with --remote.
+func (c *ctl) openDirect() (*direct, error) {
+	if c.remote != "" {
+		return nil, errDirectOnly
+	}
+	if c.direct != nil {
+		return c.direct, nil
+	}
+	configuration, err := c.loadConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	err = configuration.Validate()
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := util.NewHasher(configuration)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := payments.NewProvider(configuration.PaymentProvider, configuration.PaymentWebhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.Open(configuration)
+	if err != nil {
+		return nil, err
+	}
+	if !c.verbose {
+		// Lookups that find nothing are answers here, not errors to log
+		db = db.Session(&gorm.Session{Logger: gormlogger.Discard})
+	}
+	c.direct = &direct{db: db, store: gormstore.New(db), logger: &c.logger, hasher: hasher, provider: provider, apiTokenLifetime: configuration.ApiTokenLifetime.Duration()}
+	return c.direct, nil
+}
+
+func (c *ctl) openBackend() (backend, error) {
+	if c.remote == "" {
+		return c.openDirect()
+	}
+
+	r := &remote{}
+	token := c.jwt
+	if token == "" && c.as != "" {
+		configuration, err := c.loadConfiguration()
+		if err != nil {
+			return nil, err
+		}
+		token, err = client.SignJWT(configuration.Secret, c.as, configuration.JwtLifetime.Duration())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		r.jwt = client.New(c.remote, client.JWT(token))
+	}
+	if c.apiToken != "" && c.apiSecret != "" {
+		r.apiKey = client.New(c.remote, client.APIKey(c.apiToken, c.apiSecret))
+	}
+	return r, nil
+}
+
+func (c *ctl) run(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return c.migrate(ctx, args[1:])
+	case "config":
+		return c.config(args[1:])
+	case "customers":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"create": c.createCustomer, "list": c.listCustomers, "get": c.getCustomer, "delete": c.deleteCustomer,
+		})
+	case "sources":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"create": c.createSource,
+		})
+	case "secrets":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"issue": c.issueSecret,
+		})
+	case "tokens":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"issue": c.issueToken, "list": c.listTokens, "revoke": c.revokeToken,
+		})
+	case "reservations":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"list": c.listReservations, "get": c.getReservation, "cancel": c.cancelReservation,
+		})
+	case "export":
+		return c.export(args[1:])
+	case "import":
+		return c.importData(args[1:])
+	case "jwt":
+		return c.subcommand(ctx, args, map[string]func(context.Context, []string) error{
+			"issue": c.issueJwt, "rotate": c.rotateJwt,
+		})
+	}
+	return fmt.Errorf("unknown command %q, see reservationctl -h", args[0])
+}
+
+func (c *ctl) subcommand(ctx context.Context, args []string, subcommands map[string]func(context.Context, []string) error) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%s needs a subcommand, see reservationctl -h", args[0])
+	}
+	run, ok := subcommands[args[1]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see reservationctl -h", args[0]+" "+args[1])
+	}
+	return run(ctx, args[2:])
+}
+
+// argument returns the only positional argument of the command.
+func argument(flags *flag.FlagSet, name string) (string, error) {
+	if flags.NArg() != 1 {
+		return "", fmt.Errorf("%s needs the %s as its only argument, flags come before it", flags.Name(), name)
+	}
+	return flags.Arg(0), nil
+}
+
+// optional is nil for an empty flag, which filters leave out.
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func pagination(flags *flag.FlagSet) *models.Pagination {
+	p := &models.Pagination{}
+	flags.Func("page", "page to read, from 1 (default 1)", parseUint32(&p.Page))
+	flags.Func("size", "size of a page (default 50)", parseUint32(&p.Size))
+	p.Page, p.Size = 1, 50
+	return p
+}
+
+func parseUint32(target *uint32) func(string) error {
+	return func(value string) error {
+		var parsed uint32
+		_, err := fmt.Sscan(value, &parsed)
+		if err != nil || parsed == 0 {
+			return errors.New("must be a positive number")
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+func now() time.Time {
+	return time.Now()
+}
+
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
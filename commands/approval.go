@@ -0,0 +1,321 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// findPendingApproval loads a reservation waiting for a decision and checks
+// that the given person is the approver assigned to it.
+func findPendingApproval(tx *gorm.DB, caller, id, approverRef string) (models.Reservation, error) {
+	var reservation models.Reservation
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return reservation, fmt.Errorf("%s: Could not find the reservation with this id: %s", caller, id)
+		}
+		return reservation, res.Error
+	}
+
+	if reservation.Status != models.ReservationStatusPendingApproval {
+		return reservation, fmt.Errorf("%s: Reservation %s is %s and not waiting for approval", caller, id, reservation.Status)
+	}
+	if reservation.ApproverID == nil {
+		return reservation, fmt.Errorf("%s: Reservation %s has no approver assigned", caller, id)
+	}
+
+	var source models.Source
+	res = tx.First(&source, "id = ?", reservation.SourceID)
+	if res.Error != nil {
+		return reservation, res.Error
+	}
+	approver, err := resolvePerson(gormstore.New(tx), caller, source.CustomerID, approverRef)
+	if err != nil {
+		return reservation, err
+	}
+	if approver.ID != *reservation.ApproverID {
+		return reservation, fmt.Errorf("%s: Person %s is not the approver of reservation %s", caller, approver.ID, id)
+	}
+
+	return reservation, nil
+}
+
+// releaseBundle moves every other member of the bundle that still holds its
+// slot into the given status, since a bundle is only useful as a whole.
+func releaseBundle(tx *gorm.DB, reservation models.Reservation, status, comment string, decidedAt time.Time) ([]models.Reservation, error) {
+	if reservation.BundleID == nil {
+		return nil, nil
+	}
+
+	var members []models.Reservation
+	res := tx.Where("bundle_id = ? AND id != ? AND status NOT IN ?", *reservation.BundleID, reservation.ID, models.ReleasedReservationStatuses).Find(&members)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	for i := range members {
+		members[i].Status = status
+		members[i].DecisionComment = comment
+		members[i].DecidedAt = &decidedAt
+		members[i].Sequence++
+		res = tx.Save(&members[i])
+		if res.Error != nil {
+			return nil, res.Error
+		}
+	}
+	return members, nil
+}
+
+type ApproveReservationCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	id         string
+	approverId string
+	comment    string
+}
+
+func NewApproveReservationCommand(db *gorm.DB, logger *zerolog.Logger, id, approverId, comment string) *ApproveReservationCommand {
+	return &ApproveReservationCommand{db: db, logger: logger, id: id, approverId: approverId, comment: comment}
+}
+
+func (s *ApproveReservationCommand) Execute() (string, error) {
+	if s.id == "" || s.approverId == "" {
+		return "", errors.New("ApproveReservationCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("ApproveReservationCommand: Started")
+
+	var reservation models.Reservation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservation, err = findPendingApproval(tx, "ApproveReservationCommand", s.id, s.approverId)
+		if err != nil {
+			return err
+		}
+
+		decidedAt := time.Now()
+		reservation.Status = models.ReservationStatusConfirmed
+		reservation.DecisionComment = s.comment
+		reservation.DecidedAt = &decidedAt
+		reservation.Sequence++
+		res := tx.Save(&reservation)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.ReservationApproved, sourceCustomerId(gormstore.New(tx), reservation.SourceID), reservation.ID, reservation)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("ApproveReservationCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type RejectReservationCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	provider   payments.PaymentProvider
+	id         string
+	approverId string
+	comment    string
+}
+
+func NewRejectReservationCommand(db *gorm.DB, logger *zerolog.Logger, provider payments.PaymentProvider, id, approverId, comment string) *RejectReservationCommand {
+	return &RejectReservationCommand{db: db, logger: logger, provider: provider, id: id, approverId: approverId, comment: comment}
+}
+
+func (s *RejectReservationCommand) Execute() (string, error) {
+	if s.id == "" || s.approverId == "" {
+		return "", errors.New("RejectReservationCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("RejectReservationCommand: Started")
+
+	var rejected []models.Reservation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		reservation, err := findPendingApproval(tx, "RejectReservationCommand", s.id, s.approverId)
+		if err != nil {
+			return err
+		}
+
+		decidedAt := time.Now()
+		reservation.Status = models.ReservationStatusRejected
+		reservation.DecisionComment = s.comment
+		reservation.DecidedAt = &decidedAt
+		reservation.Sequence++
+		res := tx.Save(&reservation)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		members, err := releaseBundle(tx, reservation, models.ReservationStatusRejected, s.comment, decidedAt)
+		if err != nil {
+			return err
+		}
+		rejected = append([]models.Reservation{reservation}, members...)
+		for _, released := range rejected {
+			err = record(tx, events.ReservationRejected, sourceCustomerId(gormstore.New(tx), released.SourceID), released.ID, released)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, reservation := range rejected {
+		err = refundReservation(gormstore.New(s.db), s.provider, "RejectReservationCommand", reservation)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("RejectReservationCommand: Could not refund a rejected reservation")
+		}
+	}
+
+	s.logger.Debug().Msg("RejectReservationCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type AssignApproverCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	id         string
+	approverId string
+}
+
+func NewAssignApproverCommand(db *gorm.DB, logger *zerolog.Logger, id, approverId string) *AssignApproverCommand {
+	return &AssignApproverCommand{db: db, logger: logger, id: id, approverId: approverId}
+}
+
+func (s *AssignApproverCommand) Execute() (string, error) {
+	if s.id == "" || s.approverId == "" {
+		return "", errors.New("AssignApproverCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("AssignApproverCommand: Started")
+
+	var reservation models.Reservation
+	res := s.db.First(&reservation, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("AssignApproverCommand: Could not find the reservation with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	if reservation.Status != models.ReservationStatusPendingApproval {
+		return "", fmt.Errorf("AssignApproverCommand: Reservation %s is %s and not waiting for approval", s.id, reservation.Status)
+	}
+
+	var source models.Source
+	res = s.db.First(&source, "id = ?", reservation.SourceID)
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	approver, err := resolvePerson(gormstore.New(s.db), "AssignApproverCommand", source.CustomerID, s.approverId)
+	if err != nil {
+		return "", err
+	}
+
+	reservation.ApproverID = &approver.ID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Save(&reservation)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.ReservationApproverChanged, source.CustomerID, reservation.ID, reservation)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("AssignApproverCommand: Finished with success")
+
+	return s.id, nil
+}
+
+// ExpireApprovalsCommand releases every reservation whose approval request was
+// not answered in time. It returns the number of expired reservations.
+type ExpireApprovalsCommand struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	provider payments.PaymentProvider
+	now      time.Time
+}
+
+func NewExpireApprovalsCommand(db *gorm.DB, logger *zerolog.Logger, provider payments.PaymentProvider, now time.Time) *ExpireApprovalsCommand {
+	return &ExpireApprovalsCommand{db: db, logger: logger, provider: provider, now: now}
+}
+
+func (s *ExpireApprovalsCommand) Execute() (string, error) {
+	s.logger.Debug().Msg("ExpireApprovalsCommand: Started")
+
+	var expired []models.Reservation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var pending []models.Reservation
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND approval_expires_at < ?", models.ReservationStatusPendingApproval, s.now).
+			Find(&pending)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// Expiring a bundle member releases the rest of its bundle, whose
+		// rows later in pending are then no longer pending
+		released := map[string]bool{}
+		for _, reservation := range pending {
+			if released[reservation.ID] {
+				continue
+			}
+			reservation.Status = models.ReservationStatusExpired
+			reservation.DecisionComment = "Approval request expired"
+			reservation.DecidedAt = &s.now
+			reservation.Sequence++
+			res = tx.Save(&reservation)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			members, err := releaseBundle(tx, reservation, models.ReservationStatusExpired, reservation.DecisionComment, s.now)
+			if err != nil {
+				return err
+			}
+			for _, member := range append([]models.Reservation{reservation}, members...) {
+				err = record(tx, events.ReservationApprovalExpired, sourceCustomerId(gormstore.New(tx), member.SourceID), member.ID, member)
+				if err != nil {
+					return err
+				}
+				expired = append(expired, member)
+				released[member.ID] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, reservation := range expired {
+		err = refundReservation(gormstore.New(s.db), s.provider, "ExpireApprovalsCommand", reservation)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("ExpireApprovalsCommand: Could not refund an expired reservation")
+		}
+	}
+
+	s.logger.Debug().Msg("ExpireApprovalsCommand: Finished with success")
+
+	return fmt.Sprint(len(expired)), nil
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// addPending adds reservations of the source that wait for the approver until
+// expiresAt, in one bundle when there are several.
+func addPending(t *testing.T, f *repotest.Fixture, source models.Source, approver models.Person, expiresAt time.Time, hours ...int) []models.Reservation {
+	t.Helper()
+	var bundleId *string
+	if len(hours) > 1 {
+		bundle := models.Bundle{}
+		f.Add(t, &bundle)
+		bundleId = &bundle.ID
+	}
+	reservations := make([]models.Reservation, len(hours))
+	for i, hour := range hours {
+		reservations[i] = models.Reservation{
+			From: repotest.At(hour), To: repotest.At(hour + 1), ReserverID: approver.ID, ReserveeID: approver.ID,
+			SourceID: source.ID, BundleID: bundleId, Status: models.ReservationStatusPendingApproval,
+			ApproverID: &approver.ID, ApprovalExpiresAt: &expiresAt,
+		}
+		f.Add(t, &reservations[i])
+	}
+	return reservations
+}
+
+// statusOf reads the status and sequence of the stored reservation.
+func statusOf(t *testing.T, f *repotest.Fixture, id string) (string, int) {
+	t.Helper()
+	reservation, err := f.Reservations().Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reservation.Status, reservation.Sequence
+}
+
+func TestApprovalCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		approver := f.Person(t, customer, "approver")
+		f.Person(t, customer, "bob")
+		expiresAt := time.Now().Add(time.Hour)
+		single := addPending(t, f, source, approver, expiresAt, 1)[0]
+		bundle := addPending(t, f, source, approver, expiresAt, 3, 5)
+
+		if _, err := NewApproveReservationCommand(f.DB, f.Logger, single.ID, "bob", "").Execute(); err == nil {
+			t.Error("approved by somebody who is not the approver")
+		}
+		if _, err := NewApproveReservationCommand(f.DB, f.Logger, single.ID, "approver", "fine").Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if status, sequence := statusOf(t, f, single.ID); status != models.ReservationStatusConfirmed || sequence != 1 {
+			t.Errorf("approved into %s with sequence %d", status, sequence)
+		}
+		if _, err := NewApproveReservationCommand(f.DB, f.Logger, single.ID, "approver", "").Execute(); err == nil {
+			t.Error("approved a reservation that is not waiting for approval")
+		}
+		if _, err := NewAssignApproverCommand(f.DB, f.Logger, single.ID, "bob").Execute(); err == nil {
+			t.Error("assigned an approver to a reservation that is not waiting for approval")
+		}
+
+		if _, err := NewAssignApproverCommand(f.DB, f.Logger, bundle[0].ID, "bob").Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewRejectReservationCommand(f.DB, f.Logger, nil, bundle[0].ID, "approver", "").Execute(); err == nil {
+			t.Error("rejected by the approver that was replaced")
+		}
+		if _, err := NewRejectReservationCommand(f.DB, f.Logger, nil, bundle[0].ID, "bob", "busy").Execute(); err != nil {
+			t.Fatal(err)
+		}
+		for _, reservation := range bundle {
+			if status, sequence := statusOf(t, f, reservation.ID); status != models.ReservationStatusRejected || sequence != 1 {
+				t.Errorf("rejected into %s with sequence %d", status, sequence)
+			}
+		}
+
+		want := []string{events.ReservationApproved, events.ReservationApproverChanged, events.ReservationRejected, events.ReservationRejected}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
+
+func TestExpireApprovalsCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		approver := f.Person(t, customer, "approver")
+
+		now := time.Now().UTC()
+		bundle := addPending(t, f, source, approver, now.Add(-time.Minute), 1, 3)
+		waiting := addPending(t, f, source, approver, now.Add(time.Hour), 5)
+
+		count, err := NewExpireApprovalsCommand(f.DB, f.Logger, nil, now).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != "2" {
+			t.Errorf("expired %s reservations, want 2", count)
+		}
+		for _, reservation := range bundle {
+			if status, sequence := statusOf(t, f, reservation.ID); status != models.ReservationStatusExpired || sequence != 1 {
+				t.Errorf("stored %s with sequence %d", status, sequence)
+			}
+		}
+		if status, _ := statusOf(t, f, waiting[0].ID); status != models.ReservationStatusPendingApproval {
+			t.Errorf("expired a reservation whose request has not expired: %s", status)
+		}
+
+		want := []string{events.ReservationApprovalExpired, events.ReservationApprovalExpired}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
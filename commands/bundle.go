@@ -0,0 +1,312 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+)
+
+type CreateBundleCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	from       time.Time
+	to         time.Time
+	reserverId string
+	reserveeId string
+	sourceIds  []string
+}
+
+func NewCreateBundleCommand(store repository.Store, logger *zerolog.Logger, from, to time.Time, reserverId, reserveeId string, sourceIds []string) *CreateBundleCommand {
+	return &CreateBundleCommand{store: store, logger: logger, from: from, to: to, reserverId: reserverId, reserveeId: reserveeId, sourceIds: sourceIds}
+}
+
+func (s *CreateBundleCommand) Execute() (string, error) {
+	if s.reserveeId == "" || s.reserverId == "" || len(s.sourceIds) == 0 {
+		return "", errors.New("CreateBundleCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateBundleCommand: Started")
+
+	seen := make(map[string]bool, len(s.sourceIds))
+	for _, sourceId := range s.sourceIds {
+		if seen[sourceId] {
+			return "", fmt.Errorf("CreateBundleCommand: Source %s is given more than once", sourceId)
+		}
+		seen[sourceId] = true
+	}
+
+	bundle := models.Bundle{}
+	err := s.store.Transaction(func(tx repository.Store) error {
+		sources := make([]models.Source, 0, len(s.sourceIds))
+		for _, sourceId := range s.sourceIds {
+			source, err := tx.Sources().GetForUpdate(sourceId)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("CreateBundleCommand: Could not find the source with this id: %s", sourceId)
+				}
+				return err
+			}
+			if len(sources) > 0 && sources[0].CustomerID != source.CustomerID {
+				return fmt.Errorf("CreateBundleCommand: Source %s belongs to another customer than the rest of the bundle", sourceId)
+			}
+			if source.RequiresPayment {
+				return fmt.Errorf("CreateBundleCommand: Source %s requires payment, which bundles do not support", sourceId)
+			}
+			sources = append(sources, source)
+		}
+
+		err := requireFeature(tx, "CreateBundleCommand", sources[0].CustomerID, models.FeatureBundles)
+		if err != nil {
+			return err
+		}
+		err = useReservationQuota(tx, "CreateBundleCommand", sources[0].CustomerID, int64(len(sources)))
+		if err != nil {
+			return err
+		}
+
+		reserver, err := resolvePerson(tx, "CreateBundleCommand", sources[0].CustomerID, s.reserverId)
+		if err != nil {
+			return err
+		}
+		reservee, err := resolvePerson(tx, "CreateBundleCommand", sources[0].CustomerID, s.reserveeId)
+		if err != nil {
+			return err
+		}
+
+		// Every source is checked before anything is inserted so the bundle
+		// members do not collide with each other on the reservee/reserver.
+		for _, source := range sources {
+			err = checkReservationPossible(tx, "CreateBundleCommand", source, s.from, s.to, []string{reserver.ID, reservee.ID}, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Bundles().Create(&bundle)
+		if err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			reservation := models.Reservation{
+				From:       s.from,
+				To:         s.to,
+				SourceID:   source.ID,
+				ReserverID: reserver.ID,
+				ReserveeID: reservee.ID,
+				BundleID:   &bundle.ID,
+			}
+			err = applyApprovalRules(source, &reservation)
+			if err != nil {
+				return err
+			}
+			err = priceReservation(tx, "CreateBundleCommand", source, &reservation)
+			if err != nil {
+				return err
+			}
+			err = tx.Reservations().Create(&reservation)
+			if err != nil {
+				return err
+			}
+			bundle.Reservations = append(bundle.Reservations, reservation)
+		}
+
+		return recordBundle(tx, events.BundleCreated, events.ReservationCreated, sources[0].CustomerID, bundle)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateBundleCommand: Finished with success")
+
+	return bundle.ID, nil
+}
+
+// recordBundle records the event of every member of the bundle, followed by
+// the event of the bundle itself.
+func recordBundle(tx repository.Store, bundleEvent, memberEvent, customerId string, bundle models.Bundle) error {
+	for _, reservation := range bundle.Reservations {
+		err := tx.Record(events.NewEvent(memberEvent, customerId, reservation.ID, reservation))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Record(events.NewEvent(bundleEvent, customerId, bundle.ID, bundle))
+}
+
+type UpdateBundleCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	id     string
+	from   *time.Time
+	to     *time.Time
+}
+
+func NewUpdateBundleCommand(store repository.Store, logger *zerolog.Logger, id string, from, to *time.Time) *UpdateBundleCommand {
+	return &UpdateBundleCommand{store: store, logger: logger, id: id, from: from, to: to}
+}
+
+func (s *UpdateBundleCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdateBundleCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdateBundleCommand: Started")
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		bundle, err := tx.Bundles().Load(s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("UpdateBundleCommand: Could not find the bundle with this id: %s", s.id)
+			}
+			return err
+		}
+
+		memberIds := make([]string, 0, len(bundle.Reservations))
+		for _, reservation := range bundle.Reservations {
+			memberIds = append(memberIds, reservation.ID)
+		}
+
+		// Every source is locked before the overlap checks lock the persons,
+		// which is the order all bookings lock them in.
+		sources := make([]models.Source, 0, len(bundle.Reservations))
+		for _, reservation := range bundle.Reservations {
+			if reservation.IsReleased() {
+				return fmt.Errorf("UpdateBundleCommand: Reservation %s of the bundle is %s and can not be changed", reservation.ID, reservation.Status)
+			}
+
+			source, err := tx.Sources().GetForUpdate(reservation.SourceID)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("UpdateBundleCommand: Could not find the source with this id: %s", reservation.SourceID)
+				}
+				return err
+			}
+			sources = append(sources, source)
+		}
+
+		var customerId string
+		for i, reservation := range bundle.Reservations {
+			source := sources[i]
+			customerId = source.CustomerID
+
+			original := reservation
+			if s.from != nil {
+				reservation.From = *s.from
+			}
+			if s.to != nil {
+				reservation.To = *s.to
+			}
+
+			personIds, err := busyPersonIds(tx, reservation)
+			if err != nil {
+				return err
+			}
+
+			err = checkReservationPossible(tx, "UpdateBundleCommand", source, reservation.From, reservation.To, personIds, memberIds)
+			if err != nil {
+				return err
+			}
+
+			err = priceReservation(tx, "UpdateBundleCommand", source, &reservation)
+			if err != nil {
+				return err
+			}
+
+			if !original.From.Equal(reservation.From) || !original.To.Equal(reservation.To) {
+				_, err = chargeFee(tx, "UpdateBundleCommand", source, original, models.FeeKindModification, time.Now(), nil)
+				if err != nil {
+					return err
+				}
+				reservation.Sequence++
+			}
+
+			err = tx.Reservations().Save(&reservation)
+			if err != nil {
+				return err
+			}
+			bundle.Reservations[i] = reservation
+		}
+
+		return recordBundle(tx, events.BundleUpdated, events.ReservationUpdated, customerId, bundle)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdateBundleCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type DeleteBundleCommand struct {
+	store    repository.Store
+	logger   *zerolog.Logger
+	provider payments.PaymentProvider
+	id       string
+	fees     []models.ReservationFee
+}
+
+func NewDeleteBundleCommand(store repository.Store, logger *zerolog.Logger, provider payments.PaymentProvider, id string) *DeleteBundleCommand {
+	return &DeleteBundleCommand{store: store, logger: logger, provider: provider, id: id}
+}
+
+// Fees returns the fees charged by Execute for the cancelled members.
+func (s *DeleteBundleCommand) Fees() []models.ReservationFee {
+	return s.fees
+}
+
+// Execute cancels every member of the bundle that still holds its slot.
+func (s *DeleteBundleCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeleteBundleCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteBundleCommand: Started")
+
+	var members []models.Reservation
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		members, err = tx.Bundles().MembersForUpdate(s.id)
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("DeleteBundleCommand: Could not find an active bundle with this id: %s", s.id)
+		}
+
+		now := time.Now()
+		for i := range members {
+			fee, err := cancelReservation(tx, "DeleteBundleCommand", &members[i], now, nil)
+			if err != nil {
+				return err
+			}
+			if fee != nil {
+				s.fees = append(s.fees, *fee)
+			}
+		}
+
+		bundle := models.Bundle{Base: models.Base{ID: s.id}, Reservations: members}
+		return recordBundle(tx, events.BundleCancelled, events.ReservationCancelled, sourceCustomerId(tx, members[0].SourceID), bundle)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, member := range members {
+		err = refundReservation(s.store, s.provider, "DeleteBundleCommand", member)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.logger.Debug().Msg("DeleteBundleCommand: Finished with success")
+
+	return s.id, nil
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestBundleCommands(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		createBundle := func(from, to int, sourceIds ...string) (string, error) {
+			return NewCreateBundleCommand(f, f.Logger, repotest.At(from), repotest.At(to), "alice", "bob", sourceIds).Execute()
+		}
+
+		if _, err := createBundle(9, 10, b.room.ID, b.room.ID); err == nil {
+			t.Error("created a bundle with a source given twice")
+		}
+		other := f.Customer(t, "other", &models.Plan{Name: "basic", MaxSources: 5})
+		f.Person(t, other, "alice")
+		if _, err := createBundle(9, 10, b.room.ID, f.Source(t, other, "room").ID); err == nil {
+			t.Error("created a bundle of sources of two customers")
+		}
+		if _, err := NewCreateBundleCommand(f, f.Logger, repotest.At(9), repotest.At(10), "alice", "alice", []string{f.Source(t, other, "hall").ID}).Execute(); err == nil {
+			t.Error("created a bundle for a customer whose plan does not include bundles")
+		}
+
+		id, err := createBundle(9, 10, b.room.ID, b.hall.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = createBundle(9, 10, b.hall.ID); err == nil {
+			t.Error("created a bundle that overlaps another one")
+		}
+		bundle, err := f.Bundles().Load(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members := bundle.Reservations
+		if len(members) != 2 || members[0].ReserverID != b.alice.ID || members[1].ReserveeID != b.bob.ID {
+			t.Fatalf("stored %+v", members)
+		}
+
+		// Members are only cancelled with their bundle, which can then still
+		// be rescheduled.
+		if _, err = NewDeleteReservationCommand(f, f.Logger, nil, members[0].ID, nil).Execute(); err == nil {
+			t.Error("cancelled a single member of a bundle")
+		}
+		from, to := repotest.At(11), repotest.At(12)
+		if _, err = NewUpdateBundleCommand(f, f.Logger, id, &from, &to).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range members {
+			moved := b.reservation(t, member.ID)
+			if !moved.From.Equal(from) || !moved.To.Equal(to) || moved.Sequence != 1 {
+				t.Errorf("moved to %v - %v with sequence %d", moved.From, moved.To, moved.Sequence)
+			}
+		}
+
+		if _, err = NewDeleteBundleCommand(f, f.Logger, nil, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range members {
+			if status := b.reservation(t, member.ID).Status; status != models.ReservationStatusCancelled {
+				t.Errorf("cancelled into %s", status)
+			}
+		}
+		if _, err = NewDeleteBundleCommand(f, f.Logger, nil, id).Execute(); err == nil {
+			t.Error("cancelled a bundle twice")
+		}
+		if _, err = NewUpdateBundleCommand(f, f.Logger, id, &from, &to).Execute(); err == nil {
+			t.Error("moved a cancelled bundle")
+		}
+
+		want := []string{
+			events.ReservationCreated, events.ReservationCreated, events.BundleCreated,
+			events.ReservationUpdated, events.ReservationUpdated, events.BundleUpdated,
+			events.ReservationCancelled, events.ReservationCancelled, events.BundleCancelled,
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type CreateCalendarFeedCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	sourceId   *string
+	personId   *string
+	feed       models.CalendarFeed
+}
+
+func NewCreateCalendarFeedCommand(db *gorm.DB, logger *zerolog.Logger, customerId string, sourceId, personId *string) *CreateCalendarFeedCommand {
+	return &CreateCalendarFeedCommand{db: db, logger: logger, customerId: customerId, sourceId: sourceId, personId: personId}
+}
+
+// Feed returns the created feed with its token.
+func (s *CreateCalendarFeedCommand) Feed() models.CalendarFeed {
+	return s.feed
+}
+
+func (s *CreateCalendarFeedCommand) Execute() (string, error) {
+	hasSource := s.sourceId != nil && *s.sourceId != ""
+	hasPerson := s.personId != nil && *s.personId != ""
+	if s.customerId == "" || hasSource == hasPerson {
+		return "", errors.New("CreateCalendarFeedCommand: Either a source or a person has to be given")
+	}
+	s.logger.Debug().Msg("CreateCalendarFeedCommand: Started")
+
+	s.feed = models.CalendarFeed{CustomerID: s.customerId, Token: util.GetRandString(48)}
+	if hasSource {
+		var source models.Source
+		res := s.db.First(&source, "id = ? AND customer_id = ?", *s.sourceId, s.customerId)
+		if res.Error != nil {
+			if res.Error == gorm.ErrRecordNotFound {
+				return "", fmt.Errorf("CreateCalendarFeedCommand: Could not find the source with this id: %s", *s.sourceId)
+			}
+			return "", res.Error
+		}
+		s.feed.Kind = models.CalendarFeedKindSource
+		s.feed.SourceID = &source.ID
+	} else {
+		person, err := resolvePerson(gormstore.New(s.db), "CreateCalendarFeedCommand", s.customerId, *s.personId)
+		if err != nil {
+			return "", err
+		}
+		s.feed.Kind = models.CalendarFeedKindReservee
+		s.feed.PersonID = &person.ID
+	}
+
+	res := s.db.Create(&s.feed)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	s.feed.Path = models.CalendarFeedPath(s.feed.Token)
+
+	s.logger.Debug().Msg("CreateCalendarFeedCommand: Finished with success")
+
+	return s.feed.ID, nil
+}
+
+type DeleteCalendarFeedCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	id         string
+}
+
+func NewDeleteCalendarFeedCommand(db *gorm.DB, logger *zerolog.Logger, customerId, id string) *DeleteCalendarFeedCommand {
+	return &DeleteCalendarFeedCommand{db: db, logger: logger, customerId: customerId, id: id}
+}
+
+func (s *DeleteCalendarFeedCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("DeleteCalendarFeedCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteCalendarFeedCommand: Started")
+
+	res := s.db.Delete(&models.CalendarFeed{}, "id = ? AND customer_id = ?", s.id, s.customerId)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", fmt.Errorf("DeleteCalendarFeedCommand: Could not find the calendar feed with this id: %s", s.id)
+	}
+
+	s.logger.Debug().Msg("DeleteCalendarFeedCommand: Finished with success")
+
+	return s.id, nil
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestCalendarFeedCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		f.Person(t, customer, "bob")
+		other := f.Customer(t, "other", nil)
+		hall := f.Source(t, other, "hall")
+		bob, missing := "bob", models.NewUUID()
+
+		if _, err := NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, nil, nil).Execute(); err == nil {
+			t.Error("created a feed of neither a source nor a person")
+		}
+		if _, err := NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, &room.ID, &bob).Execute(); err == nil {
+			t.Error("created a feed of both a source and a person")
+		}
+		if _, err := NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, &hall.ID, nil).Execute(); err == nil {
+			t.Error("created a feed of the source of another customer")
+		}
+		if _, err := NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, nil, &missing).Execute(); err == nil {
+			t.Error("created a feed of a person that does not exist")
+		}
+
+		create := NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, &room.ID, nil)
+		sourceFeedId, err := create.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feed := create.Feed(); feed.Kind != models.CalendarFeedKindSource || *feed.SourceID != room.ID || len(feed.Token) != 48 || feed.Path != models.CalendarFeedPath(feed.Token) {
+			t.Errorf("created %+v", feed)
+		}
+		create = NewCreateCalendarFeedCommand(f.DB, f.Logger, customer.ID, nil, &bob)
+		if _, err = create.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if feed := create.Feed(); feed.Kind != models.CalendarFeedKindReservee || feed.PersonID == nil {
+			t.Errorf("created %+v", feed)
+		}
+
+		if _, err = NewDeleteCalendarFeedCommand(f.DB, f.Logger, other.ID, sourceFeedId).Execute(); err == nil {
+			t.Error("deleted the feed of another customer")
+		}
+		if _, err = NewDeleteCalendarFeedCommand(f.DB, f.Logger, customer.ID, sourceFeedId).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteCalendarFeedCommand(f.DB, f.Logger, customer.ID, sourceFeedId).Execute(); err == nil {
+			t.Error("deleted a feed twice")
+		}
+	})
+}
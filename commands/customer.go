@@ -8,21 +8,22 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/lghtr35/reservation-engine/events"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
 )
 
 type CreateCustomerCommand struct {
-	db      *gorm.DB
+	store   repository.Store
 	logger  *zerolog.Logger
 	name    string
 	company string
 	email   string
 }
 
-func NewCreateCustomerCommand(db *gorm.DB, logger *zerolog.Logger, name, company, email string) *CreateCustomerCommand {
-	return &CreateCustomerCommand{db: db, logger: logger, name: name, company: company, email: email}
+func NewCreateCustomerCommand(store repository.Store, logger *zerolog.Logger, name, company, email string) *CreateCustomerCommand {
+	return &CreateCustomerCommand{store: store, logger: logger, name: name, company: company, email: email}
 }
 
 func (s *CreateCustomerCommand) Execute() (string, error) {
@@ -39,15 +40,20 @@ func (s *CreateCustomerCommand) Execute() (string, error) {
 	}
 
 	customer := models.Customer{
-		Name:           s.name,
-		Company:        s.company,
-		Email:          s.email,
-		MaxSourceLimit: 1,
+		Name:    s.name,
+		Company: s.company,
+		Email:   s.email,
 	}
 
-	res := s.db.Create(&customer)
-	if res.Error != nil {
-		return "", res.Error
+	err := s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Customers().Create(&customer)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.CustomerCreated, customer.ID, customer.ID, customer))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("CreateCustomerCommand: Finished with success")
@@ -56,13 +62,13 @@ func (s *CreateCustomerCommand) Execute() (string, error) {
 }
 
 type DeleteCustomerCommand struct {
-	db     *gorm.DB
+	store  repository.Store
 	logger *zerolog.Logger
 	id     string
 }
 
-func NewDeleteCustomerCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteCustomerCommand {
-	return &DeleteCustomerCommand{db: db, logger: logger, id: id}
+func NewDeleteCustomerCommand(store repository.Store, logger *zerolog.Logger, id string) *DeleteCustomerCommand {
+	return &DeleteCustomerCommand{store: store, logger: logger, id: id}
 }
 
 func (s *DeleteCustomerCommand) Execute() (string, error) {
@@ -71,9 +77,15 @@ func (s *DeleteCustomerCommand) Execute() (string, error) {
 	}
 	s.logger.Debug().Msg("DeleteCustomerCommand: Started")
 
-	res := s.db.Delete(models.Customer{}, s.id)
-	if res.Error != nil {
-		return "", res.Error
+	err := s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Customers().Delete(s.id)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.CustomerDeleted, s.id, s.id, nil))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("DeleteCustomerCommand: Finished with success")
@@ -82,32 +94,36 @@ func (s *DeleteCustomerCommand) Execute() (string, error) {
 }
 
 type UpdateCustomerCommand struct {
-	db             *gorm.DB
-	logger         *zerolog.Logger
-	id             string
-	name           *string
-	email          *string
-	company        *string
+	store   repository.Store
+	logger  *zerolog.Logger
+	id      string
+	name    *string
+	email   *string
+	company *string
+	// maxSourceLimit overrides the source limit of the plan, see
+	// models.Customer.
 	maxSourceLimit *int
 }
 
-func NewUpdateCustomerCommand(db *gorm.DB, logger *zerolog.Logger, id string, name, email, company *string, maxSourceLimit *int) *UpdateCustomerCommand {
-	return &UpdateCustomerCommand{db: db, logger: logger, id: id, name: name, email: email, company: company, maxSourceLimit: maxSourceLimit}
+func NewUpdateCustomerCommand(store repository.Store, logger *zerolog.Logger, id string, name, email, company *string, maxSourceLimit *int) *UpdateCustomerCommand {
+	return &UpdateCustomerCommand{store: store, logger: logger, id: id, name: name, email: email, company: company, maxSourceLimit: maxSourceLimit}
 }
 
 func (s *UpdateCustomerCommand) Execute() (string, error) {
 	if s.id == "" {
 		return "", errors.New("UpdateCustomerCommand: Tried updating with empty id")
 	}
+	if s.maxSourceLimit != nil && *s.maxSourceLimit < 0 {
+		return "", fmt.Errorf("UpdateCustomerCommand: maxSourceLimit can not be negative, got %d", *s.maxSourceLimit)
+	}
 	s.logger.Debug().Msg("UpdateCustomerCommand: Started")
 
-	var customer models.Customer
-	res := s.db.First(&customer, s.id)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("UpdateReservationCommand: Could not find the customer with id: %s", s.id)
+	customer, err := s.store.Customers().Get(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("UpdateCustomerCommand: Could not find the customer with id: %s", s.id)
 		}
-		return "", res.Error
+		return "", err
 	}
 
 	if s.name != nil && *s.name != "" {
@@ -119,13 +135,19 @@ func (s *UpdateCustomerCommand) Execute() (string, error) {
 	if s.company != nil && *s.company != "" {
 		customer.Company = *s.company
 	}
-	if s.maxSourceLimit != nil && *s.maxSourceLimit >= 0 {
+	if s.maxSourceLimit != nil {
 		customer.MaxSourceLimit = *s.maxSourceLimit
 	}
 
-	res = s.db.Save(&customer)
-	if res.Error != nil {
-		return "", res.Error
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Customers().Save(&customer)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.CustomerUpdated, customer.ID, customer.ID, customer))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("UpdateCustomerCommand: Finished with success")
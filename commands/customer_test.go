@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestCreateCustomerCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		_, err := NewCreateCustomerCommand(f, f.Logger, "", "Acme", "a@acme.io").Execute()
+		if err == nil {
+			t.Fatal("created a customer without a name")
+		}
+
+		id, err := NewCreateCustomerCommand(f, f.Logger, "Alice", "Acme", "a@acme.io").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		customer, err := f.Customers().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if customer.Name != "Alice" || customer.Company != "Acme" || customer.Email != "a@acme.io" {
+			t.Errorf("stored %+v", customer)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.CustomerCreated}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestUpdateCustomerCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+
+		_, err := NewUpdateCustomerCommand(f, f.Logger, "", nil, nil, nil, nil).Execute()
+		if err == nil {
+			t.Error("updated a customer without an id")
+		}
+		_, err = NewUpdateCustomerCommand(f, f.Logger, "00000000-0000-0000-0000-000000000000", nil, nil, nil, nil).Execute()
+		if err == nil {
+			t.Error("updated a customer that does not exist")
+		}
+
+		name, email := "Alice", ""
+		_, err = NewUpdateCustomerCommand(f, f.Logger, customer.ID, &name, &email, nil, nil).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := f.Customers().Get(customer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Name != "Alice" || updated.Email != customer.Email || updated.Company != customer.Company {
+			t.Errorf("stored %+v", updated)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.CustomerUpdated}) {
+			t.Errorf("recorded %v", types)
+		}
+
+		// The source limit customers had before there were plans is kept as an
+		// override of the plan.
+		negative, limit := -1, 4
+		if _, err = NewUpdateCustomerCommand(f, f.Logger, customer.ID, nil, nil, nil, &negative).Execute(); err == nil {
+			t.Error("updated a customer to a negative source limit")
+		}
+		if _, err = NewUpdateCustomerCommand(f, f.Logger, customer.ID, nil, nil, nil, &limit).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		plan, err := f.Customers().Plan(customer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Name != models.DefaultPlan.Name || plan.MaxSources != 4 {
+			t.Errorf("customer is on %+v", plan)
+		}
+	})
+}
+
+func TestDeleteCustomerCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+
+		_, err := NewDeleteCustomerCommand(f, f.Logger, "").Execute()
+		if err == nil {
+			t.Error("deleted a customer without an id")
+		}
+
+		_, err = NewDeleteCustomerCommand(f, f.Logger, customer.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Customers().Get(customer.ID)
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("customer is still there: %v", err)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.CustomerDeleted}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestCreateSecretCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+
+		_, err := NewCreateSecretCommand(f, f.Logger, f.Hasher, "").Execute()
+		if err == nil {
+			t.Error("created a secret without a customer")
+		}
+		_, err = NewCreateSecretCommand(f, f.Logger, f.Hasher, "00000000-0000-0000-0000-000000000000").Execute()
+		if err == nil {
+			t.Error("created a secret of a customer that does not exist")
+		}
+
+		id, err := NewCreateSecretCommand(f, f.Logger, f.Hasher, customer.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err := f.Secrets().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret.CustomerID != customer.ID || secret.Value == "" {
+			t.Errorf("stored %+v", secret)
+		}
+		found, err := f.Secrets().FindByValue(secret.Value)
+		if err != nil || found.ID != id {
+			t.Errorf("found %+v, %v", found, err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportDataCommand inserts the rows of an export in one transaction, keeping
+// their ids. Rows whose id already exists are left as they are, so importing
+// the same export twice changes nothing.
+type ImportDataCommand struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	export   models.Export
+	imported map[string]int
+}
+
+func NewImportDataCommand(db *gorm.DB, logger *zerolog.Logger, export models.Export) *ImportDataCommand {
+	return &ImportDataCommand{db: db, logger: logger, export: export}
+}
+
+// Imported returns the number of rows inserted into every table.
+func (s *ImportDataCommand) Imported() map[string]int {
+	return s.imported
+}
+
+// Execute returns the number of inserted rows.
+func (s *ImportDataCommand) Execute() (string, error) {
+	if s.export.Version != models.ExportVersion {
+		return "", fmt.Errorf("ImportDataCommand: Can not import an export of version %d, expected %d", s.export.Version, models.ExportVersion)
+	}
+	s.logger.Debug().Msg("ImportDataCommand: Started")
+
+	// Parents come before the rows referring to them.
+	tables := []struct {
+		name string
+		rows any
+	}{
+		{"customers", s.export.Customers},
+		{"secrets", s.export.Secrets},
+		{"persons", s.export.Persons},
+		{"cancellationPolicies", s.export.CancellationPolicies},
+		{"promotions", s.export.Promotions},
+		{"sources", s.export.Sources},
+		{"apiTokens", s.export.ApiTokens},
+		{"rates", s.export.Rates},
+		{"bundles", s.export.Bundles},
+		{"reservations", s.export.Reservations},
+		{"participants", s.export.Participants},
+		{"fees", s.export.Fees},
+		{"payments", s.export.Payments},
+	}
+
+	imported := map[string]int{}
+	total := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			if reflect.ValueOf(table.rows).Len() == 0 {
+				imported[table.name] = 0
+				continue
+			}
+			res := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(table.rows, 500)
+			if res.Error != nil {
+				return fmt.Errorf("ImportDataCommand: Could not import the %s: %w", table.name, res.Error)
+			}
+			imported[table.name] = int(res.RowsAffected)
+			total += int(res.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.imported = imported
+	s.logger.Debug().Msg("ImportDataCommand: Finished with success")
+	return strconv.Itoa(total), nil
+}
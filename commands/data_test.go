@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestImportDataCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := models.Customer{Base: models.Base{ID: models.NewUUID()}, Name: "alice"}
+		alice := models.Person{Base: models.Base{ID: models.NewUUID()}, CustomerID: customer.ID, Name: "alice"}
+		room := models.Source{Base: models.Base{ID: models.NewUUID()}, CustomerID: customer.ID, Name: "room", MaxPossibleDuration: "24h", Currency: "EUR", Timezone: "UTC"}
+		reservation := models.Reservation{Base: models.Base{ID: models.NewUUID()}, From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed}
+		export := models.Export{
+			Version:      models.ExportVersion,
+			ExportedAt:   time.Now(),
+			Customers:    []models.Customer{customer},
+			Persons:      []models.Person{alice},
+			Sources:      []models.Source{room},
+			Reservations: []models.Reservation{reservation},
+		}
+
+		if _, err := NewImportDataCommand(f.DB, f.Logger, models.Export{Version: models.ExportVersion + 1}).Execute(); err == nil {
+			t.Error("imported an export of another version")
+		}
+
+		importData := NewImportDataCommand(f.DB, f.Logger, export)
+		count, err := importData.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported := importData.Imported(); count != "4" || imported["customers"] != 1 || imported["reservations"] != 1 || imported["rates"] != 0 {
+			t.Errorf("imported %s rows: %v", count, imported)
+		}
+		stored, err := f.Reservations().Get(reservation.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.SourceID != room.ID || !stored.From.Equal(reservation.From) {
+			t.Errorf("stored %+v", stored)
+		}
+
+		// Rows that exist are left as they are.
+		if count, err = NewImportDataCommand(f.DB, f.Logger, export).Execute(); err != nil || count != "0" {
+			t.Errorf("imported %s rows again: %v", count, err)
+		}
+	})
+}
@@ -0,0 +1,391 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/calendar"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+)
+
+// The errors of the calendar object commands wrap one of these, so that the
+// CalDAV handlers can answer with the matching status and precondition.
+var (
+	ErrCalendarObjectNotFound = errors.New("there is no calendar object with this name")
+	ErrPreconditionFailed     = errors.New("the calendar object does not match the precondition")
+	ErrInvalidCalendarData    = errors.New("the body is not valid iCalendar data")
+	ErrInvalidCalendarObject  = errors.New("the body is not a calendar object the source can store")
+	ErrUIDConflict            = errors.New("another calendar object of the source has this UID")
+)
+
+// findCalendarObject returns the reservation of the source that holds its
+// slot and is served under name, or nil when there is none.
+func findCalendarObject(store repository.Store, sourceId, name string) (*models.Reservation, error) {
+	object, err := store.CalendarObjects().Find(sourceId, name)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	id := object.ReservationID
+	if err != nil {
+		var ok bool
+		id, ok = strings.CutSuffix(name, ".ics")
+		if !ok || !util.IsUUID(id) {
+			return nil, nil
+		}
+		// A reservation with a remembered name is not served under its id.
+		_, err = store.CalendarObjects().FindByReservation(id)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	reservation, err := store.Reservations().Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reservation.SourceID != sourceId || reservation.IsReleased() {
+		return nil, nil
+	}
+	return &reservation, nil
+}
+
+// lockCalendarObject returns the reservation findCalendarObject finds and
+// holds it until the transaction tx runs in is done, so that the
+// preconditions checked on it still hold when it is changed.
+func lockCalendarObject(tx repository.Store, sourceId, name string) (*models.Reservation, error) {
+	reservation, err := findCalendarObject(tx, sourceId, name)
+	if err != nil || reservation == nil {
+		return nil, err
+	}
+	locked, err := tx.Reservations().GetForUpdate(reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+	// It was released while the lock was awaited.
+	if locked.IsReleased() {
+		return nil, nil
+	}
+	return &locked, nil
+}
+
+// checkPreconditions applies the If-Match and If-None-Match headers of a
+// request to the reservation it targets.
+func checkPreconditions(caller string, reservation *models.Reservation, ifMatch, ifNoneMatch string) error {
+	if ifNoneMatch == "*" && reservation != nil {
+		return fmt.Errorf("%s: %w: it already exists", caller, ErrPreconditionFailed)
+	}
+	if ifMatch == "" {
+		return nil
+	}
+	if reservation == nil {
+		return fmt.Errorf("%s: %w: it does not exist", caller, ErrPreconditionFailed)
+	}
+	if ifMatch != "*" && ifMatch != reservation.ETag() {
+		return fmt.Errorf("%s: %w: it was changed", caller, ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// personByEmail returns a person of the customer with the mail address.
+func personByEmail(store repository.Store, caller, customerId, email string) (models.Person, error) {
+	if email == "" {
+		return models.Person{}, fmt.Errorf("%s: The event has no mail address to find a person by", caller)
+	}
+	person, err := store.People().FindByEmail(customerId, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return person, fmt.Errorf("%s: Could not find a person of customer %s with this email: %s", caller, customerId, email)
+		}
+		return person, err
+	}
+	return person, nil
+}
+
+// sourceOfCustomer returns the source when it belongs to the customer, the
+// sources of other customers are not found.
+func sourceOfCustomer(store repository.Store, caller, customerId, sourceId string) (models.Source, error) {
+	source, err := store.Sources().Get(sourceId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return source, err
+	}
+	if err != nil || source.CustomerID != customerId {
+		return models.Source{}, fmt.Errorf("%s: %w: Could not find the source with this id: %s", caller, ErrCalendarObjectNotFound, sourceId)
+	}
+	return source, nil
+}
+
+// PutCalendarObjectCommand stores the event a CalDAV client put under name in
+// the collection of a source. A new event becomes a reservation through
+// CreateReservationCommand, with the person of its ORGANIZER as reserver and
+// the first ATTENDEE that is a person as reservee. An existing one is moved
+// the way UpdateReservationCommand moves it, or cancelled when its STATUS is
+// CANCELLED.
+type PutCalendarObjectCommand struct {
+	store       repository.Store
+	logger      *zerolog.Logger
+	provider    payments.PaymentProvider
+	customerId  string
+	sourceId    string
+	name        string
+	body        []byte
+	ifMatch     string
+	ifNoneMatch string
+	now         time.Time
+	created     bool
+	etag        string
+	conflict    string
+}
+
+func NewPutCalendarObjectCommand(store repository.Store, logger *zerolog.Logger, provider payments.PaymentProvider, customerId, sourceId, name string, body []byte, ifMatch, ifNoneMatch string, now time.Time) *PutCalendarObjectCommand {
+	return &PutCalendarObjectCommand{store: store, logger: logger, provider: provider, customerId: customerId, sourceId: sourceId, name: name, body: body, ifMatch: ifMatch, ifNoneMatch: ifNoneMatch, now: now}
+}
+
+// Created tells whether Execute created a new reservation.
+func (s *PutCalendarObjectCommand) Created() bool {
+	return s.created
+}
+
+// ETag returns the entity tag of the stored event, it is empty when the event
+// was cancelled.
+func (s *PutCalendarObjectCommand) ETag() string {
+	return s.etag
+}
+
+// Conflict returns the path of the calendar object that already has the UID
+// when Execute failed with ErrUIDConflict.
+func (s *PutCalendarObjectCommand) Conflict() string {
+	return s.conflict
+}
+
+func (s *PutCalendarObjectCommand) Execute() (string, error) {
+	if s.customerId == "" || s.sourceId == "" || s.name == "" {
+		return "", errors.New("PutCalendarObjectCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("PutCalendarObjectCommand: Started")
+
+	source, err := sourceOfCustomer(s.store, "PutCalendarObjectCommand", s.customerId, s.sourceId)
+	if err != nil {
+		return "", err
+	}
+
+	loc := time.UTC
+	if source.Timezone != "" {
+		loc, err = time.LoadLocation(source.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("PutCalendarObjectCommand: Source %s has an invalid timezone: %w", source.ID, err)
+		}
+	}
+	occurrences, err := calendar.Read(bytes.NewReader(s.body), loc, s.now.AddDate(1, 0, 0))
+	if err != nil {
+		return "", fmt.Errorf("PutCalendarObjectCommand: %w: %w", ErrInvalidCalendarData, err)
+	}
+	if len(occurrences) != 1 {
+		return "", fmt.Errorf("PutCalendarObjectCommand: %w: it has to hold exactly one event", ErrInvalidCalendarObject)
+	}
+	event := occurrences[0]
+	switch {
+	case event.Err != nil:
+		return "", fmt.Errorf("PutCalendarObjectCommand: %w: %w", ErrInvalidCalendarData, event.Err)
+	case event.UID == "":
+		return "", fmt.Errorf("PutCalendarObjectCommand: %w: the event has no UID", ErrInvalidCalendarObject)
+	case event.Recurring:
+		return "", fmt.Errorf("PutCalendarObjectCommand: %w: recurring events have to be imported", ErrInvalidCalendarObject)
+	}
+
+	// The preconditions are checked on the reservation the transaction
+	// holds, a change committed since the client read it fails them.
+	var id string
+	var cancelled *models.Reservation
+	created := false
+	err = s.store.Transaction(func(tx repository.Store) error {
+		existing, err := lockCalendarObject(tx, source.ID, s.name)
+		if err != nil {
+			return err
+		}
+		err = checkPreconditions("PutCalendarObjectCommand", existing, s.ifMatch, s.ifNoneMatch)
+		if err != nil {
+			return err
+		}
+		err = s.checkUID(tx, source, event.UID, existing)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case existing != nil && event.Cancelled:
+			_, err = cancelSingleReservation(tx, "PutCalendarObjectCommand", existing, nil)
+			if err != nil {
+				return err
+			}
+			cancelled = existing
+			return tx.CalendarObjects().DeleteForReservation(existing.ID)
+		case existing != nil:
+			id = existing.ID
+			if !existing.From.Equal(event.Start) || !existing.To.Equal(event.End) {
+				_, err = rescheduleReservation(tx, "PutCalendarObjectCommand", *existing, &event.Start, &event.End, nil)
+				if err != nil {
+					return err
+				}
+			}
+		case event.Cancelled:
+			return fmt.Errorf("PutCalendarObjectCommand: %w: a cancelled event can not be created", ErrInvalidCalendarObject)
+		default:
+			reserver, err := personByEmail(tx, "PutCalendarObjectCommand", source.CustomerID, event.Organizer)
+			if err != nil {
+				return err
+			}
+			reservee := reserver
+			for _, attendee := range event.Attendees {
+				if strings.EqualFold(attendee, event.Organizer) {
+					continue
+				}
+				person, err := personByEmail(tx, "PutCalendarObjectCommand", source.CustomerID, attendee)
+				if err == nil {
+					reservee = person
+					break
+				}
+			}
+
+			id, err = NewCreateReservationCommand(tx, s.logger, s.provider, event.Start, event.End, reserver.ID, reservee.ID, source.ID, nil, 1, "", "").Execute()
+			if err != nil {
+				return err
+			}
+			err = tx.CalendarObjects().Create(&models.CalendarObject{SourceID: source.ID, Name: s.name, ReservationID: id, UID: event.UID})
+			if err != nil {
+				return err
+			}
+			created = true
+		}
+
+		reservation, err := tx.Reservations().Get(id)
+		if err != nil {
+			return err
+		}
+		s.etag = reservation.ETag()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	s.created = created
+
+	// Refunds reach the payment provider, which no transaction waits for.
+	if cancelled != nil {
+		err = refundReservation(s.store, s.provider, "PutCalendarObjectCommand", *cancelled)
+		if err != nil {
+			return "", err
+		}
+		s.logger.Debug().Msg("PutCalendarObjectCommand: Finished with success")
+		return cancelled.ID, nil
+	}
+
+	s.logger.Debug().Msg("PutCalendarObjectCommand: Finished with success")
+
+	return id, nil
+}
+
+// checkUID refuses a UID that another calendar object of the source has,
+// either remembered from a client or derived from its reservation id.
+func (s *PutCalendarObjectCommand) checkUID(tx repository.Store, source models.Source, uid string, existing *models.Reservation) error {
+	object, err := tx.CalendarObjects().FindByUID(source.ID, uid)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	conflict := ""
+	if err == nil && object.Name != s.name {
+		conflict = object.Name
+	} else if id, ok := strings.CutSuffix(uid, calendar.UID("")); ok && util.IsUUID(id) && (existing == nil || existing.ID != id) {
+		reservation, err := tx.Reservations().Get(id)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err == nil && reservation.SourceID == source.ID {
+			conflict = id + ".ics"
+		}
+	}
+	if conflict == "" {
+		return nil
+	}
+
+	s.conflict = models.DavCollectionPath(source.ID) + conflict
+	return fmt.Errorf("PutCalendarObjectCommand: %w: %s", ErrUIDConflict, s.conflict)
+}
+
+// DeleteCalendarObjectCommand cancels the reservation a CalDAV client deleted
+// the way DeleteReservationCommand does, so the cancellation policy of the
+// source applies.
+type DeleteCalendarObjectCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	provider   payments.PaymentProvider
+	customerId string
+	sourceId   string
+	name       string
+	ifMatch    string
+}
+
+func NewDeleteCalendarObjectCommand(store repository.Store, logger *zerolog.Logger, provider payments.PaymentProvider, customerId, sourceId, name, ifMatch string) *DeleteCalendarObjectCommand {
+	return &DeleteCalendarObjectCommand{store: store, logger: logger, provider: provider, customerId: customerId, sourceId: sourceId, name: name, ifMatch: ifMatch}
+}
+
+func (s *DeleteCalendarObjectCommand) Execute() (string, error) {
+	if s.customerId == "" || s.sourceId == "" || s.name == "" {
+		return "", errors.New("DeleteCalendarObjectCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("DeleteCalendarObjectCommand: Started")
+
+	_, err := sourceOfCustomer(s.store, "DeleteCalendarObjectCommand", s.customerId, s.sourceId)
+	if err != nil {
+		return "", err
+	}
+
+	var reservation *models.Reservation
+	err = s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		reservation, err = lockCalendarObject(tx, s.sourceId, s.name)
+		if err != nil {
+			return err
+		}
+		if reservation == nil {
+			return fmt.Errorf("DeleteCalendarObjectCommand: %w: %s", ErrCalendarObjectNotFound, s.name)
+		}
+		err = checkPreconditions("DeleteCalendarObjectCommand", reservation, s.ifMatch, "")
+		if err != nil {
+			return err
+		}
+
+		_, err = cancelSingleReservation(tx, "DeleteCalendarObjectCommand", reservation, nil)
+		if err != nil {
+			return err
+		}
+		return tx.CalendarObjects().DeleteForReservation(reservation.ID)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = refundReservation(s.store, s.provider, "DeleteCalendarObjectCommand", *reservation)
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteCalendarObjectCommand: Finished with success")
+
+	return reservation.ID, nil
+}
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+	"gorm.io/gorm"
+)
+
+// interleavedPool runs before once, right before the first transaction of the
+// database begins, the way a concurrent request could.
+type interleavedPool struct {
+	gorm.ConnPool
+	before func()
+	once   sync.Once
+}
+
+func (p *interleavedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	p.once.Do(p.before)
+	return p.ConnPool.(gorm.TxBeginner).BeginTx(ctx, opts)
+}
+
+func interleave(db *gorm.DB, before func()) repository.Store {
+	// A session with a context of its own gets a statement of its own.
+	session := db.Session(&gorm.Session{Context: context.Background()})
+	session.Statement.ConnPool = &interleavedPool{ConnPool: db.Statement.ConnPool, before: before}
+	return gormstore.New(session)
+}
+
+// calendarObject is an event of alice and bob on the hours of repotest.At.
+func calendarObject(from, to int, lines ...string) []byte {
+	lines = append([]string{"DTSTART:" + icsTime(from), "DTEND:" + icsTime(to), "ORGANIZER:mailto:alice@example.com", "ATTENDEE:mailto:bob@example.com"}, lines...)
+	return []byte(vcalendar(vevent("meeting", lines...)))
+}
+
+func newDavBooking(t *testing.T, f *repotest.Fixture) *booking {
+	t.Helper()
+	b := newBooking(t, f, largePlan)
+	for _, person := range []models.Person{b.alice, b.bob} {
+		if err := f.DB.Model(&person).Update("email", person.Name+"@example.com").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+func TestPutCalendarObjectCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newDavBooking(t, f)
+		put := func(body []byte, ifMatch, ifNoneMatch string) (*PutCalendarObjectCommand, string, error) {
+			put := NewPutCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", body, ifMatch, ifNoneMatch, time.Now())
+			id, err := put.Execute()
+			return put, id, err
+		}
+
+		created, id, err := put(calendarObject(9, 10), "", "*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if !created.Created() || created.ETag() != reservation.ETag() || reservation.ReserverID != b.alice.ID || reservation.ReserveeID != b.bob.ID {
+			t.Errorf("created %+v with the etag %s", reservation, created.ETag())
+		}
+		if _, _, err = put(calendarObject(9, 10), "", "*"); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("created the calendar object twice: %v", err)
+		}
+
+		moved, _, err := put(calendarObject(10, 11), created.ETag(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation = b.reservation(t, id)
+		if moved.Created() || moved.ETag() == created.ETag() || moved.ETag() != reservation.ETag() || !reservation.From.Equal(repotest.At(10)) {
+			t.Errorf("moved into %+v with the etag %s", reservation, moved.ETag())
+		}
+		if _, _, err = put(calendarObject(11, 12), created.ETag(), ""); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("moved a calendar object that was changed: %v", err)
+		}
+
+		other := NewPutCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "other.ics", calendarObject(14, 15), "", "", time.Now())
+		if _, err = other.Execute(); !errors.Is(err, ErrUIDConflict) || other.Conflict() != models.DavCollectionPath(b.room.ID)+"meeting.ics" {
+			t.Errorf("stored a second event with the UID under %s: %v", other.Conflict(), err)
+		}
+
+		cancelled, _, err := put(calendarObject(10, 11, "STATUS:CANCELLED"), moved.ETag(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reservation = b.reservation(t, id); cancelled.ETag() != "" || reservation.Status != models.ReservationStatusCancelled {
+			t.Errorf("cancelled into %+v", reservation)
+		}
+		if _, _, err = put(calendarObject(10, 11), "*", ""); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("changed a calendar object that was cancelled: %v", err)
+		}
+	})
+}
+
+func TestPutCalendarObjectCommandChecksThePreconditionInItsTransaction(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newDavBooking(t, f)
+		created := NewPutCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", calendarObject(9, 10), "", "*", time.Now())
+		id, err := created.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The reservation is moved after the client read it, but before the
+		// put begins to write.
+		store := interleave(f.DB, func() {
+			from, to := repotest.At(12), repotest.At(13)
+			if _, err := NewUpdateReservationCommand(gormstore.New(f.DB), f.Logger, id, &from, &to, nil).Execute(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		_, err = NewPutCalendarObjectCommand(store, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", calendarObject(10, 11), created.ETag(), "", time.Now()).Execute()
+		if !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("overwrote a concurrent change: %v", err)
+		}
+		if reservation := b.reservation(t, id); !reservation.From.Equal(repotest.At(12)) {
+			t.Errorf("the concurrent change was undone into %+v", reservation)
+		}
+	})
+}
+
+func TestDeleteCalendarObjectCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newDavBooking(t, f)
+		created := NewPutCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", calendarObject(9, 10), "", "*", time.Now())
+		id, err := created.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := interleave(f.DB, func() {
+			from, to := repotest.At(12), repotest.At(13)
+			if _, err := NewUpdateReservationCommand(gormstore.New(f.DB), f.Logger, id, &from, &to, nil).Execute(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if _, err = NewDeleteCalendarObjectCommand(store, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", created.ETag()).Execute(); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("deleted a calendar object that was changed concurrently: %v", err)
+		}
+
+		current := b.reservation(t, id)
+		if _, err = NewDeleteCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.hall.ID, "meeting.ics", "").Execute(); !errors.Is(err, ErrCalendarObjectNotFound) {
+			t.Errorf("deleted the calendar object from another source: %v", err)
+		}
+		if _, err = NewDeleteCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", current.ETag()).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if reservation := b.reservation(t, id); reservation.Status != models.ReservationStatusCancelled {
+			t.Errorf("deleted into %+v", reservation)
+		}
+		var objects int64
+		if err = f.DB.Model(&models.CalendarObject{}).Count(&objects).Error; err != nil || objects != 0 {
+			t.Errorf("kept %d calendar objects: %v", objects, err)
+		}
+		if _, err = NewDeleteCalendarObjectCommand(f, f.Logger, nil, b.customer.ID, b.room.ID, "meeting.ics", "").Execute(); !errors.Is(err, ErrCalendarObjectNotFound) {
+			t.Errorf("deleted the calendar object twice: %v", err)
+		}
+	})
+}
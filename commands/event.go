@@ -0,0 +1,36 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"gorm.io/gorm"
+)
+
+// record writes the event of a mutation to the outbox. It has to run in the
+// transaction of the mutation, the relay publishes the event once it is
+// committed.
+func record(tx *gorm.DB, eventType, customerId, aggregateId string, payload any) error {
+	return gormstore.New(tx).Record(events.NewEvent(eventType, customerId, aggregateId, payload))
+}
+
+// sourceCustomerId returns the customer owning the source, which is the
+// customer of every event about the source's reservations.
+func sourceCustomerId(store repository.Store, sourceId string) string {
+	source, _ := store.Sources().Get(sourceId)
+	return source.CustomerID
+}
+
+// reservationCustomerId returns the customer owning the source of the reservation.
+func reservationCustomerId(db *gorm.DB, reservationId string) string {
+	var customerId string
+	db.Model(&models.Source{}).
+		Joins("JOIN reservations r ON r.source_id = sources.id").
+		Where("r.id = ?", reservationId).
+		Pluck("sources.customer_id", &customerId)
+	return customerId
+}
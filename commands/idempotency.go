@@ -0,0 +1,70 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrIdempotencyKeyInUse is returned while the first request with the key
+	// is still being served.
+	ErrIdempotencyKeyInUse = errors.New("ClaimIdempotencyKey: A request with this idempotency key is still in progress")
+	// ErrIdempotencyKeyReused is returned when the key was first used with a
+	// different request.
+	ErrIdempotencyKeyReused = errors.New("ClaimIdempotencyKey: This idempotency key was used with a different request")
+)
+
+// ClaimIdempotencyKey claims the key of the scope for the request with the
+// fingerprint. It returns nil when the request is the first one with the key
+// and has to be served, or the key holding the response to replay. Keys
+// older than models.IdempotencyKeyLifetime are claimed anew.
+func ClaimIdempotencyKey(db *gorm.DB, scope, key, fingerprint string, now time.Time) (*models.IdempotencyKey, error) {
+	res := db.Where("scope = ? AND key = ? AND created_at < ?", scope, key, now.Add(-models.IdempotencyKeyLifetime)).Delete(&models.IdempotencyKey{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	claim := models.IdempotencyKey{Scope: scope, Key: key, Fingerprint: fingerprint}
+	res = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&claim)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	res = db.Where("scope = ? AND key = ?", scope, key).First(&existing)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.Status == 0 {
+		return nil, ErrIdempotencyKeyInUse
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request that claimed the
+// key.
+func CompleteIdempotencyKey(db *gorm.DB, scope, key string, status int, contentType string, body []byte) error {
+	res := db.Model(&models.IdempotencyKey{}).Where("scope = ? AND key = ?", scope, key).
+		Updates(map[string]any{"status": status, "content_type": contentType, "body": body})
+	return res.Error
+}
+
+// ReleaseIdempotencyKey forgets the key, so that the request can be retried
+// after it failed without a response worth replaying.
+func ReleaseIdempotencyKey(db *gorm.DB, scope, key string) error {
+	res := db.Where("scope = ? AND key = ?", scope, key).Delete(&models.IdempotencyKey{})
+	return res.Error
+}
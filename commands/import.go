@@ -0,0 +1,137 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/calendar"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+)
+
+// maxImportedEvents caps the occurrences one import may hold.
+const maxImportedEvents = 5000
+
+// errDryRun rolls back the transaction of an import that is not committed.
+var errDryRun = errors.New("dry run")
+
+// ImportReservationsCommand turns the VEVENTs of an iCalendar document into
+// reservations of a source. Every occurrence goes through
+// CreateReservationCommand in a savepoint of one transaction, so it is held to
+// the same rules and to the occurrences imported before it. Unless commit is
+// set the transaction is rolled back and only the report is kept.
+type ImportReservationsCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	customerId string
+	sourceId   string
+	reserverId string
+	reserveeId string
+	units      int
+	calendar   string
+	commit     bool
+	now        time.Time
+	report     models.ImportReport
+}
+
+func NewImportReservationsCommand(store repository.Store, logger *zerolog.Logger, customerId, sourceId, reserverId, reserveeId string, units int, calendar string, commit bool, now time.Time) *ImportReservationsCommand {
+	return &ImportReservationsCommand{store: store, logger: logger, customerId: customerId, sourceId: sourceId, reserverId: reserverId, reserveeId: reserveeId, units: units, calendar: calendar, commit: commit, now: now}
+}
+
+// Report returns what Execute created or would create for every occurrence.
+func (s *ImportReservationsCommand) Report() models.ImportReport {
+	return s.report
+}
+
+// Execute returns the number of creatable occurrences.
+func (s *ImportReservationsCommand) Execute() (string, error) {
+	if s.sourceId == "" || s.reserverId == "" || s.reserveeId == "" || s.calendar == "" {
+		return "", errors.New("ImportReservationsCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("ImportReservationsCommand: Started")
+
+	source, err := s.store.Sources().Get(s.sourceId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return "", err
+	}
+	if err != nil || source.CustomerID != s.customerId {
+		return "", fmt.Errorf("ImportReservationsCommand: Could not find the source with this id: %s", s.sourceId)
+	}
+
+	// Floating times and all-day events are read in the time zone of the source.
+	loc := time.UTC
+	if source.Timezone != "" {
+		loc, err = time.LoadLocation(source.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("ImportReservationsCommand: Source %s has an invalid timezone: %w", source.ID, err)
+		}
+	}
+
+	occurrences, err := calendar.Read(strings.NewReader(s.calendar), loc, s.now.AddDate(1, 0, 0))
+	if err != nil {
+		return "", fmt.Errorf("ImportReservationsCommand: Could not read the calendar: %w", err)
+	}
+	if len(occurrences) > maxImportedEvents {
+		return "", fmt.Errorf("ImportReservationsCommand: The calendar has %d occurrences, at most %d can be imported at once", len(occurrences), maxImportedEvents)
+	}
+
+	s.report = models.ImportReport{SourceID: source.ID, DryRun: !s.commit, Events: make([]models.ImportedEvent, 0, len(occurrences))}
+	err = s.store.Transaction(func(tx repository.Store) error {
+		for _, occurrence := range occurrences {
+			event := models.ImportedEvent{UID: occurrence.UID, Summary: occurrence.Summary, From: occurrence.Start, To: occurrence.End}
+			switch {
+			case occurrence.Err != nil:
+				event.Status = models.ImportStatusSkipped
+				event.Reason = occurrence.Err.Error()
+			case occurrence.Cancelled:
+				event.Status = models.ImportStatusSkipped
+				event.Reason = "The event is cancelled"
+			default:
+				var id string
+				err := tx.Transaction(func(savepoint repository.Store) error {
+					var err error
+					id, err = NewCreateReservationCommand(savepoint, s.logger, nil, occurrence.Start, occurrence.End, s.reserverId, s.reserveeId, source.ID, nil, s.units, "", "").Execute()
+					return err
+				})
+				if err != nil {
+					event.Status = models.ImportStatusConflicting
+					event.Reason = strings.TrimPrefix(err.Error(), "CreateReservationCommand: ")
+					break
+				}
+				event.Status = models.ImportStatusCreatable
+				if s.commit {
+					event.Status = models.ImportStatusCreated
+					event.ReservationID = id
+				}
+			}
+
+			switch event.Status {
+			case models.ImportStatusSkipped:
+				s.report.Skipped++
+			case models.ImportStatusConflicting:
+				s.report.Conflicting++
+			default:
+				s.report.Creatable++
+			}
+			s.report.Events = append(s.report.Events, event)
+		}
+
+		if !s.commit {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("ImportReservationsCommand: Finished with success")
+
+	return fmt.Sprint(s.report.Creatable), nil
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// icsTime formats the hour of the day repotest.At uses as a UTC DATE-TIME.
+func icsTime(hour int) string {
+	return repotest.At(hour).Format("20060102T150405Z")
+}
+
+func vevent(uid string, lines ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VEVENT", "UID:" + uid}, lines...), "END:VEVENT"), "\r\n")
+}
+
+func vcalendar(events ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR"}, events...), "END:VCALENDAR"), "\r\n")
+}
+
+func TestImportReservationsCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		other := f.Customer(t, "other", nil)
+		if _, err := b.create(b.room, 9, 10, "alice", "bob"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The first of three daily occurrences collides with the reservation
+		// above, the cancelled and the broken event are skipped.
+		calendar := vcalendar(
+			vevent("daily", "SUMMARY:Daily", "DTSTART:"+icsTime(9), "DTEND:"+icsTime(10), "RRULE:FREQ=DAILY;COUNT=3"),
+			vevent("cancelled", "STATUS:CANCELLED", "DTSTART:"+icsTime(12), "DTEND:"+icsTime(13)),
+			vevent("broken", "DTEND:"+icsTime(13)),
+		)
+		reservations := func() int64 {
+			var count int64
+			if err := f.DB.Model(&models.Reservation{}).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			return count
+		}
+		eventsBefore := len(f.EventTypes(t))
+
+		dryRun := NewImportReservationsCommand(f, f.Logger, b.customer.ID, b.room.ID, "alice", "bob", 1, calendar, false, time.Now())
+		count, err := dryRun.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := dryRun.Report()
+		if count != "2" || !report.DryRun || report.Creatable != 2 || report.Conflicting != 1 || report.Skipped != 2 || len(report.Events) != 5 {
+			t.Errorf("dry run found %s creatable in %+v", count, report)
+		}
+		for i, want := range []string{models.ImportStatusConflicting, models.ImportStatusCreatable, models.ImportStatusCreatable, models.ImportStatusSkipped, models.ImportStatusSkipped} {
+			if event := report.Events[i]; event.Status != want || (want == models.ImportStatusCreatable && event.ReservationID != "") {
+				t.Errorf("dry run reported %+v, want %s", event, want)
+			}
+		}
+		if conflict := report.Events[0]; !conflict.From.Equal(repotest.At(9)) || !strings.Contains(conflict.Reason, "overlapping") {
+			t.Errorf("dry run reported the conflict %+v", conflict)
+		}
+		// Nothing of a dry run is kept.
+		if count := reservations(); count != 1 {
+			t.Errorf("dry run left %d reservations", count)
+		}
+		if types := f.EventTypes(t); len(types) != eventsBefore {
+			t.Errorf("dry run recorded %v", types[eventsBefore:])
+		}
+
+		commit := NewImportReservationsCommand(f, f.Logger, b.customer.ID, b.room.ID, "alice", "bob", 1, calendar, true, time.Now())
+		if count, err = commit.Execute(); err != nil || count != "2" {
+			t.Fatalf("imported %s: %v", count, err)
+		}
+		report = commit.Report()
+		for _, event := range report.Events[1:3] {
+			if event.Status != models.ImportStatusCreated {
+				t.Errorf("import reported %+v", event)
+				continue
+			}
+			reservation := b.reservation(t, event.ReservationID)
+			if reservation.SourceID != b.room.ID || reservation.ReserverID != b.alice.ID || !reservation.From.Equal(event.From) {
+				t.Errorf("imported %+v", reservation)
+			}
+		}
+		if count := reservations(); count != 3 {
+			t.Errorf("import left %d reservations, want 3", count)
+		}
+		// Importing again collides with what was imported.
+		if count, err = NewImportReservationsCommand(f, f.Logger, b.customer.ID, b.room.ID, "alice", "bob", 1, calendar, true, time.Now()).Execute(); err != nil || count != "0" {
+			t.Errorf("imported %s again: %v", count, err)
+		}
+
+		if _, err = NewImportReservationsCommand(f, f.Logger, other.ID, b.room.ID, "alice", "bob", 1, calendar, false, time.Now()).Execute(); err == nil {
+			t.Error("imported into the source of another customer")
+		}
+		if _, err = NewImportReservationsCommand(f, f.Logger, b.customer.ID, b.room.ID, "alice", "bob", 1, "BEGIN:VCALENDAR", false, time.Now()).Execute(); err == nil {
+			t.Error("imported a calendar that is not closed")
+		}
+
+		// Open-ended events expand up to a year after now, too many of them
+		// are refused as a whole.
+		var daily []string
+		for i := range maxImportedEvents/366 + 1 {
+			daily = append(daily, vevent(fmt.Sprint(i), "DTSTART:"+icsTime(14), "DURATION:PT1H", "RRULE:FREQ=DAILY"))
+		}
+		if _, err = NewImportReservationsCommand(f, f.Logger, b.customer.ID, b.hall.ID, "alice", "bob", 1, vcalendar(daily...), false, repotest.At(0)).Execute(); err == nil {
+			t.Error("imported more occurrences than an import may hold")
+		}
+	})
+}
@@ -0,0 +1,396 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/billing"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+)
+
+// nextInvoiceNumber hands out the next number with the prefix. It has to run
+// in the transaction issuing the invoice so that numbers stay without gaps.
+func nextInvoiceNumber(tx repository.Store, prefix string) (string, error) {
+	last, err := tx.Invoices().NextNumber(prefix)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%06d", prefix, last), nil
+}
+
+// issueInvoice numbers the invoice and freezes it.
+func issueInvoice(tx repository.Store, invoice *models.Invoice, now time.Time) error {
+	prefix, eventType := "INV", events.InvoiceIssued
+	if invoice.Kind == models.InvoiceKindCreditNote {
+		prefix, eventType = "CN", events.CreditNoteIssued
+	}
+	number, err := nextInvoiceNumber(tx, fmt.Sprintf("%s-%d", prefix, now.UTC().Year()))
+	if err != nil {
+		return err
+	}
+
+	invoice.Number = &number
+	invoice.Status = models.InvoiceStatusIssued
+	invoice.IssuedAt = &now
+	err = tx.Invoices().Save(invoice)
+	if err != nil {
+		return err
+	}
+	return tx.Record(events.NewEvent(eventType, invoice.CustomerID, invoice.ID, *invoice))
+}
+
+// buildInvoice prices the usage of the customer in the period against its
+// current plan.
+func buildInvoice(store repository.Store, caller, customerId, period string, defaultTaxRate int) (models.Invoice, error) {
+	start, end, err := billing.PeriodBounds(period)
+	if err != nil {
+		return models.Invoice{}, fmt.Errorf("%s: %w", caller, err)
+	}
+
+	customer, err := store.Customers().Get(customerId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Invoice{}, fmt.Errorf("%s: Could not find the customer with id: %s", caller, customerId)
+		}
+		return models.Invoice{}, err
+	}
+
+	plan, err := store.Customers().Plan(customerId)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	// Every source that existed during the period is billed, with the
+	// reservations made of it that still hold their slot.
+	sources, err := store.Invoices().Billable(customerId, start, end)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	slices.SortFunc(sources, func(a, b billing.SourceUsage) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.SourceID, b.SourceID))
+	})
+
+	reservations, err := store.Customers().Usage(customerId, models.UsageMetricReservations, period)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	taxRate := defaultTaxRate
+	if customer.TaxRateBasisPoints != nil {
+		taxRate = *customer.TaxRateBasisPoints
+	}
+
+	invoice := models.Invoice{
+		CustomerID:         customerId,
+		Kind:               models.InvoiceKindInvoice,
+		Status:             models.InvoiceStatusDraft,
+		Period:             period,
+		Currency:           plan.Currency,
+		Lines:              billing.InvoiceLines(plan, sources, reservations),
+		TaxRateBasisPoints: taxRate,
+	}
+	billing.ApplyTotals(&invoice)
+	return invoice, nil
+}
+
+// checkNotInvoiced refuses when the customer already has an issued invoice
+// for the period, the unique index on issued invoices catches the ones issued
+// concurrently.
+func checkNotInvoiced(tx repository.Store, caller, customerId, period string) error {
+	countOfIssued, err := tx.Invoices().CountIssued(customerId, period)
+	if err != nil {
+		return err
+	}
+	if countOfIssued > 0 {
+		return fmt.Errorf("%s: Customer %s was already invoiced for %s, correct it with a credit note", caller, customerId, period)
+	}
+	return nil
+}
+
+// generateInvoice replaces the draft of the customer for the period with a
+// freshly built one. It refuses when the period was already invoiced.
+func generateInvoice(store repository.Store, caller, customerId, period string, taxRate int) (models.Invoice, error) {
+	invoice, err := buildInvoice(store, caller, customerId, period, taxRate)
+	if err != nil {
+		return invoice, err
+	}
+
+	err = store.Transaction(func(tx repository.Store) error {
+		err := checkNotInvoiced(tx, caller, customerId, period)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Invoices().DeleteDrafts(customerId, period)
+		if err != nil {
+			return err
+		}
+		err = tx.Invoices().Create(&invoice)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.InvoiceDrafted, invoice.CustomerID, invoice.ID, invoice))
+	})
+	return invoice, err
+}
+
+type GenerateInvoiceCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	taxRate    int
+	customerId string
+	period     string
+}
+
+// NewGenerateInvoiceCommand builds the draft invoice of a customer for a
+// month. taxRate in basis points applies unless the customer has its own.
+func NewGenerateInvoiceCommand(store repository.Store, logger *zerolog.Logger, taxRate int, customerId, period string) *GenerateInvoiceCommand {
+	return &GenerateInvoiceCommand{store: store, logger: logger, taxRate: taxRate, customerId: customerId, period: period}
+}
+
+func (s *GenerateInvoiceCommand) Execute() (string, error) {
+	if s.customerId == "" || s.period == "" {
+		return "", errors.New("GenerateInvoiceCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("GenerateInvoiceCommand: Started")
+
+	invoice, err := generateInvoice(s.store, "GenerateInvoiceCommand", s.customerId, s.period, s.taxRate)
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("GenerateInvoiceCommand: Finished with success")
+
+	return invoice.ID, nil
+}
+
+type IssueInvoiceCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewIssueInvoiceCommand(store repository.Store, logger *zerolog.Logger, id string) *IssueInvoiceCommand {
+	return &IssueInvoiceCommand{store: store, logger: logger, id: id}
+}
+
+func (s *IssueInvoiceCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("IssueInvoiceCommand: Tried issuing with empty id")
+	}
+	s.logger.Debug().Msg("IssueInvoiceCommand: Started")
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		invoice, err := tx.Invoices().GetForUpdate(s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("IssueInvoiceCommand: Could not find the invoice with this id: %s", s.id)
+			}
+			return err
+		}
+		if invoice.IsIssued() {
+			return fmt.Errorf("IssueInvoiceCommand: Invoice %s is already issued", s.id)
+		}
+		if invoice.Kind == models.InvoiceKindInvoice {
+			err := checkNotInvoiced(tx, "IssueInvoiceCommand", invoice.CustomerID, invoice.Period)
+			if err != nil {
+				return err
+			}
+		}
+		return issueInvoice(tx, &invoice, time.Now())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("IssueInvoiceCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type DeleteInvoiceCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeleteInvoiceCommand(store repository.Store, logger *zerolog.Logger, id string) *DeleteInvoiceCommand {
+	return &DeleteInvoiceCommand{store: store, logger: logger, id: id}
+}
+
+// Execute deletes a draft, issued invoices stay forever.
+func (s *DeleteInvoiceCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeleteInvoiceCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteInvoiceCommand: Started")
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		invoice, err := tx.Invoices().GetForUpdate(s.id)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err != nil || invoice.Status != models.InvoiceStatusDraft {
+			return fmt.Errorf("DeleteInvoiceCommand: Could not find a draft invoice with this id: %s", s.id)
+		}
+		err = tx.Invoices().Delete(s.id)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.InvoiceDeleted, invoice.CustomerID, s.id, invoice))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteInvoiceCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type CreateCreditNoteCommand struct {
+	store     repository.Store
+	logger    *zerolog.Logger
+	invoiceId string
+	reason    string
+	lines     []models.PriceLine
+}
+
+func NewCreateCreditNoteCommand(store repository.Store, logger *zerolog.Logger, invoiceId, reason string, lines []models.PriceLine) *CreateCreditNoteCommand {
+	return &CreateCreditNoteCommand{store: store, logger: logger, invoiceId: invoiceId, reason: reason, lines: lines}
+}
+
+// Execute issues a credit note for the given lines of an issued invoice. The
+// credit notes of an invoice can never credit more than its subtotal.
+func (s *CreateCreditNoteCommand) Execute() (string, error) {
+	if s.invoiceId == "" || s.reason == "" || len(s.lines) == 0 {
+		return "", errors.New("CreateCreditNoteCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateCreditNoteCommand: Started")
+
+	creditNote := models.Invoice{
+		Kind:              models.InvoiceKindCreditNote,
+		Status:            models.InvoiceStatusDraft,
+		CorrectsInvoiceID: &s.invoiceId,
+		Reason:            s.reason,
+	}
+	var credited int64
+	for _, line := range s.lines {
+		if line.Quantity <= 0 || line.UnitAmount <= 0 {
+			return "", errors.New("CreateCreditNoteCommand: Credited lines need a positive quantity and unit amount")
+		}
+		line.Amount = -line.Quantity * line.UnitAmount
+		line.UnitAmount = -line.UnitAmount
+		credited -= line.Amount
+		creditNote.Lines = append(creditNote.Lines, line)
+	}
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		invoice, err := tx.Invoices().GetForUpdate(s.invoiceId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("CreateCreditNoteCommand: Could not find the invoice with this id: %s", s.invoiceId)
+			}
+			return err
+		}
+		if invoice.Kind != models.InvoiceKindInvoice || !invoice.IsIssued() {
+			return fmt.Errorf("CreateCreditNoteCommand: Only issued invoices can be credited, %s is a %s %s", s.invoiceId, invoice.Status, invoice.Kind)
+		}
+
+		alreadyCredited, err := tx.Invoices().Credited(invoice.ID)
+		if err != nil {
+			return err
+		}
+		if alreadyCredited+credited > invoice.Subtotal {
+			return fmt.Errorf("CreateCreditNoteCommand: Crediting %d would exceed the %d left on invoice %s", credited, invoice.Subtotal-alreadyCredited, invoice.ID)
+		}
+
+		creditNote.CustomerID = invoice.CustomerID
+		creditNote.Period = invoice.Period
+		creditNote.Currency = invoice.Currency
+		creditNote.TaxRateBasisPoints = invoice.TaxRateBasisPoints
+		billing.ApplyTotals(&creditNote)
+
+		err = tx.Invoices().Create(&creditNote)
+		if err != nil {
+			return err
+		}
+		return issueInvoice(tx, &creditNote, time.Now())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateCreditNoteCommand: Finished with success")
+
+	return creditNote.ID, nil
+}
+
+// CloseBillingPeriodCommand invoices every customer for the month before now
+// and issues the invoices. Customers that were already invoiced for that month
+// or only signed up after it are skipped, so running it again is harmless. A
+// customer that can not be invoiced does not keep the others from being
+// invoiced, the error names every one of them. It returns the number of
+// issued invoices.
+type CloseBillingPeriodCommand struct {
+	store   repository.Store
+	logger  *zerolog.Logger
+	taxRate int
+	now     time.Time
+}
+
+func NewCloseBillingPeriodCommand(store repository.Store, logger *zerolog.Logger, taxRate int, now time.Time) *CloseBillingPeriodCommand {
+	return &CloseBillingPeriodCommand{store: store, logger: logger, taxRate: taxRate, now: now}
+}
+
+func (s *CloseBillingPeriodCommand) Execute() (string, error) {
+	s.logger.Debug().Msg("CloseBillingPeriodCommand: Started")
+
+	now := s.now.UTC()
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	period := end.AddDate(0, -1, 0).Format(models.UsagePeriodFormat)
+
+	customerIds, err := s.store.Invoices().Uninvoiced(period, end)
+	if err != nil {
+		return "", err
+	}
+
+	issued := 0
+	var failures []error
+	for _, customerId := range customerIds {
+		err := s.invoice(customerId, period)
+		if err != nil {
+			s.logger.Error().Err(err).Msgf("CloseBillingPeriodCommand: Could not invoice customer %s for %s", customerId, period)
+			failures = append(failures, fmt.Errorf("customer %s: %w", customerId, err))
+			continue
+		}
+		issued++
+	}
+	if len(failures) > 0 {
+		return fmt.Sprint(issued), fmt.Errorf("CloseBillingPeriodCommand: Could not invoice %d of %d customers for %s: %w", len(failures), len(customerIds), period, errors.Join(failures...))
+	}
+
+	s.logger.Debug().Msg("CloseBillingPeriodCommand: Finished with success")
+
+	return fmt.Sprint(issued), nil
+}
+
+func (s *CloseBillingPeriodCommand) invoice(customerId, period string) error {
+	invoice, err := generateInvoice(s.store, "CloseBillingPeriodCommand", customerId, period, s.taxRate)
+	if err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx repository.Store) error {
+		return issueInvoice(tx, &invoice, s.now)
+	})
+}
@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// billedPlan charges 1000 a month, 200 per source and 50 for every
+// reservation beyond the first.
+var billedPlan = models.Plan{Name: "billed", MaxSources: 5, Currency: "EUR", MonthlyPriceMinor: 1000, SourcePriceMinor: 200, IncludedReservations: 1, OveragePriceMinor: 50}
+
+func invoiceOf(t *testing.T, f *repotest.Fixture, id string) models.Invoice {
+	t.Helper()
+	var invoice models.Invoice
+	if err := f.DB.First(&invoice, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return invoice
+}
+
+func TestNextInvoiceNumber(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		next := func(prefix string) string {
+			var number string
+			err := f.Transaction(func(tx repository.Store) error {
+				var err error
+				number, err = nextInvoiceNumber(tx, prefix)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return number
+		}
+
+		for _, want := range []string{"INV-2026-000001", "INV-2026-000002"} {
+			if number := next("INV-2026"); number != want {
+				t.Errorf("numbered %s, want %s", number, want)
+			}
+		}
+		if number := next("CN-2026"); number != "CN-2026-000001" {
+			t.Errorf("numbered %s, want the first number of another prefix", number)
+		}
+
+		// A number handed out in a transaction that is rolled back is handed
+		// out again, so that issued numbers have no gaps.
+		rollback := errors.New("rollback")
+		err := f.Transaction(func(tx repository.Store) error {
+			if _, err := nextInvoiceNumber(tx, "INV-2026"); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatal(err)
+		}
+		if number := next("INV-2026"); number != "INV-2026-000003" {
+			t.Errorf("numbered %s after a rollback, want INV-2026-000003", number)
+		}
+	})
+}
+
+func TestInvoiceCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &billedPlan)
+		room := f.Source(t, customer, "room")
+		setCreatedAt(t, f, &room, time.Date(2026, time.August, 1, 0, 0, 0, 0, time.UTC))
+		if _, err := RecordUsage(f.DB, customer.ID, models.UsageMetricReservations, time.Date(2026, time.September, 10, 0, 0, 0, 0, time.UTC), 3); err != nil {
+			t.Fatal(err)
+		}
+		year := time.Now().UTC().Year()
+
+		if _, err := NewGenerateInvoiceCommand(f, f.Logger, 1900, customer.ID, "September").Execute(); err == nil {
+			t.Error("generated an invoice for a period that is not a month")
+		}
+		if _, err := NewGenerateInvoiceCommand(f, f.Logger, 1900, customer.ID, "2026-09").Execute(); err != nil {
+			t.Fatal(err)
+		}
+		// Generating again replaces the draft.
+		id, err := NewGenerateInvoiceCommand(f, f.Logger, 1900, customer.ID, "2026-09").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var drafts int64
+		if err = f.DB.Model(&models.Invoice{}).Where("customer_id = ?", customer.ID).Count(&drafts).Error; err != nil {
+			t.Fatal(err)
+		}
+		invoice := invoiceOf(t, f, id)
+		if drafts != 1 || invoice.Status != models.InvoiceStatusDraft || invoice.Number != nil || len(invoice.Lines) != 3 ||
+			invoice.Subtotal != 1300 || invoice.TaxAmount != 247 || invoice.Total != 1547 {
+			t.Errorf("drafted %d invoices, the last %+v", drafts, invoice)
+		}
+		if _, err = NewCreateCreditNoteCommand(f, f.Logger, id, "goodwill", []models.PriceLine{{Description: "Plan", Quantity: 1, Unit: "month", UnitAmount: 100}}).Execute(); err == nil {
+			t.Error("credited a draft")
+		}
+
+		if _, err = NewIssueInvoiceCommand(f, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		invoice = invoiceOf(t, f, id)
+		if want := fmt.Sprintf("INV-%d-000001", year); invoice.Number == nil || *invoice.Number != want || invoice.IssuedAt == nil {
+			t.Errorf("issued %+v, want number %s", invoice, want)
+		}
+
+		// Issued invoices never change, they are corrected with credit notes.
+		if _, err = NewIssueInvoiceCommand(f, f.Logger, id).Execute(); err == nil {
+			t.Error("issued an invoice twice")
+		}
+		if _, err = NewGenerateInvoiceCommand(f, f.Logger, 1900, customer.ID, "2026-09").Execute(); err == nil {
+			t.Error("generated an invoice for a period that was invoiced")
+		}
+		if _, err = NewDeleteInvoiceCommand(f, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted an issued invoice")
+		}
+		if issued := invoiceOf(t, f, id); issued.Total != 1547 || *issued.Number != *invoice.Number {
+			t.Errorf("issued invoice changed into %+v", issued)
+		}
+
+		creditNoteId, err := NewCreateCreditNoteCommand(f, f.Logger, id, "goodwill", []models.PriceLine{{Description: "Plan", Quantity: 1, Unit: "month", UnitAmount: 1000}}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		creditNote := invoiceOf(t, f, creditNoteId)
+		if want := fmt.Sprintf("CN-%d-000001", year); creditNote.Kind != models.InvoiceKindCreditNote || creditNote.Number == nil || *creditNote.Number != want ||
+			creditNote.Subtotal != -1000 || creditNote.TaxAmount != -190 || creditNote.Total != -1190 || *creditNote.CorrectsInvoiceID != id {
+			t.Errorf("issued %+v, want number %s", creditNote, want)
+		}
+		if _, err = NewCreateCreditNoteCommand(f, f.Logger, id, "goodwill", []models.PriceLine{{Description: "Plan", Quantity: 4, Unit: "month", UnitAmount: 100}}).Execute(); err == nil {
+			t.Error("credited more than is left on the invoice")
+		}
+		if _, err = NewCreateCreditNoteCommand(f, f.Logger, creditNoteId, "goodwill", []models.PriceLine{{Description: "Plan", Quantity: 1, Unit: "month", UnitAmount: 100}}).Execute(); err == nil {
+			t.Error("credited a credit note")
+		}
+
+		other := f.Customer(t, "bob", nil)
+		draftId, err := NewGenerateInvoiceCommand(f, f.Logger, 1900, other.ID, "2026-09").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteInvoiceCommand(f, f.Logger, draftId).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			events.InvoiceDrafted, events.InvoiceDrafted, events.InvoiceIssued, events.CreditNoteIssued,
+			events.InvoiceDrafted, events.InvoiceDeleted,
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
+
+// setCreatedAt backdates or postdates a record the fixture created now.
+func setCreatedAt(t *testing.T, f *repotest.Fixture, record any, at time.Time) {
+	t.Helper()
+	if err := f.DB.Model(record).Update("created_at", at).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateInvoiceCommandBillsThePeriod(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		august := time.Date(2026, time.August, 20, 0, 0, 0, 0, time.UTC)
+		september := time.Date(2026, time.September, 10, 0, 0, 0, 0, time.UTC)
+		customer := f.Customer(t, "alice", &billedPlan)
+
+		room := f.Source(t, customer, "room")
+		setCreatedAt(t, f, &room, august)
+		for _, reservation := range []models.Reservation{
+			{SourceID: room.ID, Status: models.ReservationStatusConfirmed},
+			{SourceID: room.ID, Status: models.ReservationStatusCancelled},
+			{SourceID: room.ID, Status: models.ReservationStatusRejected},
+			{SourceID: room.ID, Status: models.ReservationStatusExpired},
+		} {
+			reservation.From, reservation.To = repotest.At(9), repotest.At(10)
+			f.Add(t, &reservation)
+			setCreatedAt(t, f, &reservation, september)
+		}
+		// Sources created after the period are not billed for it, deleted ones
+		// are as long as they existed during it.
+		f.Source(t, customer, "opened in october")
+		gone := f.Source(t, customer, "gone")
+		setCreatedAt(t, f, &gone, august)
+		if _, err := NewDeleteSourceCommand(f.Store, f.Logger, gone.ID).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		f.Add(t, &models.DeletedSource{ID: models.NewUUID(), CustomerID: customer.ID, Name: "gone before", CreatedAt: august.AddDate(0, -1, 0), DeletedAt: august})
+
+		id, err := NewGenerateInvoiceCommand(f, f.Logger, 0, customer.ID, "2026-09").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var descriptions []string
+		for _, line := range invoiceOf(t, f, id).Lines {
+			descriptions = append(descriptions, line.Description)
+		}
+		want := []string{"Plan billed", "Source gone (0 reservations)", "Source room (1 reservations)"}
+		if !slices.Equal(descriptions, want) {
+			t.Errorf("billed %v, want %v", descriptions, want)
+		}
+	})
+}
+
+func TestIssueInvoiceCommandRefusesASecondInvoiceOfThePeriod(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &billedPlan)
+		id, err := NewGenerateInvoiceCommand(f, f.Logger, 0, customer.ID, "2026-09").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		second := models.Invoice{CustomerID: customer.ID, Kind: models.InvoiceKindInvoice, Status: models.InvoiceStatusDraft, Period: "2026-09", Currency: "EUR"}
+		f.Add(t, &second)
+
+		if _, err = NewIssueInvoiceCommand(f, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewIssueInvoiceCommand(f, f.Logger, second.ID).Execute(); err == nil {
+			t.Error("issued a second invoice for the period")
+		}
+		if invoice := invoiceOf(t, f, second.ID); invoice.IsIssued() || invoice.Number != nil {
+			t.Errorf("second invoice is %+v", invoice)
+		}
+
+		// The database refuses one issued concurrently.
+		number := "INV-2026-999999"
+		duplicate := models.Invoice{CustomerID: customer.ID, Kind: models.InvoiceKindInvoice, Status: models.InvoiceStatusIssued, Number: &number, Period: "2026-09"}
+		if err = f.DB.Create(&duplicate).Error; err == nil {
+			t.Error("stored a second issued invoice for the period")
+		}
+	})
+}
+
+func TestCloseBillingPeriodCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		broken := f.Customer(t, "carol", nil)
+		invoiced := f.Customer(t, "alice", &billedPlan)
+		open := f.Customer(t, "bob", nil)
+		late := f.Customer(t, "dave", nil)
+		for i, customer := range []*models.Customer{&broken, &invoiced, &open} {
+			setCreatedAt(t, f, customer, time.Date(2026, time.August, 1+i, 0, 0, 0, 0, time.UTC))
+		}
+		setCreatedAt(t, f, &late, time.Date(2026, time.October, 2, 0, 0, 0, 0, time.UTC))
+		// The plan of carol does not exist, so she can not be invoiced.
+		if err := f.DB.Model(&broken).Update("plan_id", models.NewUUID()).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := NewGenerateInvoiceCommand(f, f.Logger, 0, invoiced.ID, "2026-09").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewIssueInvoiceCommand(f, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The customer that fails does not keep the next one from being
+		// invoiced, and is reported.
+		count, err := NewCloseBillingPeriodCommand(f, f.Logger, 1900, now).Execute()
+		if err == nil || !strings.Contains(err.Error(), broken.ID) || strings.Contains(err.Error(), open.ID) {
+			t.Errorf("closed the period with %v, want carol reported", err)
+		}
+		if count != "1" {
+			t.Errorf("issued %s invoices, want 1", count)
+		}
+
+		// Once fixed the customer is invoiced, and closing the period again
+		// invoices nobody. The customer that signed up in October never is.
+		if err = f.DB.Model(&broken).Update("plan_id", nil).Error; err != nil {
+			t.Fatal(err)
+		}
+		for run, want := range []string{"1", "0"} {
+			count, err := NewCloseBillingPeriodCommand(f, f.Logger, 1900, now).Execute()
+			if err != nil {
+				t.Fatalf("run %d: %v", run, err)
+			}
+			if count != want {
+				t.Errorf("run %d: issued %s invoices, want %s", run, count, want)
+			}
+		}
+		var lateInvoices int64
+		if err = f.DB.Model(&models.Invoice{}).Where("customer_id = ?", late.ID).Count(&lateInvoices).Error; err != nil {
+			t.Fatal(err)
+		}
+		if lateInvoices != 0 {
+			t.Errorf("invoiced the customer that signed up after the period %d times", lateInvoices)
+		}
+
+		var issued models.Invoice
+		if err = f.DB.First(&issued, "customer_id = ? AND status = ?", open.ID, models.InvoiceStatusIssued).Error; err != nil {
+			t.Fatal(err)
+		}
+		if issued.Period != "2026-09" || issued.TaxRateBasisPoints != 1900 || issued.Number == nil || !strings.HasPrefix(*issued.Number, "INV-2026-") {
+			t.Errorf("issued %+v", issued)
+		}
+	})
+}
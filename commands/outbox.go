@@ -0,0 +1,169 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/database"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/streams"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxRelayLock is the advisory lock key held by the relaying engine, only
+// one engine relays at a time so that the order of the events is kept.
+const outboxRelayLock = 7_036_037
+
+// TxSink is a sink that writes to the database. The relay publishes to it in
+// its own transaction, so that what the sink writes commits together with the
+// published mark. Writing through another connection would wait for the
+// relay to commit on SQLite, which only has one writer.
+type TxSink interface {
+	events.Sink
+	PublishTx(tx *gorm.DB, event events.Event) error
+}
+
+// RelayOutboxCommand publishes the unpublished events of the outbox to every
+// sink, oldest first. An event is marked published only once all sinks took
+// it; when a sink fails the later events of the same aggregate wait for the
+// next run, so sinks see the events of an aggregate in order. It returns the
+// number of published events. It stops at the next event once the context
+// is done.
+type RelayOutboxCommand struct {
+	ctx    context.Context
+	db     *gorm.DB
+	logger *zerolog.Logger
+	sinks  []events.Sink
+	batch  int
+}
+
+func NewRelayOutboxCommand(ctx context.Context, db *gorm.DB, logger *zerolog.Logger, sinks []events.Sink, batch int) *RelayOutboxCommand {
+	return &RelayOutboxCommand{ctx: ctx, db: db, logger: logger, sinks: sinks, batch: batch}
+}
+
+func (s *RelayOutboxCommand) Execute() (string, error) {
+	if s.batch <= 0 {
+		return "", errors.New("RelayOutboxCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("RelayOutboxCommand: Started")
+
+	published := 0
+	err := s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		// A SQLite transaction already holds the whole database.
+		if database.IsPostgres(tx) {
+			var locked bool
+			res := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxRelayLock).Scan(&locked)
+			if res.Error != nil {
+				return res.Error
+			}
+			if !locked {
+				return nil
+			}
+		}
+
+		var pending []models.OutboxEvent
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("published_at IS NULL").
+			Order("position").
+			Limit(s.batch).
+			Find(&pending)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// The relay holds the lock until it commits, so published positions
+		// grow in the order the events become visible.
+		var position int64
+		res = tx.Model(models.OutboxEvent{}).Select("COALESCE(MAX(published_position), 0)").Scan(&position)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		held := make(map[string]bool)
+		customerIds := make(map[string]bool)
+		for _, row := range pending {
+			if s.ctx.Err() != nil {
+				break
+			}
+			if held[row.AggregateID] {
+				continue
+			}
+
+			// What the sinks wrote for an event they did not all take is
+			// rolled back with the savepoint.
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return s.relay(tx, row)
+			})
+			if err != nil {
+				held[row.AggregateID] = true
+				res = tx.Model(&row).Updates(map[string]any{"attempts": row.Attempts + 1, "last_error": err.Error()})
+			} else {
+				position++
+				res = tx.Model(&row).Updates(map[string]any{"attempts": row.Attempts + 1, "last_error": "", "published_at": time.Now(), "published_position": position})
+				customerIds[row.CustomerID] = true
+				published++
+			}
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return notifyStreams(tx, customerIds)
+	})
+	if err != nil {
+		return fmt.Sprint(published), err
+	}
+
+	s.logger.Debug().Msg("RelayOutboxCommand: Finished with success")
+
+	return fmt.Sprint(published), nil
+}
+
+// notifyStreams tells the streams of every engine which customers have new
+// events. Postgres delivers the notification once the relay commits, without
+// it the hubs find the events by polling.
+func notifyStreams(tx *gorm.DB, customerIds map[string]bool) error {
+	if len(customerIds) == 0 || !database.IsPostgres(tx) {
+		return nil
+	}
+	ids := make([]string, 0, len(customerIds))
+	for id := range customerIds {
+		ids = append(ids, id)
+	}
+	payload, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", streams.Channel, string(payload)).Error
+}
+
+func (s *RelayOutboxCommand) relay(tx *gorm.DB, row models.OutboxEvent) error {
+	event := events.Event{
+		ID:          row.ID,
+		Type:        row.Type,
+		CustomerID:  row.CustomerID,
+		AggregateID: row.AggregateID,
+		OccurredAt:  row.OccurredAt,
+		Payload:     json.RawMessage(row.Payload),
+	}
+	for _, sink := range s.sinks {
+		var err error
+		if txSink, ok := sink.(TxSink); ok {
+			err = txSink.PublishTx(tx, event)
+		} else {
+			err = sink.Publish(event)
+		}
+		if err != nil {
+			return fmt.Errorf("RelayOutboxCommand: sink %s failed on event %s: %w", sink.Name(), row.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func validateParticipantRole(caller, role string) error {
+	switch role {
+	case models.ParticipantRoleOrganizer, models.ParticipantRoleRequired, models.ParticipantRoleOptional:
+		return nil
+	}
+	return fmt.Errorf("%s: Participant role %q is not one of organizer, required or optional", caller, role)
+}
+
+func validateRSVP(caller, rsvp string) error {
+	switch rsvp {
+	case models.RSVPPending, models.RSVPAccepted, models.RSVPTentative, models.RSVPDeclined:
+		return nil
+	}
+	return fmt.Errorf("%s: RSVP %q is not one of pending, accepted, tentative or declined", caller, rsvp)
+}
+
+// newParticipants resolves the requested participants against the persons of
+// the customer. Every participant starts with a pending RSVP.
+func newParticipants(store repository.Store, caller, customerId string, requested []models.ReservationParticipant) ([]models.Participant, error) {
+	participants := make([]models.Participant, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	organizers := 0
+	for _, r := range requested {
+		role := r.Role
+		if role == "" {
+			role = models.ParticipantRoleRequired
+		}
+		if err := validateParticipantRole(caller, role); err != nil {
+			return nil, err
+		}
+		if role == models.ParticipantRoleOrganizer {
+			organizers++
+		}
+
+		person, err := resolvePerson(store, caller, customerId, r.PersonID)
+		if err != nil {
+			return nil, err
+		}
+		if seen[person.ID] {
+			return nil, fmt.Errorf("%s: Person %s is given more than once as participant", caller, person.ID)
+		}
+		seen[person.ID] = true
+
+		participants = append(participants, models.Participant{PersonID: person.ID, Role: role, RSVP: models.RSVPPending})
+	}
+
+	if organizers > 1 {
+		return nil, fmt.Errorf("%s: A reservation can have only one organizer", caller)
+	}
+
+	return participants, nil
+}
+
+type CreateParticipantCommand struct {
+	db            *gorm.DB
+	logger        *zerolog.Logger
+	reservationId string
+	personId      string
+	role          string
+}
+
+func NewCreateParticipantCommand(db *gorm.DB, logger *zerolog.Logger, reservationId, personId, role string) *CreateParticipantCommand {
+	return &CreateParticipantCommand{db: db, logger: logger, reservationId: reservationId, personId: personId, role: role}
+}
+
+func (s *CreateParticipantCommand) Execute() (string, error) {
+	if s.reservationId == "" || s.personId == "" {
+		return "", errors.New("CreateParticipantCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateParticipantCommand: Started")
+
+	var participant models.Participant
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var reservation models.Reservation
+		res := tx.Preload("Participants").First(&reservation, "id = ?", s.reservationId)
+		if res.Error != nil {
+			if res.Error == gorm.ErrRecordNotFound {
+				return fmt.Errorf("CreateParticipantCommand: Could not find the reservation with this id: %s", s.reservationId)
+			}
+			return res.Error
+		}
+		if reservation.IsReleased() {
+			return fmt.Errorf("CreateParticipantCommand: Reservation %s is %s and takes no participants", reservation.ID, reservation.Status)
+		}
+
+		// The source and, by the overlap check, the participant are locked
+		// so that neither is booked between the check and the insert.
+		var source models.Source
+		res = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&source, "id = ?", reservation.SourceID)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		requested := make([]models.ReservationParticipant, 0, len(reservation.Participants)+1)
+		for _, participant := range reservation.Participants {
+			requested = append(requested, models.ReservationParticipant{PersonID: participant.PersonID, Role: participant.Role})
+		}
+		requested = append(requested, models.ReservationParticipant{PersonID: s.personId, Role: s.role})
+
+		participants, err := newParticipants(gormstore.New(tx), "CreateParticipantCommand", source.CustomerID, requested)
+		if err != nil {
+			return err
+		}
+		participant = participants[len(participants)-1]
+		participant.ReservationID = reservation.ID
+
+		err = checkReservationPossible(gormstore.New(tx), "CreateParticipantCommand", source, reservation.From, reservation.To, []string{participant.PersonID}, []string{reservation.ID})
+		if err != nil {
+			return err
+		}
+
+		res = tx.Create(&participant)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.ParticipantCreated, source.CustomerID, participant.ID, participant)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateParticipantCommand: Finished with success")
+
+	return participant.ID, nil
+}
+
+type DeleteParticipantCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeleteParticipantCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteParticipantCommand {
+	return &DeleteParticipantCommand{db: db, logger: logger, id: id}
+}
+
+func (s *DeleteParticipantCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeleteParticipantCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteParticipantCommand: Started")
+
+	var participant models.Participant
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Returning{}).Delete(&participant, "id = ?", s.id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("DeleteParticipantCommand: Could not find the participant with this id: %s", s.id)
+		}
+		return record(tx, events.ParticipantDeleted, reservationCustomerId(tx, participant.ReservationID), s.id, participant)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteParticipantCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdateParticipantCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+	role   *string
+	rsvp   *string
+}
+
+func NewUpdateParticipantCommand(db *gorm.DB, logger *zerolog.Logger, id string, role, rsvp *string) *UpdateParticipantCommand {
+	return &UpdateParticipantCommand{db: db, logger: logger, id: id, role: role, rsvp: rsvp}
+}
+
+func (s *UpdateParticipantCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdateParticipantCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdateParticipantCommand: Started")
+
+	var participant models.Participant
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.First(&participant, "id = ?", s.id)
+		if res.Error != nil {
+			if res.Error == gorm.ErrRecordNotFound {
+				return fmt.Errorf("UpdateParticipantCommand: Could not find the participant with this id: %s", s.id)
+			}
+			return res.Error
+		}
+
+		if s.role != nil && *s.role != "" && *s.role != participant.Role {
+			if err := validateParticipantRole("UpdateParticipantCommand", *s.role); err != nil {
+				return err
+			}
+			if *s.role == models.ParticipantRoleOrganizer {
+				var countOfOrganizers int64
+				res = tx.Model(&models.Participant{}).
+					Where("reservation_id = ? AND role = ?", participant.ReservationID, models.ParticipantRoleOrganizer).
+					Count(&countOfOrganizers)
+				if res.Error != nil {
+					return res.Error
+				}
+				if countOfOrganizers > 0 {
+					return errors.New("UpdateParticipantCommand: A reservation can have only one organizer")
+				}
+			}
+			participant.Role = *s.role
+		}
+
+		if s.rsvp != nil && *s.rsvp != "" && *s.rsvp != participant.RSVP {
+			if err := validateRSVP("UpdateParticipantCommand", *s.rsvp); err != nil {
+				return err
+			}
+
+			// Somebody who declined may have been booked elsewhere in the
+			// meantime, so coming back has to pass the overlap check again.
+			if participant.RSVP == models.RSVPDeclined {
+				var reservation models.Reservation
+				res = tx.First(&reservation, "id = ?", participant.ReservationID)
+				if res.Error != nil {
+					return res.Error
+				}
+				var source models.Source
+				res = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&source, "id = ?", reservation.SourceID)
+				if res.Error != nil {
+					return res.Error
+				}
+				err := checkReservationPossible(gormstore.New(tx), "UpdateParticipantCommand", source, reservation.From, reservation.To, []string{participant.PersonID}, []string{reservation.ID})
+				if err != nil {
+					return err
+				}
+			}
+			participant.RSVP = *s.rsvp
+		}
+
+		res = tx.Save(&participant)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.ParticipantUpdated, reservationCustomerId(tx, participant.ReservationID), participant.ID, participant)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdateParticipantCommand: Finished with success")
+
+	return s.id, nil
+}
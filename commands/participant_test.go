@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestParticipantCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room, hall := f.Source(t, customer, "room"), f.Source(t, customer, "hall")
+		alice, bob, carol := f.Person(t, customer, "alice"), f.Person(t, customer, "bob"), f.Person(t, customer, "carol")
+
+		meeting := models.Reservation{From: repotest.At(1), To: repotest.At(2), ReserverID: alice.ID, ReserveeID: alice.ID, SourceID: room.ID}
+		talk := models.Reservation{From: repotest.At(1), To: repotest.At(2), ReserverID: bob.ID, ReserveeID: bob.ID, SourceID: hall.ID}
+		cancelled := models.Reservation{From: repotest.At(3), To: repotest.At(4), ReserverID: alice.ID, ReserveeID: alice.ID, SourceID: room.ID, Status: models.ReservationStatusCancelled}
+		f.Add(t, &meeting, &talk, &cancelled)
+
+		id, err := NewCreateParticipantCommand(f.DB, f.Logger, meeting.ID, "carol", models.ParticipantRoleRequired).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewCreateParticipantCommand(f.DB, f.Logger, meeting.ID, "carol", "").Execute(); err == nil {
+			t.Error("added a participant twice")
+		}
+		if _, err = NewCreateParticipantCommand(f.DB, f.Logger, talk.ID, "carol", "").Execute(); err == nil {
+			t.Error("added a participant to a reservation that overlaps one of theirs")
+		}
+		if _, err = NewCreateParticipantCommand(f.DB, f.Logger, cancelled.ID, "bob", "").Execute(); err == nil {
+			t.Error("added a participant to a cancelled reservation")
+		}
+		if _, err = NewCreateParticipantCommand(f.DB, f.Logger, meeting.ID, "bob", "").Execute(); err == nil {
+			t.Error("added a participant who is booked elsewhere at the time")
+		}
+
+		declined := models.RSVPDeclined
+		if _, err = NewUpdateParticipantCommand(f.DB, f.Logger, id, nil, &declined).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		accepted := models.RSVPAccepted
+		if _, err = NewUpdateParticipantCommand(f.DB, f.Logger, id, nil, &accepted).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var participant models.Participant
+		if err = f.DB.First(&participant, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if participant.PersonID != carol.ID || participant.RSVP != models.RSVPAccepted {
+			t.Errorf("stored %+v", participant)
+		}
+
+		if _, err = NewDeleteParticipantCommand(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("deleted a participant that does not exist")
+		}
+		if _, err = NewDeleteParticipantCommand(f.DB, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteParticipantCommand(f.DB, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a participant twice")
+		}
+
+		want := []string{events.ParticipantCreated, events.ParticipantUpdated, events.ParticipantUpdated, events.ParticipantDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// needsPayment tells whether a new reservation has to be paid before it holds
+// its slot, and checks that it can be.
+func needsPayment(caller string, provider payments.PaymentProvider, source models.Source, reservation models.Reservation, paymentMethod string) (bool, error) {
+	if !source.RequiresPayment || reservation.TotalAmount == 0 {
+		return false, nil
+	}
+	if provider == nil {
+		return false, fmt.Errorf("%s: Source %s requires payment but no payment provider is configured", caller, source.ID)
+	}
+	if paymentMethod == "" {
+		return false, fmt.Errorf("%s: Source %s requires payment and no payment method was given", caller, source.ID)
+	}
+	return true, nil
+}
+
+// payReservation charges a reservation that was stored as pending payment.
+// Once the payment is captured the reservation moves into paidStatus, when it
+// is declined the reservation releases its slot. A payment the provider still
+// has to decide leaves the reservation pending until its webhook arrives.
+func payReservation(store repository.Store, provider payments.PaymentProvider, caller string, reservation *models.Reservation, paidStatus, paymentMethod string) error {
+	payment := models.Payment{
+		ReservationID: reservation.ID,
+		Provider:      provider.Name(),
+		Status:        models.PaymentStatusPending,
+		Amount:        reservation.TotalAmount,
+		Currency:      reservation.Currency,
+	}
+
+	authorization, err := provider.Authorize(reservation.ID, reservation.TotalAmount, reservation.Currency, paymentMethod)
+	if err == nil && authorization.Status == payments.AuthorizationDeclined {
+		err = errors.New(authorization.DeclineReason)
+	}
+	payment.ProviderPaymentID = authorization.PaymentID
+	if err == nil && authorization.Status == payments.AuthorizationAuthorized {
+		err = provider.Capture(authorization.PaymentID, reservation.TotalAmount)
+		if err == nil {
+			payment.Status = models.PaymentStatusCaptured
+			reservation.Status = paidStatus
+		}
+	}
+	if err != nil {
+		payment.Status = models.PaymentStatusFailed
+		payment.FailureReason = err.Error()
+		reservation.Status = models.ReservationStatusPaymentFailed
+	}
+
+	saveErr := store.Transaction(func(tx repository.Store) error {
+		err := tx.Reservations().AddPayment(&payment)
+		if err != nil {
+			return err
+		}
+		if reservation.Status == models.ReservationStatusPendingPayment {
+			return nil
+		}
+		reservation.Sequence++
+		err = tx.Reservations().Save(reservation)
+		if err != nil {
+			return err
+		}
+		switch reservation.Status {
+		case models.ReservationStatusPaymentFailed:
+			return tx.Record(events.NewEvent(events.ReservationPaymentFailed, sourceCustomerId(tx, reservation.SourceID), reservation.ID, *reservation))
+		default:
+			return tx.Record(events.NewEvent(events.ReservationPaid, sourceCustomerId(tx, reservation.SourceID), reservation.ID, *reservation))
+		}
+	})
+	if saveErr != nil {
+		return saveErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: Payment of reservation %s failed: %w", caller, reservation.ID, err)
+	}
+	return nil
+}
+
+// refundReservation gives back what was paid for a released reservation minus
+// the cancellation fees charged on it.
+func refundReservation(store repository.Store, provider payments.PaymentProvider, caller string, reservation models.Reservation) error {
+	captured, err := store.Reservations().Payments(reservation.ID, []string{models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded})
+	if err != nil {
+		return err
+	}
+	if len(captured) == 0 {
+		return nil
+	}
+	if provider == nil {
+		return fmt.Errorf("%s: Reservation %s was paid but no payment provider is configured to refund it", caller, reservation.ID)
+	}
+
+	fees, err := store.Reservations().FeesTotal(reservation.ID, models.FeeKindCancellation)
+	if err != nil {
+		return err
+	}
+
+	for _, payment := range captured {
+		// Fees are kept from the first payments before anything is refunded.
+		kept := min(fees, payment.Amount-payment.RefundedAmount)
+		fees -= kept
+		amount := payment.Amount - payment.RefundedAmount - kept
+		if amount <= 0 {
+			continue
+		}
+
+		err := provider.Refund(payment.ProviderPaymentID, amount)
+		if err != nil {
+			return fmt.Errorf("%s: Reservation %s was released but its payment could not be refunded: %w", caller, reservation.ID, err)
+		}
+
+		payment.RefundedAmount += amount
+		payment.Status = refundedStatus(payment)
+		err = store.Reservations().SavePayment(&payment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func refundedStatus(payment models.Payment) string {
+	if payment.RefundedAmount >= payment.Amount {
+		return models.PaymentStatusRefunded
+	}
+	if payment.RefundedAmount > 0 {
+		return models.PaymentStatusPartiallyRefunded
+	}
+	return models.PaymentStatusCaptured
+}
+
+// HandlePaymentWebhookCommand applies a notification of the payment provider.
+// The signature is verified first, and an event that was handled before is
+// acknowledged without doing anything.
+type HandlePaymentWebhookCommand struct {
+	db        *gorm.DB
+	logger    *zerolog.Logger
+	provider  payments.PaymentProvider
+	payload   []byte
+	header    map[string][]string
+	duplicate bool
+}
+
+func NewHandlePaymentWebhookCommand(db *gorm.DB, logger *zerolog.Logger, provider payments.PaymentProvider, payload []byte, header map[string][]string) *HandlePaymentWebhookCommand {
+	return &HandlePaymentWebhookCommand{db: db, logger: logger, provider: provider, payload: payload, header: header}
+}
+
+// Duplicate tells whether Execute recognised the event as already handled.
+func (s *HandlePaymentWebhookCommand) Duplicate() bool {
+	return s.duplicate
+}
+
+func (s *HandlePaymentWebhookCommand) Execute() (string, error) {
+	if s.provider == nil {
+		return "", errors.New("HandlePaymentWebhookCommand: No payment provider is configured")
+	}
+	s.logger.Debug().Msg("HandlePaymentWebhookCommand: Started")
+
+	event, err := s.provider.VerifyWebhook(s.payload, s.header)
+	if err != nil {
+		return "", fmt.Errorf("HandlePaymentWebhookCommand: %w", err)
+	}
+
+	var released *models.Reservation
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		received := models.PaymentWebhookEvent{
+			Provider:          s.provider.Name(),
+			EventID:           event.ID,
+			Type:              event.Type,
+			ProviderPaymentID: event.PaymentID,
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&received)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			s.duplicate = true
+			return nil
+		}
+
+		var payment models.Payment
+		res = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_payment_id = ?", s.provider.Name(), event.PaymentID).
+			First(&payment)
+		if res.Error != nil {
+			if res.Error == gorm.ErrRecordNotFound {
+				return fmt.Errorf("HandlePaymentWebhookCommand: Could not find the payment with this provider id: %s", event.PaymentID)
+			}
+			return res.Error
+		}
+
+		var reservation models.Reservation
+		res = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", payment.ReservationID)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		var eventType string
+		status := reservation.Status
+		switch event.Type {
+		case payments.WebhookPaymentCaptured:
+			if payment.Status != models.PaymentStatusPending {
+				return nil
+			}
+			payment.Status = models.PaymentStatusCaptured
+			eventType = events.ReservationPaid
+			if reservation.Status == models.ReservationStatusPendingPayment {
+				var source models.Source
+				res = tx.First(&source, "id = ?", reservation.SourceID)
+				if res.Error != nil {
+					return res.Error
+				}
+				err := applyApprovalRules(source, &reservation)
+				if err != nil {
+					return err
+				}
+			} else if reservation.IsReleased() {
+				// The reservation was cancelled while the payment was still
+				// pending, so the money goes back right away.
+				released = &reservation
+			}
+		case payments.WebhookPaymentFailed:
+			if payment.Status != models.PaymentStatusPending {
+				return nil
+			}
+			payment.Status = models.PaymentStatusFailed
+			payment.FailureReason = "Declined by the payment provider"
+			eventType = events.ReservationPaymentFailed
+			if reservation.Status == models.ReservationStatusPendingPayment {
+				reservation.Status = models.ReservationStatusPaymentFailed
+			}
+		case payments.WebhookRefundSucceeded:
+			if event.Amount > payment.RefundedAmount {
+				payment.RefundedAmount = event.Amount
+				payment.Status = refundedStatus(payment)
+				eventType = events.ReservationUpdated
+			}
+		default:
+			s.logger.Debug().Msgf("HandlePaymentWebhookCommand: Ignoring event of type %s", event.Type)
+			return nil
+		}
+
+		res = tx.Save(&payment)
+		if res.Error != nil {
+			return res.Error
+		}
+		if reservation.Status != status {
+			reservation.Sequence++
+		}
+		res = tx.Save(&reservation)
+		if res.Error != nil {
+			return res.Error
+		}
+		if eventType == "" {
+			return nil
+		}
+		return record(tx, eventType, sourceCustomerId(gormstore.New(tx), reservation.SourceID), reservation.ID, reservation)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if released != nil {
+		err = refundReservation(gormstore.New(s.db), s.provider, "HandlePaymentWebhookCommand", *released)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.logger.Debug().Msg("HandlePaymentWebhookCommand: Finished with success")
+
+	return event.ID, nil
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestHandlePaymentWebhookCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		provider := payments.NewFakeProvider("whsec")
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+
+		// pending adds a reservation in the given status whose payment the
+		// provider has not decided on yet.
+		pending := func(hour int, status string) (models.Reservation, models.Payment) {
+			reservation := models.Reservation{
+				From: repotest.At(hour), To: repotest.At(hour + 1), ReserverID: alice.ID, ReserveeID: alice.ID, SourceID: source.ID,
+				Status: status, Currency: "EUR", TotalAmount: 1000,
+			}
+			f.Add(t, &reservation)
+			authorization, err := provider.Authorize(reservation.ID, 1000, "EUR", payments.FakePendingMethod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payment := models.Payment{ReservationID: reservation.ID, Provider: provider.Name(), ProviderPaymentID: authorization.PaymentID, Status: models.PaymentStatusPending, Amount: 1000, Currency: "EUR"}
+			f.Add(t, &payment)
+			return reservation, payment
+		}
+		send := func(event payments.WebhookEvent, signed bool) (*HandlePaymentWebhookCommand, error) {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			if signed {
+				header.Set(payments.FakeSignatureHeader, provider.Sign(payload))
+			}
+			q := NewHandlePaymentWebhookCommand(f.DB, f.Logger, provider, payload, header)
+			_, err = q.Execute()
+			return q, err
+		}
+		paymentOf := func(id string) models.Payment {
+			var payment models.Payment
+			if err := f.DB.First(&payment, "id = ?", id).Error; err != nil {
+				t.Fatal(err)
+			}
+			return payment
+		}
+
+		paid, paidPayment := pending(1, models.ReservationStatusPendingPayment)
+		declined, declinedPayment := pending(3, models.ReservationStatusPendingPayment)
+		cancelled, cancelledPayment := pending(5, models.ReservationStatusCancelled)
+
+		captured := payments.WebhookEvent{ID: "evt_1", Type: payments.WebhookPaymentCaptured, PaymentID: paidPayment.ProviderPaymentID}
+		if _, err := send(captured, false); err == nil {
+			t.Error("handled a webhook without a signature")
+		}
+		if _, err := send(payments.WebhookEvent{ID: "evt_0", Type: payments.WebhookPaymentCaptured, PaymentID: "fake_unknown"}, true); err == nil {
+			t.Error("handled a webhook of a payment that does not exist")
+		}
+		if _, err := send(captured, true); err != nil {
+			t.Fatal(err)
+		}
+		if status := paymentOf(paidPayment.ID).Status; status != models.PaymentStatusCaptured {
+			t.Errorf("captured payment is %s", status)
+		}
+		if reservation, _ := f.Reservations().Get(paid.ID); reservation.Status != models.ReservationStatusConfirmed || reservation.Sequence != 1 {
+			t.Errorf("paid reservation is %s with sequence %d", reservation.Status, reservation.Sequence)
+		}
+		q, err := send(captured, true)
+		if err != nil || !q.Duplicate() {
+			t.Errorf("redelivered webhook was not recognised: %v", err)
+		}
+
+		if _, err = send(payments.WebhookEvent{ID: "evt_2", Type: payments.WebhookPaymentFailed, PaymentID: declinedPayment.ProviderPaymentID}, true); err != nil {
+			t.Fatal(err)
+		}
+		if reservation, _ := f.Reservations().Get(declined.ID); reservation.Status != models.ReservationStatusPaymentFailed {
+			t.Errorf("declined reservation is %s", reservation.Status)
+		}
+
+		// The money of a reservation cancelled while its payment was pending
+		// goes back as soon as the payment is captured.
+		if err = provider.Capture(cancelledPayment.ProviderPaymentID, 1000); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = send(payments.WebhookEvent{ID: "evt_3", Type: payments.WebhookPaymentCaptured, PaymentID: cancelledPayment.ProviderPaymentID}, true); err != nil {
+			t.Fatal(err)
+		}
+		if payment := paymentOf(cancelledPayment.ID); payment.Status != models.PaymentStatusRefunded || payment.RefundedAmount != 1000 {
+			t.Errorf("payment of the cancelled reservation is %s with %d refunded", payment.Status, payment.RefundedAmount)
+		}
+		if reservation, _ := f.Reservations().Get(cancelled.ID); reservation.Status != models.ReservationStatusCancelled {
+			t.Errorf("cancelled reservation is %s", reservation.Status)
+		}
+
+		if _, err = send(payments.WebhookEvent{ID: "evt_4", Type: payments.WebhookRefundSucceeded, PaymentID: paidPayment.ProviderPaymentID, Amount: 400}, true); err != nil {
+			t.Fatal(err)
+		}
+		if payment := paymentOf(paidPayment.ID); payment.Status != models.PaymentStatusPartiallyRefunded || payment.RefundedAmount != 400 {
+			t.Errorf("refunded payment is %s with %d refunded", payment.Status, payment.RefundedAmount)
+		}
+
+		want := []string{events.ReservationPaid, events.ReservationPaymentFailed, events.ReservationPaid, events.ReservationUpdated}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
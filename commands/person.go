@@ -0,0 +1,255 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// resolvePerson finds the person of a customer that is referred to either by
+// its id or by the external reference of the customer's own user system.
+func resolvePerson(store repository.Store, caller, customerId, ref string) (models.Person, error) {
+	person, err := store.People().FindByExternalRef(customerId, ref)
+	if err == nil || !errors.Is(err, repository.ErrNotFound) {
+		return person, err
+	}
+
+	if util.IsUUID(ref) {
+		person, err = store.People().Get(customerId, ref)
+		if err == nil || !errors.Is(err, repository.ErrNotFound) {
+			return person, err
+		}
+	}
+
+	return person, fmt.Errorf("%s: Could not find a person of customer %s with id or external reference: %s", caller, customerId, ref)
+}
+
+func validatePersonEmail(caller, email string) error {
+	if email == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return fmt.Errorf("%s: Email is not in correct format", caller)
+	}
+	return nil
+}
+
+type CreatePersonCommand struct {
+	db          *gorm.DB
+	logger      *zerolog.Logger
+	customerId  string
+	externalRef *string
+	name        string
+	email       string
+	phone       string
+	metadata    map[string]string
+}
+
+func NewCreatePersonCommand(db *gorm.DB, logger *zerolog.Logger, customerId string, externalRef *string, name, email, phone string, metadata map[string]string) *CreatePersonCommand {
+	return &CreatePersonCommand{db: db, logger: logger, customerId: customerId, externalRef: externalRef, name: name, email: email, phone: phone, metadata: metadata}
+}
+
+func (s *CreatePersonCommand) Execute() (string, error) {
+	if s.customerId == "" || s.name == "" {
+		return "", errors.New("CreatePersonCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreatePersonCommand: Started")
+
+	if err := validatePersonEmail("CreatePersonCommand", s.email); err != nil {
+		return "", err
+	}
+
+	var customer models.Customer
+	res := s.db.First(&customer, "id = ?", s.customerId)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("CreatePersonCommand: Could not find the customer with id: %s", s.customerId)
+		}
+		return "", res.Error
+	}
+
+	if s.externalRef != nil && *s.externalRef == "" {
+		s.externalRef = nil
+	}
+	if s.externalRef != nil {
+		var count int64
+		res = s.db.Model(&models.Person{}).Where("customer_id = ? AND external_ref = ?", s.customerId, *s.externalRef).Count(&count)
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if count > 0 {
+			return "", fmt.Errorf("CreatePersonCommand: A person with external reference %s already exists", *s.externalRef)
+		}
+	}
+
+	person := models.Person{
+		CustomerID:  s.customerId,
+		ExternalRef: s.externalRef,
+		Name:        s.name,
+		Email:       s.email,
+		Phone:       s.phone,
+		Metadata:    s.metadata,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Create(&person)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PersonCreated, person.CustomerID, person.ID, person)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreatePersonCommand: Finished with success")
+
+	return person.ID, nil
+}
+
+type DeletePersonCommand struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	id         string
+}
+
+func NewDeletePersonCommand(db *gorm.DB, logger *zerolog.Logger, customerId, id string) *DeletePersonCommand {
+	return &DeletePersonCommand{db: db, logger: logger, customerId: customerId, id: id}
+}
+
+// Execute deletes the person once no reservation refers to it. The person is
+// held while the references are counted, the way bookings hold their
+// persons, so that no reservation refers to it by the time it is deleted.
+func (s *DeletePersonCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("DeletePersonCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeletePersonCommand: Started")
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		persons, err := gormstore.New(tx).People().GetForUpdate(s.customerId, []string{s.id})
+		if err != nil {
+			return err
+		}
+		if len(persons) == 0 {
+			return fmt.Errorf("DeletePersonCommand: Could not find the person with id: %s", s.id)
+		}
+
+		var countOfReservations int64
+		res := tx.Model(&models.Reservation{}).Where("reserver_id = ? OR reservee_id = ?", s.id, s.id).Count(&countOfReservations)
+		if res.Error != nil {
+			return res.Error
+		}
+		if countOfReservations > 0 {
+			return fmt.Errorf("DeletePersonCommand: Person %s is still referred to by %d reservations", s.id, countOfReservations)
+		}
+
+		var countOfParticipations int64
+		res = tx.Model(&models.Participant{}).Where("person_id = ?", s.id).Count(&countOfParticipations)
+		if res.Error != nil {
+			return res.Error
+		}
+		if countOfParticipations > 0 {
+			return fmt.Errorf("DeletePersonCommand: Person %s is still a participant of %d reservations", s.id, countOfParticipations)
+		}
+
+		res = tx.Delete(&models.Person{}, "id = ? AND customer_id = ?", s.id, s.customerId)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PersonDeleted, s.customerId, s.id, persons[0])
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeletePersonCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdatePersonCommand struct {
+	db          *gorm.DB
+	logger      *zerolog.Logger
+	customerId  string
+	id          string
+	externalRef *string
+	name        *string
+	email       *string
+	phone       *string
+	metadata    *map[string]string
+}
+
+func NewUpdatePersonCommand(db *gorm.DB, logger *zerolog.Logger, customerId, id string, externalRef, name, email, phone *string, metadata *map[string]string) *UpdatePersonCommand {
+	return &UpdatePersonCommand{db: db, logger: logger, customerId: customerId, id: id, externalRef: externalRef, name: name, email: email, phone: phone, metadata: metadata}
+}
+
+func (s *UpdatePersonCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("UpdatePersonCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdatePersonCommand: Started")
+
+	var person models.Person
+	res := s.db.First(&person, "id = ? AND customer_id = ?", s.id, s.customerId)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("UpdatePersonCommand: Could not find the person with id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	if s.externalRef != nil && *s.externalRef != "" {
+		var count int64
+		res = s.db.Model(&models.Person{}).Where("customer_id = ? AND external_ref = ? AND id != ?", person.CustomerID, *s.externalRef, person.ID).Count(&count)
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if count > 0 {
+			return "", fmt.Errorf("UpdatePersonCommand: A person with external reference %s already exists", *s.externalRef)
+		}
+		person.ExternalRef = s.externalRef
+	}
+	if s.name != nil && *s.name != "" {
+		person.Name = *s.name
+	}
+	if s.email != nil && *s.email != "" {
+		if err := validatePersonEmail("UpdatePersonCommand", *s.email); err != nil {
+			return "", err
+		}
+		person.Email = *s.email
+	}
+	if s.phone != nil && *s.phone != "" {
+		person.Phone = *s.phone
+	}
+	if s.metadata != nil {
+		person.Metadata = *s.metadata
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Save(&person)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PersonUpdated, person.CustomerID, person.ID, person)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdatePersonCommand: Finished with success")
+
+	return s.id, nil
+}
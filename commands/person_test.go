@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestPersonCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		other := f.Customer(t, "mallory", nil)
+		source := f.Source(t, customer, "room")
+		taken := f.Person(t, customer, "taken")
+		ref := "u-1"
+
+		if _, err := NewCreatePersonCommand(f.DB, f.Logger, customer.ID, &ref, "Bob", "not an email", "", nil).Execute(); err == nil {
+			t.Error("created a person with an invalid email")
+		}
+		if _, err := NewCreatePersonCommand(f.DB, f.Logger, models.NewUUID(), &ref, "Bob", "", "", nil).Execute(); err == nil {
+			t.Error("created a person of a customer that does not exist")
+		}
+		if _, err := NewCreatePersonCommand(f.DB, f.Logger, customer.ID, taken.ExternalRef, "Bob", "", "", nil).Execute(); err == nil {
+			t.Error("created a person with an external reference that is taken")
+		}
+		id, err := NewCreatePersonCommand(f.DB, f.Logger, customer.ID, &ref, "Bob", "bob@example.com", "", map[string]string{"team": "a"}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Persons are resolved by their external reference as well as by id.
+		for _, by := range []string{ref, id} {
+			person, err := resolvePerson(f, "test", customer.ID, by)
+			if err != nil || person.ID != id {
+				t.Errorf("resolved %s into %+v: %v", by, person, err)
+			}
+		}
+
+		// The persons of one customer are out of reach of another one.
+		name, metadata := "Robert", map[string]string{"team": "b"}
+		if _, err = NewUpdatePersonCommand(f.DB, f.Logger, other.ID, id, nil, &name, nil, nil, nil).Execute(); err == nil {
+			t.Error("updated the person of another customer")
+		}
+		if _, err = NewDeletePersonCommand(f.DB, f.Logger, other.ID, id).Execute(); err == nil {
+			t.Error("deleted the person of another customer")
+		}
+		if _, err = NewUpdatePersonCommand(f.DB, f.Logger, customer.ID, id, taken.ExternalRef, nil, nil, nil, nil).Execute(); err == nil {
+			t.Error("moved a person to an external reference that is taken")
+		}
+		if _, err = NewUpdatePersonCommand(f.DB, f.Logger, customer.ID, id, nil, &name, nil, nil, &metadata).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		person, err := f.People().Get(customer.ID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if person.Name != "Robert" || person.Email != "bob@example.com" || person.Metadata["team"] != "b" || *person.ExternalRef != ref {
+			t.Errorf("stored %+v", person)
+		}
+
+		f.Add(t, &models.Reservation{From: repotest.At(1), To: repotest.At(2), ReserverID: taken.ID, ReserveeID: id, SourceID: source.ID})
+		if _, err = NewDeletePersonCommand(f.DB, f.Logger, customer.ID, id).Execute(); err == nil {
+			t.Error("deleted a person that reservations still refer to")
+		}
+		if _, err = NewDeletePersonCommand(f.DB, f.Logger, customer.ID, models.NewUUID()).Execute(); err == nil {
+			t.Error("deleted a person that does not exist")
+		}
+		unused := f.Person(t, customer, "unused")
+		if _, err = NewDeletePersonCommand(f.DB, f.Logger, customer.ID, unused.ID).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{events.PersonCreated, events.PersonUpdated, events.PersonDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// CustomerPlan returns the plan whose limits apply to the customer.
+func CustomerPlan(db *gorm.DB, customerId string) (models.Plan, error) {
+	return gormstore.New(db).Customers().Plan(customerId)
+}
+
+// requireFeature fails when the plan of the customer does not include the feature.
+func requireFeature(store repository.Store, caller, customerId, feature string) error {
+	plan, err := store.Customers().Plan(customerId)
+	if err != nil {
+		return err
+	}
+	if !plan.HasFeature(feature) {
+		return fmt.Errorf("%s: The plan %s of customer %s does not include %s", caller, plan.Name, customerId, feature)
+	}
+	return nil
+}
+
+// RecordUsage adds n to the counter of the metric for the month of now and
+// returns the new count. Concurrent calls are serialised on the counter row.
+func RecordUsage(db *gorm.DB, customerId, metric string, now time.Time, n int64) (int64, error) {
+	return gormstore.New(db).Customers().RecordUsage(customerId, metric, now, n)
+}
+
+// useReservationQuota meters n new reservations of the customer and fails
+// when that exceeds the monthly limit of its plan. It has to run in the
+// transaction creating the reservations so a failure rolls the count back.
+func useReservationQuota(tx repository.Store, caller, customerId string, n int64) error {
+	plan, err := tx.Customers().Plan(customerId)
+	if err != nil {
+		return err
+	}
+
+	count, err := tx.Customers().RecordUsage(customerId, models.UsageMetricReservations, time.Now(), n)
+	if err != nil {
+		return err
+	}
+	if plan.MaxReservationsPerMonth > 0 && count > int64(plan.MaxReservationsPerMonth) {
+		return fmt.Errorf("%s: Customer with id %s, has already hit the limit of %d reservations this month", caller, customerId, plan.MaxReservationsPerMonth)
+	}
+	return nil
+}
+
+// applyPlanBilling copies the billing settings onto the plan, keeping the
+// current currency when none is given.
+func applyPlanBilling(plan *models.Plan, billing models.PlanBilling) {
+	if billing.Currency != "" {
+		plan.Currency = billing.Currency
+	}
+	if plan.Currency == "" {
+		plan.Currency = "EUR"
+	}
+	plan.MonthlyPriceMinor = billing.MonthlyPriceMinor
+	plan.SourcePriceMinor = billing.SourcePriceMinor
+	plan.IncludedReservations = billing.IncludedReservations
+	plan.OveragePriceMinor = billing.OveragePriceMinor
+}
+
+func validatePlan(caller string, plan models.Plan) error {
+	if plan.MaxSources < 0 || plan.MaxReservationsPerMonth < 0 || plan.MaxApiTokens < 0 || plan.RateLimitPerMinute < 0 {
+		return fmt.Errorf("%s: Plan limits can not be negative", caller)
+	}
+	if plan.MonthlyPriceMinor < 0 || plan.SourcePriceMinor < 0 || plan.IncludedReservations < 0 || plan.OveragePriceMinor < 0 {
+		return fmt.Errorf("%s: Plan prices can not be negative", caller)
+	}
+	if err := pricing.ValidateSettings(plan.Currency, "UTC"); err != nil {
+		return fmt.Errorf("%s: %w", caller, err)
+	}
+	for _, feature := range plan.Features {
+		known := false
+		for _, f := range models.AllFeatures {
+			known = known || f == feature
+		}
+		if !known {
+			return fmt.Errorf("%s: Unknown feature %s", caller, feature)
+		}
+	}
+	return nil
+}
+
+type CreatePlanCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	plan   models.Plan
+}
+
+func NewCreatePlanCommand(store repository.Store, logger *zerolog.Logger, name string, maxSources, maxReservationsPerMonth, maxApiTokens, rateLimitPerMinute int, features []string, billing models.PlanBilling) *CreatePlanCommand {
+	plan := models.Plan{
+		Name:                    name,
+		MaxSources:              maxSources,
+		MaxReservationsPerMonth: maxReservationsPerMonth,
+		MaxApiTokens:            maxApiTokens,
+		RateLimitPerMinute:      rateLimitPerMinute,
+		Features:                features,
+	}
+	applyPlanBilling(&plan, billing)
+	return &CreatePlanCommand{store: store, logger: logger, plan: plan}
+}
+
+func (s *CreatePlanCommand) Execute() (string, error) {
+	if s.plan.Name == "" {
+		return "", errors.New("CreatePlanCommand: Tried creating with empty name")
+	}
+	s.logger.Debug().Msg("CreatePlanCommand: Started")
+
+	err := validatePlan("CreatePlanCommand", s.plan)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Plans().Create(&s.plan)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.PlanCreated, "", s.plan.ID, s.plan))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreatePlanCommand: Finished with success")
+
+	return s.plan.ID, nil
+}
+
+type DeletePlanCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeletePlanCommand(store repository.Store, logger *zerolog.Logger, id string) *DeletePlanCommand {
+	return &DeletePlanCommand{store: store, logger: logger, id: id}
+}
+
+func (s *DeletePlanCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeletePlanCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeletePlanCommand: Started")
+
+	countOfCustomers, err := s.store.Customers().CountForPlan(s.id)
+	if err != nil {
+		return "", err
+	}
+	if countOfCustomers > 0 {
+		return "", fmt.Errorf("DeletePlanCommand: Plan %s is still assigned to %d customers", s.id, countOfCustomers)
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		plan, err := tx.Plans().Get(s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("DeletePlanCommand: Could not find the plan with this id: %s", s.id)
+			}
+			return err
+		}
+		err = tx.Plans().Delete(s.id)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.PlanDeleted, "", s.id, plan))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeletePlanCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdatePlanCommand struct {
+	store                   repository.Store
+	logger                  *zerolog.Logger
+	id                      string
+	name                    *string
+	maxSources              *int
+	maxReservationsPerMonth *int
+	maxApiTokens            *int
+	rateLimitPerMinute      *int
+	features                *[]string
+	billing                 *models.PlanBilling
+}
+
+func NewUpdatePlanCommand(store repository.Store, logger *zerolog.Logger, id string, name *string, maxSources, maxReservationsPerMonth, maxApiTokens, rateLimitPerMinute *int, features *[]string, billing *models.PlanBilling) *UpdatePlanCommand {
+	return &UpdatePlanCommand{store: store, logger: logger, id: id, name: name, maxSources: maxSources, maxReservationsPerMonth: maxReservationsPerMonth, maxApiTokens: maxApiTokens, rateLimitPerMinute: rateLimitPerMinute, features: features, billing: billing}
+}
+
+// Execute changes the plan for every customer on it. Lowering a limit below
+// the current usage of a customer does not remove anything, it only stops
+// further growth. New prices apply to invoices generated afterwards.
+func (s *UpdatePlanCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdatePlanCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdatePlanCommand: Started")
+
+	plan, err := s.store.Plans().Get(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("UpdatePlanCommand: Could not find the plan with this id: %s", s.id)
+		}
+		return "", err
+	}
+
+	if s.name != nil && *s.name != "" {
+		plan.Name = *s.name
+	}
+	if s.maxSources != nil {
+		plan.MaxSources = *s.maxSources
+	}
+	if s.maxReservationsPerMonth != nil {
+		plan.MaxReservationsPerMonth = *s.maxReservationsPerMonth
+	}
+	if s.maxApiTokens != nil {
+		plan.MaxApiTokens = *s.maxApiTokens
+	}
+	if s.rateLimitPerMinute != nil {
+		plan.RateLimitPerMinute = *s.rateLimitPerMinute
+	}
+	if s.features != nil {
+		plan.Features = *s.features
+	}
+	if s.billing != nil {
+		applyPlanBilling(&plan, *s.billing)
+	}
+
+	err = validatePlan("UpdatePlanCommand", plan)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Plans().Save(&plan)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.PlanUpdated, "", plan.ID, plan))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdatePlanCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type AssignPlanCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	customerId string
+	planId     *string
+}
+
+func NewAssignPlanCommand(store repository.Store, logger *zerolog.Logger, customerId string, planId *string) *AssignPlanCommand {
+	return &AssignPlanCommand{store: store, logger: logger, customerId: customerId, planId: planId}
+}
+
+func (s *AssignPlanCommand) Execute() (string, error) {
+	if s.customerId == "" {
+		return "", errors.New("AssignPlanCommand: Tried assigning with empty customer id")
+	}
+	s.logger.Debug().Msg("AssignPlanCommand: Started")
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		customer, err := tx.Customers().Get(s.customerId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("AssignPlanCommand: Could not find the customer with id: %s", s.customerId)
+			}
+			return err
+		}
+
+		customer.PlanID = nil
+		if s.planId != nil && *s.planId != "" {
+			plan, err := tx.Plans().Get(*s.planId)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("AssignPlanCommand: Could not find the plan with this id: %s", *s.planId)
+				}
+				return err
+			}
+			customer.PlanID = &plan.ID
+		}
+
+		err = tx.Customers().Save(&customer)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.CustomerUpdated, customer.ID, customer.ID, customer))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("AssignPlanCommand: Finished with success")
+
+	return s.customerId, nil
+}
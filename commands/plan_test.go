@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestPlanCommands(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+
+		if _, err := NewCreatePlanCommand(f, f.Logger, "", 1, 0, 0, 0, nil, models.PlanBilling{}).Execute(); err == nil {
+			t.Error("created a plan without a name")
+		}
+		if _, err := NewCreatePlanCommand(f, f.Logger, "small", -1, 0, 0, 0, nil, models.PlanBilling{}).Execute(); err == nil {
+			t.Error("created a plan with a negative limit")
+		}
+		if _, err := NewCreatePlanCommand(f, f.Logger, "small", 1, 0, 0, 0, []string{"teleportation"}, models.PlanBilling{}).Execute(); err == nil {
+			t.Error("created a plan with an unknown feature")
+		}
+		id, err := NewCreatePlanCommand(f, f.Logger, "small", 1, 10, 1, 60, []string{models.FeatureBundles}, models.PlanBilling{MonthlyPriceMinor: 900}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := f.Plans().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Name != "small" || plan.Currency != "EUR" || plan.MonthlyPriceMinor != 900 || !plan.HasFeature(models.FeatureBundles) {
+			t.Errorf("stored %+v", plan)
+		}
+
+		missing := models.NewUUID()
+		if _, err = NewAssignPlanCommand(f, f.Logger, customer.ID, &missing).Execute(); err == nil {
+			t.Error("assigned a plan that does not exist")
+		}
+		if _, err = NewAssignPlanCommand(f, f.Logger, models.NewUUID(), &id).Execute(); err == nil {
+			t.Error("assigned a plan to a customer that does not exist")
+		}
+		if _, err = NewAssignPlanCommand(f, f.Logger, customer.ID, &id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		// An update applies to the customers on the plan right away.
+		name, sources := "medium", 3
+		if _, err = NewUpdatePlanCommand(f, f.Logger, models.NewUUID(), nil, &sources, nil, nil, nil, nil, nil).Execute(); err == nil {
+			t.Error("updated a plan that does not exist")
+		}
+		if _, err = NewUpdatePlanCommand(f, f.Logger, id, nil, nil, nil, nil, nil, nil, &models.PlanBilling{Currency: "euro"}).Execute(); err == nil {
+			t.Error("updated a plan to an unknown currency")
+		}
+		if _, err = NewUpdatePlanCommand(f, f.Logger, id, &name, &sources, nil, nil, nil, nil, nil).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if plan, err = f.Customers().Plan(customer.ID); err != nil || plan.Name != "medium" || plan.MaxSources != 3 {
+			t.Errorf("the customer is on %+v: %v", plan, err)
+		}
+
+		if _, err = NewDeletePlanCommand(f, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a plan that is still assigned")
+		}
+		if _, err = NewAssignPlanCommand(f, f.Logger, customer.ID, nil).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if plan, err = f.Customers().Plan(customer.ID); err != nil || plan.Name != models.DefaultPlan.Name {
+			t.Errorf("the customer is on %+v after the plan was taken away: %v", plan, err)
+		}
+		if _, err = NewDeletePlanCommand(f, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeletePlanCommand(f, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a plan twice")
+		}
+
+		want := []string{
+			events.PlanCreated, events.CustomerUpdated, events.PlanUpdated, events.CustomerUpdated, events.PlanDeleted,
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// chargeFee computes the fee for cancelling or rescheduling the reservation at
+// the given time from the policy of its source and records it. An override
+// replaces the computed fee. Nothing is recorded when there is neither a policy
+// nor an override.
+func chargeFee(tx repository.Store, caller string, source models.Source, reservation models.Reservation, kind string, now time.Time, override *models.FeeOverride) (*models.ReservationFee, error) {
+	computed := 0
+	if source.CancellationPolicyID != nil {
+		policy, err := tx.Sources().CancellationPolicy(source.CustomerID, *source.CancellationPolicyID)
+		if err != nil {
+			return nil, err
+		}
+
+		rules := policy.CancellationRules
+		if kind == models.FeeKindModification {
+			rules = policy.ModificationRules
+		}
+		computed, err = rules.FeePercent(reservation.From.Sub(now))
+		if err != nil {
+			return nil, err
+		}
+	} else if override == nil {
+		return nil, nil
+	}
+
+	fee := models.ReservationFee{
+		ReservationID:      reservation.ID,
+		Kind:               kind,
+		ComputedFeePercent: computed,
+		FeePercent:         computed,
+	}
+	if override != nil {
+		if override.Reason == "" {
+			return nil, fmt.Errorf("%s: Overriding a fee needs a reason", caller)
+		}
+		if override.FeePercent < 0 || override.FeePercent > 100 {
+			return nil, fmt.Errorf("%s: Fee percent %d is not between 0 and 100", caller, override.FeePercent)
+		}
+		fee.FeePercent = override.FeePercent
+		fee.OverrideReason = override.Reason
+		fee.OverriddenBy = &override.By
+	}
+	fee.Currency = reservation.Currency
+	fee.Amount = pricing.PercentOf(reservation.TotalAmount, fee.FeePercent)
+
+	err := tx.Reservations().AddFee(&fee)
+	if err != nil {
+		return nil, err
+	}
+	return &fee, nil
+}
+
+func validatePolicyRules(caller string, rules ...models.PolicyRules) error {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", caller, err)
+		}
+	}
+	return nil
+}
+
+type CreateCancellationPolicyCommand struct {
+	db                *gorm.DB
+	logger            *zerolog.Logger
+	customerId        string
+	name              string
+	cancellationRules models.PolicyRules
+	modificationRules models.PolicyRules
+}
+
+func NewCreateCancellationPolicyCommand(db *gorm.DB, logger *zerolog.Logger, customerId, name string, cancellationRules, modificationRules models.PolicyRules) *CreateCancellationPolicyCommand {
+	return &CreateCancellationPolicyCommand{db: db, logger: logger, customerId: customerId, name: name, cancellationRules: cancellationRules, modificationRules: modificationRules}
+}
+
+func (s *CreateCancellationPolicyCommand) Execute() (string, error) {
+	if s.customerId == "" || s.name == "" {
+		return "", errors.New("CreateCancellationPolicyCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateCancellationPolicyCommand: Started")
+
+	if err := validatePolicyRules("CreateCancellationPolicyCommand", s.cancellationRules, s.modificationRules); err != nil {
+		return "", err
+	}
+
+	var customer models.Customer
+	res := s.db.First(&customer, "id = ?", s.customerId)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("CreateCancellationPolicyCommand: Could not find the customer with id: %s", s.customerId)
+		}
+		return "", res.Error
+	}
+
+	policy := models.CancellationPolicy{
+		CustomerID:        s.customerId,
+		Name:              s.name,
+		CancellationRules: s.cancellationRules,
+		ModificationRules: s.modificationRules,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Create(&policy)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PolicyCreated, policy.CustomerID, policy.ID, policy)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateCancellationPolicyCommand: Finished with success")
+
+	return policy.ID, nil
+}
+
+type DeleteCancellationPolicyCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeleteCancellationPolicyCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteCancellationPolicyCommand {
+	return &DeleteCancellationPolicyCommand{db: db, logger: logger, id: id}
+}
+
+func (s *DeleteCancellationPolicyCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeleteCancellationPolicyCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteCancellationPolicyCommand: Started")
+
+	var countOfSources int64
+	res := s.db.Model(&models.Source{}).Where("cancellation_policy_id = ?", s.id).Count(&countOfSources)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if countOfSources > 0 {
+		return "", fmt.Errorf("DeleteCancellationPolicyCommand: Policy %s is still used by %d sources", s.id, countOfSources)
+	}
+
+	var policy models.CancellationPolicy
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Returning{}).Delete(&policy, "id = ?", s.id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("DeleteCancellationPolicyCommand: Could not find the policy with id: %s", s.id)
+		}
+		return record(tx, events.PolicyDeleted, policy.CustomerID, s.id, policy)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteCancellationPolicyCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdateCancellationPolicyCommand struct {
+	db                *gorm.DB
+	logger            *zerolog.Logger
+	id                string
+	name              *string
+	cancellationRules *models.PolicyRules
+	modificationRules *models.PolicyRules
+}
+
+func NewUpdateCancellationPolicyCommand(db *gorm.DB, logger *zerolog.Logger, id string, name *string, cancellationRules, modificationRules *models.PolicyRules) *UpdateCancellationPolicyCommand {
+	return &UpdateCancellationPolicyCommand{db: db, logger: logger, id: id, name: name, cancellationRules: cancellationRules, modificationRules: modificationRules}
+}
+
+func (s *UpdateCancellationPolicyCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdateCancellationPolicyCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdateCancellationPolicyCommand: Started")
+
+	var policy models.CancellationPolicy
+	res := s.db.First(&policy, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("UpdateCancellationPolicyCommand: Could not find the policy with id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	if s.name != nil && *s.name != "" {
+		policy.Name = *s.name
+	}
+	if s.cancellationRules != nil {
+		if err := validatePolicyRules("UpdateCancellationPolicyCommand", *s.cancellationRules); err != nil {
+			return "", err
+		}
+		policy.CancellationRules = *s.cancellationRules
+	}
+	if s.modificationRules != nil {
+		if err := validatePolicyRules("UpdateCancellationPolicyCommand", *s.modificationRules); err != nil {
+			return "", err
+		}
+		policy.ModificationRules = *s.modificationRules
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Save(&policy)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PolicyUpdated, policy.CustomerID, policy.ID, policy)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdateCancellationPolicyCommand: Finished with success")
+
+	return s.id, nil
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestCancellationPolicyCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		rules := models.PolicyRules{{Before: "48h", FeePercent: 0}, {Before: "24h", FeePercent: 50}}
+
+		if _, err := NewCreateCancellationPolicyCommand(f.DB, f.Logger, customer.ID, "strict", models.PolicyRules{{Before: "soon"}}, nil).Execute(); err == nil {
+			t.Error("created a policy with a rule that is not a duration")
+		}
+		if _, err := NewCreateCancellationPolicyCommand(f.DB, f.Logger, customer.ID, "strict", nil, models.PolicyRules{{Before: "1h", FeePercent: 101}}).Execute(); err == nil {
+			t.Error("created a policy with a fee above 100 percent")
+		}
+		if _, err := NewCreateCancellationPolicyCommand(f.DB, f.Logger, models.NewUUID(), "strict", rules, nil).Execute(); err == nil {
+			t.Error("created a policy of a customer that does not exist")
+		}
+		id, err := NewCreateCancellationPolicyCommand(f.DB, f.Logger, customer.ID, "strict", rules, nil).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modification := models.PolicyRules{{Before: "1h", FeePercent: 10}}
+		if _, err = NewUpdateCancellationPolicyCommand(f.DB, f.Logger, id, nil, nil, &models.PolicyRules{{Before: "1h", FeePercent: -1}}).Execute(); err == nil {
+			t.Error("updated a policy with a negative fee")
+		}
+		if _, err = NewUpdateCancellationPolicyCommand(f.DB, f.Logger, id, nil, nil, &modification).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		policy, err := f.Sources().CancellationPolicy(customer.ID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if policy.Name != "strict" || len(policy.CancellationRules) != 2 || len(policy.ModificationRules) != 1 {
+			t.Errorf("stored %+v", policy)
+		}
+
+		// The fee of the rule with the longest notice that is still given applies.
+		for _, test := range []struct {
+			notice time.Duration
+			fee    int
+		}{{72 * time.Hour, 0}, {30 * time.Hour, 50}, {time.Hour, 100}} {
+			if fee, err := policy.CancellationRules.FeePercent(test.notice); err != nil || fee != test.fee {
+				t.Errorf("charges %d%% with %v notice, want %d%%: %v", fee, test.notice, test.fee, err)
+			}
+		}
+
+		source := f.Source(t, customer, "room")
+		source.CancellationPolicyID = &id
+		if err = f.DB.Save(&source).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteCancellationPolicyCommand(f.DB, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a policy that a source uses")
+		}
+		source.CancellationPolicyID = nil
+		if err = f.DB.Save(&source).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteCancellationPolicyCommand(f.DB, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteCancellationPolicyCommand(f.DB, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a policy twice")
+		}
+
+		want := []string{events.PolicyCreated, events.PolicyUpdated, events.PolicyDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
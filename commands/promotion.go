@@ -0,0 +1,256 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// redeemPromotion checks that the code can be used for the priced reservation,
+// applies its discount and counts the redemption. Concurrent redemptions can
+// not go over the maximum. It has to run in the transaction that creates the
+// reservation.
+func redeemPromotion(tx repository.Store, caller string, customerId, code string, reservation *models.Reservation) (models.Promotion, int64, error) {
+	promotion, err := tx.Promotions().FindByCode(customerId, strings.ToUpper(code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return promotion, 0, fmt.Errorf("%s: Could not find the promotion with code: %s", caller, code)
+		}
+		return promotion, 0, err
+	}
+
+	countOfReservations, err := tx.Reservations().CountForReservee(reservation.ReserveeID)
+	if err != nil {
+		return promotion, 0, err
+	}
+
+	err = pricing.CheckPromotion(promotion, reservation.SourceID, time.Now(), countOfReservations == 0)
+	if err != nil {
+		return promotion, 0, fmt.Errorf("%s: %w", caller, err)
+	}
+
+	quote := models.Quote{SourceID: reservation.SourceID, Currency: reservation.Currency, Lines: reservation.PriceBreakdown, Total: reservation.TotalAmount}
+	discount, err := pricing.ApplyPromotion(&quote, promotion)
+	if err != nil {
+		return promotion, 0, fmt.Errorf("%s: %w", caller, err)
+	}
+
+	redeemed, err := tx.Promotions().Redeem(promotion.ID)
+	if err != nil {
+		return promotion, 0, err
+	}
+	if !redeemed {
+		return promotion, 0, fmt.Errorf("%s: Promotion %s has been redeemed too often", caller, promotion.Code)
+	}
+
+	reservation.PromotionID = &promotion.ID
+	reservation.PriceBreakdown = quote.Lines
+	reservation.TotalAmount = quote.Total
+	return promotion, discount, nil
+}
+
+type CreatePromotionCommand struct {
+	db        *gorm.DB
+	logger    *zerolog.Logger
+	promotion models.Promotion
+}
+
+func NewCreatePromotionCommand(db *gorm.DB, logger *zerolog.Logger, customerId, code, kind string, percentOff int, amountOffMinor int64, currency string, sourceIds []string, validFrom, validUntil *time.Time, firstTimeOnly bool, maxRedemptions int) *CreatePromotionCommand {
+	promotion := models.Promotion{
+		CustomerID:     customerId,
+		Code:           strings.ToUpper(code),
+		Kind:           kind,
+		PercentOff:     percentOff,
+		AmountOffMinor: amountOffMinor,
+		Currency:       currency,
+		SourceIDs:      sourceIds,
+		ValidFrom:      validFrom,
+		ValidUntil:     validUntil,
+		FirstTimeOnly:  firstTimeOnly,
+		MaxRedemptions: maxRedemptions,
+		Active:         true,
+	}
+	return &CreatePromotionCommand{db: db, logger: logger, promotion: promotion}
+}
+
+func (s *CreatePromotionCommand) Execute() (string, error) {
+	if s.promotion.CustomerID == "" || s.promotion.Code == "" {
+		return "", errors.New("CreatePromotionCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreatePromotionCommand: Started")
+
+	if err := pricing.ValidatePromotion(s.promotion); err != nil {
+		return "", fmt.Errorf("CreatePromotionCommand: %w", err)
+	}
+
+	err := requireFeature(gormstore.New(s.db), "CreatePromotionCommand", s.promotion.CustomerID, models.FeaturePromotions)
+	if err != nil {
+		return "", err
+	}
+
+	var countOfCodes int64
+	res := s.db.Model(&models.Promotion{}).Where("customer_id = ? AND code = ?", s.promotion.CustomerID, s.promotion.Code).Count(&countOfCodes)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if countOfCodes > 0 {
+		return "", fmt.Errorf("CreatePromotionCommand: A promotion with code %s already exists", s.promotion.Code)
+	}
+
+	var countOfSources int64
+	if len(s.promotion.SourceIDs) > 0 {
+		res = s.db.Model(&models.Source{}).Where("customer_id = ? AND id IN ?", s.promotion.CustomerID, s.promotion.SourceIDs).Count(&countOfSources)
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if int(countOfSources) != len(s.promotion.SourceIDs) {
+			return "", errors.New("CreatePromotionCommand: Some of the sources do not belong to the customer")
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Create(&s.promotion)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PromotionCreated, s.promotion.CustomerID, s.promotion.ID, s.promotion)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreatePromotionCommand: Finished with success")
+
+	return s.promotion.ID, nil
+}
+
+type DeletePromotionCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeletePromotionCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeletePromotionCommand {
+	return &DeletePromotionCommand{db: db, logger: logger, id: id}
+}
+
+// Execute deletes a promotion that was never redeemed, redeemed ones can only
+// be deactivated so their redemptions stay reportable.
+func (s *DeletePromotionCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeletePromotionCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeletePromotionCommand: Started")
+
+	var countOfRedemptions int64
+	res := s.db.Model(&models.PromotionRedemption{}).Where("promotion_id = ?", s.id).Count(&countOfRedemptions)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if countOfRedemptions > 0 {
+		return "", fmt.Errorf("DeletePromotionCommand: Promotion %s has been redeemed, deactivate it instead", s.id)
+	}
+
+	var promotion models.Promotion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Returning{}).Delete(&promotion, "id = ?", s.id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("DeletePromotionCommand: Could not find the promotion with this id: %s", s.id)
+		}
+		return record(tx, events.PromotionDeleted, promotion.CustomerID, s.id, promotion)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeletePromotionCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdatePromotionCommand struct {
+	db             *gorm.DB
+	logger         *zerolog.Logger
+	id             string
+	sourceIds      *[]string
+	validFrom      *time.Time
+	validUntil     *time.Time
+	firstTimeOnly  *bool
+	maxRedemptions *int
+	active         *bool
+}
+
+func NewUpdatePromotionCommand(db *gorm.DB, logger *zerolog.Logger, id string, sourceIds *[]string, validFrom, validUntil *time.Time, firstTimeOnly *bool, maxRedemptions *int, active *bool) *UpdatePromotionCommand {
+	return &UpdatePromotionCommand{db: db, logger: logger, id: id, sourceIds: sourceIds, validFrom: validFrom, validUntil: validUntil, firstTimeOnly: firstTimeOnly, maxRedemptions: maxRedemptions, active: active}
+}
+
+func (s *UpdatePromotionCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdatePromotionCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdatePromotionCommand: Started")
+
+	var promotion models.Promotion
+	res := s.db.First(&promotion, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("UpdatePromotionCommand: Could not find the promotion with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	if s.sourceIds != nil {
+		promotion.SourceIDs = *s.sourceIds
+	}
+	if s.validFrom != nil {
+		promotion.ValidFrom = s.validFrom
+	}
+	if s.validUntil != nil {
+		promotion.ValidUntil = s.validUntil
+	}
+	if s.firstTimeOnly != nil {
+		promotion.FirstTimeOnly = *s.firstTimeOnly
+	}
+	if s.maxRedemptions != nil {
+		promotion.MaxRedemptions = *s.maxRedemptions
+	}
+	if s.active != nil {
+		promotion.Active = *s.active
+	}
+
+	if err := pricing.ValidatePromotion(promotion); err != nil {
+		return "", fmt.Errorf("UpdatePromotionCommand: %w", err)
+	}
+
+	// The counter is left out, it is only ever changed by redemptions.
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&promotion).Select("source_ids", "valid_from", "valid_until", "first_time_only", "max_redemptions", "active").Updates(&promotion)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.PromotionUpdated, promotion.CustomerID, promotion.ID, promotion)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdatePromotionCommand: Finished with success")
+
+	return s.id, nil
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestPromotionCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &models.Plan{Name: "basic", MaxSources: 2, Features: []string{models.FeaturePromotions}})
+		room := f.Source(t, customer, "room")
+		other := f.Customer(t, "bob", &models.Plan{Name: "free", MaxSources: 1})
+		create := func(customerId, code, kind string, percentOff int, sourceIds ...string) (string, error) {
+			return NewCreatePromotionCommand(f.DB, f.Logger, customerId, code, kind, percentOff, 0, "", sourceIds, nil, nil, false, 0).Execute()
+		}
+
+		if _, err := create(other.ID, "spring", models.PromotionKindPercentage, 10); err == nil {
+			t.Error("created a promotion for a plan without promotions")
+		}
+		if _, err := create(customer.ID, "spring", models.PromotionKindPercentage, 150); err == nil {
+			t.Error("created a promotion of more than 100 percent")
+		}
+		if _, err := create(customer.ID, "spring", models.PromotionKindFixed, 0); err == nil {
+			t.Error("created a fixed promotion without an amount")
+		}
+		if _, err := create(customer.ID, "spring", models.PromotionKindPercentage, 10, f.Source(t, other, "hall").ID); err == nil {
+			t.Error("created a promotion for the source of another customer")
+		}
+		id, err := create(customer.ID, "spring", models.PromotionKindPercentage, 10, room.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = create(customer.ID, "Spring", models.PromotionKindPercentage, 20); err == nil {
+			t.Error("created a promotion with a code that is taken")
+		}
+		promotion, err := f.Promotions().FindByCode(customer.ID, "SPRING")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if promotion.ID != id || !promotion.Active || !slices.Equal(promotion.SourceIDs, []string{room.ID}) {
+			t.Errorf("stored %+v", promotion)
+		}
+
+		// Redemptions count on, an update keeps their counter.
+		if _, err = f.Promotions().Redeem(id); err != nil {
+			t.Fatal(err)
+		}
+		negative, limit, inactive := -1, 5, false
+		if _, err = NewUpdatePromotionCommand(f.DB, f.Logger, id, nil, nil, nil, nil, &negative, nil).Execute(); err == nil {
+			t.Error("updated a promotion to a negative maximum of redemptions")
+		}
+		if _, err = NewUpdatePromotionCommand(f.DB, f.Logger, id, &[]string{}, nil, nil, nil, &limit, &inactive).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		promotion, err = f.Promotions().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if promotion.Active || promotion.MaxRedemptions != 5 || promotion.RedemptionCount != 1 || len(promotion.SourceIDs) != 0 {
+			t.Errorf("stored %+v", promotion)
+		}
+
+		if _, err = NewDeletePromotionCommand(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("deleted a promotion that does not exist")
+		}
+		if _, err = NewDeletePromotionCommand(f.DB, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{events.PromotionCreated, events.PromotionUpdated, events.PromotionDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CreateRateCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	rate   models.Rate
+}
+
+func NewCreateRateCommand(db *gorm.DB, logger *zerolog.Logger, sourceId, name, kind string, amountMinor int64, weekdays []int, startTime, endTime string, priority int, perUnit bool) *CreateRateCommand {
+	rate := models.Rate{
+		SourceID:    sourceId,
+		Name:        name,
+		Kind:        kind,
+		AmountMinor: amountMinor,
+		Weekdays:    weekdays,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Priority:    priority,
+		PerUnit:     perUnit,
+	}
+	return &CreateRateCommand{db: db, logger: logger, rate: rate}
+}
+
+func (s *CreateRateCommand) Execute() (string, error) {
+	if s.rate.SourceID == "" || s.rate.Name == "" {
+		return "", errors.New("CreateRateCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateRateCommand: Started")
+
+	if err := pricing.ValidateRate(s.rate); err != nil {
+		return "", fmt.Errorf("CreateRateCommand: %w", err)
+	}
+
+	var source models.Source
+	res := s.db.First(&source, "id = ?", s.rate.SourceID)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("CreateRateCommand: Could not find the source with this id: %s", s.rate.SourceID)
+		}
+		return "", res.Error
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Create(&s.rate)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.RateCreated, source.CustomerID, s.rate.ID, s.rate)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateRateCommand: Finished with success")
+
+	return s.rate.ID, nil
+}
+
+type DeleteRateCommand struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewDeleteRateCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteRateCommand {
+	return &DeleteRateCommand{db: db, logger: logger, id: id}
+}
+
+func (s *DeleteRateCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("DeleteRateCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteRateCommand: Started")
+
+	var rate models.Rate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Returning{}).Delete(&rate, "id = ?", s.id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("DeleteRateCommand: Could not find the rate with this id: %s", s.id)
+		}
+		return record(tx, events.RateDeleted, sourceCustomerId(gormstore.New(tx), rate.SourceID), s.id, rate)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteRateCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdateRateCommand struct {
+	db          *gorm.DB
+	logger      *zerolog.Logger
+	id          string
+	name        *string
+	kind        *string
+	amountMinor *int64
+	weekdays    *[]int
+	startTime   *string
+	endTime     *string
+	priority    *int
+	perUnit     *bool
+}
+
+func NewUpdateRateCommand(db *gorm.DB, logger *zerolog.Logger, id string, name, kind *string, amountMinor *int64, weekdays *[]int, startTime, endTime *string, priority *int, perUnit *bool) *UpdateRateCommand {
+	return &UpdateRateCommand{db: db, logger: logger, id: id, name: name, kind: kind, amountMinor: amountMinor, weekdays: weekdays, startTime: startTime, endTime: endTime, priority: priority, perUnit: perUnit}
+}
+
+func (s *UpdateRateCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("UpdateRateCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdateRateCommand: Started")
+
+	var rate models.Rate
+	res := s.db.First(&rate, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("UpdateRateCommand: Could not find the rate with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	if s.name != nil && *s.name != "" {
+		rate.Name = *s.name
+	}
+	if s.kind != nil && *s.kind != "" {
+		rate.Kind = *s.kind
+	}
+	if s.amountMinor != nil {
+		rate.AmountMinor = *s.amountMinor
+	}
+	if s.weekdays != nil {
+		rate.Weekdays = *s.weekdays
+	}
+	if s.startTime != nil {
+		rate.StartTime = *s.startTime
+	}
+	if s.endTime != nil {
+		rate.EndTime = *s.endTime
+	}
+	if s.priority != nil {
+		rate.Priority = *s.priority
+	}
+	if s.perUnit != nil {
+		rate.PerUnit = *s.perUnit
+	}
+
+	if err := pricing.ValidateRate(rate); err != nil {
+		return "", fmt.Errorf("UpdateRateCommand: %w", err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Save(&rate)
+		if res.Error != nil {
+			return res.Error
+		}
+		return record(tx, events.RateUpdated, sourceCustomerId(gormstore.New(tx), rate.SourceID), rate.ID, rate)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdateRateCommand: Finished with success")
+
+	return s.id, nil
+}
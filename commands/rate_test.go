@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestRateCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		create := func(sourceId, kind string, weekdays []int, startTime string) (string, error) {
+			return NewCreateRateCommand(f.DB, f.Logger, sourceId, "weekend", kind, 1500, weekdays, startTime, "", 1, false).Execute()
+		}
+
+		if _, err := create(room.ID, "monthly", nil, ""); err == nil {
+			t.Error("created a rate of an unknown kind")
+		}
+		if _, err := create(room.ID, models.RateKindHourly, []int{7}, ""); err == nil {
+			t.Error("created a rate on a weekday that does not exist")
+		}
+		if _, err := create(room.ID, models.RateKindHourly, nil, "9am"); err == nil {
+			t.Error("created a rate with a start time that is not a clock time")
+		}
+		if _, err := create(models.NewUUID(), models.RateKindHourly, nil, ""); err == nil {
+			t.Error("created a rate of a source that does not exist")
+		}
+		id, err := create(room.ID, models.RateKindHourly, []int{0, 6}, "08:00")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		amount, kind := int64(2000), models.RateKindDaily
+		if _, err = NewUpdateRateCommand(f.DB, f.Logger, models.NewUUID(), nil, nil, &amount, nil, nil, nil, nil, nil).Execute(); err == nil {
+			t.Error("updated a rate that does not exist")
+		}
+		negative := int64(-1)
+		if _, err = NewUpdateRateCommand(f.DB, f.Logger, id, nil, nil, &negative, nil, nil, nil, nil, nil).Execute(); err == nil {
+			t.Error("updated a rate to a negative amount")
+		}
+		if _, err = NewUpdateRateCommand(f.DB, f.Logger, id, nil, &kind, &amount, nil, nil, nil, nil, nil).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		rates, err := f.Sources().Rates(room.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rates) != 1 || rates[0].Kind != models.RateKindDaily || rates[0].AmountMinor != 2000 || !slices.Equal(rates[0].Weekdays, []int{0, 6}) || rates[0].StartTime != "08:00" {
+			t.Errorf("stored %+v", rates)
+		}
+
+		if _, err = NewDeleteRateCommand(f.DB, f.Logger, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewDeleteRateCommand(f.DB, f.Logger, id).Execute(); err == nil {
+			t.Error("deleted a rate twice")
+		}
+		if rates, err = f.Sources().Rates(room.ID); err != nil || len(rates) != 0 {
+			t.Errorf("left %+v: %v", rates, err)
+		}
+
+		want := []string{events.RateCreated, events.RateUpdated, events.RateDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+	})
+}
@@ -4,93 +4,304 @@
 package commands
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/lghtr35/reservation-engine/events"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
 )
 
-const CHECK_IF_INSERT_POSSIBLE_SQL string = `SELECT id FROM reservations r 
-WHERE ((r.from  < @from  AND r.to > @from) OR (r.from  < @to  AND r.to > @to)) 
-AND r.sourceId = @source
-AND (r.reserveeId = @reservee OR r.reserverId = @reserver)`
-const CHECK_IF_UPDATE_POSSIBLE_SQL string = `SELECT id FROM reservations r 
-WHERE ((r.from  < @from  AND r.to > @from) OR (r.from  < @to  AND r.to > @to)) 
-AND r.sourceId = @source
-AND (r.reserveeId = @reservee OR r.reserverId = @reserver)
-AND r.id != @id`
+// ErrOverlappingReservations is wrapped by the errors of commands that were
+// refused because the slot is taken.
+var ErrOverlappingReservations = errors.New("Can not create reservation there are overlapping reservations")
+
+// checkReservationPossible validates a reservation window against the maximum
+// duration of the source and against every overlapping reservation of the
+// source or of the given persons. Reservations whose ids are in excludeIds are
+// ignored, which is what updates need. It has to run in the transaction that
+// books the window, the persons stay locked until it is done so that they are
+// not booked elsewhere in the meantime.
+func checkReservationPossible(store repository.Store, caller string, source models.Source, from, to time.Time, personIds []string, excludeIds []string) error {
+	if !to.After(from) {
+		return fmt.Errorf("%s: Tried creating a reservation that does not end after it starts", caller)
+	}
+
+	maxDurationForSource, err := time.ParseDuration(source.MaxPossibleDuration)
+	if err != nil {
+		return err
+	}
+
+	if maxDurationForSource < to.Sub(from) {
+		return fmt.Errorf("%s: Tried creating a reservation longer than maximum for this source", caller)
+	}
+
+	// Sources are locked before persons, in every transaction.
+	_, err = store.People().GetForUpdate(source.CustomerID, personIds)
+	if err != nil {
+		return err
+	}
+
+	countOfOverlaps, err := store.Reservations().CountOverlapping(repository.Overlap{
+		SourceID:   source.ID,
+		From:       from,
+		To:         to,
+		PersonIDs:  personIds,
+		ExcludeIDs: excludeIds,
+	})
+	if err != nil {
+		return err
+	}
+
+	if countOfOverlaps > 0 {
+		return fmt.Errorf("%s: %w", caller, ErrOverlappingReservations)
+	}
+
+	return nil
+}
+
+// checkSlotFree checks the window with checkReservationPossible in the
+// transaction that books it and returns the source. The source and the
+// persons stay locked until the transaction is done, so that concurrent
+// bookings of either see each other, like the bundle commands do.
+func checkSlotFree(tx repository.Store, caller string, sourceId string, from, to time.Time, personIds []string, excludeIds []string) (models.Source, error) {
+	source, err := tx.Sources().GetForUpdate(sourceId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return source, fmt.Errorf("%s: Could not find the source with this id: %s", caller, sourceId)
+		}
+		return source, err
+	}
+	return source, checkReservationPossible(tx, caller, source, from, to, personIds, excludeIds)
+}
+
+// applyApprovalRules puts a new reservation on a source that requires approval
+// into the pending state, which holds the slot until it is decided or expires.
+func applyApprovalRules(source models.Source, reservation *models.Reservation) error {
+	reservation.Status = models.ReservationStatusConfirmed
+	if !source.RequiresApproval {
+		return nil
+	}
+
+	timeout := models.DefaultApprovalTimeout
+	if source.ApprovalTimeout != "" {
+		parsed, err := time.ParseDuration(source.ApprovalTimeout)
+		if err != nil {
+			return err
+		}
+		timeout = parsed
+	}
+	expiresAt := time.Now().Add(timeout)
+
+	reservation.Status = models.ReservationStatusPendingApproval
+	reservation.ApproverID = source.ApproverID
+	reservation.ApprovalExpiresAt = &expiresAt
+	return nil
+}
+
+// priceReservation prices the reservation from the rates of its source and
+// stores the breakdown on it.
+func priceReservation(store repository.Store, caller string, source models.Source, reservation *models.Reservation) error {
+	capacity := source.Capacity
+	if capacity == 0 {
+		capacity = 1
+	}
+	if reservation.Units < 1 {
+		reservation.Units = 1
+	}
+	if reservation.Units > capacity {
+		return fmt.Errorf("%s: Tried booking %d units of a source with capacity %d", caller, reservation.Units, capacity)
+	}
+
+	rates, err := store.Sources().Rates(source.ID)
+	if err != nil {
+		return err
+	}
+
+	quote, err := pricing.Quote(source, rates, reservation.From, reservation.To, reservation.Units)
+	if err != nil {
+		return fmt.Errorf("%s: %w", caller, err)
+	}
+
+	// A promotion redeemed earlier keeps its discount when the reservation is
+	// priced again, without being redeemed a second time.
+	if reservation.PromotionID != nil {
+		promotion, err := store.Promotions().Get(*reservation.PromotionID)
+		if err != nil {
+			return err
+		}
+		discount, err := pricing.ApplyPromotion(&quote, promotion)
+		if err != nil {
+			return fmt.Errorf("%s: %w", caller, err)
+		}
+		err = store.Promotions().SetRedemptionDiscount(reservation.ID, discount)
+		if err != nil {
+			return err
+		}
+	}
+
+	reservation.Currency = quote.Currency
+	reservation.TotalAmount = quote.Total
+	reservation.PriceBreakdown = quote.Lines
+	return nil
+}
+
+// busyPersonIds returns the ids of every person who is occupied by the
+// reservation: its reserver, its reservee and the participants that did not decline.
+func busyPersonIds(store repository.Store, reservation models.Reservation) ([]string, error) {
+	participants, err := store.Reservations().Participants(reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	personIds := []string{reservation.ReserverID, reservation.ReserveeID}
+	for _, participant := range participants {
+		if participant.RSVP != models.RSVPDeclined {
+			personIds = append(personIds, participant.PersonID)
+		}
+	}
+	return personIds, nil
+}
 
 type CreateReservationCommand struct {
-	db         *gorm.DB
-	logger     *zerolog.Logger
-	from       time.Time
-	to         time.Time
-	reserverId string
-	reserveeId string
-	sourceId   string
+	store         repository.Store
+	logger        *zerolog.Logger
+	provider      payments.PaymentProvider
+	from          time.Time
+	to            time.Time
+	reserverId    string
+	reserveeId    string
+	sourceId      string
+	participants  []models.ReservationParticipant
+	units         int
+	promotionCode string
+	paymentMethod string
 }
 
-func NewCreateReservationCommand(db *gorm.DB, logger *zerolog.Logger, from time.Time, to time.Time, reserverId, reserveeId string) *CreateReservationCommand {
-	return &CreateReservationCommand{db: db, logger: logger, from: from, to: to, reserverId: reserverId, reserveeId: reserveeId}
+func NewCreateReservationCommand(store repository.Store, logger *zerolog.Logger, provider payments.PaymentProvider, from time.Time, to time.Time, reserverId, reserveeId, sourceId string, participants []models.ReservationParticipant, units int, promotionCode, paymentMethod string) *CreateReservationCommand {
+	return &CreateReservationCommand{store: store, logger: logger, provider: provider, from: from, to: to, reserverId: reserverId, reserveeId: reserveeId, sourceId: sourceId, participants: participants, units: units, promotionCode: promotionCode, paymentMethod: paymentMethod}
 }
 
 func (s *CreateReservationCommand) Execute() (string, error) {
-	if s.reserveeId == "" || s.reserverId == "" {
+	if s.reserveeId == "" || s.reserverId == "" || s.sourceId == "" {
 		return "", errors.New("CreateReservationCommand: Tried creating with empty name")
 	}
 	s.logger.Debug().Msg("CreateReservationCommand: Started")
 
-	var source models.Source
-	res := s.db.First(&source, s.sourceId)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
+	source, err := s.store.Sources().Get(s.sourceId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return "", fmt.Errorf("CreateReservationCommand: Could not find the source with this id: %s", s.sourceId)
 		}
-		return "", res.Error
+		return "", err
 	}
 
-	maxDurationForSource, err := time.ParseDuration(source.MaxPossibleDuration)
+	reserver, err := resolvePerson(s.store, "CreateReservationCommand", source.CustomerID, s.reserverId)
+	if err != nil {
+		return "", err
+	}
+	reservee, err := resolvePerson(s.store, "CreateReservationCommand", source.CustomerID, s.reserveeId)
 	if err != nil {
 		return "", err
 	}
 
-	reservationDuration := s.to.Sub(s.from)
+	participants, err := newParticipants(s.store, "CreateReservationCommand", source.CustomerID, s.participants)
+	if err != nil {
+		return "", err
+	}
 
-	if maxDurationForSource < reservationDuration {
-		return "", errors.New("CreateReservationCommand: Tried creating a reservation longer than maximum for this source")
+	personIds := []string{reserver.ID, reservee.ID}
+	for _, participant := range participants {
+		personIds = append(personIds, participant.PersonID)
 	}
 
-	var countOfOverlaps int64
-	res = s.db.Raw(CHECK_IF_INSERT_POSSIBLE_SQL,
-		sql.Named("from", s.from),
-		sql.Named("to", s.to),
-		sql.Named("source", s.sourceId),
-		sql.Named("reservee", s.reserveeId),
-		sql.Named("reserver", s.reserverId),
-	).Count(&countOfOverlaps)
-	if res.Error != nil {
-		return "", res.Error
+	reservation := models.Reservation{
+		From:         s.from,
+		To:           s.to,
+		SourceID:     s.sourceId,
+		ReserverID:   reserver.ID,
+		ReserveeID:   reservee.ID,
+		Participants: participants,
+		Units:        s.units,
 	}
 
-	if countOfOverlaps > 0 {
-		return "", errors.New("CreateReservationCommand: Can not create reservation there are overlapping reservations")
+	err = applyApprovalRules(source, &reservation)
+	if err != nil {
+		return "", err
 	}
 
-	reservation := models.Reservation{
-		From:       s.from,
-		To:         s.to,
-		SourceID:   s.sourceId,
-		ReserverID: s.reserverId,
-		ReserveeID: s.reserveeId,
+	err = priceReservation(s.store, "CreateReservationCommand", source, &reservation)
+	if err != nil {
+		return "", err
 	}
 
-	res = s.db.Create(&reservation)
-	if res.Error != nil {
-		return "", res.Error
+	// The slot is held while the payment is on its way, the status the
+	// reservation would get otherwise is applied once it is paid.
+	paidStatus := reservation.Status
+	paymentNeeded := false
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		_, err := checkSlotFree(tx, "CreateReservationCommand", source.ID, s.from, s.to, personIds, nil)
+		if err != nil {
+			return err
+		}
+
+		err = useReservationQuota(tx, "CreateReservationCommand", source.CustomerID, 1)
+		if err != nil {
+			return err
+		}
+
+		var redemption *models.PromotionRedemption
+		if s.promotionCode != "" {
+			promotion, discount, err := redeemPromotion(tx, "CreateReservationCommand", source.CustomerID, s.promotionCode, &reservation)
+			if err != nil {
+				return err
+			}
+			redemption = &models.PromotionRedemption{
+				PromotionID:    promotion.ID,
+				ReserveeID:     reservation.ReserveeID,
+				DiscountAmount: discount,
+				Currency:       reservation.Currency,
+			}
+		}
+
+		// Payment is decided on the discounted total, a reservation the
+		// promotion made free is not charged.
+		paymentNeeded, err = needsPayment("CreateReservationCommand", s.provider, source, reservation, s.paymentMethod)
+		if err != nil {
+			return err
+		}
+		if paymentNeeded {
+			reservation.Status = models.ReservationStatusPendingPayment
+		}
+
+		err = tx.Reservations().Create(&reservation)
+		if err != nil {
+			return err
+		}
+
+		if redemption != nil {
+			redemption.ReservationID = reservation.ID
+			err = tx.Promotions().CreateRedemption(redemption)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Record(events.NewEvent(events.ReservationCreated, source.CustomerID, reservation.ID, reservation))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if paymentNeeded {
+		err = payReservation(s.store, s.provider, "CreateReservationCommand", &reservation, paidStatus, s.paymentMethod)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	s.logger.Debug().Msg("CreateReservationCommand: Finished with success")
@@ -99,24 +310,53 @@ func (s *CreateReservationCommand) Execute() (string, error) {
 }
 
 type DeleteReservationCommand struct {
-	db     *gorm.DB
-	logger *zerolog.Logger
-	id     string
+	store    repository.Store
+	logger   *zerolog.Logger
+	provider payments.PaymentProvider
+	id       string
+	override *models.FeeOverride
+	fee      *models.ReservationFee
+}
+
+func NewDeleteReservationCommand(store repository.Store, logger *zerolog.Logger, provider payments.PaymentProvider, id string, override *models.FeeOverride) *DeleteReservationCommand {
+	return &DeleteReservationCommand{store: store, logger: logger, provider: provider, id: id, override: override}
 }
 
-func NewDeleteReservationCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteReservationCommand {
-	return &DeleteReservationCommand{db: db, logger: logger, id: id}
+// Fee returns the fee charged by Execute, nil when cancelling was free.
+func (s *DeleteReservationCommand) Fee() *models.ReservationFee {
+	return s.fee
 }
 
+// Execute cancels the reservation. It is kept with the charged fee instead of
+// being removed so that the fee stays on record, and whatever was paid beyond
+// the fee is refunded.
 func (s *DeleteReservationCommand) Execute() (string, error) {
 	if s.id == "" {
 		return "", errors.New("DeleteReservationCommand: Tried deleting with empty id")
 	}
 	s.logger.Debug().Msg("DeleteReservationCommand: Started")
 
-	res := s.db.Delete(models.Reservation{}, s.id)
-	if res.Error != nil {
-		return "", res.Error
+	var reservation models.Reservation
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		reservation, err = tx.Reservations().GetForUpdate(s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("DeleteReservationCommand: Could not find the reservation with this id: %s", s.id)
+			}
+			return err
+		}
+
+		s.fee, err = cancelSingleReservation(tx, "DeleteReservationCommand", &reservation, s.override)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = refundReservation(s.store, s.provider, "DeleteReservationCommand", reservation)
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("DeleteReservationCommand: Finished with success")
@@ -124,83 +364,146 @@ func (s *DeleteReservationCommand) Execute() (string, error) {
 	return s.id, nil
 }
 
+// cancelSingleReservation cancels the reservation the transaction holds and
+// records the cancellation. A bundle is only ever cancelled as a whole, a
+// released member would keep the rest of it from being rescheduled.
+func cancelSingleReservation(tx repository.Store, caller string, reservation *models.Reservation, override *models.FeeOverride) (*models.ReservationFee, error) {
+	if reservation.BundleID != nil {
+		return nil, fmt.Errorf("%s: Reservation %s is part of bundle %s, cancel the bundle instead", caller, reservation.ID, *reservation.BundleID)
+	}
+
+	fee, err := cancelReservation(tx, caller, reservation, time.Now(), override)
+	if err != nil {
+		return nil, err
+	}
+	return fee, tx.Record(events.NewEvent(events.ReservationCancelled, sourceCustomerId(tx, reservation.SourceID), reservation.ID, *reservation))
+}
+
+// cancelReservation releases the slot of the reservation and charges the
+// cancellation fee of its source.
+func cancelReservation(tx repository.Store, caller string, reservation *models.Reservation, now time.Time, override *models.FeeOverride) (*models.ReservationFee, error) {
+	if reservation.IsReleased() {
+		return nil, fmt.Errorf("%s: Reservation %s is already %s", caller, reservation.ID, reservation.Status)
+	}
+
+	source, err := tx.Sources().Get(reservation.SourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := chargeFee(tx, caller, source, *reservation, models.FeeKindCancellation, now, override)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation.Status = models.ReservationStatusCancelled
+	reservation.CancelledAt = &now
+	reservation.Sequence++
+	err = tx.Reservations().Save(reservation)
+	if err != nil {
+		return nil, err
+	}
+
+	return fee, nil
+}
+
 type UpdateReservationCommand struct {
-	db     *gorm.DB
-	logger *zerolog.Logger
-	id     string
-	from   *time.Time
-	to     *time.Time
+	store    repository.Store
+	logger   *zerolog.Logger
+	id       string
+	from     *time.Time
+	to       *time.Time
+	override *models.FeeOverride
+	fee      *models.ReservationFee
 }
 
-func NewUpdateReservationCommand(db *gorm.DB, logger *zerolog.Logger, id string, from, to *time.Time) *UpdateReservationCommand {
-	return &UpdateReservationCommand{db: db, logger: logger, id: id, from: from, to: to}
+func NewUpdateReservationCommand(store repository.Store, logger *zerolog.Logger, id string, from, to *time.Time, override *models.FeeOverride) *UpdateReservationCommand {
+	return &UpdateReservationCommand{store: store, logger: logger, id: id, from: from, to: to, override: override}
 }
 
+// Fee returns the fee charged by Execute, nil when rescheduling was free.
+func (s *UpdateReservationCommand) Fee() *models.ReservationFee {
+	return s.fee
+}
+
+// Execute checks and changes the reservation in one transaction that holds
+// it, so that a cancellation or decision committed in the meantime is seen
+// rather than overwritten.
 func (s *UpdateReservationCommand) Execute() (string, error) {
 	if s.id == "" {
 		return "", errors.New("UpdateReservationCommand: Tried updating with empty id")
 	}
 	s.logger.Debug().Msg("UpdateReservationCommand: Started")
 
-	var reservation models.Reservation
-	res := s.db.First(&reservation, s.id)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("UpdateReservationCommand: Could not find the reservation with this id: %s", s.id)
+	err := s.store.Transaction(func(tx repository.Store) error {
+		reservation, err := tx.Reservations().GetForUpdate(s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("UpdateReservationCommand: Could not find the reservation with this id: %s", s.id)
+			}
+			return err
 		}
-		return "", res.Error
+
+		s.fee, err = rescheduleReservation(tx, "UpdateReservationCommand", reservation, s.from, s.to, s.override)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
 
-	var source models.Source
-	res = s.db.First(&source, reservation.SourceID)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("UpdateReservationCommand: Could not find the source with this id: %s", reservation.SourceID)
-		}
-		return "", res.Error
+	s.logger.Debug().Msg("UpdateReservationCommand: Finished with success")
+
+	return s.id, nil
+}
+
+// rescheduleReservation moves the reservation the transaction holds to the
+// new window and charges the modification fee of its source.
+func rescheduleReservation(tx repository.Store, caller string, reservation models.Reservation, from, to *time.Time, override *models.FeeOverride) (*models.ReservationFee, error) {
+	var fee *models.ReservationFee
+	if reservation.BundleID != nil {
+		return nil, fmt.Errorf("%s: Reservation %s is part of bundle %s, reschedule the bundle instead", caller, reservation.ID, *reservation.BundleID)
+	}
+
+	if reservation.IsReleased() {
+		return nil, fmt.Errorf("%s: Reservation %s is %s and can not be changed", caller, reservation.ID, reservation.Status)
 	}
 
-	if s.from != nil {
-		reservation.From = *s.from
+	original := reservation
+	if from != nil {
+		reservation.From = *from
 	}
-	if s.to != nil {
-		reservation.To = *s.to
+	if to != nil {
+		reservation.To = *to
 	}
 
-	maxDurationForSource, err := time.ParseDuration(source.MaxPossibleDuration)
+	personIds, err := busyPersonIds(tx, reservation)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	reservationDuration := reservation.To.Sub(reservation.From)
-
-	if maxDurationForSource < reservationDuration {
-		return "", errors.New("CreateReservationCommand: Tried creating a reservation longer than maximum for this source")
+	source, err := checkSlotFree(tx, caller, reservation.SourceID, reservation.From, reservation.To, personIds, []string{reservation.ID})
+	if err != nil {
+		return nil, err
 	}
 
-	var countOfOverlaps int64
-	res = s.db.Raw(CHECK_IF_UPDATE_POSSIBLE_SQL,
-		sql.Named("from", reservation.From),
-		sql.Named("to", reservation.To),
-		sql.Named("source", source.ID),
-		sql.Named("reservee", reservation.ReserveeID),
-		sql.Named("reserver", reservation.ReserverID),
-		sql.Named("id", reservation.ID),
-	).Count(&countOfOverlaps)
-	if res.Error != nil {
-		return "", res.Error
+	// Pricing records the new discount of a redeemed promotion, which
+	// has to be undone when the update fails.
+	err = priceReservation(tx, caller, source, &reservation)
+	if err != nil {
+		return nil, err
 	}
 
-	if countOfOverlaps > 0 {
-		return "", errors.New("CreateReservationCommand: Can not create reservation there are overlapping reservations")
+	// The fee depends on how close the change is to the original start.
+	if !original.From.Equal(reservation.From) || !original.To.Equal(reservation.To) {
+		fee, err = chargeFee(tx, caller, source, original, models.FeeKindModification, time.Now(), override)
+		if err != nil {
+			return nil, err
+		}
+		reservation.Sequence++
 	}
-
-	res = s.db.Save(&reservation)
-	if res.Error != nil {
-		return "", res.Error
+	err = tx.Reservations().Save(&reservation)
+	if err != nil {
+		return nil, err
 	}
-
-	s.logger.Debug().Msg("UpdateReservationCommand: Finished with success")
-
-	return s.id, nil
+	return fee, tx.Record(events.NewEvent(events.ReservationUpdated, source.CustomerID, reservation.ID, reservation))
 }
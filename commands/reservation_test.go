@@ -0,0 +1,555 @@
+package commands
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// booking is a customer with two sources and three persons, alice, bob and
+// carol, to make reservations with.
+type booking struct {
+	*repotest.Fixture
+	customer models.Customer
+	room     models.Source
+	hall     models.Source
+	alice    models.Person
+	bob      models.Person
+	carol    models.Person
+}
+
+func newBooking(t *testing.T, f *repotest.Fixture, plan models.Plan) *booking {
+	t.Helper()
+	b := &booking{Fixture: f}
+	b.customer = f.Customer(t, "acme", &plan)
+	b.room = f.Source(t, b.customer, "room")
+	b.hall = f.Source(t, b.customer, "hall")
+	b.alice = f.Person(t, b.customer, "alice")
+	b.bob = f.Person(t, b.customer, "bob")
+	b.carol = f.Person(t, b.customer, "carol")
+	return b
+}
+
+var largePlan = models.Plan{Name: "large", MaxSources: 5, Features: models.AllFeatures}
+
+func (b *booking) create(source models.Source, from, to int, reserver, reservee string, participants ...string) (string, error) {
+	var requested []models.ReservationParticipant
+	for _, participant := range participants {
+		requested = append(requested, models.ReservationParticipant{PersonID: participant})
+	}
+	return NewCreateReservationCommand(b, b.Logger, nil, repotest.At(from), repotest.At(to), reserver, reservee, source.ID, requested, 1, "", "").Execute()
+}
+
+func (b *booking) reservation(t *testing.T, id string) models.Reservation {
+	t.Helper()
+	reservation, err := b.Reservations().Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reservation
+}
+
+func TestCreateReservationCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+
+		if _, err := b.create(models.Source{}, 9, 10, "alice", "bob"); err == nil {
+			t.Error("created a reservation without a source")
+		}
+		if _, err := b.create(b.room, 9, 10, "alice", "nobody"); err == nil {
+			t.Error("created a reservation for a person that does not exist")
+		}
+		if _, err := b.create(b.room, 9, 10, "alice", "bob", "carol", "carol"); err == nil {
+			t.Error("created a reservation with a participant given twice")
+		}
+		if _, err := b.create(b.room, 10, 9, "alice", "bob"); err == nil {
+			t.Error("created a reservation that ends before it starts")
+		}
+		if _, err := b.create(b.room, 0, 25, "alice", "bob"); err == nil {
+			t.Error("created a reservation longer than the source allows")
+		}
+
+		// Persons are found by their id as well as by their external reference.
+		id, err := b.create(b.room, 9, 11, b.alice.ID, "bob", "carol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if reservation.ReserverID != b.alice.ID || reservation.ReserveeID != b.bob.ID || reservation.Status != models.ReservationStatusConfirmed {
+			t.Errorf("stored %+v", reservation)
+		}
+		if len(reservation.Participants) != 1 || reservation.Participants[0].PersonID != b.carol.ID || reservation.Participants[0].RSVP != models.RSVPPending {
+			t.Errorf("stored participants %+v", reservation.Participants)
+		}
+		if reservation.TotalAmount != 2000 || reservation.Currency != "EUR" {
+			t.Errorf("priced %d %s", reservation.TotalAmount, reservation.Currency)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.ReservationCreated}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestCreateReservationCommandRefusesOverlaps(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		hall         bool
+		from, to     int
+		reserver     string
+		reservee     string
+		participants []string
+		overlaps     bool
+	}{
+		{name: "same source", from: 10, to: 12, reserver: "dave", reservee: "erin", overlaps: true},
+		{name: "same source within", from: 9, to: 10, reserver: "dave", reservee: "erin", overlaps: true},
+		{name: "same source around", from: 8, to: 12, reserver: "dave", reservee: "erin", overlaps: true},
+		{name: "reserver elsewhere", hall: true, from: 10, to: 12, reserver: "alice", reservee: "erin", overlaps: true},
+		{name: "reservee elsewhere", hall: true, from: 10, to: 12, reserver: "dave", reservee: "bob", overlaps: true},
+		{name: "participant elsewhere", hall: true, from: 10, to: 12, reserver: "dave", reservee: "erin", participants: []string{"carol"}, overlaps: true},
+		{name: "reserver was participant", hall: true, from: 10, to: 12, reserver: "carol", reservee: "erin", overlaps: true},
+		{name: "ends at the start", from: 7, to: 9, reserver: "alice", reservee: "bob"},
+		{name: "starts at the end", from: 11, to: 12, reserver: "alice", reservee: "bob"},
+		{name: "other persons elsewhere", hall: true, from: 9, to: 11, reserver: "dave", reservee: "erin"},
+		{name: "declined participant elsewhere", hall: true, from: 9, to: 11, reserver: "dave", reservee: "erin", participants: []string{"frank"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+				b := newBooking(t, f, largePlan)
+				f.Person(t, b.customer, "dave")
+				f.Person(t, b.customer, "erin")
+				frank := f.Person(t, b.customer, "frank")
+				f.Add(t, &models.Reservation{
+					From:         repotest.At(9),
+					To:           repotest.At(11),
+					SourceID:     b.room.ID,
+					ReserverID:   b.alice.ID,
+					ReserveeID:   b.bob.ID,
+					Status:       models.ReservationStatusConfirmed,
+					Participants: []models.Participant{{PersonID: b.carol.ID, Role: models.ParticipantRoleRequired, RSVP: models.RSVPAccepted}, {PersonID: frank.ID, Role: models.ParticipantRoleOptional, RSVP: models.RSVPDeclined}},
+				})
+
+				source := b.room
+				if test.hall {
+					source = b.hall
+				}
+				_, err := b.create(source, test.from, test.to, test.reserver, test.reservee, test.participants...)
+				if test.overlaps && !errors.Is(err, ErrOverlappingReservations) {
+					t.Errorf("got %v instead of an overlap", err)
+				}
+				if !test.overlaps && err != nil {
+					t.Error(err)
+				}
+			})
+		})
+	}
+}
+
+func TestCreateReservationCommandIgnoresReleasedReservations(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		for _, status := range models.ReleasedReservationStatuses {
+			f.Add(t, &models.Reservation{From: repotest.At(9), To: repotest.At(11), SourceID: b.room.ID, ReserverID: b.alice.ID, ReserveeID: b.bob.ID, Status: status})
+		}
+
+		_, err := b.create(b.room, 9, 11, "alice", "bob")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestCreateReservationCommandRollsBackOverQuota(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, models.Plan{Name: "small", MaxSources: 5, MaxReservationsPerMonth: 1})
+
+		if _, err := b.create(b.room, 9, 10, "alice", "bob"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.create(b.room, 10, 11, "alice", "bob"); err == nil {
+			t.Error("created more reservations than the plan allows")
+		}
+
+		_, total, err := f.Reservations().Filter(repository.ReservationFilter{SourceID: b.room.ID}, models.Pagination{Page: 1, Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 {
+			t.Errorf("stored %d reservations", total)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.ReservationCreated}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestCreateReservationCommandRedeemsPromotions(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		promotion := models.Promotion{CustomerID: b.customer.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 25, MaxRedemptions: 1, Active: true}
+		f.Add(t, &promotion)
+
+		create := func(from, to int, code string) (string, error) {
+			return NewCreateReservationCommand(f, f.Logger, nil, repotest.At(from), repotest.At(to), "alice", "bob", b.room.ID, nil, 1, code, "").Execute()
+		}
+		if _, err := create(9, 11, "WINTER"); err == nil {
+			t.Error("redeemed a promotion that does not exist")
+		}
+
+		id, err := create(9, 11, "spring")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if reservation.TotalAmount != 1500 || reservation.PromotionID == nil || *reservation.PromotionID != promotion.ID {
+			t.Errorf("stored %+v", reservation)
+		}
+		redeemed, err := f.Promotions().Get(promotion.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redeemed.RedemptionCount != 1 {
+			t.Errorf("counted %d redemptions", redeemed.RedemptionCount)
+		}
+
+		if _, err := create(11, 12, "SPRING"); err == nil {
+			t.Error("redeemed a promotion more often than allowed")
+		}
+	})
+}
+
+func TestCreateReservationCommandChargesPayments(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		b.room.RequiresPayment = true
+		if err := f.Sources().Save(&b.room); err != nil {
+			t.Fatal(err)
+		}
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		provider := payments.NewFakeProvider("test")
+
+		create := func(from, to int, method string) (string, error) {
+			return NewCreateReservationCommand(f, f.Logger, provider, repotest.At(from), repotest.At(to), "alice", "bob", b.room.ID, nil, 1, "", method).Execute()
+		}
+		if _, err := create(9, 10, ""); err == nil {
+			t.Error("created a reservation that requires payment without a payment method")
+		}
+
+		id, err := create(9, 10, "card")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paid := b.reservation(t, id)
+		if paid.Status != models.ReservationStatusConfirmed || len(paid.Payments) != 1 || paid.Payments[0].Status != models.PaymentStatusCaptured || paid.Payments[0].Amount != 1000 {
+			t.Errorf("stored %+v", paid)
+		}
+
+		if _, err := create(10, 11, payments.FakeDeclinedMethod); err == nil {
+			t.Error("a declined payment did not fail")
+		}
+		failed, _, err := f.Reservations().Filter(repository.ReservationFilter{Status: models.ReservationStatusPaymentFailed}, models.Pagination{Page: 1, Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed) != 1 {
+			t.Fatalf("stored %d reservations with a failed payment", len(failed))
+		}
+		if declined := b.reservation(t, failed[0].ID); len(declined.Payments) != 1 || declined.Payments[0].Status != models.PaymentStatusFailed {
+			t.Errorf("stored %+v", declined)
+		}
+
+		// A declined payment releases the slot.
+		if _, err := create(10, 11, "card"); err != nil {
+			t.Error(err)
+		}
+		want := []string{events.ReservationCreated, events.ReservationPaid, events.ReservationCreated, events.ReservationPaymentFailed, events.ReservationCreated, events.ReservationPaid}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestCreateReservationCommandSkipsPaymentOfFreeReservations(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		b.room.RequiresPayment = true
+		if err := f.Sources().Save(&b.room); err != nil {
+			t.Fatal(err)
+		}
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		f.Add(t, &models.Promotion{CustomerID: b.customer.ID, Code: "FREE", Kind: models.PromotionKindPercentage, PercentOff: 100, Active: true})
+
+		id, err := NewCreateReservationCommand(f, f.Logger, payments.NewFakeProvider("test"), repotest.At(9), repotest.At(10), "alice", "bob", b.room.ID, nil, 1, "FREE", "card").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if reservation.Status != models.ReservationStatusConfirmed || reservation.TotalAmount != 0 || len(reservation.Payments) != 0 {
+			t.Errorf("stored %+v", reservation)
+		}
+	})
+}
+
+func TestUpdateReservationCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		policy := models.CancellationPolicy{CustomerID: b.customer.ID, Name: "strict", ModificationRules: models.PolicyRules{{Before: "0s", FeePercent: 10}}}
+		f.Add(t, &policy)
+		b.room.CancellationPolicyID = &policy.ID
+		if err := f.Sources().Save(&b.room); err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := b.create(b.room, 9, 11, "alice", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.create(b.room, 13, 14, "carol", "carol"); err != nil {
+			t.Fatal(err)
+		}
+
+		update := func(from, to int) (*UpdateReservationCommand, error) {
+			fromTime, toTime := repotest.At(from), repotest.At(to)
+			command := NewUpdateReservationCommand(f, f.Logger, id, &fromTime, &toTime, nil)
+			_, err := command.Execute()
+			return command, err
+		}
+		if _, err := NewUpdateReservationCommand(f, f.Logger, "00000000-0000-0000-0000-000000000000", nil, nil, nil).Execute(); err == nil {
+			t.Error("updated a reservation that does not exist")
+		}
+		if _, err := update(12, 14); !errors.Is(err, ErrOverlappingReservations) {
+			t.Errorf("got %v instead of an overlap", err)
+		}
+
+		// The reservation does not overlap with itself.
+		command, err := update(10, 13)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if !reservation.From.Equal(repotest.At(10)) || !reservation.To.Equal(repotest.At(13)) || reservation.TotalAmount != 3000 || reservation.Sequence != 1 {
+			t.Errorf("stored %+v", reservation)
+		}
+		if command.Fee() == nil || command.Fee().Kind != models.FeeKindModification || command.Fee().Amount != 200 {
+			t.Errorf("charged %+v", command.Fee())
+		}
+		if len(reservation.Fees) != 1 {
+			t.Errorf("stored fees %+v", reservation.Fees)
+		}
+		want := []string{events.ReservationCreated, events.ReservationCreated, events.ReservationUpdated}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestUpdateReservationCommandKeepsDiscountOfFailedUpdates(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		f.Add(t, &models.Promotion{CustomerID: b.customer.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 25, Active: true})
+		id, err := NewCreateReservationCommand(f, f.Logger, nil, repotest.At(9), repotest.At(11), "alice", "bob", b.room.ID, nil, 1, "SPRING", "").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// An override without a reason fails after the new window is priced.
+		from, to := repotest.At(9), repotest.At(13)
+		_, err = NewUpdateReservationCommand(f, f.Logger, id, &from, &to, &models.FeeOverride{FeePercent: 10}).Execute()
+		if err == nil {
+			t.Fatal("updated with an override without a reason")
+		}
+		redemption, err := f.Promotions().Redemption(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redemption.DiscountAmount != 500 {
+			t.Errorf("a failed update changed the discount to %d", redemption.DiscountAmount)
+		}
+	})
+}
+
+// interleaved is a store on which another command commits right before the
+// first transaction begins, as if it ran concurrently.
+type interleaved struct {
+	repository.Store
+	before func()
+	once   sync.Once
+}
+
+func (s *interleaved) Transaction(f func(tx repository.Store) error) error {
+	s.once.Do(s.before)
+	return s.Store.Transaction(f)
+}
+
+func TestUpdateReservationCommandSeesConcurrentCancellation(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		id, err := b.create(b.room, 9, 11, "alice", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := &interleaved{Store: f, before: func() {
+			if _, err := NewDeleteReservationCommand(f, f.Logger, nil, id, nil).Execute(); err != nil {
+				t.Fatal(err)
+			}
+		}}
+		from, to := repotest.At(10), repotest.At(12)
+		if _, err = NewUpdateReservationCommand(store, f.Logger, id, &from, &to, nil).Execute(); err == nil {
+			t.Error("updated a reservation that was cancelled in the meantime")
+		}
+		if reservation := b.reservation(t, id); reservation.Status != models.ReservationStatusCancelled || !reservation.From.Equal(repotest.At(9)) {
+			t.Errorf("the cancellation was undone into %+v", reservation)
+		}
+	})
+}
+
+// personLocks is a store that records the persons its transactions lock.
+type personLocks struct {
+	repository.Store
+	mu     sync.Mutex
+	locked [][]string
+}
+
+func (s *personLocks) Transaction(f func(tx repository.Store) error) error {
+	return s.Store.Transaction(func(tx repository.Store) error {
+		return f(&lockingTx{Store: tx, locks: s})
+	})
+}
+
+type lockingTx struct {
+	repository.Store
+	locks *personLocks
+}
+
+func (tx *lockingTx) People() repository.People {
+	return lockingPeople{People: tx.Store.People(), locks: tx.locks}
+}
+
+type lockingPeople struct {
+	repository.People
+	locks *personLocks
+}
+
+func (p lockingPeople) GetForUpdate(customerId string, ids []string) ([]models.Person, error) {
+	people, err := p.People.GetForUpdate(customerId, ids)
+	p.locks.mu.Lock()
+	defer p.locks.mu.Unlock()
+	var locked []string
+	for _, person := range people {
+		locked = append(locked, person.ID)
+	}
+	p.locks.locked = append(p.locks.locked, locked)
+	return people, err
+}
+
+func TestCreateReservationCommandLocksThePersons(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		store := &personLocks{Store: f}
+
+		_, err := NewCreateReservationCommand(store, f.Logger, nil, repotest.At(9), repotest.At(10), "alice", "bob", b.room.ID,
+			[]models.ReservationParticipant{{PersonID: "carol"}}, 1, "", "").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{b.alice.ID, b.bob.ID, b.carol.ID}
+		slices.Sort(want)
+		if len(store.locked) != 1 || !slices.Equal(store.locked[0], want) {
+			t.Errorf("locked %v in the transaction, want %v in the order of their ids", store.locked, want)
+		}
+	})
+}
+
+func TestCreateReservationCommandBooksAPersonOnce(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+
+		// Bookings of the same person on different sources that run at the
+		// same time do not both get through the overlap check.
+		var wg sync.WaitGroup
+		errs := make([]error, 6)
+		for i := range errs {
+			source := b.room
+			if i%2 == 1 {
+				source = b.hall
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = b.create(source, 9, 10, "alice", "bob")
+			}()
+		}
+		wg.Wait()
+
+		booked := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				booked++
+			case !errors.Is(err, ErrOverlappingReservations):
+				t.Errorf("booking failed with %v", err)
+			}
+		}
+		if booked != 1 {
+			t.Errorf("booked alice %d times at once", booked)
+		}
+	})
+}
+
+func TestDeleteReservationCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		b := newBooking(t, f, largePlan)
+		b.room.RequiresPayment = true
+		policy := models.CancellationPolicy{CustomerID: b.customer.ID, Name: "strict", CancellationRules: models.PolicyRules{{Before: "0s", FeePercent: 50}}}
+		f.Add(t, &policy)
+		b.room.CancellationPolicyID = &policy.ID
+		if err := f.Sources().Save(&b.room); err != nil {
+			t.Fatal(err)
+		}
+		f.Add(t, &models.Rate{SourceID: b.room.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000})
+		provider := payments.NewFakeProvider("test")
+
+		id, err := NewCreateReservationCommand(f, f.Logger, provider, repotest.At(9), repotest.At(11), "alice", "bob", b.room.ID, nil, 1, "", "card").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewDeleteReservationCommand(f, f.Logger, provider, "00000000-0000-0000-0000-000000000000", nil).Execute(); err == nil {
+			t.Error("cancelled a reservation that does not exist")
+		}
+
+		command := NewDeleteReservationCommand(f, f.Logger, provider, id, nil)
+		if _, err := command.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		reservation := b.reservation(t, id)
+		if reservation.Status != models.ReservationStatusCancelled || reservation.CancelledAt == nil {
+			t.Errorf("stored %+v", reservation)
+		}
+		if command.Fee() == nil || command.Fee().Amount != 1000 {
+			t.Errorf("charged %+v", command.Fee())
+		}
+		if len(reservation.Payments) != 1 || reservation.Payments[0].RefundedAmount != 1000 || reservation.Payments[0].Status != models.PaymentStatusPartiallyRefunded {
+			t.Errorf("stored payments %+v", reservation.Payments)
+		}
+
+		if _, err := NewDeleteReservationCommand(f, f.Logger, provider, id, nil).Execute(); err == nil {
+			t.Error("cancelled a reservation twice")
+		}
+		want := []string{events.ReservationCreated, events.ReservationPaid, events.ReservationCancelled}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
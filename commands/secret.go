@@ -5,20 +5,20 @@ import (
 	"fmt"
 
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/lghtr35/reservation-engine/util"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
 )
 
 type CreateSecretCommand struct {
-	db         *gorm.DB
+	store      repository.Store
 	logger     *zerolog.Logger
 	hasher     *util.Hasher
 	customerId string
 }
 
-func NewCreateSecretCommand(db *gorm.DB, logger *zerolog.Logger, hasher *util.Hasher, customerId string) *CreateSecretCommand {
-	return &CreateSecretCommand{db: db, logger: logger, hasher: hasher, customerId: customerId}
+func NewCreateSecretCommand(store repository.Store, logger *zerolog.Logger, hasher *util.Hasher, customerId string) *CreateSecretCommand {
+	return &CreateSecretCommand{store: store, logger: logger, hasher: hasher, customerId: customerId}
 }
 
 func (s *CreateSecretCommand) Execute() (string, error) {
@@ -27,13 +27,12 @@ func (s *CreateSecretCommand) Execute() (string, error) {
 	}
 	s.logger.Debug().Msg("CreateSecretCommand: Started")
 
-	var customer models.Customer
-	res := s.db.First(&customer, s.customerId)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
+	customer, err := s.store.Customers().Get(s.customerId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return "", fmt.Errorf("CreateSecretCommand: Could not find the customer with id: %s", s.customerId)
 		}
-		return "", res.Error
+		return "", err
 	}
 
 	hashed, err := s.hasher.GetHash(fmt.Sprintf("%s:%s", customer.Company, util.GetRandString(16)))
@@ -45,9 +44,9 @@ func (s *CreateSecretCommand) Execute() (string, error) {
 		CustomerID: s.customerId,
 		Value:      hashed,
 	}
-	res = s.db.Create(&apiSecret)
-	if res.Error != nil {
-		return "", res.Error
+	err = s.store.Secrets().Create(&apiSecret)
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("CreateSecretCommand: Finished with success")
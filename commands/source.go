@@ -6,22 +6,106 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/lghtr35/reservation-engine/events"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
 )
 
+// validateApprovalSettings checks the approval settings of a source of the
+// given customer and resolves the approver to a person id.
+func validateApprovalSettings(store repository.Store, caller, customerId string, approverId *string, approvalTimeout string) (*string, error) {
+	if approvalTimeout != "" {
+		if _, err := time.ParseDuration(approvalTimeout); err != nil {
+			return nil, fmt.Errorf("%s: Approval timeout %q is not a valid duration", caller, approvalTimeout)
+		}
+	}
+
+	if approverId == nil || *approverId == "" {
+		return nil, nil
+	}
+	approver, err := resolvePerson(store, caller, customerId, *approverId)
+	if err != nil {
+		return nil, err
+	}
+	return &approver.ID, nil
+}
+
 type CreateSourceCommand struct {
-	db          *gorm.DB
-	logger      *zerolog.Logger
-	name        string
-	maxDuration string
-	customerId  string
+	store            repository.Store
+	logger           *zerolog.Logger
+	name             string
+	maxDuration      string
+	customerId       string
+	requiresApproval bool
+	approverId       *string
+	approvalTimeout  string
+	policyId         *string
+	pricing          models.SourcePricing
+}
+
+func NewCreateSourceCommand(store repository.Store, logger *zerolog.Logger, name string, maxPossibleDuration string, customerId string, requiresApproval bool, approverId *string, approvalTimeout string, policyId *string, pricing models.SourcePricing) *CreateSourceCommand {
+	return &CreateSourceCommand{store: store, logger: logger, name: name, maxDuration: maxPossibleDuration, customerId: customerId, requiresApproval: requiresApproval, approverId: approverId, approvalTimeout: approvalTimeout, policyId: policyId, pricing: pricing}
+}
+
+// applyPricing copies the pricing settings onto the source, keeping the
+// current ones for empty fields.
+func applyPricing(caller string, source *models.Source, pricingSettings models.SourcePricing) error {
+	if pricingSettings.Currency != "" {
+		source.Currency = pricingSettings.Currency
+	}
+	if pricingSettings.Timezone != "" {
+		source.Timezone = pricingSettings.Timezone
+	}
+	if source.Currency == "" {
+		source.Currency = "EUR"
+	}
+	if source.Timezone == "" {
+		source.Timezone = "UTC"
+	}
+	if err := pricing.ValidateSettings(source.Currency, source.Timezone); err != nil {
+		return fmt.Errorf("%s: %w", caller, err)
+	}
+
+	if pricingSettings.WeekendSurchargePercent < 0 || pricingSettings.Capacity < 0 {
+		return fmt.Errorf("%s: Weekend surcharge and capacity can not be negative", caller)
+	}
+	source.WeekendSurchargePercent = pricingSettings.WeekendSurchargePercent
+	source.Capacity = pricingSettings.Capacity
+	source.RequiresPayment = pricingSettings.RequiresPayment
+	return nil
 }
 
-func NewCreateSourceCommand(db *gorm.DB, logger *zerolog.Logger, name string, maxPossibleDuration string, customerId string) *CreateSourceCommand {
-	return &CreateSourceCommand{db: db, logger: logger, name: name, maxDuration: maxPossibleDuration, customerId: customerId}
+// checkSourceFeatures fails when the source turns on a feature that the plan
+// of its customer does not include. Features that were already on are kept
+// so that a downgraded customer can still edit its sources.
+func checkSourceFeatures(plan models.Plan, caller string, before, after models.Source) error {
+	if after.RequiresApproval && !before.RequiresApproval && !plan.HasFeature(models.FeatureApprovals) {
+		return fmt.Errorf("%s: The plan %s does not include %s", caller, plan.Name, models.FeatureApprovals)
+	}
+	if after.RequiresPayment && !before.RequiresPayment && !plan.HasFeature(models.FeaturePayments) {
+		return fmt.Errorf("%s: The plan %s does not include %s", caller, plan.Name, models.FeaturePayments)
+	}
+	return nil
+}
+
+// validatePolicy checks that the cancellation policy belongs to the customer.
+func validatePolicy(store repository.Store, caller, customerId string, policyId *string) (*string, error) {
+	if policyId == nil || *policyId == "" {
+		return nil, nil
+	}
+
+	policy, err := store.Sources().CancellationPolicy(customerId, *policyId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%s: Could not find a cancellation policy of customer %s with id: %s", caller, customerId, *policyId)
+		}
+		return nil, err
+	}
+	return &policy.ID, nil
 }
 
 func (s *CreateSourceCommand) Execute() (string, error) {
@@ -30,28 +114,61 @@ func (s *CreateSourceCommand) Execute() (string, error) {
 	}
 	s.logger.Debug().Msg("CreateSourceCommand: Started")
 
-	var customer models.Customer
-	res := s.db.Preload("Sources").First(&customer, s.customerId)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
+	plan, err := s.store.Customers().Plan(s.customerId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return "", fmt.Errorf("CreateSourceCommand: Could not find the customer with id: %s", s.customerId)
 		}
-		return "", res.Error
+		return "", err
 	}
 
-	if customer.MaxSourceLimit == len(customer.Sources) {
+	countOfSources, err := s.store.Sources().CountForCustomer(s.customerId)
+	if err != nil {
+		return "", err
+	}
+	if plan.MaxSources > 0 && countOfSources >= int64(plan.MaxSources) {
 		return "", fmt.Errorf("CreateSourceCommand: Customer with id %s, has already hit the limit for sources", s.customerId)
 	}
 
+	approverId, err := validateApprovalSettings(s.store, "CreateSourceCommand", s.customerId, s.approverId, s.approvalTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	policyId, err := validatePolicy(s.store, "CreateSourceCommand", s.customerId, s.policyId)
+	if err != nil {
+		return "", err
+	}
+
 	source := models.Source{
-		Name:                s.name,
-		MaxPossibleDuration: s.maxDuration,
-		CustomerID:          s.customerId,
+		Name:                 s.name,
+		MaxPossibleDuration:  s.maxDuration,
+		CustomerID:           s.customerId,
+		RequiresApproval:     s.requiresApproval,
+		ApproverID:           approverId,
+		ApprovalTimeout:      s.approvalTimeout,
+		CancellationPolicyID: policyId,
+	}
+
+	err = applyPricing("CreateSourceCommand", &source, s.pricing)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkSourceFeatures(plan, "CreateSourceCommand", models.Source{}, source)
+	if err != nil {
+		return "", err
 	}
 
-	res = s.db.Create(&source)
-	if res.Error != nil {
-		return "", res.Error
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Sources().Create(&source)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.SourceCreated, source.CustomerID, source.ID, source))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("CreateSourceCommand: Finished with success")
@@ -60,13 +177,13 @@ func (s *CreateSourceCommand) Execute() (string, error) {
 }
 
 type DeleteSourceCommand struct {
-	db     *gorm.DB
+	store  repository.Store
 	logger *zerolog.Logger
 	id     string
 }
 
-func NewDeleteSourceCommand(db *gorm.DB, logger *zerolog.Logger, id string) *DeleteSourceCommand {
-	return &DeleteSourceCommand{db: db, logger: logger, id: id}
+func NewDeleteSourceCommand(store repository.Store, logger *zerolog.Logger, id string) *DeleteSourceCommand {
+	return &DeleteSourceCommand{store: store, logger: logger, id: id}
 }
 
 func (s *DeleteSourceCommand) Execute() (string, error) {
@@ -75,9 +192,23 @@ func (s *DeleteSourceCommand) Execute() (string, error) {
 	}
 	s.logger.Debug().Msg("DeleteSourceCommand: Started")
 
-	res := s.db.Delete(models.Source{}, s.id)
-	if res.Error != nil {
-		return "", res.Error
+	source, err := s.store.Sources().Get(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("DeleteSourceCommand: Could not find the source with id: %s", s.id)
+		}
+		return "", err
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Sources().Delete(source.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.SourceDeleted, source.CustomerID, source.ID, source))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("DeleteSourceCommand: Finished with success")
@@ -86,15 +217,20 @@ func (s *DeleteSourceCommand) Execute() (string, error) {
 }
 
 type UpdateSourceCommand struct {
-	db          *gorm.DB
-	logger      *zerolog.Logger
-	id          string
-	name        *string
-	maxDuration *string
+	store            repository.Store
+	logger           *zerolog.Logger
+	id               string
+	name             *string
+	maxDuration      *string
+	requiresApproval *bool
+	approverId       *string
+	approvalTimeout  *string
+	policyId         *string
+	pricing          *models.SourcePricing
 }
 
-func NewUpdateSourceCommand(db *gorm.DB, logger *zerolog.Logger, id string, name, maxDuration *string) *UpdateSourceCommand {
-	return &UpdateSourceCommand{db: db, logger: logger, id: id, name: name, maxDuration: maxDuration}
+func NewUpdateSourceCommand(store repository.Store, logger *zerolog.Logger, id string, name, maxDuration *string, requiresApproval *bool, approverId, approvalTimeout, policyId *string, pricing *models.SourcePricing) *UpdateSourceCommand {
+	return &UpdateSourceCommand{store: store, logger: logger, id: id, name: name, maxDuration: maxDuration, requiresApproval: requiresApproval, approverId: approverId, approvalTimeout: approvalTimeout, policyId: policyId, pricing: pricing}
 }
 
 func (s *UpdateSourceCommand) Execute() (string, error) {
@@ -103,26 +239,75 @@ func (s *UpdateSourceCommand) Execute() (string, error) {
 	}
 	s.logger.Debug().Msg("UpdateSourceCommand: Started")
 
-	var source models.Source
-	res := s.db.First(&source, s.id)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("UpdateReservationCommand: Could not find the source with id: %s", s.id)
+	source, err := s.store.Sources().Get(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("UpdateSourceCommand: Could not find the source with id: %s", s.id)
 		}
-		return "", res.Error
+		return "", err
 	}
+	original := source
 
 	if s.name != nil && *s.name != "" {
 		source.Name = *s.name
 	}
 
 	if s.maxDuration != nil && *s.maxDuration != "" {
-		source.Name = *s.name
+		source.MaxPossibleDuration = *s.maxDuration
+	}
+
+	if s.requiresApproval != nil {
+		source.RequiresApproval = *s.requiresApproval
+	}
+
+	if s.approvalTimeout != nil {
+		source.ApprovalTimeout = *s.approvalTimeout
 	}
 
-	res = s.db.Save(&source)
-	if res.Error != nil {
-		return "", res.Error
+	if s.approverId != nil || s.approvalTimeout != nil {
+		approverId := source.ApproverID
+		if s.approverId != nil {
+			approverId = s.approverId
+		}
+		approverId, err := validateApprovalSettings(s.store, "UpdateSourceCommand", source.CustomerID, approverId, source.ApprovalTimeout)
+		if err != nil {
+			return "", err
+		}
+		source.ApproverID = approverId
+	}
+
+	if s.policyId != nil {
+		policyId, err := validatePolicy(s.store, "UpdateSourceCommand", source.CustomerID, s.policyId)
+		if err != nil {
+			return "", err
+		}
+		source.CancellationPolicyID = policyId
+	}
+
+	if s.pricing != nil {
+		if err := applyPricing("UpdateSourceCommand", &source, *s.pricing); err != nil {
+			return "", err
+		}
+	}
+
+	plan, err := s.store.Customers().Plan(source.CustomerID)
+	if err != nil {
+		return "", err
+	}
+	err = checkSourceFeatures(plan, "UpdateSourceCommand", original, source)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.Sources().Save(&source)
+		if err != nil {
+			return err
+		}
+		return tx.Record(events.NewEvent(events.SourceUpdated, source.CustomerID, source.ID, source))
+	})
+	if err != nil {
+		return "", err
 	}
 
 	s.logger.Debug().Msg("UpdateSourceCommand: Finished with success")
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestCreateSourceCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		approver := f.Person(t, customer, "approver")
+		policy := models.CancellationPolicy{CustomerID: customer.ID, Name: "strict"}
+		foreign := models.CancellationPolicy{CustomerID: f.Customer(t, "bob", nil).ID, Name: "strict"}
+		f.Add(t, &policy, &foreign)
+
+		create := func(name, timeout string, policyId *string, pricing models.SourcePricing) (string, error) {
+			ref := "approver"
+			return NewCreateSourceCommand(f, f.Logger, name, "2h", customer.ID, true, &ref, timeout, policyId, pricing).Execute()
+		}
+		if _, err := create("", "", nil, models.SourcePricing{}); err == nil {
+			t.Error("created a source without a name")
+		}
+		if _, err := create("room", "soon", nil, models.SourcePricing{}); err == nil {
+			t.Error("created a source with an invalid approval timeout")
+		}
+		if _, err := create("room", "", &foreign.ID, models.SourcePricing{}); err == nil {
+			t.Error("created a source with the policy of another customer")
+		}
+		if _, err := create("room", "", nil, models.SourcePricing{Currency: "XXXX"}); err == nil {
+			t.Error("created a source with an invalid currency")
+		}
+		if _, err := NewCreateSourceCommand(f, f.Logger, "room", "2h", "00000000-0000-0000-0000-000000000000", false, nil, "", nil, models.SourcePricing{}).Execute(); err == nil {
+			t.Error("created a source of a customer that does not exist")
+		}
+
+		id, err := create("room", "30m", &policy.ID, models.SourcePricing{Capacity: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, err := f.Sources().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source.CustomerID != customer.ID || source.ApproverID == nil || *source.ApproverID != approver.ID ||
+			source.CancellationPolicyID == nil || *source.CancellationPolicyID != policy.ID ||
+			source.Currency != "EUR" || source.Timezone != "UTC" || source.Capacity != 3 {
+			t.Errorf("stored %+v", source)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.SourceCreated}) {
+			t.Errorf("recorded %v", types)
+		}
+
+		// The default plan allows a single source, unless the customer had a
+		// higher limit before there were plans.
+		if _, err := create("other room", "", nil, models.SourcePricing{}); err == nil {
+			t.Error("created more sources than the plan allows")
+		}
+		limit := 2
+		if _, err := NewUpdateCustomerCommand(f, f.Logger, customer.ID, nil, nil, nil, &limit).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := create("other room", "", nil, models.SourcePricing{}); err != nil {
+			t.Errorf("could not create a source within the limit of the customer: %v", err)
+		}
+		if _, err := create("third room", "", nil, models.SourcePricing{}); err == nil {
+			t.Error("created more sources than the limit of the customer allows")
+		}
+	})
+}
+
+func TestCreateSourceCommandChecksFeaturesOfPlan(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &models.Plan{Name: "basic", Features: []string{models.FeaturePromotions}})
+
+		_, err := NewCreateSourceCommand(f, f.Logger, "room", "2h", customer.ID, true, nil, "", nil, models.SourcePricing{}).Execute()
+		if err == nil {
+			t.Error("created a source requiring approval without the feature")
+		}
+		_, err = NewCreateSourceCommand(f, f.Logger, "room", "2h", customer.ID, false, nil, "", nil, models.SourcePricing{RequiresPayment: true}).Execute()
+		if err == nil {
+			t.Error("created a source requiring payment without the feature")
+		}
+		_, err = NewCreateSourceCommand(f, f.Logger, "room", "2h", customer.ID, false, nil, "", nil, models.SourcePricing{}).Execute()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestUpdateSourceCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+
+		_, err := NewUpdateSourceCommand(f, f.Logger, "00000000-0000-0000-0000-000000000000", nil, nil, nil, nil, nil, nil, nil).Execute()
+		if err == nil {
+			t.Error("updated a source that does not exist")
+		}
+		unknown := "nobody"
+		_, err = NewUpdateSourceCommand(f, f.Logger, source.ID, nil, nil, nil, &unknown, nil, nil, nil).Execute()
+		if err == nil {
+			t.Error("updated a source with an approver that does not exist")
+		}
+
+		name, duration, requiresApproval := "hall", "4h", true
+		_, err = NewUpdateSourceCommand(f, f.Logger, source.ID, &name, &duration, &requiresApproval, nil, nil, nil, &models.SourcePricing{Currency: "USD"}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := f.Sources().Get(source.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Name != "hall" || updated.MaxPossibleDuration != "4h" || !updated.RequiresApproval || updated.Currency != "USD" || updated.Timezone != "UTC" {
+			t.Errorf("stored %+v", updated)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.SourceUpdated}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
+
+func TestDeleteSourceCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		source := f.Source(t, f.Customer(t, "alice", nil), "room")
+
+		_, err := NewDeleteSourceCommand(f, f.Logger, "00000000-0000-0000-0000-000000000000").Execute()
+		if err == nil {
+			t.Error("deleted a source that does not exist")
+		}
+
+		_, err = NewDeleteSourceCommand(f, f.Logger, source.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Sources().Get(source.ID)
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("source is still there: %v", err)
+		}
+		if types := f.EventTypes(t); !slices.Equal(types, []string{events.SourceDeleted}) {
+			t.Errorf("recorded %v", types)
+		}
+	})
+}
@@ -6,55 +6,121 @@ import (
 	"time"
 
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/lghtr35/reservation-engine/util"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
 )
 
 type CreateApiTokenCommand struct {
-	db         *gorm.DB
+	store      repository.Store
 	logger     *zerolog.Logger
 	hasher     *util.Hasher
 	customerId string
 	sourceId   string
+	lifetime   time.Duration
+	token      models.ApiToken
 }
 
-func NewCreateApiTokenCommand(db *gorm.DB, logger *zerolog.Logger, hasher *util.Hasher, customerId, sourceId string) *CreateApiTokenCommand {
-	return &CreateApiTokenCommand{db: db, logger: logger, hasher: hasher, customerId: customerId, sourceId: sourceId}
+// NewCreateApiTokenCommand creates a token that is valid for lifetime.
+func NewCreateApiTokenCommand(store repository.Store, logger *zerolog.Logger, hasher *util.Hasher, customerId, sourceId string, lifetime time.Duration) *CreateApiTokenCommand {
+	return &CreateApiTokenCommand{store: store, logger: logger, hasher: hasher, customerId: customerId, sourceId: sourceId, lifetime: lifetime}
+}
+
+// Token is the api token the command created.
+func (s *CreateApiTokenCommand) Token() models.ApiToken {
+	return s.token
 }
 
 func (s *CreateApiTokenCommand) Execute() (string, error) {
-	if s.customerId == "" || s.sourceId == "" {
+	if s.customerId == "" || s.sourceId == "" || s.lifetime <= 0 {
 		return "", errors.New("CreateApiTokenCommand: missing arguments")
 	}
 	s.logger.Debug().Msg("CreateApiTokenCommand: Started")
 
-	var customer models.Customer
-	res := s.db.Preload("Secret").First(&customer, s.customerId)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
+	customer, err := s.store.Customers().Load(s.customerId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return "", fmt.Errorf("CreateApiTokenCommand: Could not find the customer with id: %s", s.customerId)
 		}
-		return "", res.Error
+		return "", err
 	}
 
-	hashed, err := s.hasher.GetHash(fmt.Sprintf("%s:%s", customer.Secret.Value, util.GetRandString(8)))
+	source, err := s.store.Sources().Get(s.sourceId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return "", err
+	}
+	if err != nil || source.CustomerID != s.customerId {
+		return "", fmt.Errorf("CreateApiTokenCommand: Could not find the source with id %s of the customer with id: %s", s.sourceId, s.customerId)
+	}
+
+	plan, err := s.store.Customers().Plan(s.customerId)
 	if err != nil {
 		return "", err
 	}
+	countOfTokens, err := s.store.ApiTokens().CountValid(s.customerId, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if plan.MaxApiTokens > 0 && countOfTokens >= int64(plan.MaxApiTokens) {
+		return "", fmt.Errorf("CreateApiTokenCommand: Customer with id %s, has already hit the limit for api tokens", s.customerId)
+	}
 
-	oneYearLater := time.Now().AddDate(1, 0, 0)
+	hashed, err := s.hasher.GetHash(fmt.Sprintf("%s:%s", customer.Secret.Value, util.GetRandString(8)))
+	if err != nil {
+		return "", err
+	}
 
 	apiToken := models.ApiToken{
+		CustomerID: s.customerId,
 		SourceID:   s.sourceId,
-		ValidUntil: oneYearLater,
+		ValidUntil: time.Now().Add(s.lifetime),
 		Token:      hashed,
 	}
-	res = s.db.Create(&apiToken)
-	if res.Error != nil {
-		return "", res.Error
+	err = s.store.ApiTokens().Create(&apiToken)
+	if err != nil {
+		return "", err
 	}
 
+	s.token = apiToken
 	s.logger.Debug().Msg("CreateApiTokenCommand: Finished with success")
 	return apiToken.ID, nil
 }
+
+// RevokeApiTokenCommand ends the validity of an api token now, calls
+// authenticated with it fail from then on.
+type RevokeApiTokenCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	id     string
+	now    time.Time
+}
+
+func NewRevokeApiTokenCommand(store repository.Store, logger *zerolog.Logger, id string, now time.Time) *RevokeApiTokenCommand {
+	return &RevokeApiTokenCommand{store: store, logger: logger, id: id, now: now}
+}
+
+func (s *RevokeApiTokenCommand) Execute() (string, error) {
+	if s.id == "" {
+		return "", errors.New("RevokeApiTokenCommand: Tried revoking with empty id")
+	}
+	s.logger.Debug().Msg("RevokeApiTokenCommand: Started")
+
+	token, err := s.store.ApiTokens().Get(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("RevokeApiTokenCommand: Could not find the api token with id: %s", s.id)
+		}
+		return "", err
+	}
+
+	if token.ValidUntil.After(s.now) {
+		token.ValidUntil = s.now
+		err = s.store.ApiTokens().Save(&token)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.logger.Debug().Msg("RevokeApiTokenCommand: Finished with success")
+	return token.ID, nil
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// lifetime is the default lifetime of api tokens.
+var lifetime = models.DefaultConfiguration().ApiTokenLifetime.Duration()
+
+func TestCreateApiTokenCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &models.Plan{Name: "small", MaxSources: 2, MaxApiTokens: 1})
+		source := f.Source(t, customer, "room")
+		other := f.Source(t, f.Customer(t, "bob", nil), "room")
+
+		_, err := NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, "", lifetime).Execute()
+		if err == nil {
+			t.Error("created a token without a source")
+		}
+		_, err = NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, other.ID, lifetime).Execute()
+		if err == nil {
+			t.Error("created a token for the source of another customer")
+		}
+
+		command := NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, source.ID, lifetime)
+		id, err := command.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := f.ApiTokens().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.CustomerID != customer.ID || token.SourceID != source.ID || token.Token == "" || token.Token != command.Token().Token {
+			t.Errorf("stored %+v", token)
+		}
+		if !token.ValidUntil.After(time.Now().AddDate(0, 11, 0)) {
+			t.Errorf("token is valid until %s only", token.ValidUntil)
+		}
+
+		_, err = NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, source.ID, lifetime).Execute()
+		if err == nil {
+			t.Error("created more tokens than the plan allows")
+		}
+	})
+}
+
+func TestRevokeApiTokenCommand(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", &models.Plan{Name: "small", MaxSources: 1, MaxApiTokens: 1})
+		source := f.Source(t, customer, "room")
+		id, err := NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, source.ID, lifetime).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = NewRevokeApiTokenCommand(f, f.Logger, "00000000-0000-0000-0000-000000000000", time.Now()).Execute()
+		if err == nil {
+			t.Error("revoked a token that does not exist")
+		}
+
+		now := time.Now().Truncate(time.Second)
+		_, err = NewRevokeApiTokenCommand(f, f.Logger, id, now).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := f.ApiTokens().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !token.ValidUntil.Equal(now) {
+			t.Errorf("token is valid until %s", token.ValidUntil)
+		}
+
+		// A revoked token no longer counts against the limit of the plan.
+		_, err = NewCreateApiTokenCommand(f, f.Logger, f.Hasher, customer.ID, source.ID, lifetime).Execute()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
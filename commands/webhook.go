@@ -0,0 +1,400 @@
+/*
+ * Everything involving a mutation belongs to the 'commands' package.
+ */
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/lghtr35/reservation-engine/webhooks"
+	"github.com/rs/zerolog"
+)
+
+func validateWebhookEndpoint(caller string, endpoint models.WebhookEndpoint) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute http or https url", caller, endpoint.URL)
+	}
+	for _, eventType := range endpoint.EventTypes {
+		if !events.IsKnown(eventType) {
+			return fmt.Errorf("%s: Unknown event type %q", caller, eventType)
+		}
+	}
+	return nil
+}
+
+type CreateWebhookEndpointCommand struct {
+	store    repository.Store
+	logger   *zerolog.Logger
+	endpoint models.WebhookEndpoint
+}
+
+func NewCreateWebhookEndpointCommand(store repository.Store, logger *zerolog.Logger, customerId, url string, eventTypes []string) *CreateWebhookEndpointCommand {
+	endpoint := models.WebhookEndpoint{
+		CustomerID: customerId,
+		URL:        url,
+		Secret:     util.GetRandHexString(64),
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+	return &CreateWebhookEndpointCommand{store: store, logger: logger, endpoint: endpoint}
+}
+
+func (s *CreateWebhookEndpointCommand) Execute() (string, error) {
+	if s.endpoint.CustomerID == "" || s.endpoint.URL == "" {
+		return "", errors.New("CreateWebhookEndpointCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("CreateWebhookEndpointCommand: Started")
+
+	if err := validateWebhookEndpoint("CreateWebhookEndpointCommand", s.endpoint); err != nil {
+		return "", err
+	}
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		err := tx.WebhookEndpoints().Create(&s.endpoint)
+		if err != nil {
+			return err
+		}
+		announced := s.endpoint
+		announced.Secret = ""
+		return tx.Record(events.NewEvent(events.WebhookEndpointCreated, s.endpoint.CustomerID, s.endpoint.ID, announced))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("CreateWebhookEndpointCommand: Finished with success")
+
+	return s.endpoint.ID, nil
+}
+
+// Endpoint returns the created endpoint, its secret is only shown here and
+// when it is rotated.
+func (s *CreateWebhookEndpointCommand) Endpoint() models.WebhookEndpoint {
+	return s.endpoint
+}
+
+type DeleteWebhookEndpointCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	customerId string
+	id         string
+}
+
+func NewDeleteWebhookEndpointCommand(store repository.Store, logger *zerolog.Logger, customerId, id string) *DeleteWebhookEndpointCommand {
+	return &DeleteWebhookEndpointCommand{store: store, logger: logger, customerId: customerId, id: id}
+}
+
+// Execute deletes the endpoint, its deliveries stay in the log.
+func (s *DeleteWebhookEndpointCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("DeleteWebhookEndpointCommand: Tried deleting with empty id")
+	}
+	s.logger.Debug().Msg("DeleteWebhookEndpointCommand: Started")
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		endpoint, err := tx.WebhookEndpoints().Get(s.customerId, s.id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("DeleteWebhookEndpointCommand: Could not find the webhook endpoint with this id: %s", s.id)
+			}
+			return err
+		}
+		err = tx.WebhookEndpoints().Delete(s.id)
+		if err != nil {
+			return err
+		}
+		endpoint.Secret = ""
+		return tx.Record(events.NewEvent(events.WebhookEndpointDeleted, s.customerId, s.id, endpoint))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("DeleteWebhookEndpointCommand: Finished with success")
+
+	return s.id, nil
+}
+
+type UpdateWebhookEndpointCommand struct {
+	store        repository.Store
+	logger       *zerolog.Logger
+	customerId   string
+	id           string
+	url          *string
+	eventTypes   *[]string
+	active       *bool
+	rotateSecret bool
+	endpoint     models.WebhookEndpoint
+}
+
+func NewUpdateWebhookEndpointCommand(store repository.Store, logger *zerolog.Logger, customerId, id string, url *string, eventTypes *[]string, active *bool, rotateSecret bool) *UpdateWebhookEndpointCommand {
+	return &UpdateWebhookEndpointCommand{store: store, logger: logger, customerId: customerId, id: id, url: url, eventTypes: eventTypes, active: active, rotateSecret: rotateSecret}
+}
+
+func (s *UpdateWebhookEndpointCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("UpdateWebhookEndpointCommand: Tried updating with empty id")
+	}
+	s.logger.Debug().Msg("UpdateWebhookEndpointCommand: Started")
+
+	endpoint, err := s.store.WebhookEndpoints().Get(s.customerId, s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("UpdateWebhookEndpointCommand: Could not find the webhook endpoint with this id: %s", s.id)
+		}
+		return "", err
+	}
+	s.endpoint = endpoint
+
+	if s.url != nil && *s.url != "" {
+		s.endpoint.URL = *s.url
+	}
+	if s.eventTypes != nil {
+		s.endpoint.EventTypes = *s.eventTypes
+	}
+	if s.active != nil {
+		s.endpoint.Active = *s.active
+	}
+	if s.rotateSecret {
+		s.endpoint.Secret = util.GetRandHexString(64)
+	}
+
+	if err := validateWebhookEndpoint("UpdateWebhookEndpointCommand", s.endpoint); err != nil {
+		return "", err
+	}
+
+	err = s.store.Transaction(func(tx repository.Store) error {
+		err := tx.WebhookEndpoints().Save(&s.endpoint)
+		if err != nil {
+			return err
+		}
+		announced := s.endpoint
+		announced.Secret = ""
+		return tx.Record(events.NewEvent(events.WebhookEndpointUpdated, s.customerId, s.id, announced))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("UpdateWebhookEndpointCommand: Finished with success")
+
+	return s.id, nil
+}
+
+// Endpoint returns the updated endpoint.
+func (s *UpdateWebhookEndpointCommand) Endpoint() models.WebhookEndpoint {
+	return s.endpoint
+}
+
+// EnqueueWebhookDeliveriesCommand queues the event for every active endpoint
+// of its customer that accepts it. Queueing an event again does nothing. It
+// returns the number of queued deliveries.
+type EnqueueWebhookDeliveriesCommand struct {
+	store  repository.Store
+	logger *zerolog.Logger
+	event  events.Event
+}
+
+func NewEnqueueWebhookDeliveriesCommand(store repository.Store, logger *zerolog.Logger, event events.Event) *EnqueueWebhookDeliveriesCommand {
+	return &EnqueueWebhookDeliveriesCommand{store: store, logger: logger, event: event}
+}
+
+func (s *EnqueueWebhookDeliveriesCommand) Execute() (string, error) {
+	if s.event.CustomerID == "" {
+		return "0", nil
+	}
+	s.logger.Debug().Msg("EnqueueWebhookDeliveriesCommand: Started")
+
+	endpoints, err := s.store.WebhookEndpoints().Active(s.event.CustomerID)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(s.event)
+	if err != nil {
+		return "", fmt.Errorf("EnqueueWebhookDeliveriesCommand: %w", err)
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Accepts(s.event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			CustomerID:    s.event.CustomerID,
+			EndpointID:    endpoint.ID,
+			EventID:       s.event.ID,
+			EventType:     s.event.Type,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryStatusPending,
+			NextAttemptAt: &now,
+		})
+	}
+	queued, err := s.store.WebhookDeliveries().Enqueue(deliveries)
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debug().Msg("EnqueueWebhookDeliveriesCommand: Finished with success")
+
+	return fmt.Sprint(queued), nil
+}
+
+type RedeliverWebhookCommand struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	customerId string
+	id         string
+}
+
+func NewRedeliverWebhookCommand(store repository.Store, logger *zerolog.Logger, customerId, id string) *RedeliverWebhookCommand {
+	return &RedeliverWebhookCommand{store: store, logger: logger, customerId: customerId, id: id}
+}
+
+// Execute queues the delivery again with a fresh set of attempts, whatever
+// its status was.
+func (s *RedeliverWebhookCommand) Execute() (string, error) {
+	if s.id == "" || s.customerId == "" {
+		return "", errors.New("RedeliverWebhookCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("RedeliverWebhookCommand: Started")
+
+	err := s.store.WebhookDeliveries().Requeue(s.customerId, s.id, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("RedeliverWebhookCommand: Could not find the webhook delivery with this id: %s", s.id)
+		}
+		return "", err
+	}
+
+	s.logger.Debug().Msg("RedeliverWebhookCommand: Finished with success")
+
+	return s.id, nil
+}
+
+// DeliverWebhooksCommand attempts up to batch deliveries that are due. Each
+// delivery is claimed in a short transaction, sent outside of any
+// transaction and its result written on its own, so that slow endpoints hold
+// neither locks nor connections. A claim is a lease: when the engine holding
+// it never reports back the delivery is due again once it runs out. Due
+// deliveries are claimed with SKIP LOCKED so that several engines can deliver
+// side by side. Failed attempts are retried with exponential backoff until
+// webhooks.MaxAttempts, then the delivery is dead. It stops at the next
+// delivery once the context is done and returns the number of attempts made.
+type DeliverWebhooksCommand struct {
+	ctx    context.Context
+	store  repository.Store
+	logger *zerolog.Logger
+	sender *webhooks.Sender
+	now    time.Time
+	batch  int
+}
+
+// deliveryLeaseMargin is how much longer than a send a claim lasts.
+const deliveryLeaseMargin = time.Minute
+
+func NewDeliverWebhooksCommand(ctx context.Context, store repository.Store, logger *zerolog.Logger, sender *webhooks.Sender, now time.Time, batch int) *DeliverWebhooksCommand {
+	return &DeliverWebhooksCommand{ctx: ctx, store: store, logger: logger, sender: sender, now: now, batch: batch}
+}
+
+func (s *DeliverWebhooksCommand) Execute() (string, error) {
+	if s.sender == nil || s.batch <= 0 {
+		return "", errors.New("DeliverWebhooksCommand: missing arguments")
+	}
+	s.logger.Debug().Msg("DeliverWebhooksCommand: Started")
+
+	attempted := 0
+	for attempted < s.batch && s.ctx.Err() == nil {
+		delivery, err := s.claim()
+		if errors.Is(err, repository.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return fmt.Sprint(attempted), err
+		}
+		err = s.attempt(delivery)
+		if err != nil {
+			return fmt.Sprint(attempted), err
+		}
+		attempted++
+	}
+
+	s.logger.Debug().Msg("DeliverWebhooksCommand: Finished with success")
+
+	return fmt.Sprint(attempted), nil
+}
+
+// claim leases the delivery that is due the longest and counts the attempt,
+// it returns repository.ErrNotFound when none is due.
+func (s *DeliverWebhooksCommand) claim() (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		delivery, err = tx.WebhookDeliveries().GetDueForUpdate(s.now)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		leasedUntil := now.Add(s.sender.Timeout() + deliveryLeaseMargin)
+		delivery.Attempts++
+		delivery.Status = models.WebhookDeliveryStatusSending
+		delivery.LastAttemptAt = &now
+		delivery.NextAttemptAt = &leasedUntil
+		return tx.WebhookDeliveries().Save(&delivery)
+	})
+	return delivery, err
+}
+
+// attempt sends the claimed delivery and writes down how that went, unless
+// the lease ran out and another engine claimed the delivery in the meantime.
+func (s *DeliverWebhooksCommand) attempt(delivery models.WebhookDelivery) error {
+	endpoint, err := s.store.WebhookEndpoints().Get(delivery.CustomerID, delivery.EndpointID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		delivery.Status, delivery.NextAttemptAt = models.WebhookDeliveryStatusDead, nil
+		delivery.LastStatusCode, delivery.LastError = 0, "Endpoint was deleted"
+	case err != nil:
+		return err
+	case !endpoint.Active:
+		delivery.Status, delivery.NextAttemptAt = models.WebhookDeliveryStatusDead, nil
+		delivery.LastStatusCode, delivery.LastError = 0, "Endpoint is disabled"
+	default:
+		code, err := s.sender.Send(s.ctx, endpoint.URL, endpoint.Secret, delivery.ID, delivery.EventType, []byte(delivery.Payload), *delivery.LastAttemptAt)
+		delivery.LastStatusCode, delivery.LastError = code, ""
+		if err != nil {
+			delivery.LastError = err.Error()
+		}
+		switch {
+		case err == nil:
+			delivery.Status, delivery.NextAttemptAt = models.WebhookDeliveryStatusSucceeded, nil
+		case delivery.Attempts >= webhooks.MaxAttempts:
+			delivery.Status, delivery.NextAttemptAt = models.WebhookDeliveryStatusDead, nil
+		default:
+			next := time.Now().Add(webhooks.Backoff(delivery.Attempts))
+			delivery.Status, delivery.NextAttemptAt = models.WebhookDeliveryStatusRetrying, &next
+		}
+	}
+
+	// The result is written even when the context is done, the endpoint may
+	// have received the delivery.
+	saved, err := s.store.WebhookDeliveries().SaveAttempt(delivery)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		s.logger.Warn().Msgf("DeliverWebhooksCommand: the lease of delivery %s ran out before it was sent", delivery.ID)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+	"github.com/lghtr35/reservation-engine/webhooks"
+)
+
+func TestWebhookEndpointCommands(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		other := f.Customer(t, "bob", nil)
+
+		if _, err := NewCreateWebhookEndpointCommand(f, f.Logger, customer.ID, "ftp://example.com", nil).Execute(); err == nil {
+			t.Error("created an endpoint with a url that is not http")
+		}
+		if _, err := NewCreateWebhookEndpointCommand(f, f.Logger, customer.ID, "https://example.com", []string{"reservation.exploded"}).Execute(); err == nil {
+			t.Error("created an endpoint for an unknown event type")
+		}
+		create := NewCreateWebhookEndpointCommand(f, f.Logger, customer.ID, "https://example.com/hook", []string{events.ReservationCreated})
+		id, err := create.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := create.Endpoint().Secret
+		if len(secret) != 64 {
+			t.Errorf("created the secret %q", secret)
+		}
+
+		if _, err = NewUpdateWebhookEndpointCommand(f, f.Logger, other.ID, id, nil, nil, nil, true).Execute(); err == nil {
+			t.Error("updated the endpoint of another customer")
+		}
+		inactive := false
+		update := NewUpdateWebhookEndpointCommand(f, f.Logger, customer.ID, id, nil, &[]string{}, &inactive, true)
+		if _, err = update.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if endpoint := update.Endpoint(); endpoint.Secret == secret || endpoint.Active || len(endpoint.EventTypes) != 0 || endpoint.URL != "https://example.com/hook" {
+			t.Errorf("updated into %+v", endpoint)
+		}
+
+		if _, err = NewDeleteWebhookEndpointCommand(f, f.Logger, other.ID, id).Execute(); err == nil {
+			t.Error("deleted the endpoint of another customer")
+		}
+		if _, err = NewDeleteWebhookEndpointCommand(f, f.Logger, customer.ID, id).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{events.WebhookEndpointCreated, events.WebhookEndpointUpdated, events.WebhookEndpointDeleted}
+		if types := f.EventTypes(t); !slices.Equal(types, want) {
+			t.Errorf("recorded %v, want %v", types, want)
+		}
+		var payloads []string
+		if err = f.DB.Model(&models.OutboxEvent{}).Pluck("payload", &payloads).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, payload := range payloads {
+			if payload == "" || strings.Contains(payload, secret) || strings.Contains(payload, update.Endpoint().Secret) {
+				t.Errorf("recorded the secret in %s", payload)
+			}
+		}
+	})
+}
+
+func TestDeliverWebhooksCommand(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+
+		// The endpoint answers with status and writes to the database while it
+		// is called, which waits for any transaction still open on SQLite.
+		var status, calls atomic.Int32
+		status.Store(http.StatusInternalServerError)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			var endpoint models.WebhookEndpoint
+			if err = f.DB.First(&endpoint, "customer_id = ?", customer.ID).Error; err != nil {
+				t.Error(err)
+			}
+			if err = webhooks.Verify(endpoint.Secret, r.Header.Get(webhooks.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+				t.Errorf("received a delivery that does not verify: %v", err)
+			}
+			if err = f.DB.Create(&models.Person{CustomerID: customer.ID, Name: "written while sending"}).Error; err != nil {
+				t.Errorf("could not write while a delivery was sent: %v", err)
+			}
+			w.WriteHeader(int(status.Load()))
+		}))
+		defer server.Close()
+
+		create := NewCreateWebhookEndpointCommand(f, f.Logger, customer.ID, server.URL, []string{events.ReservationCreated})
+		endpointId, err := create.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueue := func(eventType string) string {
+			event := events.NewEvent(eventType, customer.ID, models.NewUUID(), map[string]string{"hello": "world"})
+			queued, err := NewEnqueueWebhookDeliveriesCommand(f, f.Logger, event).Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queued == "1" {
+				// Queueing the same event again does nothing.
+				if again, err := NewEnqueueWebhookDeliveriesCommand(f, f.Logger, event).Execute(); err != nil || again != "0" {
+					t.Errorf("queued %s deliveries of the same event again: %v", again, err)
+				}
+			}
+			return queued
+		}
+		if queued := enqueue(events.SourceCreated); queued != "0" {
+			t.Errorf("queued %s deliveries of an event the endpoint does not accept", queued)
+		}
+		if queued := enqueue(events.ReservationCreated); queued != "1" {
+			t.Fatalf("queued %s deliveries", queued)
+		}
+		deliveryOf := func() models.WebhookDelivery {
+			var delivery models.WebhookDelivery
+			if err := f.DB.First(&delivery, "endpoint_id = ?", endpointId).Error; err != nil {
+				t.Fatal(err)
+			}
+			return delivery
+		}
+		deliver := func(now time.Time) string {
+			count, err := NewDeliverWebhooksCommand(context.Background(), f, f.Logger, webhooks.NewSender(5*time.Second), now, 10).Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return count
+		}
+
+		// A failed attempt is retried after the backoff, not before.
+		sent := time.Now()
+		if count := deliver(time.Now()); count != "1" {
+			t.Fatalf("attempted %s deliveries", count)
+		}
+		delivery := deliveryOf()
+		if delivery.Status != models.WebhookDeliveryStatusRetrying || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusInternalServerError ||
+			delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(sent.Add(webhooks.Backoff(1))) || delivery.NextAttemptAt.After(time.Now().Add(webhooks.Backoff(1))) {
+			t.Errorf("failed delivery is %+v", delivery)
+		}
+		if count := deliver(time.Now()); count != "0" {
+			t.Errorf("attempted %s deliveries during the backoff", count)
+		}
+		if count := deliver(time.Now().Add(time.Minute)); count != "1" || deliveryOf().Attempts != 2 {
+			t.Errorf("attempted %s deliveries after the backoff", count)
+		}
+
+		// The last attempt that fails makes the delivery dead.
+		if err = f.DB.Model(&delivery).Update("attempts", webhooks.MaxAttempts-1).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count := deliver(time.Now().Add(time.Hour)); count != "1" {
+			t.Errorf("attempted %s deliveries", count)
+		}
+		if delivery = deliveryOf(); delivery.Status != models.WebhookDeliveryStatusDead || delivery.Attempts != webhooks.MaxAttempts || delivery.NextAttemptAt != nil {
+			t.Errorf("delivery out of attempts is %+v", delivery)
+		}
+		if count := deliver(time.Now().Add(24 * time.Hour)); count != "0" {
+			t.Errorf("attempted %s dead deliveries", count)
+		}
+
+		if _, err = NewRedeliverWebhookCommand(f, f.Logger, models.NewUUID(), delivery.ID).Execute(); err == nil {
+			t.Error("redelivered the delivery of another customer")
+		}
+		if _, err = NewRedeliverWebhookCommand(f, f.Logger, customer.ID, delivery.ID).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		status.Store(http.StatusOK)
+		if count := deliver(time.Now()); count != "1" {
+			t.Errorf("attempted %s redelivered deliveries", count)
+		}
+		if delivery = deliveryOf(); delivery.Status != models.WebhookDeliveryStatusSucceeded || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusOK || delivery.LastError != "" {
+			t.Errorf("redelivered delivery is %+v", delivery)
+		}
+
+		// A delivery claimed by an engine that never reported back is sent
+		// again once its lease runs out.
+		leasedUntil := time.Now().Add(time.Minute)
+		if err = f.DB.Model(&delivery).Updates(map[string]any{"status": models.WebhookDeliveryStatusSending, "next_attempt_at": leasedUntil}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count := deliver(time.Now()); count != "0" {
+			t.Errorf("attempted %s deliveries that are claimed", count)
+		}
+		if count := deliver(leasedUntil.Add(time.Second)); count != "1" || deliveryOf().Status != models.WebhookDeliveryStatusSucceeded {
+			t.Errorf("attempted %s deliveries whose lease ran out", count)
+		}
+
+		inactive := false
+		if _, err = NewUpdateWebhookEndpointCommand(f, f.Logger, customer.ID, endpointId, nil, nil, &inactive, false).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewRedeliverWebhookCommand(f, f.Logger, customer.ID, delivery.ID).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		before := calls.Load()
+		deliver(time.Now())
+		if delivery = deliveryOf(); delivery.Status != models.WebhookDeliveryStatusDead || delivery.LastError != "Endpoint is disabled" || calls.Load() != before {
+			t.Errorf("delivery to a disabled endpoint is %+v", delivery)
+		}
+
+		// Nothing is claimed once the context is done.
+		if _, err = NewRedeliverWebhookCommand(f, f.Logger, customer.ID, delivery.ID).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		if count, err := NewDeliverWebhooksCommand(cancelled, f, f.Logger, webhooks.NewSender(time.Second), time.Now(), 10).Execute(); err != nil || count != "0" {
+			t.Errorf("attempted %s deliveries with a cancelled context: %v", count, err)
+		}
+		if delivery = deliveryOf(); delivery.Status != models.WebhookDeliveryStatusPending {
+			t.Errorf("delivery is %s after a cancelled run", delivery.Status)
+		}
+	})
+}
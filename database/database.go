@@ -0,0 +1,110 @@
+// Package database opens the database of the engine with the driver the
+// configuration names. Postgres is what the engine runs on in production,
+// SQLite lets small deployments and local development do without a server.
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// sqliteDriver is the name the SQLite driver of the engine is registered
+// with, see utcConn.
+const sqliteDriver = "sqlite3_utc"
+
+// sqliteOptions make SQLite enforce foreign keys, let readers work next to
+// the writer and have a transaction take the write lock when it begins, so
+// that transactions wait for each other instead of failing halfway.
+const sqliteOptions = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+func init() {
+	sql.Register(sqliteDriver, &utcDriver{})
+}
+
+// Open connects to the database of the configuration, with the pool of
+// connections it configures.
+func Open(configuration *models.Configuration) (*gorm.DB, error) {
+	dialector, err := Dialector(configuration.DbDriver, configuration.DbConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(configuration.DbMaxOpenConns)
+	sqlDB.SetMaxIdleConns(configuration.DbMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(configuration.DbConnMaxLifetime.Duration())
+	return db, nil
+}
+
+// Dialector returns the dialector of the driver, "postgres" when it is empty.
+func Dialector(name string, connectionString string) (gorm.Dialector, error) {
+	switch name {
+	case "", Postgres:
+		return postgres.Open(connectionString), nil
+	case SQLite:
+		separator := "?"
+		if strings.Contains(connectionString, "?") {
+			separator = "&"
+		}
+		return sqlite.New(sqlite.Config{DriverName: sqliteDriver, DSN: connectionString + separator + sqliteOptions}), nil
+	}
+	return nil, fmt.Errorf("database: unknown driver %q, use %q or %q", name, Postgres, SQLite)
+}
+
+// IsPostgres tells whether the database is a Postgres one, the only one with
+// advisory locks and notifications.
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == Postgres
+}
+
+// utcDriver is the SQLite driver with connections that store times in UTC.
+type utcDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *utcDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &utcConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// utcConn converts every time to UTC before it is bound. SQLite keeps times
+// as text and compares them as text, which only orders them when they are
+// written in the same zone.
+type utcConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *utcConn) CheckNamedValue(value *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(value.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(time.Time); ok {
+		v = t.UTC()
+	}
+	value.Value = v
+	return nil
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/caldav"
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/queries"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8; component=vevent"
+
+// davChallengeMiddleware asks for credentials when a request has none, which
+// is how calendar clients learn to send the api token and secret as basic
+// auth user name and password.
+func davChallengeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, _, ok := c.Request.BasicAuth()
+		if !ok && c.GetHeader("x-api-token") == "" {
+			c.Header("WWW-Authenticate", `Basic realm="reservation-engine"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// davResponse picks the requested properties out of the ones the resource
+// has, the rest are reported missing.
+func davResponse(href string, available []caldav.Property, allProp bool, names []xml.Name) caldav.Response {
+	response := caldav.Response{Href: href}
+	if allProp {
+		response.Properties = available
+		return response
+	}
+	for _, name := range names {
+		found := false
+		for _, property := range available {
+			if property.Name == name {
+				response.Properties = append(response.Properties, property)
+				found = true
+				break
+			}
+		}
+		if !found {
+			response.Missing = append(response.Missing, name)
+		}
+	}
+	return response
+}
+
+func wantsCalendarData(allProp bool, names []xml.Name) bool {
+	return !allProp && containsName(names, caldav.CalDAV("calendar-data"))
+}
+
+func containsName(names []xml.Name, name xml.Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func homeProperties() []caldav.Property {
+	return []caldav.Property{
+		{Name: caldav.DAV("resourcetype"), Value: caldav.Element(caldav.DAV("collection"))},
+		{Name: caldav.DAV("displayname"), Value: "Reservations"},
+		{Name: caldav.DAV("current-user-principal"), Value: caldav.Href(models.DavRoot)},
+		{Name: caldav.DAV("principal-URL"), Value: caldav.Href(models.DavRoot)},
+		{Name: caldav.CalDAV("calendar-home-set"), Value: caldav.Href(models.DavRoot)},
+	}
+}
+
+func collectionProperties(collection models.CalendarCollection) []caldav.Property {
+	privileges := ""
+	for _, privilege := range []string{"read", "write", "write-content", "bind", "unbind"} {
+		privileges += "<D:privilege>" + caldav.Element(caldav.DAV(privilege)) + "</D:privilege>"
+	}
+	return []caldav.Property{
+		{Name: caldav.DAV("resourcetype"), Value: caldav.Element(caldav.DAV("collection")) + caldav.Element(caldav.CalDAV("calendar"))},
+		{Name: caldav.DAV("displayname"), Value: caldav.Text(collection.Source.Name)},
+		{Name: caldav.DAV("current-user-principal"), Value: caldav.Href(models.DavRoot)},
+		{Name: caldav.DAV("current-user-privilege-set"), Value: privileges},
+		{Name: caldav.CalDAV("supported-calendar-component-set"), Value: `<C:comp name="VEVENT"/>`},
+		{Name: caldav.CalDAV("calendar-timezone"), Value: caldav.Text(collection.Source.Timezone)},
+		{Name: xml.Name{Space: caldav.NamespaceCalendarServer, Local: "getctag"}, Value: caldav.Text(collection.CTag)},
+	}
+}
+
+func objectProperties(resource models.CalendarObjectResource) []caldav.Property {
+	properties := []caldav.Property{
+		{Name: caldav.DAV("resourcetype")},
+		{Name: caldav.DAV("getetag"), Value: caldav.Text(resource.ETag)},
+		{Name: caldav.DAV("getcontenttype"), Value: calendarContentType},
+	}
+	if resource.Data != nil {
+		properties = append(properties, caldav.Property{Name: caldav.CalDAV("calendar-data"), Value: caldav.Text(string(resource.Data))})
+	}
+	return properties
+}
+
+func writeMultistatus(c *gin.Context, responses []caldav.Response) {
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(http.StatusMultiStatus)
+	caldav.WriteMultistatus(c.Writer, responses)
+}
+
+// davCollection returns the collection of the source in the path, it
+// answers 404 and returns false when the customer has no such source.
+func (h *Handler) davCollection(c *gin.Context) (models.CalendarCollection, bool) {
+	q := queries.NewCalendarCollectionsQuery(h.db, h.logger, c.GetString("customerId"), c.Param("sourceId"))
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return models.CalendarCollection{}, false
+	}
+	collections := res.([]models.CalendarCollection)
+	if len(collections) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return models.CalendarCollection{}, false
+	}
+	return collections[0], true
+}
+
+func (h *Handler) davObjects(c *gin.Context, source models.Source, names []string, from, to *time.Time, withData bool) ([]models.CalendarObjectResource, bool) {
+	q := queries.NewCalendarObjectsQuery(h.db, h.logger, source, names, from, to, withData, time.Now())
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return res.([]models.CalendarObjectResource), true
+}
+
+// davError answers a failed command with the status and the precondition
+// CalDAV clients expect for it.
+func (h *Handler) davError(c *gin.Context, err error, conflict string) {
+	h.logger.Err(err)
+	status := http.StatusForbidden
+	precondition := xml.Name{Space: caldav.NamespaceEngine, Local: "valid-reservation"}
+	value := ""
+	switch {
+	case errors.Is(err, commands.ErrCalendarObjectNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	case errors.Is(err, commands.ErrPreconditionFailed):
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return
+	case errors.Is(err, commands.ErrInvalidCalendarData):
+		precondition = caldav.CalDAV("valid-calendar-data")
+	case errors.Is(err, commands.ErrInvalidCalendarObject):
+		precondition = caldav.CalDAV("valid-calendar-object-resource")
+	case errors.Is(err, commands.ErrUIDConflict):
+		precondition = caldav.CalDAV("no-uid-conflict")
+		value = caldav.Href(conflict)
+	case errors.Is(err, commands.ErrOverlappingReservations):
+		status = http.StatusConflict
+		precondition = xml.Name{Space: caldav.NamespaceEngine, Local: "no-overlapping-reservations"}
+	}
+
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(status)
+	caldav.WriteError(c.Writer, precondition, value, err.Error())
+	c.Abort()
+}
+
+func (h *Handler) DavOptions(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, PROPFIND, REPORT, GET, HEAD, PUT, DELETE")
+	c.AbortWithStatus(http.StatusOK)
+}
+
+// DavRedirect sends clients that discover the service through
+// /.well-known/caldav to the calendar home.
+func (h *Handler) DavRedirect(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, models.DavRoot)
+}
+
+// PropfindDavHome lists the calendar home and, unless the depth is 0, the
+// collections of the sources in it.
+func (h *Handler) PropfindDavHome(c *gin.Context) {
+	request, err := caldav.ReadPropfind(c.Request.Body)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	responses := []caldav.Response{davResponse(models.DavRoot, homeProperties(), request.AllProp, request.Names)}
+	if c.GetHeader("Depth") != "0" {
+		q := queries.NewCalendarCollectionsQuery(h.db, h.logger, c.GetString("customerId"), "")
+
+		res, err := q.Execute()
+		if err != nil {
+			h.logger.Err(err)
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		for _, collection := range res.([]models.CalendarCollection) {
+			responses = append(responses, davResponse(models.DavCollectionPath(collection.Source.ID), collectionProperties(collection), request.AllProp, request.Names))
+		}
+	}
+
+	writeMultistatus(c, responses)
+}
+
+// PropfindDavCollection describes the collection of a source and, unless the
+// depth is 0, the reservations in it.
+func (h *Handler) PropfindDavCollection(c *gin.Context) {
+	request, err := caldav.ReadPropfind(c.Request.Body)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	collection, ok := h.davCollection(c)
+	if !ok {
+		return
+	}
+
+	href := models.DavCollectionPath(collection.Source.ID)
+	responses := []caldav.Response{davResponse(href, collectionProperties(collection), request.AllProp, request.Names)}
+	if c.GetHeader("Depth") != "0" {
+		resources, ok := h.davObjects(c, collection.Source, nil, nil, nil, wantsCalendarData(request.AllProp, request.Names))
+		if !ok {
+			return
+		}
+		for _, resource := range resources {
+			responses = append(responses, davResponse(href+resource.Name, objectProperties(resource), request.AllProp, request.Names))
+		}
+	}
+
+	writeMultistatus(c, responses)
+}
+
+func (h *Handler) PropfindDavObject(c *gin.Context) {
+	request, err := caldav.ReadPropfind(c.Request.Body)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	collection, ok := h.davCollection(c)
+	if !ok {
+		return
+	}
+
+	resources, ok := h.davObjects(c, collection.Source, []string{c.Param("file")}, nil, nil, wantsCalendarData(request.AllProp, request.Names))
+	if !ok {
+		return
+	}
+	if len(resources) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	href := models.DavCollectionPath(collection.Source.ID) + resources[0].Name
+	writeMultistatus(c, []caldav.Response{davResponse(href, objectProperties(resources[0]), request.AllProp, request.Names)})
+}
+
+// ReportDavCollection answers calendar-query and calendar-multiget reports on
+// the collection of a source.
+func (h *Handler) ReportDavCollection(c *gin.Context) {
+	request, err := caldav.ReadReport(c.Request.Body)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	collection, ok := h.davCollection(c)
+	if !ok {
+		return
+	}
+
+	href := models.DavCollectionPath(collection.Source.ID)
+	withData := request.AllProp || containsName(request.Names, caldav.CalDAV("calendar-data"))
+	var responses []caldav.Response
+	switch request.Kind {
+	case caldav.CalendarQuery:
+		resources, ok := h.davObjects(c, collection.Source, nil, request.Start, request.End, withData)
+		if !ok {
+			return
+		}
+		for _, resource := range resources {
+			responses = append(responses, davResponse(href+resource.Name, objectProperties(resource), request.AllProp, request.Names))
+		}
+	case caldav.CalendarMultiget:
+		names := make([]string, 0, len(request.Hrefs))
+		for _, requested := range request.Hrefs {
+			unescaped, err := url.PathUnescape(requested)
+			if err == nil && path.Dir(unescaped)+"/" == href {
+				names = append(names, path.Base(unescaped))
+			}
+		}
+		resources, ok := h.davObjects(c, collection.Source, names, nil, nil, withData)
+		if !ok {
+			return
+		}
+		found := make(map[string]models.CalendarObjectResource, len(resources))
+		for _, resource := range resources {
+			found[href+resource.Name] = resource
+		}
+		for _, requested := range request.Hrefs {
+			unescaped, _ := url.PathUnescape(requested)
+			resource, ok := found[unescaped]
+			if !ok {
+				responses = append(responses, caldav.Response{Href: requested, NotFound: true})
+				continue
+			}
+			responses = append(responses, davResponse(requested, objectProperties(resource), request.AllProp, request.Names))
+		}
+	default:
+		c.Header("Content-Type", "application/xml; charset=utf-8")
+		c.Status(http.StatusForbidden)
+		caldav.WriteError(c.Writer, caldav.DAV("supported-report"), "", "Only calendar-query and calendar-multiget are supported")
+		c.Abort()
+		return
+	}
+
+	writeMultistatus(c, responses)
+}
+
+func (h *Handler) GetDavObject(c *gin.Context) {
+	collection, ok := h.davCollection(c)
+	if !ok {
+		return
+	}
+
+	resources, ok := h.davObjects(c, collection.Source, []string{c.Param("file")}, nil, nil, true)
+	if !ok {
+		return
+	}
+	if len(resources) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.Header("ETag", resources[0].ETag)
+	c.Data(http.StatusOK, calendarContentType, resources[0].Data)
+}
+
+// PutDavObject creates or moves the reservation of a calendar object, with
+// the same validation as the reservation endpoints.
+func (h *Handler) PutDavObject(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewPutCalendarObjectCommand(h.store, h.logger, h.payments, c.GetString("customerId"), c.Param("sourceId"), c.Param("file"), body, c.GetHeader("If-Match"), strings.TrimSpace(c.GetHeader("If-None-Match")), time.Now())
+
+	_, err = q.Execute()
+	if err != nil {
+		h.davError(c, err, q.Conflict())
+		return
+	}
+
+	if q.ETag() != "" {
+		c.Header("ETag", q.ETag())
+	}
+	if q.Created() {
+		c.AbortWithStatus(http.StatusCreated)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// DeleteDavObject cancels the reservation of a calendar object.
+func (h *Handler) DeleteDavObject(c *gin.Context) {
+	q := commands.NewDeleteCalendarObjectCommand(h.store, h.logger, h.payments, c.GetString("customerId"), c.Param("sourceId"), c.Param("file"), c.GetHeader("If-Match"))
+
+	_, err := q.Execute()
+	if err != nil {
+		h.davError(c, err, "")
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// All can be used as event type to subscribe to every event.
+const All = "*"
+
+type Subscriber func(Event) error
+
+// Sink receives the events relayed from the outbox. Delivery is at least
+// once, so a sink has to tolerate seeing an event again.
+type Sink interface {
+	Name() string
+	Publish(Event) error
+}
+
+// Bus delivers events synchronously to in-process subscribers. A failing
+// subscriber does not stop delivery to the others, its error is returned so
+// that the relay offers the event again.
+type Bus struct {
+	mu          sync.RWMutex
+	logger      *zerolog.Logger
+	subscribers map[string][]Subscriber
+}
+
+func NewBus(logger *zerolog.Logger) *Bus {
+	return &Bus{logger: logger, subscribers: make(map[string][]Subscriber)}
+}
+
+func (b *Bus) Subscribe(eventType string, subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], subscriber)
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+func (b *Bus) Publish(event Event) error {
+	b.mu.RLock()
+	subscribers := append(append([]Subscriber{}, b.subscribers[event.Type]...), b.subscribers[All]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, subscriber := range subscribers {
+		if err := subscriber(event); err != nil {
+			b.logger.Error().Err(err).Str("type", event.Type).Str("aggregateId", event.AggregateID).Msg("Bus: subscriber failed")
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink writes every event it receives to the logger.
+type LogSink struct {
+	logger *zerolog.Logger
+}
+
+func NewLogSink(logger *zerolog.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Publish(event Event) error {
+	s.logger.Info().Str("id", event.ID).Str("type", event.Type).Str("aggregateId", event.AggregateID).Msg("Event published")
+	return nil
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestBus(t *testing.T) {
+	logger := zerolog.Nop()
+	bus := NewBus(&logger)
+	var received []string
+	subscriber := func(name string, err error) Subscriber {
+		return func(event Event) error {
+			received = append(received, name+" "+event.Type)
+			return err
+		}
+	}
+	failure := errors.New("failed")
+	bus.Subscribe(ReservationCreated, subscriber("failing", failure))
+	bus.Subscribe(ReservationCreated, subscriber("created", nil))
+	bus.Subscribe(All, subscriber("all", nil))
+
+	// A failing subscriber does not keep the others from the event.
+	if err := bus.Publish(NewEvent(ReservationCreated, "customer", "reservation", nil)); !errors.Is(err, failure) {
+		t.Errorf("published with %v", err)
+	}
+	if err := bus.Publish(NewEvent(SourceCreated, "customer", "source", nil)); err != nil {
+		t.Error(err)
+	}
+	want := []string{"failing " + ReservationCreated, "created " + ReservationCreated, "all " + ReservationCreated, "all " + SourceCreated}
+	if !slices.Equal(received, want) {
+		t.Errorf("delivered %v, want %v", received, want)
+	}
+}
+
+func TestLogSink(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := zerolog.New(&buffer)
+	event := NewEvent(ReservationCreated, "customer", "reservation", nil)
+
+	if err := NewLogSink(&logger).Publish(event); err != nil {
+		t.Fatal(err)
+	}
+	if line := buffer.String(); !strings.Contains(line, event.ID) || !strings.Contains(line, ReservationCreated) {
+		t.Errorf("logged %q", line)
+	}
+}
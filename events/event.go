@@ -0,0 +1,113 @@
+/*
+ * Domain events raised by commands so other parts of the engine can react.
+ */
+package events
+
+import (
+	"time"
+
+	"github.com/lghtr35/reservation-engine/util"
+)
+
+// Every successful mutation of a command raises one of these. Secrets, api
+// tokens and calendar feeds are left out on purpose so that credentials never
+// leave the engine.
+const (
+	CustomerCreated = "customer.created"
+	CustomerUpdated = "customer.updated"
+	CustomerDeleted = "customer.deleted"
+
+	SourceCreated = "source.created"
+	SourceUpdated = "source.updated"
+	SourceDeleted = "source.deleted"
+
+	ReservationCreated         = "reservation.created"
+	ReservationUpdated         = "reservation.updated"
+	ReservationCancelled       = "reservation.cancelled"
+	ReservationApproved        = "reservation.approved"
+	ReservationRejected        = "reservation.rejected"
+	ReservationApprovalExpired = "reservation.approval_expired"
+	ReservationPaid            = "reservation.paid"
+	ReservationPaymentFailed   = "reservation.payment_failed"
+	ReservationApproverChanged = "reservation.approver_changed"
+
+	BundleCreated   = "bundle.created"
+	BundleUpdated   = "bundle.updated"
+	BundleCancelled = "bundle.cancelled"
+
+	PersonCreated = "person.created"
+	PersonUpdated = "person.updated"
+	PersonDeleted = "person.deleted"
+
+	ParticipantCreated = "participant.created"
+	ParticipantUpdated = "participant.updated"
+	ParticipantDeleted = "participant.deleted"
+
+	PolicyCreated = "cancellation_policy.created"
+	PolicyUpdated = "cancellation_policy.updated"
+	PolicyDeleted = "cancellation_policy.deleted"
+
+	RateCreated = "rate.created"
+	RateUpdated = "rate.updated"
+	RateDeleted = "rate.deleted"
+
+	PromotionCreated = "promotion.created"
+	PromotionUpdated = "promotion.updated"
+	PromotionDeleted = "promotion.deleted"
+
+	PlanCreated = "plan.created"
+	PlanUpdated = "plan.updated"
+	PlanDeleted = "plan.deleted"
+
+	InvoiceDrafted   = "invoice.drafted"
+	InvoiceIssued    = "invoice.issued"
+	InvoiceDeleted   = "invoice.deleted"
+	CreditNoteIssued = "credit_note.issued"
+
+	WebhookEndpointCreated = "webhook_endpoint.created"
+	WebhookEndpointUpdated = "webhook_endpoint.updated"
+	WebhookEndpointDeleted = "webhook_endpoint.deleted"
+)
+
+// Types lists every event type above, in the order they are declared.
+var Types = []string{
+	CustomerCreated, CustomerUpdated, CustomerDeleted,
+	SourceCreated, SourceUpdated, SourceDeleted,
+	ReservationCreated, ReservationUpdated, ReservationCancelled, ReservationApproved, ReservationRejected,
+	ReservationApprovalExpired, ReservationPaid, ReservationPaymentFailed, ReservationApproverChanged,
+	BundleCreated, BundleUpdated, BundleCancelled,
+	PersonCreated, PersonUpdated, PersonDeleted,
+	ParticipantCreated, ParticipantUpdated, ParticipantDeleted,
+	PolicyCreated, PolicyUpdated, PolicyDeleted,
+	RateCreated, RateUpdated, RateDeleted,
+	PromotionCreated, PromotionUpdated, PromotionDeleted,
+	PlanCreated, PlanUpdated, PlanDeleted,
+	InvoiceDrafted, InvoiceIssued, InvoiceDeleted, CreditNoteIssued,
+	WebhookEndpointCreated, WebhookEndpointUpdated, WebhookEndpointDeleted,
+}
+
+// IsKnown reports whether eventType is one of Types.
+func IsKnown(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened to the aggregate with AggregateID. Events
+// of a customer's resources carry its CustomerID, events of the engine itself,
+// like plan changes, have none.
+type Event struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	CustomerID  string    `json:"customerId"`
+	AggregateID string    `json:"aggregateId"`
+	OccurredAt  time.Time `json:"occurredAt"`
+	Payload     any       `json:"payload"`
+}
+
+func NewEvent(eventType, customerId, aggregateId string, payload any) Event {
+	return Event{ID: util.NewUUID(), Type: eventType, CustomerID: customerId, AggregateID: aggregateId, OccurredAt: time.Now().UTC(), Payload: payload}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/queries"
+	"github.com/lghtr35/reservation-engine/rpc"
+	"github.com/lghtr35/reservation-engine/streams"
+	"github.com/lghtr35/reservation-engine/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// jwtMethods are the calls authenticated with a jwt, every other one is
+// authenticated with an api key and metered like the api key routes.
+var jwtMethods = map[string]bool{
+	rpc.ReservationEngine_ListCustomers_FullMethodName:  true,
+	rpc.ReservationEngine_GetCustomer_FullMethodName:    true,
+	rpc.ReservationEngine_CreateCustomer_FullMethodName: true,
+	rpc.ReservationEngine_UpdateCustomer_FullMethodName: true,
+	rpc.ReservationEngine_DeleteCustomer_FullMethodName: true,
+}
+
+// apiTokenKey holds the api token that authenticated a call in its context.
+type apiTokenKey struct{}
+
+// grpcServer serves the gRPC API with the commands and queries of the REST
+// handlers.
+type grpcServer struct {
+	rpc.UnimplementedReservationEngineServer
+	h       *Handler
+	limiter *util.RateLimiter
+}
+
+func newGrpcServer(h *Handler, limiter *util.RateLimiter) (*grpc.Server, error) {
+	s := &grpcServer{h: h, limiter: limiter}
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAuthInterceptor),
+		grpc.StreamInterceptor(s.streamAuthInterceptor),
+	}
+	// The gRPC API serves TLS with the certificate of the REST one
+	if h.configuration.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(h.configuration.TLSCertFile, h.configuration.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(options...)
+	rpc.RegisterReservationEngineServer(server, s)
+	return server, nil
+}
+
+// serveGrpc serves the gRPC API until the listener fails or the server is
+// stopped.
+func serveGrpc(server *grpc.Server, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// stopGrpc stops the gRPC server from accepting calls and waits for the ones
+// in flight, the calls left when the context is done are cancelled.
+func stopGrpc(ctx context.Context, server *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return fmt.Errorf("stopGrpc: calls in flight were cancelled: %w", ctx.Err())
+	}
+}
+
+func metadataValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// authenticate does what jwtAuthMiddleware or apiKeyAuthMiddleware and
+// usageMiddleware do for the REST routes, and returns the context the call is
+// served with.
+func (s *grpcServer) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if jwtMethods[method] {
+		_, err := authenticateJwt(s.h.configuration, s.h.db, s.h.logger, metadataValue(md, "authorization"))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, nil
+	}
+
+	token, err := authenticateApiKey(s.h.db, s.h.logger, metadataValue(md, "x-api-token"), metadataValue(md, "x-api-secret"))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	err = meterUsage(s.h.db, s.h.logger, s.limiter, token.CustomerID)
+	if err == errRateLimited {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "An error occured")
+	}
+	return context.WithValue(ctx, apiTokenKey{}, token), nil
+}
+
+func (s *grpcServer) unaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream serves a stream with the context of its
+// authentication.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcServer) streamAuthInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// grpcError is the status of an error of a command or query. Like the REST
+// handlers every failure is blamed on the request, except for overlapping
+// reservations which conflict with the state of the source.
+func (s *grpcServer) grpcError(err error) error {
+	s.h.logger.Err(err)
+	if errors.Is(err, commands.ErrOverlappingReservations) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func (s *grpcServer) ListCustomers(ctx context.Context, req *rpc.ListCustomersRequest) (*rpc.ListCustomersResponse, error) {
+	q := queries.NewFilterCustomersQuery(s.h.store, s.h.logger, rpc.StringsOrNil(req.Ids), req.Name, rpc.FromPagination(req.Pagination))
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	page := res.(models.PaginationResponse[models.Customer])
+	content := make([]*rpc.Customer, 0, len(page.Content))
+	for _, customer := range page.Content {
+		content = append(content, rpc.FromCustomer(customer))
+	}
+	return &rpc.ListCustomersResponse{Total: page.Total, Page: page.Page, Count: int32(page.Count), Content: content}, nil
+}
+
+func (s *grpcServer) GetCustomer(ctx context.Context, req *rpc.GetRequest) (*rpc.Customer, error) {
+	q := queries.NewReadCustomerQuery(s.h.store, s.h.logger, req.Id)
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromCustomer(res.(models.Customer)), nil
+}
+
+func (s *grpcServer) CreateCustomer(ctx context.Context, req *rpc.CreateCustomerRequest) (*rpc.Customer, error) {
+	q := commands.NewCreateCustomerCommand(s.h.store, s.h.logger, req.Name, req.Company, req.Email)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	sQ := commands.NewCreateSecretCommand(s.h.store, s.h.logger, s.h.hasher, id)
+	_, err = sQ.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return s.GetCustomer(ctx, &rpc.GetRequest{Id: id})
+}
+
+func (s *grpcServer) UpdateCustomer(ctx context.Context, req *rpc.UpdateCustomerRequest) (*rpc.Customer, error) {
+	q := commands.NewUpdateCustomerCommand(s.h.store, s.h.logger, req.Id, req.Name, req.Email, req.Company, nil)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return s.GetCustomer(ctx, &rpc.GetRequest{Id: id})
+}
+
+func (s *grpcServer) DeleteCustomer(ctx context.Context, req *rpc.DeleteRequest) (*rpc.DeleteResponse, error) {
+	q := commands.NewDeleteCustomerCommand(s.h.store, s.h.logger, req.Id)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return &rpc.DeleteResponse{Id: id}, nil
+}
+
+func (s *grpcServer) ListSources(ctx context.Context, req *rpc.ListSourcesRequest) (*rpc.ListSourcesResponse, error) {
+	q := queries.NewFilterSourcesQuery(s.h.store, s.h.logger, rpc.StringsOrNil(req.Ids), req.Name, rpc.FromPagination(req.Pagination))
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	page := res.(models.PaginationResponse[models.Source])
+	content := make([]*rpc.Source, 0, len(page.Content))
+	for _, source := range page.Content {
+		content = append(content, rpc.FromSource(source))
+	}
+	return &rpc.ListSourcesResponse{Total: page.Total, Page: page.Page, Count: int32(page.Count), Content: content}, nil
+}
+
+func (s *grpcServer) GetSource(ctx context.Context, req *rpc.GetRequest) (*rpc.Source, error) {
+	q := queries.NewReadSourceQuery(s.h.store, s.h.logger, req.Id)
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromSource(res.(models.Source)), nil
+}
+
+func (s *grpcServer) CreateSource(ctx context.Context, req *rpc.CreateSourceRequest) (*rpc.Source, error) {
+	var pricing models.SourcePricing
+	if p := rpc.ToSourcePricing(req.Pricing); p != nil {
+		pricing = *p
+	}
+	q := commands.NewCreateSourceCommand(s.h.store, s.h.logger, req.Name, req.MaxPossibleReservationDuration, req.CustomerId, req.RequiresApproval, req.ApproverId, req.ApprovalTimeout, req.CancellationPolicyId, pricing)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	tQ := commands.NewCreateApiTokenCommand(s.h.store, s.h.logger, s.h.hasher, req.CustomerId, id, s.h.configuration.ApiTokenLifetime.Duration())
+	_, err = tQ.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return s.GetSource(ctx, &rpc.GetRequest{Id: id})
+}
+
+func (s *grpcServer) UpdateSource(ctx context.Context, req *rpc.UpdateSourceRequest) (*rpc.Source, error) {
+	q := commands.NewUpdateSourceCommand(s.h.store, s.h.logger, req.Id, req.Name, req.MaxPossibleReservationDuration, req.RequiresApproval, req.ApproverId, req.ApprovalTimeout, req.CancellationPolicyId, rpc.ToSourcePricing(req.Pricing))
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return s.GetSource(ctx, &rpc.GetRequest{Id: id})
+}
+
+func (s *grpcServer) DeleteSource(ctx context.Context, req *rpc.DeleteRequest) (*rpc.DeleteResponse, error) {
+	q := commands.NewDeleteSourceCommand(s.h.store, s.h.logger, req.Id)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return &rpc.DeleteResponse{Id: id}, nil
+}
+
+func (s *grpcServer) ListReservations(ctx context.Context, req *rpc.ListReservationsRequest) (*rpc.ListReservationsResponse, error) {
+	q := queries.NewFilterReservationsQuery(s.h.store, s.h.logger, rpc.StringsOrNil(req.Ids), req.ReserveeId, req.ReserverId, req.SourceId, req.ParticipantId, req.Status, req.ApproverId, rpc.FromPagination(req.Pagination))
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	page := res.(models.PaginationResponse[models.Reservation])
+	content := make([]*rpc.Reservation, 0, len(page.Content))
+	for _, reservation := range page.Content {
+		content = append(content, rpc.FromReservation(reservation))
+	}
+	return &rpc.ListReservationsResponse{Total: page.Total, Page: page.Page, Count: int32(page.Count), Content: content}, nil
+}
+
+func (s *grpcServer) GetReservation(ctx context.Context, req *rpc.GetRequest) (*rpc.Reservation, error) {
+	q := queries.NewReadReservationQuery(s.h.store, s.h.logger, req.Id)
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromReservation(res.(models.Reservation)), nil
+}
+
+func (s *grpcServer) CreateReservation(ctx context.Context, req *rpc.CreateReservationRequest) (*rpc.Reservation, error) {
+	q := commands.NewCreateReservationCommand(s.h.store, s.h.logger, s.h.payments, rpc.Time(req.From), rpc.Time(req.To), req.ReserverId, req.ReserveeId, req.SourceId, rpc.ToReservationParticipants(req.Participants), int(req.Units), req.PromotionCode, req.PaymentMethod)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return s.GetReservation(ctx, &rpc.GetRequest{Id: id})
+}
+
+func (s *grpcServer) UpdateReservation(ctx context.Context, req *rpc.UpdateReservationRequest) (*rpc.ReservationChange, error) {
+	q := commands.NewUpdateReservationCommand(s.h.store, s.h.logger, req.Id, rpc.OptionalTime(req.From), rpc.OptionalTime(req.To), nil)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromReservationChange(id, q.Fee()), nil
+}
+
+func (s *grpcServer) CancelReservation(ctx context.Context, req *rpc.CancelReservationRequest) (*rpc.ReservationChange, error) {
+	q := commands.NewDeleteReservationCommand(s.h.store, s.h.logger, s.h.payments, req.Id, nil)
+
+	id, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromReservationChange(id, q.Fee()), nil
+}
+
+func (s *grpcServer) GetAvailability(ctx context.Context, req *rpc.GetAvailabilityRequest) (*rpc.Availability, error) {
+	q := queries.NewAvailabilityQuery(s.h.db, s.h.logger, req.SourceId, rpc.Time(req.From), rpc.Time(req.To))
+
+	res, err := q.Execute()
+	if err != nil {
+		return nil, s.grpcError(err)
+	}
+
+	return rpc.FromAvailability(res.(models.Availability)), nil
+}
+
+// reservationEvent turns a stream message into its protobuf form.
+func reservationEvent(message streams.Message) (*rpc.ReservationEvent, error) {
+	event := &rpc.ReservationEvent{Id: message.ID, Type: message.Event}
+	if message.Event == streams.AvailabilityChanged {
+		var availability streams.Availability
+		err := json.Unmarshal(message.Data, &availability)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = &rpc.ReservationEvent_Slot{Slot: rpc.FromSlotChange(availability)}
+		return event, nil
+	}
+
+	var reservation models.Reservation
+	err := json.Unmarshal(message.Data, &reservation)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = &rpc.ReservationEvent_Reservation{Reservation: rpc.FromReservation(reservation)}
+	return event, nil
+}
+
+func (s *grpcServer) WatchReservations(req *rpc.WatchReservationsRequest, stream grpc.ServerStreamingServer[rpc.ReservationEvent]) error {
+	token, _ := stream.Context().Value(apiTokenKey{}).(models.ApiToken)
+	cursor := int64(-1)
+	if req.LastEventId != nil && *req.LastEventId >= 0 {
+		cursor = *req.LastEventId
+	}
+
+	// The source is checked before the stream starts, like the REST stream.
+	_, err := queries.NewStreamEventsQuery(s.h.db, s.h.logger, token.CustomerID, req.GetSourceId(), -1, 1).Execute()
+	if err != nil {
+		return s.grpcError(err)
+	}
+
+	err = s.h.stream(stream.Context(), token.CustomerID, req.GetSourceId(), cursor, func(message streams.Message) error {
+		event, err := reservationEvent(message)
+		if err != nil {
+			return err
+		}
+		return stream.Send(event)
+	}, func() error {
+		// HTTP/2 pings keep the connection alive, there is nothing to send.
+		return nil
+	})
+	if err != nil {
+		s.h.logger.Error().Err(err).Msg("WatchReservations: stream ended with an error")
+	}
+	return err
+}
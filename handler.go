@@ -1,21 +1,38 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/billing"
 	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/migrations"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/openapi"
+	"github.com/lghtr35/reservation-engine/payments"
 	"github.com/lghtr35/reservation-engine/queries"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/streams"
 	"github.com/lghtr35/reservation-engine/util"
+	"github.com/lghtr35/reservation-engine/workers"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db     *gorm.DB
-	logger *zerolog.Logger
-	hasher *util.Hasher
+	db            *gorm.DB
+	store         repository.Store
+	logger        *zerolog.Logger
+	hasher        *util.Hasher
+	payments      payments.PaymentProvider
+	configuration *models.Configuration
+	hub           *streams.Hub
+	migrator      *migrations.Migrator
+	workers       *workers.Group
 }
 
 // Queries
@@ -28,7 +45,7 @@ func (h *Handler) ReadAllCustomers(c *gin.Context) {
 		return
 	}
 
-	q := queries.NewFilterCustomersQuery(h.db, h.logger, request.IDs, request.Name, request.Pagination)
+	q := queries.NewFilterCustomersQuery(h.store, h.logger, request.IDs, request.Name, request.Pagination)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -49,7 +66,7 @@ func (h *Handler) ReadAllSources(c *gin.Context) {
 		return
 	}
 
-	q := queries.NewFilterSourcesQuery(h.db, h.logger, request.IDs, request.Name, request.Pagination)
+	q := queries.NewFilterSourcesQuery(h.store, h.logger, request.IDs, request.Name, request.Pagination)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -70,7 +87,28 @@ func (h *Handler) ReadAllReservations(c *gin.Context) {
 		return
 	}
 
-	q := queries.NewFilterReservationsQuery(h.db, h.logger, request.IDs, request.ReserveeID, request.ReserverID, request.SourceID, request.Pagination)
+	q := queries.NewFilterReservationsQuery(h.store, h.logger, request.IDs, request.ReserveeID, request.ReserverID, request.SourceID, request.ParticipantID, request.Status, request.ApproverID, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllPersons(c *gin.Context) {
+	var request models.ReadAllPersons
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterPersonsQuery(h.db, h.logger, c.GetString("customerId"), request.IDs, request.ExternalRef, request.Name, request.Email, request.Pagination)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -85,7 +123,7 @@ func (h *Handler) ReadAllReservations(c *gin.Context) {
 func (h *Handler) ReadCustomer(c *gin.Context) {
 	id := c.Param("id")
 
-	q := queries.NewReadCustomerQuery(h.db, h.logger, id)
+	q := queries.NewReadCustomerQuery(h.store, h.logger, id)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -100,7 +138,7 @@ func (h *Handler) ReadCustomer(c *gin.Context) {
 func (h *Handler) ReadSource(c *gin.Context) {
 	id := c.Param("id")
 
-	q := queries.NewReadSourceQuery(h.db, h.logger, id)
+	q := queries.NewReadSourceQuery(h.store, h.logger, id)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -115,7 +153,37 @@ func (h *Handler) ReadSource(c *gin.Context) {
 func (h *Handler) ReadReservation(c *gin.Context) {
 	id := c.Param("id")
 
-	q := queries.NewReadReservationQuery(h.db, h.logger, id)
+	q := queries.NewReadReservationQuery(h.store, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadBundle(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadBundleQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadPerson(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadPersonQuery(h.db, h.logger, c.GetString("customerId"), id)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -131,7 +199,7 @@ func (h *Handler) ReadReservation(c *gin.Context) {
 func (h *Handler) DeleteCustomer(c *gin.Context) {
 	id := c.Param("id")
 
-	q := commands.NewDeleteCustomerCommand(h.db, h.logger, id)
+	q := commands.NewDeleteCustomerCommand(h.store, h.logger, id)
 
 	_, err := q.Execute()
 	if err != nil {
@@ -146,7 +214,7 @@ func (h *Handler) DeleteCustomer(c *gin.Context) {
 func (h *Handler) DeleteSource(c *gin.Context) {
 	id := c.Param("id")
 
-	q := commands.NewDeleteSourceCommand(h.db, h.logger, id)
+	q := commands.NewDeleteSourceCommand(h.store, h.logger, id)
 
 	_, err := q.Execute()
 	if err != nil {
@@ -161,7 +229,76 @@ func (h *Handler) DeleteSource(c *gin.Context) {
 func (h *Handler) DeleteReservation(c *gin.Context) {
 	id := c.Param("id")
 
-	q := commands.NewDeleteReservationCommand(h.db, h.logger, id)
+	q := commands.NewDeleteReservationCommand(h.store, h.logger, h.payments, id, nil)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReservationChange{ID: res, Fee: q.Fee()})
+}
+
+func (h *Handler) AdminDeleteReservation(c *gin.Context) {
+	id := c.Param("id")
+
+	var override models.FeeOverride
+	err := c.ShouldBindQuery(&override)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	override.By = claimedCustomerID(c)
+
+	q := commands.NewDeleteReservationCommand(h.store, h.logger, h.payments, id, &override)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReservationChange{ID: res, Fee: q.Fee()})
+}
+
+func (h *Handler) DeleteBundle(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteBundleCommand(h.store, h.logger, h.payments, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Fees())
+}
+
+func (h *Handler) DeletePerson(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeletePersonCommand(h.db, h.logger, c.GetString("customerId"), id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) DeleteParticipant(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteParticipantCommand(h.db, h.logger, id)
 
 	_, err := q.Execute()
 	if err != nil {
@@ -182,7 +319,7 @@ func (h *Handler) CreateCustomer(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewCreateCustomerCommand(h.db, h.logger, request.Name, request.Company, request.Email)
+	q := commands.NewCreateCustomerCommand(h.store, h.logger, request.Name, request.Company, request.Email)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -191,7 +328,7 @@ func (h *Handler) CreateCustomer(c *gin.Context) {
 		return
 	}
 
-	sQ := commands.NewCreateSecretCommand(h.db, h.logger, h.hasher, res)
+	sQ := commands.NewCreateSecretCommand(h.store, h.logger, h.hasher, res)
 	_, err = sQ.Execute()
 	if err != nil {
 		h.logger.Err(err)
@@ -211,7 +348,7 @@ func (h *Handler) CreateSource(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewCreateSourceCommand(h.db, h.logger, request.Name, request.MaxPossibleDuration, request.CustomerID)
+	q := commands.NewCreateSourceCommand(h.store, h.logger, request.Name, request.MaxPossibleDuration, request.CustomerID, request.RequiresApproval, request.ApproverID, request.ApprovalTimeout, request.CancellationPolicyID, request.Pricing)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -220,7 +357,7 @@ func (h *Handler) CreateSource(c *gin.Context) {
 		return
 	}
 
-	tQ := commands.NewCreateApiTokenCommand(h.db, h.logger, h.hasher, request.CustomerID, res)
+	tQ := commands.NewCreateApiTokenCommand(h.store, h.logger, h.hasher, request.CustomerID, res, h.configuration.ApiTokenLifetime.Duration())
 	_, err = tQ.Execute()
 	if err != nil {
 		h.logger.Err(err)
@@ -240,7 +377,93 @@ func (h *Handler) CreateReservation(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewCreateReservationCommand(h.db, h.logger, request.From, request.To, request.ReserverID, request.ReserveeID)
+	q := commands.NewCreateReservationCommand(h.store, h.logger, h.payments, request.From, request.To, request.ReserverID, request.ReserveeID, request.SourceID, request.Participants, request.Units, request.PromotionCode, request.PaymentMethod)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// ImportReservations reports what the import of the calendar would create,
+// and creates it when the request commits.
+func (h *Handler) ImportReservations(c *gin.Context) {
+	var request models.ImportReservations
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewImportReservationsCommand(h.store, h.logger, c.GetString("customerId"), request.SourceID, request.ReserverID, request.ReserveeID, request.Units, request.Calendar, request.Commit, time.Now())
+
+	_, err = q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Report())
+}
+
+func (h *Handler) CreateBundle(c *gin.Context) {
+	var request models.CreateBundle
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateBundleCommand(h.store, h.logger, request.From, request.To, request.ReserverID, request.ReserveeID, request.SourceIDs)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreatePerson(c *gin.Context) {
+	var request models.CreatePerson
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreatePersonCommand(h.db, h.logger, c.GetString("customerId"), request.ExternalRef, request.Name, request.Email, request.Phone, request.Metadata)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateParticipant(c *gin.Context) {
+	var request models.CreateParticipant
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateParticipantCommand(h.db, h.logger, request.ReservationID, request.PersonID, request.Role)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -261,7 +484,7 @@ func (h *Handler) UpdateCustomer(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewUpdateCustomerCommand(h.db, h.logger, request.ID, request.Name, request.Email, request.Company, request.MaxSourceLimit)
+	q := commands.NewUpdateCustomerCommand(h.store, h.logger, request.ID, request.Name, request.Email, request.Company, request.MaxSourceLimit)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -282,7 +505,7 @@ func (h *Handler) UpdateSource(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewUpdateSourceCommand(h.db, h.logger, request.ID, request.Name, request.MaxPossibleDuration)
+	q := commands.NewUpdateSourceCommand(h.store, h.logger, request.ID, request.Name, request.MaxPossibleDuration, request.RequiresApproval, request.ApproverID, request.ApprovalTimeout, request.CancellationPolicyID, request.Pricing)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -303,7 +526,92 @@ func (h *Handler) UpdateReservation(c *gin.Context) {
 		return
 	}
 
-	q := commands.NewUpdateReservationCommand(h.db, h.logger, request.ID, request.From, request.To)
+	q := commands.NewUpdateReservationCommand(h.store, h.logger, request.ID, request.From, request.To, nil)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReservationChange{ID: res, Fee: q.Fee()})
+}
+
+func (h *Handler) AdminUpdateReservation(c *gin.Context) {
+	var request models.AdminUpdateReservation
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	request.Override.By = claimedCustomerID(c)
+
+	q := commands.NewUpdateReservationCommand(h.store, h.logger, request.ID, request.From, request.To, &request.Override)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReservationChange{ID: res, Fee: q.Fee()})
+}
+
+func (h *Handler) UpdateBundle(c *gin.Context) {
+	var request models.UpdateBundle
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdateBundleCommand(h.store, h.logger, request.ID, request.From, request.To)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdatePerson(c *gin.Context) {
+	var request models.UpdatePerson
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdatePersonCommand(h.db, h.logger, c.GetString("customerId"), request.ID, request.ExternalRef, request.Name, request.Email, request.Phone, request.Metadata)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdateParticipant(c *gin.Context) {
+	var request models.UpdateParticipant
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdateParticipantCommand(h.db, h.logger, request.ID, request.Role, request.RSVP)
 
 	res, err := q.Execute()
 	if err != nil {
@@ -314,3 +622,996 @@ func (h *Handler) UpdateReservation(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+func (h *Handler) ApproveReservation(c *gin.Context) {
+	var request models.ReservationDecision
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewApproveReservationCommand(h.db, h.logger, request.ID, request.ApproverID, request.Comment)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) RejectReservation(c *gin.Context) {
+	var request models.ReservationDecision
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewRejectReservationCommand(h.db, h.logger, h.payments, request.ID, request.ApproverID, request.Comment)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) AssignApprover(c *gin.Context) {
+	var request models.AssignApprover
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewAssignApproverCommand(h.db, h.logger, request.ID, request.ApproverID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllCancellationPolicies(c *gin.Context) {
+	var request models.ReadAllCancellationPolicies
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterCancellationPoliciesQuery(h.db, h.logger, request.CustomerID, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadCancellationPolicy(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadCancellationPolicyQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteCancellationPolicy(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteCancellationPolicyCommand(h.db, h.logger, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) CreateCancellationPolicy(c *gin.Context) {
+	var request models.CreateCancellationPolicy
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateCancellationPolicyCommand(h.db, h.logger, request.CustomerID, request.Name, request.CancellationRules, request.ModificationRules)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdateCancellationPolicy(c *gin.Context) {
+	var request models.UpdateCancellationPolicy
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdateCancellationPolicyCommand(h.db, h.logger, request.ID, request.Name, request.CancellationRules, request.ModificationRules)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateQuote(c *gin.Context) {
+	var request models.CreateQuote
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewQuoteQuery(h.db, h.logger, request.SourceID, request.From, request.To, request.Units, request.PromotionCode, request.ReserveeID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllRates(c *gin.Context) {
+	var request models.ReadAllRates
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterRatesQuery(h.db, h.logger, request.SourceID, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadRate(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadRateQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteRate(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteRateCommand(h.db, h.logger, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) CreateRate(c *gin.Context) {
+	var request models.CreateRate
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateRateCommand(h.db, h.logger, request.SourceID, request.Name, request.Kind, request.AmountMinor, request.Weekdays, request.StartTime, request.EndTime, request.Priority, request.PerUnit)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdateRate(c *gin.Context) {
+	var request models.UpdateRate
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdateRateCommand(h.db, h.logger, request.ID, request.Name, request.Kind, request.AmountMinor, request.Weekdays, request.StartTime, request.EndTime, request.Priority, request.PerUnit)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllPromotions(c *gin.Context) {
+	var request models.ReadAllPromotions
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterPromotionsQuery(h.db, h.logger, request.CustomerID, request.Code, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadPromotion(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadPromotionQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadPromotionReport(c *gin.Context) {
+	var request models.ReadPromotionReport
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewPromotionReportQuery(h.db, h.logger, request.CustomerID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeletePromotion(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeletePromotionCommand(h.db, h.logger, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) CreatePromotion(c *gin.Context) {
+	var request models.CreatePromotion
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreatePromotionCommand(h.db, h.logger, request.CustomerID, request.Code, request.Kind, request.PercentOff, request.AmountOffMinor, request.Currency, request.SourceIDs, request.ValidFrom, request.ValidUntil, request.FirstTimeOnly, request.MaxRedemptions)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdatePromotion(c *gin.Context) {
+	var request models.UpdatePromotion
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdatePromotionCommand(h.db, h.logger, request.ID, request.SourceIDs, request.ValidFrom, request.ValidUntil, request.FirstTimeOnly, request.MaxRedemptions, request.Active)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// HandlePaymentWebhook is called by the payment provider, the signature of the
+// body takes the place of the usual authentication.
+func (h *Handler) HandlePaymentWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewHandlePaymentWebhookCommand(h.db, h.logger, h.payments, payload, c.Request.Header)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhookReceipt{EventID: res, Duplicate: q.Duplicate()})
+}
+
+func (h *Handler) ReadAllPlans(c *gin.Context) {
+	var request models.ReadAllPlans
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterPlansQuery(h.db, h.logger, request.Name, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadPlan(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadPlanQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeletePlan(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeletePlanCommand(h.store, h.logger, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) CreatePlan(c *gin.Context) {
+	var request models.CreatePlan
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreatePlanCommand(h.store, h.logger, request.Name, request.MaxSources, request.MaxReservationsPerMonth, request.MaxApiTokens, request.RateLimitPerMinute, request.Features, request.Billing)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdatePlan(c *gin.Context) {
+	var request models.UpdatePlan
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdatePlanCommand(h.store, h.logger, request.ID, request.Name, request.MaxSources, request.MaxReservationsPerMonth, request.MaxApiTokens, request.RateLimitPerMinute, request.Features, request.Billing)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) AssignPlan(c *gin.Context) {
+	var request models.AssignPlan
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewAssignPlanCommand(h.store, h.logger, request.CustomerID, request.PlanID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// ReadUsage reports the usage of the customer the api token belongs to.
+func (h *Handler) ReadUsage(c *gin.Context) {
+	var request models.ReadUsage
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewUsageQuery(h.db, h.logger, c.GetString("customerId"), request.Period)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllApiTokens(c *gin.Context) {
+	var request models.ReadAllApiTokens
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterApiTokensQuery(h.store, h.logger, request.CustomerID, request.SourceID, request.Valid, time.Now(), request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// CreateApiToken issues another api token for a source, the one created with
+// the source can then be revoked without downtime.
+func (h *Handler) CreateApiToken(c *gin.Context) {
+	var request models.CreateApiToken
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateApiTokenCommand(h.store, h.logger, h.hasher, request.CustomerID, request.SourceID, h.configuration.ApiTokenLifetime.Duration())
+
+	_, err = q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Token())
+}
+
+func (h *Handler) RevokeApiToken(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewRevokeApiTokenCommand(h.store, h.logger, id, time.Now())
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) ReadAllInvoices(c *gin.Context) {
+	var request models.ReadAllInvoices
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterInvoicesQuery(h.db, h.logger, request.CustomerID, request.Period, request.Kind, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadInvoice(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadInvoiceQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadInvoiceCSV(c *gin.Context) {
+	id := c.Param("id")
+
+	q := queries.NewReadInvoiceQuery(h.db, h.logger, id)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	var buffer bytes.Buffer
+	err = billing.WriteCSV(&buffer, res.(models.Invoice))
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=invoice-"+id+".csv")
+	c.Data(http.StatusOK, "text/csv", buffer.Bytes())
+}
+
+func (h *Handler) CreateInvoice(c *gin.Context) {
+	var request models.CreateInvoice
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewGenerateInvoiceCommand(h.store, h.logger, h.configuration.TaxRateBasisPoints, request.CustomerID, request.Period)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) IssueInvoice(c *gin.Context) {
+	var request models.IssueInvoice
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewIssueInvoiceCommand(h.store, h.logger, request.ID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteInvoice(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteInvoiceCommand(h.store, h.logger, id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) CreateCreditNote(c *gin.Context) {
+	var request models.CreateCreditNote
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateCreditNoteCommand(h.store, h.logger, request.InvoiceID, request.Reason, request.Lines)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllWebhookEndpoints(c *gin.Context) {
+	var request models.ReadAllWebhookEndpoints
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterWebhookEndpointsQuery(h.db, h.logger, c.GetString("customerId"), request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateWebhookEndpoint(c *gin.Context) {
+	var request models.CreateWebhookEndpoint
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateWebhookEndpointCommand(h.store, h.logger, c.GetString("customerId"), request.URL, request.EventTypes)
+
+	_, err = q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Endpoint())
+}
+
+func (h *Handler) UpdateWebhookEndpoint(c *gin.Context) {
+	var request models.UpdateWebhookEndpoint
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewUpdateWebhookEndpointCommand(h.store, h.logger, c.GetString("customerId"), request.ID, request.URL, request.EventTypes, request.Active, request.RotateSecret)
+
+	_, err = q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	endpoint := q.Endpoint()
+	if !request.RotateSecret {
+		endpoint.Secret = ""
+	}
+	c.JSON(http.StatusOK, endpoint)
+}
+
+func (h *Handler) DeleteWebhookEndpoint(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteWebhookEndpointCommand(h.store, h.logger, c.GetString("customerId"), id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *Handler) ReadAllWebhookDeliveries(c *gin.Context) {
+	var request models.ReadAllWebhookDeliveries
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterWebhookDeliveriesQuery(h.db, h.logger, c.GetString("customerId"), request.EndpointID, request.EventID, request.Status, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadWebhookDeadLetters(c *gin.Context) {
+	var request models.ReadAllWebhookDeliveries
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	status := models.WebhookDeliveryStatusDead
+	q := queries.NewFilterWebhookDeliveriesQuery(h.db, h.logger, c.GetString("customerId"), request.EndpointID, request.EventID, &status, request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	var request models.RedeliverWebhook
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewRedeliverWebhookCommand(h.store, h.logger, c.GetString("customerId"), request.ID)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadAllCalendarFeeds(c *gin.Context) {
+	var request models.ReadAllCalendarFeeds
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewFilterCalendarFeedsQuery(h.db, h.logger, c.GetString("customerId"), request.Pagination)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateCalendarFeed(c *gin.Context) {
+	var request models.CreateCalendarFeed
+	err := c.ShouldBind(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := commands.NewCreateCalendarFeedCommand(h.db, h.logger, c.GetString("customerId"), request.SourceID, request.PersonID)
+
+	_, err = q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Feed())
+}
+
+func (h *Handler) DeleteCalendarFeed(c *gin.Context) {
+	id := c.Param("id")
+
+	q := commands.NewDeleteCalendarFeedCommand(h.db, h.logger, c.GetString("customerId"), id)
+
+	_, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// ReadCalendarFeed serves /feeds/<token>.ics, the token authenticates the
+// request so that calendar apps can subscribe without credentials.
+func (h *Handler) ReadCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("file"), ".ics")
+
+	q := queries.NewCalendarFeedQuery(h.db, h.logger, token, time.Now())
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", res.([]byte))
+}
+
+func (h *Handler) ReadAvailability(c *gin.Context) {
+	var request models.ReadAvailability
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	q := queries.NewAvailabilityQuery(h.db, h.logger, request.SourceID, request.From, request.To)
+
+	res, err := q.Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReadOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openapi.Spec)
+}
+
+func (h *Handler) ReadDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.Docs)
+}
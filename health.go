@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// readinessTimeout bounds the checks of one readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// ReadHealth answers the liveness probe, the engine is alive while it
+// answers at all.
+func (h *Handler) ReadHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Health{Status: "ok"})
+}
+
+// ReadReadiness answers the readiness probe, the engine is ready when its
+// database answers, no migration is pending and every background worker is
+// running.
+func (h *Handler) ReadReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "migrations": "ok", "workers": "ok"}
+	sqlDB, err := h.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		checks["database"] = err.Error()
+	}
+	pending, err := h.migrator.Pending(ctx)
+	if err != nil {
+		checks["migrations"] = err.Error()
+	} else if len(pending) > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending, run reservation-engine migrate up", len(pending))
+	}
+	if stopped := h.workers.Stopped(); len(stopped) > 0 {
+		checks["workers"] = "stopped: " + strings.Join(stopped, ", ")
+	}
+
+	for _, check := range checks {
+		if check != "ok" {
+			c.JSON(http.StatusServiceUnavailable, models.Health{Status: "unavailable", Checks: checks})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.Health{Status: "ok", Checks: checks})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// idempotencyKeyHeader names the key clients send with mutations they may
+// retry.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// recordingWriter keeps a copy of the response body while writing it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyMiddleware replays the response to a mutation that was already
+// served with the same Idempotency-Key for the same customer, so that a
+// client can retry mutations it did not get an answer to. Responses of
+// failures on the server are not kept, those requests can be retried.
+func idempotencyMiddleware(db *gorm.DB, logger *zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request - Idempotency key is longer than 255 characters"})
+			c.Abort()
+			return
+		}
+
+		// Keys of api key and jwt callers never meet.
+		scope := "apiKey:" + c.GetString("customerId")
+		if c.GetString("customerId") == "" {
+			scope = "jwt:" + claimedCustomerID(c)
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bad Request - Could not read the body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n" + string(body)))
+		fingerprint := hex.EncodeToString(hash[:])
+
+		existing, err := commands.ClaimIdempotencyKey(db, scope, key, fingerprint, time.Now())
+		if err != nil {
+			switch {
+			case errors.Is(err, commands.ErrIdempotencyKeyInUse):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, commands.ErrIdempotencyKeyReused):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			default:
+				logger.Err(err).Msg(fmt.Sprintf("idempotencyMiddleware: an error occured: %s", err.Error()))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+			}
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.Status, existing.ContentType, existing.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			err = commands.ReleaseIdempotencyKey(db, scope, key)
+		} else {
+			err = commands.CompleteIdempotencyKey(db, scope, key, status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+		}
+		if err != nil {
+			logger.Err(err).Msg(fmt.Sprintf("idempotencyMiddleware: could not keep the response: %s", err.Error()))
+		}
+	}
+}
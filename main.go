@@ -1,77 +1,378 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/database"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/migrations"
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/streams"
 	"github.com/lghtr35/reservation-engine/util"
+	"github.com/lghtr35/reservation-engine/webhooks"
+	"github.com/lghtr35/reservation-engine/workers"
 	"github.com/rs/zerolog"
-	"gorm.io/driver/postgres"
+	"google.golang.org/grpc"
 	"gorm.io/gorm"
 )
 
 func main() {
-	logger := zerolog.New(os.Stdout)
-
-	configuration := models.Configuration{}
-	err := configuration.ReadAndFillSelf(logger)
+	configuration, args, err := models.LoadConfiguration("reservation-engine", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reservation-engine:", err)
+		os.Exit(2)
+	}
+	// "reservation-engine config print" shows what the engine would run with
+	if len(args) > 0 && args[0] == "config" {
+		err = models.ConfigurationCommand(configuration, args[1:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "reservation-engine:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	err = configuration.Validate()
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, "reservation-engine:", err)
+		os.Exit(2)
 	}
+	logger := zerolog.New(os.Stdout).Level(configuration.Level())
+
 	hasher, err := util.NewHasher(&configuration)
 	if err != nil {
-		panic(err)
+		logger.Error().Err(err).Msg("Could not set up the secret hasher")
+		os.Exit(1)
+	}
+	db, err := database.Open(&configuration)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not open the database")
+		os.Exit(1)
+	}
+
+	migrator, err := migrations.New(db, &logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not load the migrations")
+		os.Exit(1)
 	}
-	db, err := gorm.Open(postgres.Open(configuration.DbConnectionString), &gorm.Config{})
+	// "reservation-engine migrate ..." only migrates, see migrations.CommandUsage
+	if len(args) > 0 && args[0] == "migrate" {
+		err = migrations.Command(context.Background(), migrator, models.Entities(), args[1:], os.Stdout)
+		if err != nil {
+			logger.Error().Err(err).Msg("migrate failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if !configuration.SkipMigrations {
+		_, err = migrator.Up(context.Background())
+		if err != nil {
+			logger.Error().Err(err).Msg("Could not apply the migrations")
+			os.Exit(1)
+		}
+	}
+	drifts, err := migrator.Drift(models.Entities())
 	if err != nil {
-		panic(err)
+		logger.Error().Err(err).Msg("Could not compare the schema with the models")
+		os.Exit(1)
+	}
+	for _, drift := range drifts {
+		logger.Warn().Msg("Schema drift: " + drift.String())
+	}
+	// A migrated schema that differs from the models is broken, the engine
+	// only starts on one while migrating is left to "migrate".
+	if len(drifts) > 0 && !configuration.SkipMigrations {
+		logger.Error().Err(&migrations.DriftError{Drifts: drifts}).Msg("The schema does not match the models")
+		os.Exit(1)
 	}
 
-	err = db.AutoMigrate(
-		&models.Source{},
-		&models.Secret{},
-		&models.ApiToken{},
-		&models.Reservation{},
-		&models.Customer{},
-	)
+	provider, err := payments.NewProvider(configuration.PaymentProvider, configuration.PaymentWebhookSecret)
 	if err != nil {
-		panic(err)
+		logger.Error().Err(err).Msg("Could not set up the payment provider")
+		os.Exit(1)
 	}
 
+	// Commands write their events to the outbox, the relay hands them to
+	// the sinks once they are committed.
+	store := gormstore.New(db)
+	bus := events.NewBus(&logger)
+	sinks := []events.Sink{events.NewLogSink(&logger), workers.NewWebhookSink(db, &logger), bus}
+
+	// Streams of every engine are woken through Postgres notifications, on
+	// SQLite the hub polls.
+	hubConnectionString := ""
+	if database.IsPostgres(db) {
+		hubConnectionString = configuration.DbConnectionString
+	}
+	hub := streams.NewHub(&logger, hubConnectionString)
+
+	background := workers.NewGroup(&logger)
+	background.Go("outbox relay", workers.NewOutboxRelayWorker(db, &logger, sinks, time.Second))
+	background.Go("approval expiry", workers.NewApprovalExpiryWorker(db, &logger, provider, time.Minute))
+	background.Go("billing close", workers.NewBillingCloseWorker(store, &logger, configuration.TaxRateBasisPoints, time.Hour))
+	background.Go("webhook delivery", workers.NewWebhookDeliveryWorker(store, &logger, webhooks.NewSender(15*time.Second), 10*time.Second))
+	background.Go("stream hub", hub)
+
 	h := Handler{
-		logger: &logger,
-		db:     db,
-		hasher: hasher,
+		logger:        &logger,
+		db:            db,
+		store:         store,
+		hasher:        hasher,
+		payments:      provider,
+		configuration: &configuration,
+		hub:           hub,
+		migrator:      migrator,
+		workers:       background,
 	}
 
+	limiter := util.NewRateLimiter()
 	g := gin.New()
-	// TODO implement generate api token endpoint
-	// TODO implement deleting non valid api tokens
+	registerRoutes(g, &h, limiter)
+
+	// The gRPC API serves the same commands next to the REST one
+	grpcServer, err := newGrpcServer(&h, limiter)
+	if err != nil {
+		logger.Error().Err(err).Msg("Could not set up the gRPC server")
+		os.Exit(1)
+	}
+	// Either server failing stops the engine, a port taken by another
+	// process is not a state to keep running in.
+	grpcServed := make(chan error, 1)
+	go func() {
+		grpcServed <- serveGrpc(grpcServer, configuration.GrpcAddress)
+	}()
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", configuration.Port), Handler: g}
+	// Streams would keep the server from ever finishing its shutdown
+	server.RegisterOnShutdown(hub.Close)
+	served := make(chan error, 1)
+	logger.Info().Msgf("REST server listening on %s", server.Addr)
+	go func() {
+		if configuration.TLSCertFile != "" {
+			served <- server.ListenAndServeTLS(configuration.TLSCertFile, configuration.TLSKeyFile)
+		} else {
+			served <- server.ListenAndServe()
+		}
+	}()
+
+	// SIGTERM is how orchestrators stop the engine, SIGINT how people do
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	exitCode := 0
+	select {
+	case err = <-served:
+		logger.Error().Err(err).Msg("REST server stopped")
+		exitCode = 1
+	case err = <-grpcServed:
+		logger.Error().Err(err).Msg("gRPC server stopped")
+		exitCode = 1
+	case <-signals.Done():
+		logger.Info().Msg("Shutting down")
+	}
+	// A second signal stops the engine right away
+	stop()
+
+	if !shutdown(&logger, configuration.ShutdownTimeout.Duration(), server, grpcServer, background, db) {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+// shutdown stops the servers from accepting requests, waits for the ones in
+// flight, then stops the background workers and closes the database, all
+// within the timeout. It tells whether everything stopped in time.
+func shutdown(logger *zerolog.Logger, timeout time.Duration, server *http.Server, grpcServer *grpc.Server, background *workers.Group, db *gorm.DB) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ok := true
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("REST requests in flight were not drained")
+		ok = false
+	}
+	err = stopGrpc(ctx, grpcServer)
+	if err != nil {
+		logger.Error().Err(err).Msg("gRPC calls in flight were not drained")
+		ok = false
+	}
+	err = background.Stop(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Background workers were not stopped")
+		ok = false
+	}
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Database was not closed")
+		ok = false
+	}
+	logger.Info().Msg("Shut down")
+	return ok
+}
+
+// registerRoutes is the route table of the REST API. The operations in
+// openapi.go describe every route registered here.
+func registerRoutes(g *gin.Engine, h *Handler, limiter *util.RateLimiter) {
+	// Probes of the orchestrator
+	g.GET("/healthz", h.ReadHealth)
+	g.GET("/readyz", h.ReadReadiness)
+	// Calendar clients discover the CalDAV service here
+	g.Handle(http.MethodGet, "/.well-known/caldav", h.DavRedirect)
+	g.Handle("PROPFIND", "/.well-known/caldav", h.DavRedirect)
 	// TODO implement validation for if a command is going to affect the same source that it had in apiToken and secret
 	api := g.Group("/api")
 	{
 		v1 := api.Group("/v1")
 		{
+			// Payment provider webhooks are authenticated by their signature
+			v1.POST("/payments/webhook", h.HandlePaymentWebhook)
+			// Calendar feeds are authenticated by the token in their path
+			v1.GET("/feeds/:file", h.ReadCalendarFeed)
+			// The description of this api
+			v1.GET("/openapi.json", h.ReadOpenAPI)
+			v1.GET("/docs", h.ReadDocs)
+			// CalDAV, every source is a calendar collection
+			dav := v1.Group("/dav")
+			{
+				dav.Use(davChallengeMiddleware())
+				dav.Use(apiKeyAuthMiddleware(h.db, h.logger))
+				dav.Use(usageMiddleware(h.db, h.logger, limiter))
+				for _, path := range []string{"", "/"} {
+					dav.OPTIONS(path, h.DavOptions)
+					dav.Handle("PROPFIND", path, h.PropfindDavHome)
+				}
+				for _, path := range []string{"/sources/:sourceId", "/sources/:sourceId/"} {
+					dav.OPTIONS(path, h.DavOptions)
+					dav.Handle("PROPFIND", path, h.PropfindDavCollection)
+					dav.Handle("REPORT", path, h.ReportDavCollection)
+				}
+				dav.OPTIONS("/sources/:sourceId/:file", h.DavOptions)
+				dav.Handle("PROPFIND", "/sources/:sourceId/:file", h.PropfindDavObject)
+				dav.GET("/sources/:sourceId/:file", h.GetDavObject)
+				dav.HEAD("/sources/:sourceId/:file", h.GetDavObject)
+				dav.PUT("/sources/:sourceId/:file", h.PutDavObject)
+				dav.DELETE("/sources/:sourceId/:file", h.DeleteDavObject)
+			}
 			jwt := v1.Group("/")
 			{
-				jwt.Use(jwtAuthMiddleware(&configuration, db, &logger))
+				jwt.Use(jwtAuthMiddleware(h.configuration, h.db, h.logger))
+				jwt.Use(idempotencyMiddleware(h.db, h.logger))
 				// Customers
 				jwt.GET("/customers", h.ReadAllCustomers)
 				jwt.POST("/customers", h.CreateCustomer)
 				jwt.PATCH("/customers", h.UpdateCustomer)
 				jwt.GET("/customers/:id", h.ReadCustomer)
 				jwt.DELETE("/customers/:id", h.DeleteCustomer)
+				jwt.PATCH("/customers/plan", h.AssignPlan)
+				jwt.GET("/customers/:id/stream", h.StreamReservations)
+				jwt.GET("/customers/:id/sources/:sourceId/stream", h.StreamReservations)
+
+				jwt.GET("/api-tokens", h.ReadAllApiTokens)
+				jwt.POST("/api-tokens", h.CreateApiToken)
+				jwt.DELETE("/api-tokens/:id", h.RevokeApiToken)
+				// Plans
+				jwt.GET("/plans", h.ReadAllPlans)
+				jwt.POST("/plans", h.CreatePlan)
+				jwt.PATCH("/plans", h.UpdatePlan)
+				jwt.GET("/plans/:id", h.ReadPlan)
+				jwt.DELETE("/plans/:id", h.DeletePlan)
+				// Invoices
+				jwt.GET("/invoices", h.ReadAllInvoices)
+				jwt.POST("/invoices", h.CreateInvoice)
+				jwt.POST("/invoices/issue", h.IssueInvoice)
+				jwt.GET("/invoices/:id", h.ReadInvoice)
+				jwt.GET("/invoices/:id/csv", h.ReadInvoiceCSV)
+				jwt.DELETE("/invoices/:id", h.DeleteInvoice)
+				jwt.POST("/credit-notes", h.CreateCreditNote)
+				// Fee overrides
+				jwt.PATCH("/admin/reservations", h.AdminUpdateReservation)
+				jwt.DELETE("/admin/reservations/:id", h.AdminDeleteReservation)
 			}
 			apiKey := v1.Group("/")
 			{
-				apiKey.Use(apiKeyAuthMiddleware(db, &logger))
+				apiKey.Use(apiKeyAuthMiddleware(h.db, h.logger))
+				apiKey.Use(usageMiddleware(h.db, h.logger, limiter))
+				apiKey.Use(idempotencyMiddleware(h.db, h.logger))
+				// Usage
+				apiKey.GET("/usage", h.ReadUsage)
+				// Streams
+				apiKey.GET("/stream", h.StreamReservations)
+				apiKey.GET("/sources/:id/stream", h.StreamReservations)
 				// Reservations
 				apiKey.GET("/reservations", h.ReadAllReservations)
 				apiKey.POST("/reservations", h.CreateReservation)
+				apiKey.POST("/reservations/import", h.ImportReservations)
 				apiKey.PATCH("/reservations", h.UpdateReservation)
 				apiKey.GET("/reservations/:id", h.ReadReservation)
 				apiKey.DELETE("/reservations/:id", h.DeleteReservation)
+				// Approvals
+				apiKey.POST("/reservations/approve", h.ApproveReservation)
+				apiKey.POST("/reservations/reject", h.RejectReservation)
+				apiKey.POST("/reservations/approver", h.AssignApprover)
+				// Bundles
+				apiKey.POST("/bundles", h.CreateBundle)
+				apiKey.PATCH("/bundles", h.UpdateBundle)
+				apiKey.GET("/bundles/:id", h.ReadBundle)
+				apiKey.DELETE("/bundles/:id", h.DeleteBundle)
+				// Persons
+				apiKey.GET("/persons", h.ReadAllPersons)
+				apiKey.POST("/persons", h.CreatePerson)
+				apiKey.PATCH("/persons", h.UpdatePerson)
+				apiKey.GET("/persons/:id", h.ReadPerson)
+				apiKey.DELETE("/persons/:id", h.DeletePerson)
+				// Participants
+				apiKey.POST("/participants", h.CreateParticipant)
+				apiKey.PATCH("/participants", h.UpdateParticipant)
+				apiKey.DELETE("/participants/:id", h.DeleteParticipant)
+				// Cancellation policies
+				apiKey.GET("/policies", h.ReadAllCancellationPolicies)
+				apiKey.POST("/policies", h.CreateCancellationPolicy)
+				apiKey.PATCH("/policies", h.UpdateCancellationPolicy)
+				apiKey.GET("/policies/:id", h.ReadCancellationPolicy)
+				apiKey.DELETE("/policies/:id", h.DeleteCancellationPolicy)
+				// Pricing
+				apiKey.GET("/rates", h.ReadAllRates)
+				apiKey.POST("/rates", h.CreateRate)
+				apiKey.PATCH("/rates", h.UpdateRate)
+				apiKey.GET("/rates/:id", h.ReadRate)
+				apiKey.DELETE("/rates/:id", h.DeleteRate)
+				apiKey.POST("/quotes", h.CreateQuote)
+				apiKey.GET("/availability", h.ReadAvailability)
+				// Promotions
+				apiKey.GET("/promotions", h.ReadAllPromotions)
+				apiKey.POST("/promotions", h.CreatePromotion)
+				apiKey.PATCH("/promotions", h.UpdatePromotion)
+				apiKey.GET("/promotions/:id", h.ReadPromotion)
+				apiKey.DELETE("/promotions/:id", h.DeletePromotion)
+				apiKey.GET("/reports/promotions", h.ReadPromotionReport)
+				// Webhooks
+				apiKey.GET("/webhooks", h.ReadAllWebhookEndpoints)
+				apiKey.POST("/webhooks", h.CreateWebhookEndpoint)
+				apiKey.PATCH("/webhooks", h.UpdateWebhookEndpoint)
+				apiKey.DELETE("/webhooks/:id", h.DeleteWebhookEndpoint)
+				apiKey.GET("/webhooks/deliveries", h.ReadAllWebhookDeliveries)
+				apiKey.GET("/webhooks/dead-letters", h.ReadWebhookDeadLetters)
+				apiKey.POST("/webhooks/deliveries/redeliver", h.RedeliverWebhook)
+				// Calendar feeds
+				apiKey.GET("/calendar-feeds", h.ReadAllCalendarFeeds)
+				apiKey.POST("/calendar-feeds", h.CreateCalendarFeed)
+				apiKey.DELETE("/calendar-feeds/:id", h.DeleteCalendarFeed)
 				// Sources
 				apiKey.GET("/sources", h.ReadAllSources)
 				apiKey.POST("/sources", h.CreateSource)
@@ -81,5 +382,4 @@ func main() {
 			}
 		}
 	}
-	g.Run(":11242")
 }
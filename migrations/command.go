@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CommandUsage describes the arguments of Command.
+const CommandUsage = `migrate [up]        apply the pending migrations
+migrate down [n]    revert the last n migrations, 1 by default
+migrate status      list the migrations and whether they are applied
+migrate drift       compare the database with the models
+migrate adopt       record the migrations of a database AutoMigrate created,
+                    once it does not differ from the models`
+
+// Command carries out the migrate command of the binaries and writes its
+// result to w as JSON. Drift is an error, so that scripts notice it.
+func Command(ctx context.Context, m *Migrator, models []any, args []string, w io.Writer) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	var res any
+	var err error
+	var drifts []Drift
+	switch action {
+	case "up":
+		var done []Migration
+		done, err = m.Up(ctx)
+		res = map[string]any{"applied": names(done)}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate down needs a positive number of steps, not %s", args[1])
+			}
+		}
+		var done []Migration
+		done, err = m.Down(ctx, steps)
+		res = map[string]any{"reverted": names(done)}
+	case "status":
+		res, err = m.Status(ctx)
+	case "drift":
+		drifts, err = m.Drift(models)
+		res = drifts
+	case "adopt":
+		var done []Migration
+		done, err = m.Adopt(ctx, models)
+		res = map[string]any{"adopted": names(done)}
+		var driftErr *DriftError
+		if errors.As(err, &driftErr) {
+			res, drifts, err = driftErr.Drifts, driftErr.Drifts, nil
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, use:\n%s", action, CommandUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(res)
+	if err != nil {
+		return err
+	}
+	if len(drifts) > 0 {
+		return &DriftError{Drifts: drifts}
+	}
+	return nil
+}
+
+func names(migrations []Migration) []string {
+	res := []string{}
+	for _, migration := range migrations {
+		res = append(res, fmt.Sprintf("%04d_%s", migration.Version, migration.Name))
+	}
+	return res
+}
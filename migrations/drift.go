@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Drift is a difference between the schema of the database and the one the
+// models describe. Column is empty for differences of a whole table and
+// Index is set for missing indexes.
+type Drift struct {
+	Table   string `json:"table"`
+	Column  string `json:"column,omitempty"`
+	Index   string `json:"index,omitempty"`
+	Problem string `json:"problem"`
+}
+
+func (d Drift) String() string {
+	switch {
+	case d.Index != "":
+		return fmt.Sprintf("%s index %s: %s", d.Table, d.Index, d.Problem)
+	case d.Column != "":
+		return fmt.Sprintf("%s.%s: %s", d.Table, d.Column, d.Problem)
+	}
+	return fmt.Sprintf("%s: %s", d.Table, d.Problem)
+}
+
+// postgresTypes maps the type names of the models to the names Postgres
+// reports for them.
+var postgresTypes = map[string]string{
+	"bigint":           "int8",
+	"bigserial":        "int8",
+	"integer":          "int4",
+	"serial":           "int4",
+	"smallint":         "int2",
+	"smallserial":      "int2",
+	"boolean":          "bool",
+	"double precision": "float8",
+	"real":             "float4",
+	"decimal":          "numeric",
+}
+
+var sizedType = regexp.MustCompile(`^([a-z ]+?)\s*(?:\((\d+)\))?$`)
+
+// normalType splits a column type into the name the database reports and its
+// length, 0 when the type has none.
+func (m *Migrator) normalType(columnType string) (string, string) {
+	match := sizedType.FindStringSubmatch(strings.ToLower(strings.TrimSpace(columnType)))
+	if match == nil {
+		return strings.ToLower(columnType), ""
+	}
+	name := match[1]
+	if m.dialect == "postgres" {
+		if normal, ok := postgresTypes[name]; ok {
+			name = normal
+		}
+	}
+	return name, match[2]
+}
+
+// Drift compares the database with the models and lists what differs:
+// missing tables, columns and indexes, columns of another type and columns no
+// model knows about. Migrations are written by hand, this is how one that was
+// forgotten or does not match the models is noticed.
+func (m *Migrator) Drift(models []any) ([]Drift, error) {
+	drifts := []Drift{}
+	migrator := m.db.Migrator()
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: m.db}
+		err := stmt.Parse(model)
+		if err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(table) {
+			drifts = append(drifts, Drift{Table: table, Problem: "missing table"})
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		columns := map[string]gorm.ColumnType{}
+		for _, columnType := range columnTypes {
+			columns[columnType.Name()] = columnType
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			column, ok := columns[field.DBName]
+			if !ok {
+				drifts = append(drifts, Drift{Table: table, Column: field.DBName, Problem: "missing column"})
+				continue
+			}
+			delete(columns, field.DBName)
+			if drift, ok := m.typeDrift(table, field, column); ok {
+				drifts = append(drifts, drift)
+			}
+		}
+		for name := range columns {
+			drifts = append(drifts, Drift{Table: table, Column: name, Problem: "column is not in the model"})
+		}
+
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, index.Name) {
+				drifts = append(drifts, Drift{Table: table, Index: index.Name, Problem: "missing index"})
+			}
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].String() < drifts[j].String() })
+	return drifts, nil
+}
+
+func (m *Migrator) typeDrift(table string, field *schema.Field, column gorm.ColumnType) (Drift, bool) {
+	// FullDataTypeOf follows the type with the constraints and the default.
+	expected := m.db.Migrator().FullDataTypeOf(field).SQL
+	for _, suffix := range []string{" NOT NULL", " DEFAULT ", " PRIMARY KEY"} {
+		expected, _, _ = strings.Cut(expected, suffix)
+	}
+	expectedName, expectedLength := m.normalType(expected)
+	actualName, _ := m.normalType(column.DatabaseTypeName())
+
+	if expectedName != actualName {
+		return Drift{Table: table, Column: field.DBName, Problem: fmt.Sprintf("is %s, the model has %s", actualName, expectedName)}, true
+	}
+	if length, ok := column.Length(); ok && expectedLength != "" && fmt.Sprint(length) != expectedLength {
+		return Drift{Table: table, Column: field.DBName, Problem: fmt.Sprintf("is %s(%d), the model has %s(%s)", actualName, length, expectedName, expectedLength)}, true
+	}
+	return Drift{}, false
+}
@@ -0,0 +1,428 @@
+// Package migrations evolves the database schema with versioned SQL scripts
+// embedded in the binary. Every version has an up script and a down script
+// that undoes it, named "0002_add_something.up.sql" and
+// "0002_add_something.down.sql" in the directory of the dialect.
+//
+// Instances that migrate at the same time take turns on a lock, the applied
+// versions are recorded in schema_migrations. A script starting with the line
+// "-- migrate: no-transaction" runs outside of a transaction, which statements
+// like CREATE INDEX CONCURRENTLY need.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var scripts embed.FS
+
+// lockId identifies the advisory lock migrating instances take turns on.
+const lockId = 7_301_046
+
+const noTransaction = "-- migrate: no-transaction"
+
+// adoptedTable is created by the first migration. A database that has it
+// without recorded versions was created by AutoMigrate, it has to be adopted
+// before it can be migrated.
+const adoptedTable = "customers"
+
+var scriptName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDirty is returned when a version is recorded that the binary has no
+// scripts for, the database was migrated by a newer binary.
+var ErrDirty = errors.New("the database has migrations this binary does not know")
+
+// ErrNotAdopted is returned when migrating a database that has a schema but
+// no recorded versions, see Migrator.Adopt.
+var ErrNotAdopted = errors.New("the database has a schema without recorded migrations, adopt it with migrate adopt")
+
+// DriftError is returned when adopting a database that differs from the
+// models.
+type DriftError struct {
+	Drifts []Drift
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("the database differs from the models in %d places", len(e.Drifts))
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status tells whether a migration was applied and when.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	dialect    string
+	migrations []Migration
+}
+
+// New returns the migrator of the database, with the scripts of its dialect.
+func New(db *gorm.DB, logger *zerolog.Logger) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, logger: logger, dialect: dialect, migrations: migrations}, nil
+}
+
+// Migrations are the migrations of the binary, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(scripts, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: no migrations for the %s dialect", dialect)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := scriptName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: %s is not named version_name.up.sql or version_name.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		script, err := fs.ReadFile(scripts, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down script", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// locked runs f on one connection while holding the migration lock, so that
+// only one instance migrates at a time.
+func (m *Migrator) locked(ctx context.Context, f func(conn *sql.Conn) error) error {
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case "postgres":
+		// The lock belongs to the session, it is released by the unlock or
+		// when the connection is gone.
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockId)
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockId)
+	case "sqlite":
+		// A SQLite database has a single writer, every migration runs in a
+		// transaction that holds the database until it commits.
+	default:
+		return fmt.Errorf("migrations: can not lock a %s database", m.dialect)
+	}
+
+	_, err = conn.ExecContext(ctx, m.schemaMigrationsTable())
+	if err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+func (m *Migrator) schemaMigrationsTable() string {
+	// SQLite only reads a column back as a time when it is declared as one
+	// of its date types.
+	timestamp := "timestamptz"
+	if m.dialect == "sqlite" {
+		timestamp = "datetime"
+	}
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name varchar(255) NOT NULL,
+	applied_at ` + timestamp + ` NOT NULL
+)`
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		res[version] = appliedAt
+	}
+	return res, rows.Err()
+}
+
+// run executes the script and records the change of version, in one
+// transaction unless the script opts out.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	if strings.HasPrefix(script, noTransaction) {
+		_, err := conn.ExecContext(ctx, script)
+		if err != nil {
+			return err
+		}
+		_, err = conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies the migrations that were not applied yet and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		err = m.checkKnown(versions)
+		if err != nil {
+			return err
+		}
+
+		if len(versions) == 0 && m.db.Migrator().HasTable(adoptedTable) {
+			return fmt.Errorf("migrations: %w", ErrNotAdopted)
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			m.logger.Info().Msg(fmt.Sprintf("Migrator: applying %d_%s", migration.Version, migration.Name))
+			err = m.run(ctx, conn, migration.Up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", migration.Version, migration.Name, time.Now())
+			if err != nil {
+				return fmt.Errorf("migrations: %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Adopt records every migration as applied on a database that AutoMigrate
+// created, which is only the case when its schema is the one of the models.
+// A database that differs from them is refused with a DriftError, the
+// differences have to be fixed by hand before it can be adopted.
+func (m *Migrator) Adopt(ctx context.Context, models []any) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(versions) > 0 {
+			return errors.New("migrations: the database already records its migrations, there is nothing to adopt")
+		}
+		if !m.db.Migrator().HasTable(adoptedTable) {
+			return errors.New("migrations: the database has no schema to adopt, migrate up creates it")
+		}
+		drifts, err := m.Drift(models)
+		if err != nil {
+			return err
+		}
+		if len(drifts) > 0 {
+			return &DriftError{Drifts: drifts}
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", migration.Version, migration.Name, time.Now())
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		done = m.migrations
+		m.logger.Info().Msg(fmt.Sprintf("Migrator: adopted the existing schema with %d migrations", len(done)))
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations and returns them, newest
+// first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		err = m.checkKnown(versions)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			m.logger.Info().Msg(fmt.Sprintf("Migrator: reverting %d_%s", migration.Version, migration.Name))
+			err = m.run(ctx, conn, migration.Down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrations: reverting %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// recorded reads the applied versions without waiting for the lock, a
+// database that is being migrated simply has fewer of them.
+func (m *Migrator) recorded(ctx context.Context) (map[int64]time.Time, error) {
+	versions := map[int64]time.Time{}
+	if !m.db.Migrator().HasTable("schema_migrations") {
+		return versions, nil
+	}
+	var rows []struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+	res := m.db.WithContext(ctx).Raw("SELECT version, applied_at FROM schema_migrations").Scan(&rows)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+	return versions, nil
+}
+
+// Status lists every migration of the binary and whether it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := m.recorded(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkKnown(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Status{}
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+// Pending returns the migrations that were not applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	versions, err := m.recorded(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := versions[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) checkKnown(versions map[int64]time.Time) error {
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range versions {
+		if !known[version] {
+			return fmt.Errorf("migrations: version %d is applied: %w", version, ErrDirty)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package migrations_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/migrations"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func newMigrator(t *testing.T, db *gorm.DB) *migrations.Migrator {
+	t.Helper()
+	logger := zerolog.Nop()
+	migrator, err := migrations.New(db, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrator.Migrations()) == 0 {
+		t.Fatal("loaded no migrations")
+	}
+	return migrator
+}
+
+// applied returns the versions the status reports as applied.
+func applied(t *testing.T, migrator *migrations.Migrator) []int64 {
+	t.Helper()
+	statuses, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, status := range statuses {
+		if status.Applied {
+			versions = append(versions, status.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	repotest.RunEmptyDatabases(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		migrator := newMigrator(t, db)
+		all := migrator.Migrations()
+
+		done, err := migrator.Up(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != len(all) || len(applied(t, migrator)) != len(all) {
+			t.Errorf("applied %d of %d migrations", len(done), len(all))
+		}
+		if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+			t.Errorf("%d migrations pending after migrating: %v", len(pending), err)
+		}
+		// The migrations create the schema the models describe.
+		drifts, err := migrator.Drift(models.Entities())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, drift := range drifts {
+			t.Errorf("drift after migrating: %s", drift)
+		}
+		if done, err = migrator.Up(ctx); err != nil || len(done) != 0 {
+			t.Errorf("migrating again applied %d migrations: %v", len(done), err)
+		}
+
+		done, err = migrator.Down(ctx, len(all))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != len(all) || done[0].Version != all[len(all)-1].Version || len(applied(t, migrator)) != 0 {
+			t.Errorf("reverted %v", done)
+		}
+		if db.Migrator().HasTable(&models.Customer{}) {
+			t.Error("reverting every migration left the customers table")
+		}
+		if _, err = migrator.Up(ctx); err != nil {
+			t.Fatalf("could not migrate again after reverting: %v", err)
+		}
+
+		// A version this binary has no scripts for was applied by a newer one.
+		if err = db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 9999, "from_the_future", "2026-10-19 00:00:00").Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err = migrator.Up(ctx); !errors.Is(err, migrations.ErrDirty) {
+			t.Errorf("migrated a database of a newer binary: %v", err)
+		}
+		if _, err = migrator.Status(ctx); !errors.Is(err, migrations.ErrDirty) {
+			t.Errorf("reported the status of a database of a newer binary: %v", err)
+		}
+	})
+}
+
+func TestMigratorAdopt(t *testing.T) {
+	repotest.RunEmptyDatabases(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		migrator := newMigrator(t, db)
+
+		if _, err := migrator.Adopt(ctx, models.Entities()); err == nil {
+			t.Error("adopted an empty database")
+		}
+
+		// A schema AutoMigrate created is neither migrated nor adopted
+		// blindly.
+		if err := db.AutoMigrate(models.Entities()...); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(ctx); !errors.Is(err, migrations.ErrNotAdopted) {
+			t.Fatalf("migrated a database that was not adopted: %v", err)
+		}
+		if err := db.Migrator().DropTable(&models.DeletedSource{}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := migrator.Adopt(ctx, models.Entities())
+		var driftErr *migrations.DriftError
+		if !errors.As(err, &driftErr) || len(driftErr.Drifts) != 1 || driftErr.Drifts[0].Table != "deleted_sources" {
+			t.Fatalf("adopted a database that lacks a table: %v", err)
+		}
+		if versions := applied(t, migrator); len(versions) != 0 {
+			t.Errorf("recorded %v for a database that was refused", versions)
+		}
+
+		if err = db.AutoMigrate(&models.DeletedSource{}); err != nil {
+			t.Fatal(err)
+		}
+		done, err := migrator.Adopt(ctx, models.Entities())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != len(migrator.Migrations()) || len(applied(t, migrator)) != len(done) {
+			t.Errorf("adopted %d of %d migrations", len(done), len(migrator.Migrations()))
+		}
+		if done, err = migrator.Up(ctx); err != nil || len(done) != 0 {
+			t.Errorf("migrating an adopted database applied %d migrations: %v", len(done), err)
+		}
+		if _, err = migrator.Adopt(ctx, models.Entities()); err == nil {
+			t.Error("adopted a database twice")
+		}
+	})
+}
+
+func TestCommand(t *testing.T) {
+	repotest.RunEmptyDatabases(t, func(t *testing.T, db *gorm.DB) {
+		ctx := context.Background()
+		migrator := newMigrator(t, db)
+		run := func(args ...string) (string, error) {
+			var out bytes.Buffer
+			err := migrations.Command(ctx, migrator, models.Entities(), args, &out)
+			return out.String(), err
+		}
+
+		if _, err := run("sideways"); err == nil || !strings.Contains(err.Error(), migrations.CommandUsage) {
+			t.Errorf("ran an unknown command: %v", err)
+		}
+		if _, err := run("down", "none"); err == nil {
+			t.Error("reverted a number of migrations that is not one")
+		}
+
+		if err := db.AutoMigrate(&models.Customer{}); err != nil {
+			t.Fatal(err)
+		}
+		out, err := run("adopt")
+		var driftErr *migrations.DriftError
+		if !errors.As(err, &driftErr) {
+			t.Fatalf("adopted a partial schema: %v", err)
+		}
+		var drifts []migrations.Drift
+		if err = json.Unmarshal([]byte(out), &drifts); err != nil || len(drifts) != len(driftErr.Drifts) {
+			t.Errorf("printed %s for %d drifts: %v", out, len(driftErr.Drifts), err)
+		}
+
+		if err = db.Migrator().DropTable(&models.Customer{}); err != nil {
+			t.Fatal(err)
+		}
+		if out, err = run(); err != nil || !strings.Contains(out, "0001_initial") {
+			t.Errorf("migrated with %s: %v", out, err)
+		}
+		if out, err = run("drift"); err != nil || strings.TrimSpace(out) != "[]" {
+			t.Errorf("found drift %s: %v", out, err)
+		}
+		if out, err = run("down"); err != nil || !strings.Contains(out, "0001_initial") {
+			t.Errorf("reverted with %s: %v", out, err)
+		}
+		if out, err = run("drift"); err == nil {
+			t.Errorf("found no drift in an empty database: %s", out)
+		}
+	})
+}
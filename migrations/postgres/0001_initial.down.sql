@@ -0,0 +1,26 @@
+DROP TABLE IF EXISTS "idempotency_keys";
+DROP TABLE IF EXISTS "calendar_objects";
+DROP TABLE IF EXISTS "calendar_feeds";
+DROP TABLE IF EXISTS "outbox_events";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhook_endpoints";
+DROP TABLE IF EXISTS "deleted_sources";
+DROP TABLE IF EXISTS "invoice_sequences";
+DROP TABLE IF EXISTS "invoices";
+DROP TABLE IF EXISTS "usage_counters";
+DROP TABLE IF EXISTS "plans";
+DROP TABLE IF EXISTS "payment_webhook_events";
+DROP TABLE IF EXISTS "payments";
+DROP TABLE IF EXISTS "promotion_redemptions";
+DROP TABLE IF EXISTS "promotions";
+DROP TABLE IF EXISTS "rates";
+DROP TABLE IF EXISTS "reservation_fees";
+DROP TABLE IF EXISTS "cancellation_policies";
+DROP TABLE IF EXISTS "participants";
+DROP TABLE IF EXISTS "people";
+DROP TABLE IF EXISTS "reservations";
+DROP TABLE IF EXISTS "bundles";
+DROP TABLE IF EXISTS "api_tokens";
+DROP TABLE IF EXISTS "secrets";
+DROP TABLE IF EXISTS "sources";
+DROP TABLE IF EXISTS "customers";
@@ -0,0 +1,404 @@
+-- The first version of the Postgres schema in the types of SQLite. Ids have
+-- no default, the engine generates them.
+
+CREATE TABLE "customers" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "name" varchar(128),
+    "company" varchar(64),
+    "email" varchar(128),
+    "plan_id" uuid,
+    "tax_rate_basis_points" integer,
+    "max_source_limit" integer,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "sources" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "name" varchar(256),
+    "max_possible_duration" text,
+    "customer_id" uuid,
+    "requires_approval" numeric,
+    "approver_id" uuid,
+    "approval_timeout" text,
+    "cancellation_policy_id" uuid,
+    "currency" varchar(3) DEFAULT 'EUR',
+    "timezone" varchar(64) DEFAULT 'UTC',
+    "weekend_surcharge_percent" integer,
+    "capacity" integer,
+    "requires_payment" numeric,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_customers_sources" FOREIGN KEY ("customer_id") REFERENCES "customers"("id")
+);
+
+CREATE TABLE "secrets" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "value" varchar(64),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_customers_secret" FOREIGN KEY ("customer_id") REFERENCES "customers"("id")
+);
+
+CREATE TABLE "api_tokens" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "source_id" uuid,
+    "token" varchar(64),
+    "valid_until" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sources_tokens" FOREIGN KEY ("source_id") REFERENCES "sources"("id"),
+    CONSTRAINT "fk_customers_api_tokens" FOREIGN KEY ("customer_id") REFERENCES "customers"("id")
+);
+
+CREATE TABLE "bundles" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "reservations" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "from" datetime,
+    "to" datetime,
+    "reserver_id" text,
+    "reservee_id" text,
+    "source_id" uuid,
+    "bundle_id" uuid,
+    "status" varchar(24) DEFAULT 'confirmed',
+    "approver_id" uuid,
+    "approval_expires_at" datetime,
+    "decision_comment" text,
+    "decided_at" datetime,
+    "cancelled_at" datetime,
+    "units" integer DEFAULT 1,
+    "currency" varchar(3),
+    "total_amount" integer,
+    "price_breakdown" text,
+    "promotion_id" uuid,
+    "sequence" integer,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sources_reservations" FOREIGN KEY ("source_id") REFERENCES "sources"("id"),
+    CONSTRAINT "fk_bundles_reservations" FOREIGN KEY ("bundle_id") REFERENCES "bundles"("id")
+);
+CREATE INDEX "idx_reservations_status" ON "reservations" ("status");
+
+CREATE TABLE "people" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "external_ref" varchar(128),
+    "name" varchar(128),
+    "email" varchar(128),
+    "phone" varchar(32),
+    "metadata" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_person_external_ref" ON "people" ("customer_id","external_ref");
+
+CREATE TABLE "participants" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "reservation_id" uuid,
+    "person_id" uuid,
+    "role" varchar(16),
+    "rsvp" varchar(16),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reservations_participants" FOREIGN KEY ("reservation_id") REFERENCES "reservations"("id")
+);
+CREATE INDEX "idx_participants_person_id" ON "participants" ("person_id");
+CREATE INDEX "idx_participants_reservation_id" ON "participants" ("reservation_id");
+
+CREATE TABLE "cancellation_policies" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "name" varchar(128),
+    "cancellation_rules" text,
+    "modification_rules" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_cancellation_policies_customer_id" ON "cancellation_policies" ("customer_id");
+
+CREATE TABLE "reservation_fees" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "reservation_id" uuid,
+    "kind" varchar(16),
+    "computed_fee_percent" integer,
+    "fee_percent" integer,
+    "override_reason" text,
+    "overridden_by" text,
+    "amount" integer,
+    "currency" varchar(3),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reservations_fees" FOREIGN KEY ("reservation_id") REFERENCES "reservations"("id")
+);
+CREATE INDEX "idx_reservation_fees_reservation_id" ON "reservation_fees" ("reservation_id");
+
+CREATE TABLE "rates" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "source_id" uuid,
+    "name" varchar(128),
+    "kind" varchar(8),
+    "amount_minor" integer,
+    "weekdays" text,
+    "start_time" varchar(5),
+    "end_time" varchar(5),
+    "priority" integer,
+    "per_unit" numeric,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sources_rates" FOREIGN KEY ("source_id") REFERENCES "sources"("id")
+);
+CREATE INDEX "idx_rates_source_id" ON "rates" ("source_id");
+
+CREATE TABLE "promotions" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "code" varchar(64),
+    "kind" varchar(16),
+    "percent_off" integer,
+    "amount_off_minor" integer,
+    "currency" varchar(3),
+    "source_ids" text,
+    "valid_from" datetime,
+    "valid_until" datetime,
+    "first_time_only" numeric,
+    "max_redemptions" integer,
+    "redemption_count" integer,
+    "active" numeric,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_promotion_code" ON "promotions" ("customer_id","code");
+
+CREATE TABLE "promotion_redemptions" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "promotion_id" uuid,
+    "reservation_id" uuid,
+    "reservee_id" uuid,
+    "discount_amount" integer,
+    "currency" varchar(3),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_promotion_redemptions_promotion_id" ON "promotion_redemptions" ("promotion_id");
+CREATE INDEX "idx_promotion_redemptions_reservation_id" ON "promotion_redemptions" ("reservation_id");
+
+CREATE TABLE "payments" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "reservation_id" uuid,
+    "provider" varchar(32),
+    "provider_payment_id" varchar(128),
+    "status" varchar(24),
+    "amount" integer,
+    "currency" varchar(3),
+    "refunded_amount" integer,
+    "failure_reason" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reservations_payments" FOREIGN KEY ("reservation_id") REFERENCES "reservations"("id")
+);
+CREATE INDEX "idx_payments_provider_payment_id" ON "payments" ("provider_payment_id");
+CREATE INDEX "idx_payments_reservation_id" ON "payments" ("reservation_id");
+
+CREATE TABLE "payment_webhook_events" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "provider" varchar(32),
+    "event_id" varchar(128),
+    "type" varchar(64),
+    "provider_payment_id" varchar(128),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_payment_webhook_event" ON "payment_webhook_events" ("provider","event_id");
+
+CREATE TABLE "plans" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "name" varchar(64),
+    "max_sources" integer,
+    "max_reservations_per_month" integer,
+    "max_api_tokens" integer,
+    "rate_limit_per_minute" integer,
+    "features" text,
+    "currency" varchar(3) DEFAULT 'EUR',
+    "monthly_price_minor" integer,
+    "source_price_minor" integer,
+    "included_reservations" integer,
+    "overage_price_minor" integer,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_plans_name" ON "plans" ("name");
+
+CREATE TABLE "usage_counters" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "period" varchar(7),
+    "metric" varchar(32),
+    "count" integer,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_usage_counter" ON "usage_counters" ("customer_id","period","metric");
+
+CREATE TABLE "invoices" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "kind" varchar(16),
+    "status" varchar(16),
+    "number" varchar(32),
+    "period" varchar(7),
+    "currency" varchar(3),
+    "lines" text,
+    "subtotal" integer,
+    "tax_rate_basis_points" integer,
+    "tax_amount" integer,
+    "total" integer,
+    "issued_at" datetime,
+    "corrects_invoice_id" uuid,
+    "reason" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_invoices_corrects_invoice_id" ON "invoices" ("corrects_invoice_id");
+CREATE INDEX "idx_invoices_customer_id" ON "invoices" ("customer_id");
+CREATE INDEX "idx_invoices_period" ON "invoices" ("period");
+CREATE UNIQUE INDEX "idx_invoices_number" ON "invoices" ("number");
+CREATE UNIQUE INDEX "idx_invoices_issued_period" ON "invoices" ("customer_id","period") WHERE kind = 'invoice' AND status = 'issued';
+
+CREATE TABLE "invoice_sequences" (
+    "prefix" varchar(16),
+    "last" integer,
+    PRIMARY KEY ("prefix")
+);
+
+CREATE TABLE "deleted_sources" (
+    "id" uuid,
+    "customer_id" uuid,
+    "name" varchar(256),
+    "created_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_deleted_sources_customer_id" ON "deleted_sources" ("customer_id");
+
+CREATE TABLE "webhook_endpoints" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "url" varchar(512),
+    "secret" varchar(64),
+    "event_types" text,
+    "active" numeric,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_webhook_endpoints_customer_id" ON "webhook_endpoints" ("customer_id");
+
+CREATE TABLE "webhook_deliveries" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "endpoint_id" uuid,
+    "event_id" varchar(36),
+    "event_type" varchar(64),
+    "payload" text,
+    "status" varchar(16),
+    "attempts" integer,
+    "next_attempt_at" datetime,
+    "last_attempt_at" datetime,
+    "last_status_code" integer,
+    "last_error" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_webhook_deliveries_customer_id" ON "webhook_deliveries" ("customer_id");
+CREATE INDEX "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE UNIQUE INDEX "idx_webhook_delivery_event" ON "webhook_deliveries" ("endpoint_id","event_id");
+
+-- Only the integer primary key counts up by itself in SQLite, so the
+-- position is the primary key here and the id a unique column.
+CREATE TABLE "outbox_events" (
+    "id" uuid NOT NULL UNIQUE,
+    "position" integer PRIMARY KEY AUTOINCREMENT,
+    "type" varchar(64),
+    "customer_id" varchar(36),
+    "aggregate_id" varchar(36),
+    "payload" text,
+    "occurred_at" datetime,
+    "attempts" integer,
+    "last_error" text,
+    "published_at" datetime,
+    "published_position" integer
+);
+CREATE INDEX "idx_outbox_events_aggregate_id" ON "outbox_events" ("aggregate_id");
+CREATE INDEX "idx_outbox_events_customer_id" ON "outbox_events" ("customer_id");
+CREATE INDEX "idx_outbox_events_published_at" ON "outbox_events" ("published_at");
+CREATE UNIQUE INDEX "idx_outbox_events_position" ON "outbox_events" ("position");
+CREATE UNIQUE INDEX "idx_outbox_events_published_position" ON "outbox_events" ("published_position");
+
+CREATE TABLE "calendar_feeds" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "customer_id" uuid,
+    "kind" varchar(16),
+    "source_id" uuid,
+    "person_id" uuid,
+    "token" varchar(64),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_calendar_feeds_customer_id" ON "calendar_feeds" ("customer_id");
+CREATE UNIQUE INDEX "idx_calendar_feeds_token" ON "calendar_feeds" ("token");
+
+CREATE TABLE "calendar_objects" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "source_id" uuid,
+    "name" varchar(255),
+    "reservation_id" uuid,
+    "uid" varchar(255),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_calendar_objects_uid" ON "calendar_objects" ("uid");
+CREATE UNIQUE INDEX "idx_calendar_object_name" ON "calendar_objects" ("source_id","name");
+CREATE UNIQUE INDEX "idx_calendar_objects_reservation_id" ON "calendar_objects" ("reservation_id");
+
+CREATE TABLE "idempotency_keys" (
+    "id" uuid,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "scope" varchar(64),
+    "key" varchar(255),
+    "fingerprint" varchar(64),
+    "status" integer,
+    "content_type" text,
+    "body" blob,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_idempotency_key" ON "idempotency_keys" ("scope","key");
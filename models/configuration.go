@@ -1,38 +1,476 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
 )
 
+// Configuration of the engine and its tools. It is read in layers, every
+// layer overriding the ones before: the defaults, the configuration file, the
+// environment and the command line flags. Every setting is named by its json
+// key in the file, by RESERVATION_ENGINE_ and the key in upper snake case in
+// the environment and by the key in kebab case on the command line, so
+// dbConnectionString is RESERVATION_ENGINE_DB_CONNECTION_STRING and
+// --db-connection-string. Lists are comma separated in the environment and on
+// the command line. Settings tagged secret are redacted when printed.
 type Configuration struct {
-	DbConnectionString string `json:"dbConnectionString"`
-	Secret             string `json:"secret"`
-	salt               string
+	// Port is where the REST API listens.
+	Port int `json:"port" yaml:"port"`
+	// GrpcAddress is where the gRPC API listens.
+	GrpcAddress string `json:"grpcAddress" yaml:"grpcAddress"`
+	// TLSCertFile and TLSKeyFile make both APIs serve TLS, they are set
+	// together or not at all.
+	TLSCertFile string `json:"tlsCertFile" yaml:"tlsCertFile"`
+	TLSKeyFile  string `json:"tlsKeyFile" yaml:"tlsKeyFile"`
+	// DbDriver is "postgres", the default, or "sqlite" for small deployments
+	// and local development. The DbConnectionString of SQLite is the path of
+	// the database file.
+	DbDriver           string `json:"dbDriver" yaml:"dbDriver"`
+	DbConnectionString string `json:"dbConnectionString" yaml:"dbConnectionString" config:"secret"`
+	// The pool of database connections, 0 open connections means no limit.
+	DbMaxOpenConns    int      `json:"dbMaxOpenConns" yaml:"dbMaxOpenConns"`
+	DbMaxIdleConns    int      `json:"dbMaxIdleConns" yaml:"dbMaxIdleConns"`
+	DbConnMaxLifetime Duration `json:"dbConnMaxLifetime" yaml:"dbConnMaxLifetime"`
+	// LogLevel is the least level that is logged, such as "debug" or "warn".
+	LogLevel string `json:"logLevel" yaml:"logLevel"`
+	// ApiTokenLifetime is how long a new api token is valid, JwtLifetime how
+	// long the jwt reservationctl signs are.
+	ApiTokenLifetime Duration `json:"apiTokenLifetime" yaml:"apiTokenLifetime"`
+	JwtLifetime      Duration `json:"jwtLifetime" yaml:"jwtLifetime"`
+	Secret           string   `json:"secret" yaml:"secret" config:"secret"`
+	// PreviousSecrets are secrets the jwt were signed with before the last
+	// rotations, jwt signed with them are still accepted until they expire.
+	PreviousSecrets []string `json:"previousSecrets" yaml:"previousSecrets" config:"secret"`
+	// Salt is mixed into every hash, changing it invalidates the api tokens
+	// and secrets hashed with the old one.
+	Salt string `json:"salt" yaml:"salt" config:"secret"`
+	// PaymentProvider names the provider that charges reservations of sources
+	// requiring payment, "fake" or empty for none.
+	PaymentProvider      string `json:"paymentProvider" yaml:"paymentProvider"`
+	PaymentWebhookSecret string `json:"paymentWebhookSecret" yaml:"paymentWebhookSecret" config:"secret"`
+	// TaxRateBasisPoints is the tax rate of invoices, 1900 is 19%.
+	TaxRateBasisPoints int `json:"taxRateBasisPoints" yaml:"taxRateBasisPoints"`
+	// SkipMigrations leaves migrating to "reservation-engine migrate", the
+	// engine then starts on a database with pending migrations and only warns
+	// about where it differs from the models.
+	SkipMigrations bool `json:"skipMigrations" yaml:"skipMigrations"`
+	// ShutdownTimeout is how long a stopping engine waits for the requests in
+	// flight and the background workers before it closes the database.
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`
 }
 
-func (c *Configuration) ReadAndFillSelf(logger zerolog.Logger) error {
-	file, err := os.ReadFile("./config.json")
+// DefaultConfigurationPath is where the engine and its tools read the
+// configuration from when no other file is named.
+const DefaultConfigurationPath = "./config.json"
+
+// ConfigurationEnvPrefix starts the names of the environment variables of the
+// settings.
+const ConfigurationEnvPrefix = "RESERVATION_ENGINE_"
+
+// redacted replaces secrets in printed configurations.
+const redacted = "REDACTED"
+
+// DefaultConfiguration is the bottom layer of every configuration.
+func DefaultConfiguration() Configuration {
+	return Configuration{
+		Port:              11242,
+		GrpcAddress:       ":11243",
+		DbDriver:          "postgres",
+		DbMaxOpenConns:    20,
+		DbMaxIdleConns:    5,
+		DbConnMaxLifetime: Duration(30 * time.Minute),
+		LogLevel:          "info",
+		ApiTokenLifetime:  Duration(365 * 24 * time.Hour),
+		JwtLifetime:       Duration(time.Hour),
+		ShutdownTimeout:   Duration(30 * time.Second),
+		// The salt every hash was made with before it became a setting.
+		Salt: "salty-crackers",
+	}
+}
+
+// LoadConfiguration reads the configuration with the flags in args, which
+// come before the command, and returns the arguments after them. The file is
+// named by --config, see ConfigurationPath. The configuration still needs to
+// be validated.
+func LoadConfiguration(name string, args []string, lookupEnv func(string) (string, bool)) (Configuration, []string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := flags.String("config", "", "configuration file, JSON or YAML (default "+DefaultConfigurationPath+")")
+	overrides := NewConfigurationFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return Configuration{}, nil, err
+	}
+
+	path, required := ConfigurationPath(*configPath, lookupEnv)
+	c, err := ReadConfiguration(path, required, lookupEnv)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error reading local JSON config file")
+		return Configuration{}, nil, err
+	}
+	if err = overrides.Apply(&c); err != nil {
+		return Configuration{}, nil, err
+	}
+	return c, flags.Args(), nil
+}
+
+// ConfigurationPath is the file given with --config, or else the one named
+// by RESERVATION_ENGINE_CONFIG, or else DefaultConfigurationPath. Only the
+// default may be missing.
+func ConfigurationPath(flagValue string, lookupEnv func(string) (string, bool)) (string, bool) {
+	if flagValue != "" {
+		return flagValue, true
+	}
+	if value, ok := lookupEnv(ConfigurationEnvPrefix + "CONFIG"); ok && value != "" {
+		return value, true
+	}
+	return DefaultConfigurationPath, false
+}
+
+// ConfigurationCommandUsage describes the arguments of ConfigurationCommand.
+const ConfigurationCommandUsage = `config print        print the configuration with its secrets redacted`
+
+// ConfigurationCommand carries out the config command of the binaries and
+// writes the configuration to w as JSON. A configuration that is not valid is
+// printed as well, and then reported.
+func ConfigurationCommand(c Configuration, args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] != "print" || len(args) > 1 {
+		return fmt.Errorf("unknown config command %q, use:\n%s", strings.Join(args, " "), ConfigurationCommandUsage)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Redacted()); err != nil {
 		return err
 	}
+	return c.Validate()
+}
+
+// ReadConfiguration layers the defaults, the file at path and the environment.
+// A missing file is skipped unless it is required.
+func ReadConfiguration(path string, required bool, lookupEnv func(string) (string, bool)) (Configuration, error) {
+	c := DefaultConfiguration()
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err = DecodeConfigurationFile(path, data, &c); err != nil {
+			return Configuration{}, fmt.Errorf("configuration: %s: %w", path, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist) || required:
+		return Configuration{}, fmt.Errorf("configuration: %w", err)
+	}
+
+	for _, s := range settings(&c) {
+		value, ok := lookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err = s.set(value); err != nil {
+			return Configuration{}, fmt.Errorf("configuration: %s: %w", s.env, err)
+		}
+	}
+	return c, nil
+}
+
+// DecodeConfigurationFile reads a configuration file into v, as YAML when the
+// file is named .yaml or .yml and as JSON otherwise.
+func DecodeConfigurationFile(path string, data []byte, v any) error {
+	if isYAML(path) {
+		return yaml.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// EncodeConfigurationFile writes v in the format of the file at path.
+func EncodeConfigurationFile(path string, v any) ([]byte, error) {
+	if isYAML(path) {
+		return yaml.Marshal(v)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	return append(data, '\n'), err
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ConfigurationFlags are the settings given on the command line.
+type ConfigurationFlags struct {
+	values map[string]string
+	order  []string
+}
+
+// NewConfigurationFlags defines a flag for every setting on flags.
+func NewConfigurationFlags(flags *flag.FlagSet) *ConfigurationFlags {
+	f := &ConfigurationFlags{values: map[string]string{}}
+	defaults := DefaultConfiguration()
+	for _, s := range settings(&defaults) {
+		name := s.flag
+		usage := fmt.Sprintf("overrides %s (%s)", s.key, s.env)
+		if !s.secret && !s.value.IsZero() {
+			usage += fmt.Sprintf(", %s by default", s.String())
+		}
+		flags.Func(name, usage, func(value string) error {
+			if _, ok := f.values[name]; !ok {
+				f.order = append(f.order, name)
+			}
+			f.values[name] = value
+			return nil
+		})
+	}
+	return f
+}
+
+// Apply sets the settings given on the command line.
+func (f *ConfigurationFlags) Apply(c *Configuration) error {
+	byFlag := map[string]setting{}
+	for _, s := range settings(c) {
+		byFlag[s.flag] = s
+	}
+	for _, name := range f.order {
+		if err := byFlag[name].set(f.values[name]); err != nil {
+			return fmt.Errorf("configuration: --%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once.
+func (c *Configuration) Validate() error {
+	var problems []error
+	problem := func(key string, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		problem("port", "%d is not between 1 and 65535", c.Port)
+	}
+	if c.GrpcAddress == "" {
+		problem("grpcAddress", "is empty")
+	}
+	if c.TLSCertFile != "" && c.TLSKeyFile == "" {
+		problem("tlsKeyFile", "is empty while tlsCertFile is set")
+	}
+	if c.TLSKeyFile != "" && c.TLSCertFile == "" {
+		problem("tlsCertFile", "is empty while tlsKeyFile is set")
+	}
+	for _, file := range []struct{ key, path string }{{"tlsCertFile", c.TLSCertFile}, {"tlsKeyFile", c.TLSKeyFile}} {
+		if _, err := os.Stat(file.path); file.path != "" && err != nil {
+			problem(file.key, "%v", err)
+		}
+	}
+	if c.DbDriver != "postgres" && c.DbDriver != "sqlite" {
+		problem("dbDriver", "%q is neither postgres nor sqlite", c.DbDriver)
+	}
+	if c.DbConnectionString == "" {
+		problem("dbConnectionString", "is empty")
+	}
+	if c.DbMaxOpenConns < 0 {
+		problem("dbMaxOpenConns", "%d is negative", c.DbMaxOpenConns)
+	}
+	if c.DbMaxIdleConns < 0 {
+		problem("dbMaxIdleConns", "%d is negative", c.DbMaxIdleConns)
+	}
+	if c.DbMaxOpenConns > 0 && c.DbMaxIdleConns > c.DbMaxOpenConns {
+		problem("dbMaxIdleConns", "%d is more than the %d dbMaxOpenConns", c.DbMaxIdleConns, c.DbMaxOpenConns)
+	}
+	if c.DbConnMaxLifetime < 0 {
+		problem("dbConnMaxLifetime", "%s is negative", c.DbConnMaxLifetime)
+	}
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil || c.LogLevel == "" {
+		problem("logLevel", "%q is not one of trace, debug, info, warn, error, fatal, panic or disabled", c.LogLevel)
+	}
+	if c.ApiTokenLifetime <= 0 {
+		problem("apiTokenLifetime", "%s is not positive", c.ApiTokenLifetime)
+	}
+	if c.JwtLifetime <= 0 {
+		problem("jwtLifetime", "%s is not positive", c.JwtLifetime)
+	}
+	if c.Secret == "" {
+		problem("secret", "is empty, jwt could not be verified")
+	}
+	if c.Salt == "" {
+		problem("salt", "is empty")
+	}
+	if c.TaxRateBasisPoints < 0 || c.TaxRateBasisPoints > 10000 {
+		problem("taxRateBasisPoints", "%d is not between 0 and 10000", c.TaxRateBasisPoints)
+	}
+	if c.ShutdownTimeout <= 0 {
+		problem("shutdownTimeout", "%s is not positive", c.ShutdownTimeout)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("configuration is not valid:\n%w", errors.Join(problems...))
+}
+
+// Level is the parsed LogLevel, info when it does not parse.
+func (c *Configuration) Level() zerolog.Level {
+	level, err := zerolog.ParseLevel(c.LogLevel)
+	if err != nil || c.LogLevel == "" {
+		return zerolog.InfoLevel
+	}
+	return level
+}
+
+// Redacted returns a copy of the configuration to print, with the secrets
+// replaced. Only the password of the connection string is replaced.
+func (c Configuration) Redacted() Configuration {
+	for _, s := range settings(&c) {
+		if !s.secret || s.value.IsZero() {
+			continue
+		}
+		switch {
+		case s.key == "dbConnectionString":
+			c.DbConnectionString = redactConnectionString(c.DbConnectionString)
+		case s.value.Kind() == reflect.Slice:
+			values := make([]string, s.value.Len())
+			for i := range values {
+				values[i] = redacted
+			}
+			s.value.Set(reflect.ValueOf(values))
+		default:
+			s.value.SetString(redacted)
+		}
+	}
+	return c
+}
+
+var passwordSetting = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// redactConnectionString replaces the password of Postgres URLs and key=value
+// connection strings.
+func redactConnectionString(connectionString string) string {
+	if u, err := url.Parse(connectionString); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+	}
+	return passwordSetting.ReplaceAllString(connectionString, "${1}"+redacted)
+}
+
+func (c *Configuration) GetSalt() string {
+	return c.Salt
+}
+
+// Duration is a time.Duration written as "90s" or "1h30m" in configuration
+// files.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
 
-	// Unmarshal the JSON data
-	var config Configuration
-	err = json.Unmarshal(file, &c)
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(bytes.TrimSpace(text)))
 	if err != nil {
-		logger.Error().Err(err).Msg("Error unmarshalling config JSON")
 		return err
 	}
+	*d = Duration(parsed)
+	return nil
+}
 
-	c.DbConnectionString = config.DbConnectionString
-	c.salt = "salty-crackers"
+// setting is one field of a configuration, see Configuration for its names.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	secret bool
+	value  reflect.Value
+}
+
+func settings(c *Configuration) []setting {
+	v := reflect.ValueOf(c).Elem()
+	res := make([]setting, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		words := splitCamel(key)
+		res = append(res, setting{
+			key:    key,
+			env:    ConfigurationEnvPrefix + strings.ToUpper(strings.Join(words, "_")),
+			flag:   strings.Join(words, "-"),
+			secret: field.Tag.Get("config") == "secret",
+			value:  v.Field(i),
+		})
+	}
+	return res
+}
+
+// splitCamel splits "dbMaxOpenConns" into db, max, open and conns.
+func splitCamel(key string) []string {
+	var words []string
+	start := 0
+	runes := []rune(key)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	return append(words, strings.ToLower(string(runes[start:])))
+}
+
+func (s setting) set(value string) error {
+	if u, ok := s.value.Addr().Interface().(interface{ UnmarshalText([]byte) error }); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is neither true nor false", value)
+		}
+		s.value.SetBool(b)
+	case reflect.Slice:
+		values := []string{}
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		s.value.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("%s settings can not be set", s.value.Kind())
+	}
 	return nil
 }
 
-func (c *Configuration) GetSalt() string {
-	return c.salt
+func (s setting) String() string {
+	if s.value.Kind() == reflect.Slice {
+		return strings.Join(s.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(s.value.Interface())
 }
@@ -0,0 +1,34 @@
+package models
+
+// Entities are the models stored in the database, in the order they are
+// migrated.
+func Entities() []any {
+	return []any{
+		&Source{},
+		&Secret{},
+		&ApiToken{},
+		&Reservation{},
+		&Customer{},
+		&Bundle{},
+		&Person{},
+		&Participant{},
+		&CancellationPolicy{},
+		&ReservationFee{},
+		&Rate{},
+		&Promotion{},
+		&PromotionRedemption{},
+		&Payment{},
+		&PaymentWebhookEvent{},
+		&Plan{},
+		&UsageCounter{},
+		&Invoice{},
+		&InvoiceSequence{},
+		&DeletedSource{},
+		&WebhookEndpoint{},
+		&WebhookDelivery{},
+		&OutboxEvent{},
+		&CalendarFeed{},
+		&CalendarObject{},
+		&IdempotencyKey{},
+	}
+}
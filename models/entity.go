@@ -1,52 +1,640 @@
 package models
 
-import "time"
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Base struct {
-	ID        string    `gorm:"primarykey;type:uuid;default:gen_random_uuid()" json:"id"`
+	ID        string    `gorm:"primarykey;type:uuid" json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// BeforeCreate gives the record its id, ids are generated here rather than by
+// the database so that every dialect creates them alike.
+func (b *Base) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = NewUUID()
+	}
+	return nil
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
 type Source struct {
 	Base
-	Name                string        `gorm:"type:nvarchar(256)" json:"name"`
+	Name                string        `gorm:"type:varchar(256)" json:"name"`
 	Tokens              []ApiToken    `json:"tokens"`
 	Reservations        []Reservation `json:"reservations"`
 	MaxPossibleDuration string        `json:"maxPossibleReservationDuration"`
-	CustomerID          string        `json:"customerId"`
+	CustomerID          string        `gorm:"type:uuid" json:"customerId"`
+	RequiresApproval    bool          `json:"requiresApproval"`
+	ApproverID          *string       `gorm:"type:uuid" json:"approverId"`
+	ApprovalTimeout     string        `json:"approvalTimeout"`
+	// CancellationPolicyID points to the rules that price cancelling and
+	// rescheduling reservations of this source, nil means it is always free.
+	CancellationPolicyID *string `gorm:"type:uuid" json:"cancellationPolicyId"`
+	// Pricing settings, amounts of the rates are in minor units of Currency
+	// and their time windows are read in Timezone.
+	Currency                string `gorm:"type:varchar(3);default:EUR" json:"currency"`
+	Timezone                string `gorm:"type:varchar(64);default:UTC" json:"timezone"`
+	WeekendSurchargePercent int    `json:"weekendSurchargePercent"`
+	// Capacity is the number of units a single reservation may book, 0 means 1.
+	Capacity int    `json:"capacity"`
+	Rates    []Rate `json:"rates"`
+	// RequiresPayment makes reservations of this source hold their slot only
+	// once their price was paid.
+	RequiresPayment bool `json:"requiresPayment"`
 }
 
+const (
+	RateKindHourly = "hourly"
+	RateKindDaily  = "daily"
+	RateKindFlat   = "flat"
+)
+
+// Rate prices a reservation of a source. Hourly rates are charged per minute,
+// daily rates per calendar day and act as a cap for the hourly charges of that
+// day, flat rates once per reservation. A rate only applies on Weekdays (0 is
+// Sunday, empty means every day) between StartTime and EndTime ("15:04",
+// empty means the whole day). When several rates apply the one with the
+// highest Priority wins, which is how peak and off-peak prices are defined.
+type Rate struct {
+	Base
+	SourceID    string `gorm:"type:uuid;index" json:"sourceId"`
+	Name        string `gorm:"type:varchar(128)" json:"name"`
+	Kind        string `gorm:"type:varchar(8)" json:"kind"`
+	AmountMinor int64  `json:"amountMinor"`
+	Weekdays    []int  `gorm:"serializer:json" json:"weekdays"`
+	StartTime   string `gorm:"type:varchar(5)" json:"startTime"`
+	EndTime     string `gorm:"type:varchar(5)" json:"endTime"`
+	Priority    int    `json:"priority"`
+	PerUnit     bool   `json:"perUnit"`
+}
+
+// PriceLine is one line of the price breakdown of a reservation or a quote.
+type PriceLine struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	Unit        string `json:"unit"`
+	UnitAmount  int64  `json:"unitAmount"`
+	Amount      int64  `json:"amount"`
+}
+
+// DefaultApprovalTimeout is used when a source requiring approval does not
+// define how long its requests may wait for a decision.
+const DefaultApprovalTimeout = 48 * time.Hour
+
 type ApiToken struct {
 	Base
 	CustomerID string    `gorm:"type:uuid" json:"customerId"`
 	SourceID   string    `gorm:"type:uuid" json:"sourceId"`
-	Token      string    `gorm:"type:nvarchar(64)" json:"token"`
+	Token      string    `gorm:"type:varchar(64)" json:"token"`
 	ValidUntil time.Time `json:"validUntil"`
 }
 
+const (
+	ReservationStatusConfirmed       = "confirmed"
+	ReservationStatusPendingApproval = "pending_approval"
+	ReservationStatusRejected        = "rejected"
+	ReservationStatusExpired         = "expired"
+	ReservationStatusCancelled       = "cancelled"
+	ReservationStatusPendingPayment  = "pending_payment"
+	ReservationStatusPaymentFailed   = "payment_failed"
+)
+
+// ReleasedReservationStatuses are the statuses of reservations that no longer
+// hold their slot.
+var ReleasedReservationStatuses = []string{ReservationStatusRejected, ReservationStatusExpired, ReservationStatusCancelled, ReservationStatusPaymentFailed}
+
 type Reservation struct {
 	Base
-	From       time.Time `json:"from"`
-	To         time.Time `json:"to"`
-	ReserverID string    `json:"reserverId"`
-	ReserveeID string    `json:"reserveeId"`
-	SourceID   string    `json:"sourceId"`
+	From              time.Time        `json:"from"`
+	To                time.Time        `json:"to"`
+	ReserverID        string           `json:"reserverId"`
+	ReserveeID        string           `json:"reserveeId"`
+	SourceID          string           `json:"sourceId"`
+	BundleID          *string          `gorm:"type:uuid" json:"bundleId"`
+	Participants      []Participant    `json:"participants"`
+	Status            string           `gorm:"type:varchar(24);default:confirmed;index" json:"status"`
+	ApproverID        *string          `gorm:"type:uuid" json:"approverId"`
+	ApprovalExpiresAt *time.Time       `json:"approvalExpiresAt"`
+	DecisionComment   string           `json:"decisionComment"`
+	DecidedAt         *time.Time       `json:"decidedAt"`
+	CancelledAt       *time.Time       `json:"cancelledAt"`
+	Fees              []ReservationFee `json:"fees"`
+	Units             int              `gorm:"default:1" json:"units"`
+	Currency          string           `gorm:"type:varchar(3)" json:"currency"`
+	TotalAmount       int64            `json:"totalAmount"`
+	PriceBreakdown    []PriceLine      `gorm:"serializer:json" json:"priceBreakdown"`
+	PromotionID       *string          `gorm:"type:uuid" json:"promotionId"`
+	Payments          []Payment        `json:"payments"`
+	// Sequence counts the changes of time and status, calendar clients only
+	// pick up a changed event when its sequence grew.
+	Sequence int `json:"sequence"`
+}
+
+func (r *Reservation) IsReleased() bool {
+	for _, status := range ReleasedReservationStatuses {
+		if r.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	ParticipantRoleOrganizer = "organizer"
+	ParticipantRoleRequired  = "required"
+	ParticipantRoleOptional  = "optional"
+)
+
+const (
+	RSVPPending   = "pending"
+	RSVPAccepted  = "accepted"
+	RSVPTentative = "tentative"
+	RSVPDeclined  = "declined"
+)
+
+// Participant is a person attending a reservation next to its reservee. A
+// participant that has not declined is considered busy for the whole window.
+type Participant struct {
+	Base
+	ReservationID string `gorm:"type:uuid;index" json:"reservationId"`
+	PersonID      string `gorm:"type:uuid;index" json:"personId"`
+	Role          string `gorm:"type:varchar(16)" json:"role"`
+	RSVP          string `gorm:"type:varchar(16)" json:"rsvp"`
+}
+
+// Bundle links reservations on several sources that were booked together for
+// the same window, so they can be rescheduled or cancelled as one.
+type Bundle struct {
+	Base
+	Reservations []Reservation `json:"reservations"`
+}
+
+const (
+	FeeKindCancellation = "cancellation"
+	FeeKindModification = "modification"
+)
+
+// ReservationFee records a fee charged for cancelling or rescheduling a
+// reservation. When an admin overrides the fee, the computed one is kept next
+// to the applied one together with the reason.
+type ReservationFee struct {
+	Base
+	ReservationID      string  `gorm:"type:uuid;index" json:"reservationId"`
+	Kind               string  `gorm:"type:varchar(16)" json:"kind"`
+	ComputedFeePercent int     `json:"computedFeePercent"`
+	FeePercent         int     `json:"feePercent"`
+	OverrideReason     string  `json:"overrideReason"`
+	OverriddenBy       *string `json:"overriddenBy"`
+	Amount             int64   `json:"amount"`
+	Currency           string  `gorm:"type:varchar(3)" json:"currency"`
+}
+
+// PolicyRule charges FeePercent when the change happens at least Before
+// (a duration such as "24h") ahead of the start of the reservation.
+type PolicyRule struct {
+	Before     string `json:"before"`
+	FeePercent int    `json:"feePercent"`
+}
+
+type PolicyRules []PolicyRule
+
+// FeePercent picks the rule with the longest Before that is still satisfied by
+// the time left until the start. Without rules changes are free, and once no
+// rule applies anymore nothing is refunded.
+func (r PolicyRules) FeePercent(untilStart time.Duration) (int, error) {
+	if len(r) == 0 {
+		return 0, nil
+	}
+
+	fee := 100
+	var best time.Duration = -1
+	for _, rule := range r {
+		before, err := time.ParseDuration(rule.Before)
+		if err != nil {
+			return 0, err
+		}
+		if untilStart >= before && before > best {
+			best = before
+			fee = rule.FeePercent
+		}
+	}
+	return fee, nil
+}
+
+func (r PolicyRules) Validate() error {
+	for _, rule := range r {
+		if _, err := time.ParseDuration(rule.Before); err != nil {
+			return fmt.Errorf("rule before %q is not a valid duration", rule.Before)
+		}
+		if rule.FeePercent < 0 || rule.FeePercent > 100 {
+			return fmt.Errorf("rule fee percent %d is not between 0 and 100", rule.FeePercent)
+		}
+	}
+	return nil
+}
+
+type CancellationPolicy struct {
+	Base
+	CustomerID        string      `gorm:"type:uuid;index" json:"customerId"`
+	Name              string      `gorm:"type:varchar(128)" json:"name"`
+	CancellationRules PolicyRules `gorm:"serializer:json" json:"cancellationRules"`
+	ModificationRules PolicyRules `gorm:"serializer:json" json:"modificationRules"`
+}
+
+const (
+	PromotionKindPercentage = "percentage"
+	PromotionKindFixed      = "fixed"
+)
+
+// Promotion is a discount code of a customer. A percentage promotion takes
+// PercentOff of the price, a fixed one AmountOffMinor in Currency. SourceIDs
+// restricts it to some sources, an empty list means all of them.
+// MaxRedemptions of 0 means the code can be redeemed without limit.
+type Promotion struct {
+	Base
+	CustomerID      string     `gorm:"type:uuid;uniqueIndex:idx_promotion_code" json:"customerId"`
+	Code            string     `gorm:"type:varchar(64);uniqueIndex:idx_promotion_code" json:"code"`
+	Kind            string     `gorm:"type:varchar(16)" json:"kind"`
+	PercentOff      int        `json:"percentOff"`
+	AmountOffMinor  int64      `json:"amountOffMinor"`
+	Currency        string     `gorm:"type:varchar(3)" json:"currency"`
+	SourceIDs       []string   `gorm:"serializer:json" json:"sourceIds"`
+	ValidFrom       *time.Time `json:"validFrom"`
+	ValidUntil      *time.Time `json:"validUntil"`
+	FirstTimeOnly   bool       `json:"firstTimeOnly"`
+	MaxRedemptions  int        `json:"maxRedemptions"`
+	RedemptionCount int        `json:"redemptionCount"`
+	Active          bool       `json:"active"`
+}
+
+type PromotionRedemption struct {
+	Base
+	PromotionID    string `gorm:"type:uuid;index" json:"promotionId"`
+	ReservationID  string `gorm:"type:uuid;index" json:"reservationId"`
+	ReserveeID     string `gorm:"type:uuid" json:"reserveeId"`
+	DiscountAmount int64  `json:"discountAmount"`
+	Currency       string `gorm:"type:varchar(3)" json:"currency"`
+}
+
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusFailed            = "failed"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+)
+
+// Payment is the charge of a reservation at a payment provider.
+// ProviderPaymentID is the id the provider knows the payment by.
+type Payment struct {
+	Base
+	ReservationID     string `gorm:"type:uuid;index" json:"reservationId"`
+	Provider          string `gorm:"type:varchar(32)" json:"provider"`
+	ProviderPaymentID string `gorm:"type:varchar(128);index" json:"providerPaymentId"`
+	Status            string `gorm:"type:varchar(24)" json:"status"`
+	Amount            int64  `json:"amount"`
+	Currency          string `gorm:"type:varchar(3)" json:"currency"`
+	RefundedAmount    int64  `json:"refundedAmount"`
+	FailureReason     string `json:"failureReason"`
+}
+
+// PaymentWebhookEvent records every handled webhook of a provider, so that a
+// redelivered event is recognised and ignored.
+type PaymentWebhookEvent struct {
+	Base
+	Provider          string `gorm:"type:varchar(32);uniqueIndex:idx_payment_webhook_event" json:"provider"`
+	EventID           string `gorm:"type:varchar(128);uniqueIndex:idx_payment_webhook_event" json:"eventId"`
+	Type              string `gorm:"type:varchar(64)" json:"type"`
+	ProviderPaymentID string `gorm:"type:varchar(128)" json:"providerPaymentId"`
 }
 
 type Customer struct {
 	Base
-	Name           string     `gorm:"type:nvarchar(128)" json:"name"`
-	Company        string     `gorm:"type:nvarchar(64)" json:"company"`
-	Email          string     `gorm:"type:nvarchar(128)" json:"email"`
-	Sources        []string   `json:"sources"`
-	ApiTokens      []ApiToken `json:"apiTokens"`
-	Secret         Secret     `json:"secret"`
-	MaxSourceLimit int        `json:"maxSourceLimit"`
+	Name      string     `gorm:"type:varchar(128)" json:"name"`
+	Company   string     `gorm:"type:varchar(64)" json:"company"`
+	Email     string     `gorm:"type:varchar(128)" json:"email"`
+	Sources   []Source   `json:"sources"`
+	ApiTokens []ApiToken `json:"apiTokens"`
+	Secret    Secret     `json:"secret"`
+	// PlanID is the plan whose limits apply to the customer, nil means
+	// DefaultPlan.
+	PlanID *string `gorm:"type:uuid" json:"planId"`
+	// TaxRateBasisPoints overrides the configured tax rate for the invoices
+	// of the customer, 1900 is 19%.
+	TaxRateBasisPoints *int `json:"taxRateBasisPoints"`
+	// MaxSourceLimit lets the customer have more sources than its plan
+	// allows, the larger of the two applies. It is the limit customers had
+	// before there were plans, 0 leaves the one of the plan.
+	MaxSourceLimit int `json:"maxSourceLimit"`
+}
+
+const (
+	FeatureApprovals  = "approvals"
+	FeaturePayments   = "payments"
+	FeaturePromotions = "promotions"
+	FeatureBundles    = "bundles"
+)
+
+// AllFeatures lists every feature a plan can enable.
+var AllFeatures = []string{FeatureApprovals, FeaturePayments, FeaturePromotions, FeatureBundles}
+
+// Plan defines the commercial limits of the customers assigned to it. A limit
+// of 0 means unlimited. RateLimitPerMinute caps the API calls made with the
+// customer's api tokens.
+type Plan struct {
+	Base
+	Name                    string   `gorm:"type:varchar(64);uniqueIndex" json:"name"`
+	MaxSources              int      `json:"maxSources"`
+	MaxReservationsPerMonth int      `json:"maxReservationsPerMonth"`
+	MaxApiTokens            int      `json:"maxApiTokens"`
+	RateLimitPerMinute      int      `json:"rateLimitPerMinute"`
+	Features                []string `gorm:"serializer:json" json:"features"`
+	// Billing of the plan, amounts are in minor units of Currency. Every
+	// reservation of a month beyond IncludedReservations is billed at
+	// OveragePriceMinor.
+	Currency             string `gorm:"type:varchar(3);default:EUR" json:"currency"`
+	MonthlyPriceMinor    int64  `json:"monthlyPriceMinor"`
+	SourcePriceMinor     int64  `json:"sourcePriceMinor"`
+	IncludedReservations int    `json:"includedReservations"`
+	OveragePriceMinor    int64  `json:"overagePriceMinor"`
+}
+
+func (p Plan) HasFeature(feature string) bool {
+	for _, f := range p.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ForCustomer returns the plan with the limits the customer overrides.
+func (p Plan) ForCustomer(customer Customer) Plan {
+	if p.MaxSources > 0 && customer.MaxSourceLimit > p.MaxSources {
+		p.MaxSources = customer.MaxSourceLimit
+	}
+	return p
+}
+
+// DefaultPlan applies to customers without a plan. It keeps the single source
+// customers used to start with and does not restrict anything else.
+var DefaultPlan = Plan{Name: "default", MaxSources: 1, Features: AllFeatures, Currency: "EUR"}
+
+const (
+	UsageMetricReservations = "reservations_created"
+	UsageMetricApiCalls     = "api_calls"
+)
+
+// UsagePeriodFormat formats the calendar month a usage counter belongs to.
+const UsagePeriodFormat = "2006-01"
+
+// UsageCounter meters one metric of a customer for one calendar month (UTC).
+type UsageCounter struct {
+	Base
+	CustomerID string `gorm:"type:uuid;uniqueIndex:idx_usage_counter" json:"customerId"`
+	Period     string `gorm:"type:varchar(7);uniqueIndex:idx_usage_counter" json:"period"`
+	Metric     string `gorm:"type:varchar(32);uniqueIndex:idx_usage_counter" json:"metric"`
+	Count      int64  `json:"count"`
+}
+
+// Person is somebody who can reserve or be reserved for on the sources of a
+// customer. ExternalRef maps the person to an id in the customer's own user
+// system and can be used wherever a person id is expected.
+type Person struct {
+	Base
+	CustomerID  string            `gorm:"type:uuid;uniqueIndex:idx_person_external_ref" json:"customerId"`
+	ExternalRef *string           `gorm:"type:varchar(128);uniqueIndex:idx_person_external_ref" json:"externalRef"`
+	Name        string            `gorm:"type:varchar(128)" json:"name"`
+	Email       string            `gorm:"type:varchar(128)" json:"email"`
+	Phone       string            `gorm:"type:varchar(32)" json:"phone"`
+	Metadata    map[string]string `gorm:"serializer:json" json:"metadata"`
 }
 
 type Secret struct {
 	Base
 	CustomerID string `gorm:"type:uuid" json:"customerId"`
-	Value      string `gorm:"type:nvarchar(64)" json:"secret"`
+	Value      string `gorm:"type:varchar(64)" json:"secret"`
+}
+
+const (
+	InvoiceKindInvoice    = "invoice"
+	InvoiceKindCreditNote = "credit_note"
+)
+
+const (
+	InvoiceStatusDraft  = "draft"
+	InvoiceStatusIssued = "issued"
+)
+
+// Invoice bills a customer for one month (Period, "2006-01"). Drafts can be
+// regenerated or deleted, once issued an invoice gets its Number and never
+// changes again; corrections are made with credit notes, which are invoices
+// of kind credit_note pointing to the corrected one through CorrectsInvoiceID.
+// A customer has at most one issued invoice per period.
+// Amounts are in minor units of Currency, negative on credit notes.
+type Invoice struct {
+	Base
+	CustomerID         string      `gorm:"type:uuid;index;uniqueIndex:idx_invoices_issued_period,where:kind = 'invoice' AND status = 'issued'" json:"customerId"`
+	Kind               string      `gorm:"type:varchar(16)" json:"kind"`
+	Status             string      `gorm:"type:varchar(16)" json:"status"`
+	Number             *string     `gorm:"type:varchar(32);uniqueIndex" json:"number"`
+	Period             string      `gorm:"type:varchar(7);index;uniqueIndex:idx_invoices_issued_period" json:"period"`
+	Currency           string      `gorm:"type:varchar(3)" json:"currency"`
+	Lines              []PriceLine `gorm:"serializer:json" json:"lines"`
+	Subtotal           int64       `json:"subtotal"`
+	TaxRateBasisPoints int         `json:"taxRateBasisPoints"`
+	TaxAmount          int64       `json:"taxAmount"`
+	Total              int64       `json:"total"`
+	IssuedAt           *time.Time  `json:"issuedAt"`
+	CorrectsInvoiceID  *string     `gorm:"type:uuid;index" json:"correctsInvoiceId"`
+	Reason             string      `json:"reason"`
+}
+
+func (i *Invoice) IsIssued() bool {
+	return i.Status == InvoiceStatusIssued
+}
+
+// DeletedSource keeps what invoices need of a source once it is deleted, so
+// that the periods it was active in are still billed.
+type DeletedSource struct {
+	ID         string    `gorm:"primarykey;type:uuid" json:"id"`
+	CustomerID string    `gorm:"type:uuid;index" json:"customerId"`
+	Name       string    `gorm:"type:varchar(256)" json:"name"`
+	CreatedAt  time.Time `json:"createdAt"`
+	DeletedAt  time.Time `json:"deletedAt"`
+}
+
+// InvoiceSequence hands out the consecutive numbers of invoices with the same
+// prefix, such as "INV-2026".
+type InvoiceSequence struct {
+	Prefix string `gorm:"type:varchar(16);primarykey" json:"prefix"`
+	Last   int64  `json:"last"`
+}
+
+// WebhookEndpoint receives the events of its customer as signed HTTP POSTs.
+// EventTypes limits the events sent to it, empty means every event. Secret is
+// the key of the HMAC in the signature header of every delivery.
+type WebhookEndpoint struct {
+	Base
+	CustomerID string   `gorm:"type:uuid;index" json:"customerId"`
+	URL        string   `gorm:"type:varchar(512)" json:"url"`
+	Secret     string   `gorm:"type:varchar(64)" json:"secret"`
+	EventTypes []string `gorm:"serializer:json" json:"eventTypes"`
+	Active     bool     `json:"active"`
+}
+
+func (e WebhookEndpoint) Accepts(eventType string) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	WebhookDeliveryStatusPending  = "pending"
+	WebhookDeliveryStatusRetrying = "retrying"
+	// WebhookDeliveryStatusSending deliveries were claimed by an engine that
+	// is sending them. NextAttemptAt is when the claim runs out, the delivery
+	// is attempted again then if that engine never reported back.
+	WebhookDeliveryStatusSending   = "sending"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	// WebhookDeliveryStatusDead deliveries ran out of attempts and wait in the
+	// dead letter list until they are redelivered by hand.
+	WebhookDeliveryStatusDead = "dead"
+)
+
+// WebhookDelivery is one event on its way to one endpoint, and the log of the
+// attempts made so far. Payload is the JSON body that is posted. An event is
+// queued only once per endpoint, even when the outbox relays it again.
+type WebhookDelivery struct {
+	Base
+	CustomerID     string     `gorm:"type:uuid;index" json:"customerId"`
+	EndpointID     string     `gorm:"type:uuid;uniqueIndex:idx_webhook_delivery_event" json:"endpointId"`
+	EventID        string     `gorm:"type:varchar(36);uniqueIndex:idx_webhook_delivery_event" json:"eventId"`
+	EventType      string     `gorm:"type:varchar(64)" json:"eventType"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `gorm:"type:varchar(16);index" json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"nextAttemptAt"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	LastStatusCode int        `json:"lastStatusCode"`
+	LastError      string     `json:"lastError"`
+}
+
+// OutboxEvent is a domain event written in the transaction of the mutation
+// that raised it, so that it exists if and only if the mutation is committed.
+// The relay publishes it afterwards and sets PublishedAt. Position orders the
+// events, and with that the events of every aggregate.
+type OutboxEvent struct {
+	ID          string     `gorm:"primarykey;type:uuid" json:"id"`
+	Position    int64      `gorm:"autoIncrement;uniqueIndex" json:"position"`
+	Type        string     `gorm:"type:varchar(64)" json:"type"`
+	CustomerID  string     `gorm:"type:varchar(36);index" json:"customerId"`
+	AggregateID string     `gorm:"type:varchar(36);index" json:"aggregateId"`
+	Payload     string     `gorm:"type:text" json:"payload"`
+	OccurredAt  time.Time  `json:"occurredAt"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"lastError"`
+	PublishedAt *time.Time `gorm:"index" json:"publishedAt"`
+	// PublishedPosition orders the events as the relay published them, which
+	// is the order streams deliver them in and resume from.
+	PublishedPosition *int64 `gorm:"uniqueIndex" json:"publishedPosition"`
+}
+
+const (
+	CalendarFeedKindSource   = "source"
+	CalendarFeedKindReservee = "reservee"
+)
+
+// CalendarFeed lets whoever knows its Token read the reservations of a source
+// or of a reservee as an iCalendar feed, which is how calendar apps subscribe.
+// Deleting the feed revokes the token.
+type CalendarFeed struct {
+	Base
+	CustomerID string  `gorm:"type:uuid;index" json:"customerId"`
+	Kind       string  `gorm:"type:varchar(16)" json:"kind"`
+	SourceID   *string `gorm:"type:uuid" json:"sourceId"`
+	PersonID   *string `gorm:"type:uuid" json:"personId"`
+	Token      string  `gorm:"type:varchar(64);uniqueIndex" json:"token"`
+	// Path is where the feed is served, relative to the host of the engine.
+	Path string `gorm:"-" json:"path"`
+}
+
+// CalendarFeedPath is the path the feed with the token is served at.
+func CalendarFeedPath(token string) string {
+	return "/api/v1/feeds/" + token + ".ics"
+}
+
+// DavRoot is where the CalDAV calendar home of a customer is served, every
+// source is a calendar collection below it.
+const DavRoot = "/api/v1/dav/"
+
+// DavCollectionPath is the path of the calendar collection of a source.
+func DavCollectionPath(sourceId string) string {
+	return DavRoot + "sources/" + sourceId + "/"
+}
+
+// CalendarObject remembers the resource name and the UID a CalDAV client
+// gave to the reservation it created, so the client finds its event again.
+// Reservations without one are served as "<id>.ics".
+type CalendarObject struct {
+	Base
+	SourceID      string `gorm:"type:uuid;uniqueIndex:idx_calendar_object_name" json:"sourceId"`
+	Name          string `gorm:"type:varchar(255);uniqueIndex:idx_calendar_object_name" json:"name"`
+	ReservationID string `gorm:"type:uuid;uniqueIndex" json:"reservationId"`
+	UID           string `gorm:"type:varchar(255);index" json:"uid"`
+}
+
+// CalendarObjectResource is a reservation served as a calendar object of the
+// collection of its source.
+type CalendarObjectResource struct {
+	Name          string
+	UID           string
+	ETag          string
+	ReservationID string
+	Data          []byte
+}
+
+// ETag identifies the version of the reservation for conditional requests.
+func (r *Reservation) ETag() string {
+	return fmt.Sprintf(`"%d"`, r.UpdatedAt.UnixMicro())
+}
+
+// IdempotencyKeyLifetime is how long the response to a request with an
+// idempotency key is replayed.
+const IdempotencyKeyLifetime = 24 * time.Hour
+
+// IdempotencyKey remembers the response to a mutation sent with an
+// Idempotency-Key header, so that a client retrying the mutation gets the
+// same response instead of applying it twice. Status is 0 while the first
+// request is being served. Fingerprint identifies the request the key was
+// first used with.
+type IdempotencyKey struct {
+	Base
+	Scope       string `gorm:"type:varchar(64);uniqueIndex:idx_idempotency_key" json:"scope"`
+	Key         string `gorm:"type:varchar(255);uniqueIndex:idx_idempotency_key" json:"key"`
+	Fingerprint string `gorm:"type:varchar(64)" json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
 }
@@ -15,11 +15,22 @@ type ReadAllSources struct {
 }
 
 type ReadAllReservations struct {
-	Pagination Pagination `json:"pagination"`
-	IDs        *[]string  `json:"ids"`
-	ReserverID *string    `json:"reserverId"`
-	ReserveeID *string    `json:"reserveeId"`
-	SourceID   *string    `json:"sourceId"`
+	Pagination    Pagination `json:"pagination"`
+	IDs           *[]string  `json:"ids"`
+	ReserverID    *string    `json:"reserverId"`
+	ReserveeID    *string    `json:"reserveeId"`
+	SourceID      *string    `json:"sourceId"`
+	ParticipantID *string    `json:"participantId"`
+	Status        *string    `json:"status"`
+	ApproverID    *string    `json:"approverId"`
+}
+
+type ReadAllPersons struct {
+	Pagination  Pagination `json:"pagination"`
+	IDs         *[]string  `json:"ids"`
+	ExternalRef *string    `json:"externalRef"`
+	Name        *string    `json:"name"`
+	Email       *string    `json:"email"`
 }
 
 type CreateCustomer struct {
@@ -29,17 +40,107 @@ type CreateCustomer struct {
 }
 
 type CreateSource struct {
-	Name                string `json:"name"`
-	MaxPossibleDuration string `json:"maxPossibleReservationDuration"`
-	CustomerID          string `json:"customerId"`
+	Name                 string        `json:"name"`
+	MaxPossibleDuration  string        `json:"maxPossibleReservationDuration"`
+	CustomerID           string        `json:"customerId"`
+	RequiresApproval     bool          `json:"requiresApproval"`
+	ApproverID           *string       `json:"approverId"`
+	ApprovalTimeout      string        `json:"approvalTimeout"`
+	CancellationPolicyID *string       `json:"cancellationPolicyId"`
+	Pricing              SourcePricing `json:"pricing"`
+}
+
+type SourcePricing struct {
+	Currency                string `json:"currency"`
+	Timezone                string `json:"timezone"`
+	WeekendSurchargePercent int    `json:"weekendSurchargePercent"`
+	Capacity                int    `json:"capacity"`
+	RequiresPayment         bool   `json:"requiresPayment"`
 }
 
 type CreateReservation struct {
+	From          time.Time                `json:"from"`
+	To            time.Time                `json:"to"`
+	ReserverID    string                   `json:"reserverId"`
+	ReserveeID    string                   `json:"reserveeId"`
+	SourceID      string                   `json:"sourceId"`
+	Participants  []ReservationParticipant `json:"participants"`
+	Units         int                      `json:"units"`
+	PromotionCode string                   `json:"promotionCode"`
+	// PaymentMethod is the provider specific token to charge when the source
+	// requires payment.
+	PaymentMethod string `json:"paymentMethod"`
+}
+
+type ReservationParticipant struct {
+	PersonID string `json:"personId"`
+	Role     string `json:"role"`
+}
+
+type CreateParticipant struct {
+	ReservationID string `json:"reservationId"`
+	PersonID      string `json:"personId"`
+	Role          string `json:"role"`
+}
+
+type CreatePerson struct {
+	ExternalRef *string           `json:"externalRef"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email"`
+	Phone       string            `json:"phone"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+type CreateCancellationPolicy struct {
+	CustomerID        string      `json:"customerId"`
+	Name              string      `json:"name"`
+	CancellationRules PolicyRules `json:"cancellationRules"`
+	ModificationRules PolicyRules `json:"modificationRules"`
+}
+
+type CreateRate struct {
+	SourceID    string `json:"sourceId"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	AmountMinor int64  `json:"amountMinor"`
+	Weekdays    []int  `json:"weekdays"`
+	StartTime   string `json:"startTime"`
+	EndTime     string `json:"endTime"`
+	Priority    int    `json:"priority"`
+	PerUnit     bool   `json:"perUnit"`
+}
+
+type CreateQuote struct {
+	SourceID string    `json:"sourceId" binding:"required"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Units    int       `json:"units"`
+	// PromotionCode and ReserveeID are optional, the reservee is needed to
+	// check promotions for first-time reservees.
+	PromotionCode string `json:"promotionCode"`
+	ReserveeID    string `json:"reserveeId"`
+}
+
+type CreatePromotion struct {
+	CustomerID     string     `json:"customerId"`
+	Code           string     `json:"code"`
+	Kind           string     `json:"kind"`
+	PercentOff     int        `json:"percentOff"`
+	AmountOffMinor int64      `json:"amountOffMinor"`
+	Currency       string     `json:"currency"`
+	SourceIDs      []string   `json:"sourceIds"`
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidUntil     *time.Time `json:"validUntil"`
+	FirstTimeOnly  bool       `json:"firstTimeOnly"`
+	MaxRedemptions int        `json:"maxRedemptions"`
+}
+
+type CreateBundle struct {
 	From       time.Time `json:"from"`
 	To         time.Time `json:"to"`
 	ReserverID string    `json:"reserverId"`
 	ReserveeID string    `json:"reserveeId"`
-	SourceID   string    `json:"sourceId"`
+	SourceIDs  []string  `json:"sourceIds"`
 }
 
 type UpdateCustomer struct {
@@ -51,9 +152,14 @@ type UpdateCustomer struct {
 }
 
 type UpdateSource struct {
-	ID                  string  `json:"id" binding:"required"`
-	Name                *string `json:"name"`
-	MaxPossibleDuration *string `json:"maxPossibleReservationDuration"`
+	ID                   string         `json:"id" binding:"required"`
+	Name                 *string        `json:"name"`
+	MaxPossibleDuration  *string        `json:"maxPossibleReservationDuration"`
+	RequiresApproval     *bool          `json:"requiresApproval"`
+	ApproverID           *string        `json:"approverId"`
+	ApprovalTimeout      *string        `json:"approvalTimeout"`
+	CancellationPolicyID *string        `json:"cancellationPolicyId"`
+	Pricing              *SourcePricing `json:"pricing"`
 }
 
 type UpdateReservation struct {
@@ -61,3 +167,244 @@ type UpdateReservation struct {
 	From *time.Time `json:"from"`
 	To   *time.Time `json:"to"`
 }
+
+type UpdateBundle struct {
+	ID   string     `json:"id" binding:"required"`
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+type UpdatePerson struct {
+	ID          string             `json:"id" binding:"required"`
+	ExternalRef *string            `json:"externalRef"`
+	Name        *string            `json:"name"`
+	Email       *string            `json:"email"`
+	Phone       *string            `json:"phone"`
+	Metadata    *map[string]string `json:"metadata"`
+}
+
+type UpdateParticipant struct {
+	ID   string  `json:"id" binding:"required"`
+	Role *string `json:"role"`
+	RSVP *string `json:"rsvp"`
+}
+
+type ReservationDecision struct {
+	ID         string `json:"id" binding:"required"`
+	ApproverID string `json:"approverId" binding:"required"`
+	Comment    string `json:"comment"`
+}
+
+type AssignApprover struct {
+	ID         string `json:"id" binding:"required"`
+	ApproverID string `json:"approverId" binding:"required"`
+}
+
+type UpdateCancellationPolicy struct {
+	ID                string       `json:"id" binding:"required"`
+	Name              *string      `json:"name"`
+	CancellationRules *PolicyRules `json:"cancellationRules"`
+	ModificationRules *PolicyRules `json:"modificationRules"`
+}
+
+type ReadAllCancellationPolicies struct {
+	Pagination Pagination `json:"pagination"`
+	CustomerID *string    `json:"customerId"`
+}
+
+// FeeOverride replaces the fee computed from the cancellation policy. It is
+// only accepted from admins and always needs a reason for the audit trail.
+type FeeOverride struct {
+	FeePercent int    `json:"feePercent" form:"feePercent"`
+	Reason     string `json:"reason" form:"reason" binding:"required"`
+	By         string `json:"-" form:"-"`
+}
+
+type AdminUpdateReservation struct {
+	UpdateReservation
+	Override FeeOverride `json:"override" binding:"required"`
+}
+
+type UpdateRate struct {
+	ID          string  `json:"id" binding:"required"`
+	Name        *string `json:"name"`
+	Kind        *string `json:"kind"`
+	AmountMinor *int64  `json:"amountMinor"`
+	Weekdays    *[]int  `json:"weekdays"`
+	StartTime   *string `json:"startTime"`
+	EndTime     *string `json:"endTime"`
+	Priority    *int    `json:"priority"`
+	PerUnit     *bool   `json:"perUnit"`
+}
+
+type ReadAllRates struct {
+	Pagination Pagination `json:"pagination"`
+	SourceID   *string    `json:"sourceId"`
+}
+
+type UpdatePromotion struct {
+	ID             string     `json:"id" binding:"required"`
+	SourceIDs      *[]string  `json:"sourceIds"`
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidUntil     *time.Time `json:"validUntil"`
+	FirstTimeOnly  *bool      `json:"firstTimeOnly"`
+	MaxRedemptions *int       `json:"maxRedemptions"`
+	Active         *bool      `json:"active"`
+}
+
+type ReadAllPromotions struct {
+	Pagination Pagination `json:"pagination"`
+	CustomerID *string    `json:"customerId"`
+	Code       *string    `json:"code"`
+}
+
+type ReadPromotionReport struct {
+	CustomerID string `json:"customerId" form:"customerId" binding:"required"`
+}
+
+type CreatePlan struct {
+	Name                    string      `json:"name" binding:"required"`
+	MaxSources              int         `json:"maxSources"`
+	MaxReservationsPerMonth int         `json:"maxReservationsPerMonth"`
+	MaxApiTokens            int         `json:"maxApiTokens"`
+	RateLimitPerMinute      int         `json:"rateLimitPerMinute"`
+	Features                []string    `json:"features"`
+	Billing                 PlanBilling `json:"billing"`
+}
+
+type PlanBilling struct {
+	Currency             string `json:"currency"`
+	MonthlyPriceMinor    int64  `json:"monthlyPriceMinor"`
+	SourcePriceMinor     int64  `json:"sourcePriceMinor"`
+	IncludedReservations int    `json:"includedReservations"`
+	OveragePriceMinor    int64  `json:"overagePriceMinor"`
+}
+
+type UpdatePlan struct {
+	ID                      string       `json:"id" binding:"required"`
+	Name                    *string      `json:"name"`
+	MaxSources              *int         `json:"maxSources"`
+	MaxReservationsPerMonth *int         `json:"maxReservationsPerMonth"`
+	MaxApiTokens            *int         `json:"maxApiTokens"`
+	RateLimitPerMinute      *int         `json:"rateLimitPerMinute"`
+	Features                *[]string    `json:"features"`
+	Billing                 *PlanBilling `json:"billing"`
+}
+
+type ReadAllPlans struct {
+	Pagination Pagination `json:"pagination"`
+	Name       *string    `json:"name"`
+}
+
+// AssignPlan moves a customer onto a plan, a nil PlanID moves it back to the
+// default plan.
+type AssignPlan struct {
+	CustomerID string  `json:"customerId" binding:"required"`
+	PlanID     *string `json:"planId"`
+}
+
+type ReadUsage struct {
+	// Period is the month to report in the "2006-01" format, empty for the
+	// current one.
+	Period string `json:"period" form:"period"`
+}
+
+type ReadAllInvoices struct {
+	Pagination Pagination `json:"pagination"`
+	CustomerID *string    `json:"customerId" form:"customerId"`
+	Period     *string    `json:"period" form:"period"`
+	Kind       *string    `json:"kind" form:"kind"`
+}
+
+// CreateInvoice generates the draft invoice of a customer for a month.
+type CreateInvoice struct {
+	CustomerID string `json:"customerId" binding:"required"`
+	Period     string `json:"period" binding:"required"`
+}
+
+type IssueInvoice struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// CreateCreditNote corrects an issued invoice by crediting the given lines,
+// amounts are positive and credited before tax.
+type CreateCreditNote struct {
+	InvoiceID string      `json:"invoiceId" binding:"required"`
+	Reason    string      `json:"reason" binding:"required"`
+	Lines     []PriceLine `json:"lines" binding:"required"`
+}
+
+// CreateWebhookEndpoint registers an endpoint for the customer of the api
+// token. Leaving EventTypes empty subscribes it to every event.
+type CreateWebhookEndpoint struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// UpdateWebhookEndpoint changes an endpoint, RotateSecret replaces its signing
+// secret with a new one.
+type UpdateWebhookEndpoint struct {
+	ID           string    `json:"id" binding:"required"`
+	URL          *string   `json:"url"`
+	EventTypes   *[]string `json:"eventTypes"`
+	Active       *bool     `json:"active"`
+	RotateSecret bool      `json:"rotateSecret"`
+}
+
+type ReadAllWebhookEndpoints struct {
+	Pagination Pagination `json:"pagination"`
+}
+
+type ReadAllWebhookDeliveries struct {
+	Pagination Pagination `json:"pagination"`
+	EndpointID *string    `json:"endpointId" form:"endpointId"`
+	EventID    *string    `json:"eventId" form:"eventId"`
+	Status     *string    `json:"status" form:"status"`
+}
+
+type RedeliverWebhook struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// CreateCalendarFeed creates the feed of a source or of a reservee, exactly
+// one of them has to be given. PersonID may be an external reference.
+type CreateCalendarFeed struct {
+	SourceID *string `json:"sourceId"`
+	PersonID *string `json:"personId"`
+}
+
+type ReadAllCalendarFeeds struct {
+	Pagination Pagination `json:"pagination"`
+}
+
+// ImportReservations imports the VEVENTs of an iCalendar document as
+// reservations of one source. Nothing is created unless Commit is set, the
+// report of the dry run lists what would be.
+type ImportReservations struct {
+	SourceID   string `json:"sourceId"`
+	ReserverID string `json:"reserverId"`
+	ReserveeID string `json:"reserveeId"`
+	Units      int    `json:"units"`
+	Calendar   string `json:"calendar"`
+	Commit     bool   `json:"commit"`
+}
+
+// ReadAvailability asks which parts of a window a source is free in.
+type ReadAvailability struct {
+	SourceID string    `json:"sourceId" form:"sourceId"`
+	From     time.Time `json:"from" form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `json:"to" form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type ReadAllApiTokens struct {
+	Pagination Pagination `json:"pagination"`
+	CustomerID *string    `json:"customerId" form:"customerId"`
+	SourceID   *string    `json:"sourceId" form:"sourceId"`
+	// Valid leaves out the tokens that expired or were revoked.
+	Valid bool `json:"valid" form:"valid"`
+}
+
+type CreateApiToken struct {
+	CustomerID string `json:"customerId" binding:"required"`
+	SourceID   string `json:"sourceId" binding:"required"`
+}
@@ -1,13 +1,15 @@
 package models
 
-type PaginationResponse[T Source | Reservation | Customer] struct {
+import "time"
+
+type PaginationResponse[T Source | Reservation | Customer | Person | CancellationPolicy | Rate | Promotion | Plan | Invoice | WebhookEndpoint | WebhookDelivery | CalendarFeed | ApiToken] struct {
 	Total   int64
 	Page    uint32
 	Count   int
 	Content []T
 }
 
-func NewPaginationResponse[T Source | Reservation | Customer](vals []T, total int64, page uint32) PaginationResponse[T] {
+func NewPaginationResponse[T Source | Reservation | Customer | Person | CancellationPolicy | Rate | Promotion | Plan | Invoice | WebhookEndpoint | WebhookDelivery | CalendarFeed | ApiToken](vals []T, total int64, page uint32) PaginationResponse[T] {
 	return PaginationResponse[T]{
 		Content: vals,
 		Page:    page,
@@ -15,3 +17,134 @@ func NewPaginationResponse[T Source | Reservation | Customer](vals []T, total in
 		Count:   len(vals),
 	}
 }
+
+// ReservationChange is returned by mutations of a reservation that may be
+// charged according to the cancellation policy of its source.
+type ReservationChange struct {
+	ID  string          `json:"id"`
+	Fee *ReservationFee `json:"fee"`
+}
+
+// Quote is the price of a prospective reservation, amounts are in minor units
+// of Currency.
+type Quote struct {
+	SourceID string      `json:"sourceId"`
+	Currency string      `json:"currency"`
+	Lines    []PriceLine `json:"lines"`
+	Total    int64       `json:"total"`
+}
+
+// PromotionReport sums up the redemptions of one promotion code.
+type PromotionReport struct {
+	PromotionID    string `json:"promotionId"`
+	Code           string `json:"code"`
+	Redemptions    int64  `json:"redemptions"`
+	DiscountAmount int64  `json:"discountAmount"`
+	Currency       string `json:"currency"`
+}
+
+type WebhookReceipt struct {
+	EventID   string `json:"eventId"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+// Usage reports what a customer used in a period next to the limits of its plan.
+type Usage struct {
+	CustomerID          string `json:"customerId"`
+	Period              string `json:"period"`
+	Plan                Plan   `json:"plan"`
+	Sources             int64  `json:"sources"`
+	ApiTokens           int64  `json:"apiTokens"`
+	ReservationsCreated int64  `json:"reservationsCreated"`
+	ApiCalls            int64  `json:"apiCalls"`
+}
+
+const (
+	ImportStatusCreatable   = "creatable"
+	ImportStatusCreated     = "created"
+	ImportStatusConflicting = "conflicting"
+	ImportStatusSkipped     = "skipped"
+)
+
+// ImportedEvent is one occurrence of an imported calendar event and what the
+// import did or would do with it.
+type ImportedEvent struct {
+	UID           string    `json:"uid"`
+	Summary       string    `json:"summary"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason,omitempty"`
+	ReservationID string    `json:"reservationId,omitempty"`
+}
+
+// ImportReport sums up a calendar import. A dry run reports what would be
+// created without keeping any of it.
+type ImportReport struct {
+	SourceID    string          `json:"sourceId"`
+	DryRun      bool            `json:"dryRun"`
+	Creatable   int             `json:"creatable"`
+	Conflicting int             `json:"conflicting"`
+	Skipped     int             `json:"skipped"`
+	Events      []ImportedEvent `json:"events"`
+}
+
+// CalendarCollection is a source served as a CalDAV calendar collection.
+// CTag changes whenever a reservation of the source does.
+type CalendarCollection struct {
+	Source Source
+	CTag   string
+}
+
+// StreamPage is a page of published events of a stream. Position is where
+// the next page starts, past events the stream does not deliver.
+type StreamPage struct {
+	Events   []OutboxEvent
+	Position int64
+}
+
+// Health answers the health probes. Checks holds what readiness checked,
+// "ok" or what is wrong.
+type Health struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type Interval struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Availability splits a window of a source into the intervals held by
+// reservations and the free ones in between.
+type Availability struct {
+	SourceID string     `json:"sourceId"`
+	From     time.Time  `json:"from"`
+	To       time.Time  `json:"to"`
+	Busy     []Interval `json:"busy"`
+	Free     []Interval `json:"free"`
+}
+
+// ExportVersion is the version of the Export format, imports refuse other
+// versions.
+const ExportVersion = 1
+
+// Export holds the data of customers, the rows of every table that belongs to
+// them, in the order they are imported.
+type Export struct {
+	Version              int                  `json:"version"`
+	ExportedAt           time.Time            `json:"exportedAt"`
+	Customers            []Customer           `json:"customers"`
+	Secrets              []Secret             `json:"secrets"`
+	Persons              []Person             `json:"persons"`
+	CancellationPolicies []CancellationPolicy `json:"cancellationPolicies"`
+	Promotions           []Promotion          `json:"promotions"`
+	Sources              []Source             `json:"sources"`
+	ApiTokens            []ApiToken           `json:"apiTokens"`
+	Rates                []Rate               `json:"rates"`
+	Bundles              []Bundle             `json:"bundles"`
+	Reservations         []Reservation        `json:"reservations"`
+	Participants         []Participant        `json:"participants"`
+	Fees                 []ReservationFee     `json:"fees"`
+	Payments             []Payment            `json:"payments"`
+}
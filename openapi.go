@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/openapi"
+)
+
+const (
+	apiTitle   = "reservation-engine"
+	apiVersion = "1.0.0"
+)
+
+// Content types of the responses that are not JSON.
+const (
+	contentXml      = "application/xml"
+	contentCalendar = "text/calendar"
+	contentStream   = "text/event-stream"
+)
+
+// changedId stands for what commands answer with, the id of what they changed.
+var changedId = ""
+
+// operations describe the routes of registerRoutes, in the same order. A test
+// checks that both list the same routes and that openapi.Spec is built from
+// them.
+var operations = []openapi.Operation{
+	// Health
+	{Method: http.MethodGet, Path: "/healthz", Tag: "Health", Summary: "Tell whether the engine is alive", Response: models.Health{}},
+	{Method: http.MethodGet, Path: "/readyz", Tag: "Health", Summary: "Tell whether the engine is ready, its database answers, it is migrated and its workers run; 503 when it is not", Response: models.Health{}},
+	// CalDAV discovery
+	{Method: http.MethodGet, Path: "/.well-known/caldav", Tag: "CalDAV", Summary: "Redirect calendar clients to the CalDAV home", Status: http.StatusMovedPermanently},
+	{Method: "PROPFIND", Path: "/.well-known/caldav", Tag: "CalDAV", Summary: "Redirect calendar clients to the CalDAV home", Status: http.StatusMovedPermanently},
+	// Public
+	{Method: http.MethodPost, Path: "/api/v1/payments/webhook", Tag: "Payments", Summary: "Receive a webhook of the payment provider, authenticated by its signature", Response: models.WebhookReceipt{}},
+	{Method: http.MethodGet, Path: "/api/v1/feeds/:file", Tag: "Calendar feeds", Summary: "Read a calendar feed, authenticated by the token in its file name", ContentType: contentCalendar},
+	{Method: http.MethodGet, Path: "/api/v1/openapi.json", Tag: "Documentation", Summary: "Read this document", ContentType: "application/json"},
+	{Method: http.MethodGet, Path: "/api/v1/docs", Tag: "Documentation", Summary: "Browse this document", ContentType: "text/html"},
+	// CalDAV
+	{Method: http.MethodOptions, Path: "/api/v1/dav", Tag: "CalDAV", Summary: "List the DAV capabilities", Security: openapi.SecurityApiKey},
+	{Method: "PROPFIND", Path: "/api/v1/dav", Tag: "CalDAV", Summary: "Read the calendar home and its collections", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: http.MethodOptions, Path: "/api/v1/dav/", Tag: "CalDAV", Summary: "List the DAV capabilities", Security: openapi.SecurityApiKey},
+	{Method: "PROPFIND", Path: "/api/v1/dav/", Tag: "CalDAV", Summary: "Read the calendar home and its collections", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: http.MethodOptions, Path: "/api/v1/dav/sources/:sourceId", Tag: "CalDAV", Summary: "List the DAV capabilities", Security: openapi.SecurityApiKey},
+	{Method: "PROPFIND", Path: "/api/v1/dav/sources/:sourceId", Tag: "CalDAV", Summary: "Read the calendar collection of a source", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: "REPORT", Path: "/api/v1/dav/sources/:sourceId", Tag: "CalDAV", Summary: "Query or multiget the calendar objects of a source", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: http.MethodOptions, Path: "/api/v1/dav/sources/:sourceId/", Tag: "CalDAV", Summary: "List the DAV capabilities", Security: openapi.SecurityApiKey},
+	{Method: "PROPFIND", Path: "/api/v1/dav/sources/:sourceId/", Tag: "CalDAV", Summary: "Read the calendar collection of a source", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: "REPORT", Path: "/api/v1/dav/sources/:sourceId/", Tag: "CalDAV", Summary: "Query or multiget the calendar objects of a source", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: http.MethodOptions, Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "List the DAV capabilities", Security: openapi.SecurityApiKey},
+	{Method: "PROPFIND", Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "Read the properties of a calendar object", Security: openapi.SecurityApiKey, ContentType: contentXml, Status: http.StatusMultiStatus},
+	{Method: http.MethodGet, Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "Read a calendar object", Security: openapi.SecurityApiKey, ContentType: contentCalendar},
+	{Method: http.MethodHead, Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "Read the headers of a calendar object", Security: openapi.SecurityApiKey},
+	{Method: http.MethodPut, Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "Create, reschedule or cancel the reservation of a calendar object", Security: openapi.SecurityApiKey, Status: http.StatusCreated},
+	{Method: http.MethodDelete, Path: "/api/v1/dav/sources/:sourceId/:file", Tag: "CalDAV", Summary: "Cancel the reservation of a calendar object", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	// Customers
+	{Method: http.MethodGet, Path: "/api/v1/customers", Tag: "Customers", Summary: "List customers", Security: openapi.SecurityJwt, Query: models.ReadAllCustomers{}, Response: models.PaginationResponse[models.Customer]{}},
+	{Method: http.MethodPost, Path: "/api/v1/customers", Tag: "Customers", Summary: "Create a customer and its secret", Security: openapi.SecurityJwt, Body: models.CreateCustomer{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/customers", Tag: "Customers", Summary: "Update a customer", Security: openapi.SecurityJwt, Body: models.UpdateCustomer{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/customers/:id", Tag: "Customers", Summary: "Read a customer", Security: openapi.SecurityJwt, Response: models.Customer{}},
+	{Method: http.MethodDelete, Path: "/api/v1/customers/:id", Tag: "Customers", Summary: "Delete a customer", Security: openapi.SecurityJwt, Status: http.StatusNoContent},
+	{Method: http.MethodPatch, Path: "/api/v1/customers/plan", Tag: "Plans", Summary: "Move a customer onto a plan", Security: openapi.SecurityJwt, Body: models.AssignPlan{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/customers/:id/stream", Tag: "Streams", Summary: "Stream the reservation events of a customer, resuming after Last-Event-ID or lastEventId", Security: openapi.SecurityJwt, ContentType: contentStream},
+	{Method: http.MethodGet, Path: "/api/v1/customers/:id/sources/:sourceId/stream", Tag: "Streams", Summary: "Stream the reservation events of a source of a customer, resuming after Last-Event-ID or lastEventId", Security: openapi.SecurityJwt, ContentType: contentStream},
+	// Api tokens
+	{Method: http.MethodGet, Path: "/api/v1/api-tokens", Tag: "Api tokens", Summary: "List api tokens", Security: openapi.SecurityJwt, Query: models.ReadAllApiTokens{}, Response: models.PaginationResponse[models.ApiToken]{}},
+	{Method: http.MethodPost, Path: "/api/v1/api-tokens", Tag: "Api tokens", Summary: "Issue an api token for a source", Security: openapi.SecurityJwt, Body: models.CreateApiToken{}, Response: models.ApiToken{}},
+	{Method: http.MethodDelete, Path: "/api/v1/api-tokens/:id", Tag: "Api tokens", Summary: "Revoke an api token", Security: openapi.SecurityJwt, Status: http.StatusNoContent},
+	// Plans
+	{Method: http.MethodGet, Path: "/api/v1/plans", Tag: "Plans", Summary: "List plans", Security: openapi.SecurityJwt, Query: models.ReadAllPlans{}, Response: models.PaginationResponse[models.Plan]{}},
+	{Method: http.MethodPost, Path: "/api/v1/plans", Tag: "Plans", Summary: "Create a plan", Security: openapi.SecurityJwt, Body: models.CreatePlan{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/plans", Tag: "Plans", Summary: "Update a plan", Security: openapi.SecurityJwt, Body: models.UpdatePlan{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/plans/:id", Tag: "Plans", Summary: "Read a plan", Security: openapi.SecurityJwt, Response: models.Plan{}},
+	{Method: http.MethodDelete, Path: "/api/v1/plans/:id", Tag: "Plans", Summary: "Delete a plan", Security: openapi.SecurityJwt, Status: http.StatusNoContent},
+	// Invoices
+	{Method: http.MethodGet, Path: "/api/v1/invoices", Tag: "Invoices", Summary: "List invoices", Security: openapi.SecurityJwt, Query: models.ReadAllInvoices{}, Response: models.PaginationResponse[models.Invoice]{}},
+	{Method: http.MethodPost, Path: "/api/v1/invoices", Tag: "Invoices", Summary: "Generate the draft invoice of a customer for a period", Security: openapi.SecurityJwt, Body: models.CreateInvoice{}, Response: changedId},
+	{Method: http.MethodPost, Path: "/api/v1/invoices/issue", Tag: "Invoices", Summary: "Issue a draft invoice", Security: openapi.SecurityJwt, Body: models.IssueInvoice{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/invoices/:id", Tag: "Invoices", Summary: "Read an invoice", Security: openapi.SecurityJwt, Response: models.Invoice{}},
+	{Method: http.MethodGet, Path: "/api/v1/invoices/:id/csv", Tag: "Invoices", Summary: "Read the lines of an invoice as CSV", Security: openapi.SecurityJwt, ContentType: "text/csv"},
+	{Method: http.MethodDelete, Path: "/api/v1/invoices/:id", Tag: "Invoices", Summary: "Delete a draft invoice", Security: openapi.SecurityJwt, Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/v1/credit-notes", Tag: "Invoices", Summary: "Credit an issued invoice", Security: openapi.SecurityJwt, Body: models.CreateCreditNote{}, Response: changedId},
+	// Fee overrides
+	{Method: http.MethodPatch, Path: "/api/v1/admin/reservations", Tag: "Reservations", Summary: "Reschedule a reservation overriding its fee", Security: openapi.SecurityJwt, Body: models.AdminUpdateReservation{}, Response: models.ReservationChange{}},
+	{Method: http.MethodDelete, Path: "/api/v1/admin/reservations/:id", Tag: "Reservations", Summary: "Cancel a reservation overriding its fee", Security: openapi.SecurityJwt, Query: models.FeeOverride{}, Response: models.ReservationChange{}},
+	// Usage
+	{Method: http.MethodGet, Path: "/api/v1/usage", Tag: "Plans", Summary: "Read the usage of the customer in a period", Security: openapi.SecurityApiKey, Query: models.ReadUsage{}, Response: models.Usage{}},
+	// Streams
+	{Method: http.MethodGet, Path: "/api/v1/stream", Tag: "Streams", Summary: "Stream the reservation events of the customer, resuming after Last-Event-ID or lastEventId", Security: openapi.SecurityApiKey, ContentType: contentStream},
+	{Method: http.MethodGet, Path: "/api/v1/sources/:id/stream", Tag: "Streams", Summary: "Stream the reservation events of a source, resuming after Last-Event-ID or lastEventId", Security: openapi.SecurityApiKey, ContentType: contentStream},
+	// Reservations
+	{Method: http.MethodGet, Path: "/api/v1/reservations", Tag: "Reservations", Summary: "List reservations", Security: openapi.SecurityApiKey, Query: models.ReadAllReservations{}, Response: models.PaginationResponse[models.Reservation]{}},
+	{Method: http.MethodPost, Path: "/api/v1/reservations", Tag: "Reservations", Summary: "Create a reservation", Security: openapi.SecurityApiKey, Body: models.CreateReservation{}, Response: changedId},
+	{Method: http.MethodPost, Path: "/api/v1/reservations/import", Tag: "Reservations", Summary: "Import the events of an iCalendar document as reservations", Security: openapi.SecurityApiKey, Body: models.ImportReservations{}, Response: models.ImportReport{}},
+	{Method: http.MethodPatch, Path: "/api/v1/reservations", Tag: "Reservations", Summary: "Reschedule a reservation", Security: openapi.SecurityApiKey, Body: models.UpdateReservation{}, Response: models.ReservationChange{}},
+	{Method: http.MethodGet, Path: "/api/v1/reservations/:id", Tag: "Reservations", Summary: "Read a reservation", Security: openapi.SecurityApiKey, Response: models.Reservation{}},
+	{Method: http.MethodDelete, Path: "/api/v1/reservations/:id", Tag: "Reservations", Summary: "Cancel a reservation", Security: openapi.SecurityApiKey, Response: models.ReservationChange{}},
+	// Approvals
+	{Method: http.MethodPost, Path: "/api/v1/reservations/approve", Tag: "Approvals", Summary: "Approve a pending reservation", Security: openapi.SecurityApiKey, Body: models.ReservationDecision{}, Response: changedId},
+	{Method: http.MethodPost, Path: "/api/v1/reservations/reject", Tag: "Approvals", Summary: "Reject a pending reservation", Security: openapi.SecurityApiKey, Body: models.ReservationDecision{}, Response: changedId},
+	{Method: http.MethodPost, Path: "/api/v1/reservations/approver", Tag: "Approvals", Summary: "Assign the approver of a pending reservation", Security: openapi.SecurityApiKey, Body: models.AssignApprover{}, Response: changedId},
+	// Bundles
+	{Method: http.MethodPost, Path: "/api/v1/bundles", Tag: "Bundles", Summary: "Reserve several sources for the same window", Security: openapi.SecurityApiKey, Body: models.CreateBundle{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/bundles", Tag: "Bundles", Summary: "Reschedule the reservations of a bundle", Security: openapi.SecurityApiKey, Body: models.UpdateBundle{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/bundles/:id", Tag: "Bundles", Summary: "Read a bundle", Security: openapi.SecurityApiKey, Response: models.Bundle{}},
+	{Method: http.MethodDelete, Path: "/api/v1/bundles/:id", Tag: "Bundles", Summary: "Cancel the reservations of a bundle", Security: openapi.SecurityApiKey, Response: []models.ReservationFee{}},
+	// Persons
+	{Method: http.MethodGet, Path: "/api/v1/persons", Tag: "Persons", Summary: "List persons", Security: openapi.SecurityApiKey, Query: models.ReadAllPersons{}, Response: models.PaginationResponse[models.Person]{}},
+	{Method: http.MethodPost, Path: "/api/v1/persons", Tag: "Persons", Summary: "Create a person", Security: openapi.SecurityApiKey, Body: models.CreatePerson{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/persons", Tag: "Persons", Summary: "Update a person", Security: openapi.SecurityApiKey, Body: models.UpdatePerson{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/persons/:id", Tag: "Persons", Summary: "Read a person", Security: openapi.SecurityApiKey, Response: models.Person{}},
+	{Method: http.MethodDelete, Path: "/api/v1/persons/:id", Tag: "Persons", Summary: "Delete a person", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	// Participants
+	{Method: http.MethodPost, Path: "/api/v1/participants", Tag: "Participants", Summary: "Add a participant to a reservation", Security: openapi.SecurityApiKey, Body: models.CreateParticipant{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/participants", Tag: "Participants", Summary: "Update a participant", Security: openapi.SecurityApiKey, Body: models.UpdateParticipant{}, Response: changedId},
+	{Method: http.MethodDelete, Path: "/api/v1/participants/:id", Tag: "Participants", Summary: "Remove a participant from a reservation", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	// Cancellation policies
+	{Method: http.MethodGet, Path: "/api/v1/policies", Tag: "Cancellation policies", Summary: "List cancellation policies", Security: openapi.SecurityApiKey, Query: models.ReadAllCancellationPolicies{}, Response: models.PaginationResponse[models.CancellationPolicy]{}},
+	{Method: http.MethodPost, Path: "/api/v1/policies", Tag: "Cancellation policies", Summary: "Create a cancellation policy", Security: openapi.SecurityApiKey, Body: models.CreateCancellationPolicy{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/policies", Tag: "Cancellation policies", Summary: "Update a cancellation policy", Security: openapi.SecurityApiKey, Body: models.UpdateCancellationPolicy{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/policies/:id", Tag: "Cancellation policies", Summary: "Read a cancellation policy", Security: openapi.SecurityApiKey, Response: models.CancellationPolicy{}},
+	{Method: http.MethodDelete, Path: "/api/v1/policies/:id", Tag: "Cancellation policies", Summary: "Delete a cancellation policy", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	// Pricing
+	{Method: http.MethodGet, Path: "/api/v1/rates", Tag: "Pricing", Summary: "List rates", Security: openapi.SecurityApiKey, Query: models.ReadAllRates{}, Response: models.PaginationResponse[models.Rate]{}},
+	{Method: http.MethodPost, Path: "/api/v1/rates", Tag: "Pricing", Summary: "Create a rate", Security: openapi.SecurityApiKey, Body: models.CreateRate{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/rates", Tag: "Pricing", Summary: "Update a rate", Security: openapi.SecurityApiKey, Body: models.UpdateRate{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/rates/:id", Tag: "Pricing", Summary: "Read a rate", Security: openapi.SecurityApiKey, Response: models.Rate{}},
+	{Method: http.MethodDelete, Path: "/api/v1/rates/:id", Tag: "Pricing", Summary: "Delete a rate", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/v1/quotes", Tag: "Pricing", Summary: "Price a prospective reservation", Security: openapi.SecurityApiKey, Body: models.CreateQuote{}, Response: models.Quote{}},
+	{Method: http.MethodGet, Path: "/api/v1/availability", Tag: "Reservations", Summary: "Read the busy and free intervals of a source", Security: openapi.SecurityApiKey, Query: models.ReadAvailability{}, Response: models.Availability{}},
+	// Promotions
+	{Method: http.MethodGet, Path: "/api/v1/promotions", Tag: "Promotions", Summary: "List promotions", Security: openapi.SecurityApiKey, Query: models.ReadAllPromotions{}, Response: models.PaginationResponse[models.Promotion]{}},
+	{Method: http.MethodPost, Path: "/api/v1/promotions", Tag: "Promotions", Summary: "Create a promotion", Security: openapi.SecurityApiKey, Body: models.CreatePromotion{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/promotions", Tag: "Promotions", Summary: "Update a promotion", Security: openapi.SecurityApiKey, Body: models.UpdatePromotion{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/promotions/:id", Tag: "Promotions", Summary: "Read a promotion", Security: openapi.SecurityApiKey, Response: models.Promotion{}},
+	{Method: http.MethodDelete, Path: "/api/v1/promotions/:id", Tag: "Promotions", Summary: "Delete a promotion", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/v1/reports/promotions", Tag: "Promotions", Summary: "Sum up the redemptions of the promotions of a customer", Security: openapi.SecurityApiKey, Query: models.ReadPromotionReport{}, Response: []models.PromotionReport{}},
+	// Webhooks
+	{Method: http.MethodGet, Path: "/api/v1/webhooks", Tag: "Webhooks", Summary: "List webhook endpoints", Security: openapi.SecurityApiKey, Query: models.ReadAllWebhookEndpoints{}, Response: models.PaginationResponse[models.WebhookEndpoint]{}},
+	{Method: http.MethodPost, Path: "/api/v1/webhooks", Tag: "Webhooks", Summary: "Create a webhook endpoint, its signing secret is only returned here", Security: openapi.SecurityApiKey, Body: models.CreateWebhookEndpoint{}, Response: models.WebhookEndpoint{}},
+	{Method: http.MethodPatch, Path: "/api/v1/webhooks", Tag: "Webhooks", Summary: "Update a webhook endpoint or rotate its secret", Security: openapi.SecurityApiKey, Body: models.UpdateWebhookEndpoint{}, Response: models.WebhookEndpoint{}},
+	{Method: http.MethodDelete, Path: "/api/v1/webhooks/:id", Tag: "Webhooks", Summary: "Delete a webhook endpoint", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/v1/webhooks/deliveries", Tag: "Webhooks", Summary: "List webhook deliveries", Security: openapi.SecurityApiKey, Query: models.ReadAllWebhookDeliveries{}, Response: models.PaginationResponse[models.WebhookDelivery]{}},
+	{Method: http.MethodGet, Path: "/api/v1/webhooks/dead-letters", Tag: "Webhooks", Summary: "List webhook deliveries that gave up", Security: openapi.SecurityApiKey, Query: models.ReadAllWebhookDeliveries{}, Response: models.PaginationResponse[models.WebhookDelivery]{}},
+	{Method: http.MethodPost, Path: "/api/v1/webhooks/deliveries/redeliver", Tag: "Webhooks", Summary: "Deliver an event again", Security: openapi.SecurityApiKey, Body: models.RedeliverWebhook{}, Response: changedId},
+	// Calendar feeds
+	{Method: http.MethodGet, Path: "/api/v1/calendar-feeds", Tag: "Calendar feeds", Summary: "List calendar feeds", Security: openapi.SecurityApiKey, Query: models.ReadAllCalendarFeeds{}, Response: models.PaginationResponse[models.CalendarFeed]{}},
+	{Method: http.MethodPost, Path: "/api/v1/calendar-feeds", Tag: "Calendar feeds", Summary: "Create a calendar feed of a source or a reservee", Security: openapi.SecurityApiKey, Body: models.CreateCalendarFeed{}, Response: models.CalendarFeed{}},
+	{Method: http.MethodDelete, Path: "/api/v1/calendar-feeds/:id", Tag: "Calendar feeds", Summary: "Revoke a calendar feed", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+	// Sources
+	{Method: http.MethodGet, Path: "/api/v1/sources", Tag: "Sources", Summary: "List sources", Security: openapi.SecurityApiKey, Query: models.ReadAllSources{}, Response: models.PaginationResponse[models.Source]{}},
+	{Method: http.MethodPost, Path: "/api/v1/sources", Tag: "Sources", Summary: "Create a source and an api token for it", Security: openapi.SecurityApiKey, Body: models.CreateSource{}, Response: changedId},
+	{Method: http.MethodPatch, Path: "/api/v1/sources", Tag: "Sources", Summary: "Update a source", Security: openapi.SecurityApiKey, Body: models.UpdateSource{}, Response: changedId},
+	{Method: http.MethodGet, Path: "/api/v1/sources/:id", Tag: "Sources", Summary: "Read a source", Security: openapi.SecurityApiKey, Response: models.Source{}},
+	{Method: http.MethodDelete, Path: "/api/v1/sources/:id", Tag: "Sources", Summary: "Delete a source", Security: openapi.SecurityApiKey, Status: http.StatusNoContent},
+}
@@ -0,0 +1,327 @@
+// Package openapi builds the OpenAPI 3.1 document of the REST API from the
+// operations of its routes. Schemas are derived from the Go types the
+// handlers bind and return, named types become components. The document of
+// the tree is embedded as Spec, a test of the main package rebuilds it from
+// the route table and fails when the two differ.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SecurityJwt    = "jwt"
+	SecurityApiKey = "apiKey"
+)
+
+// Operation describes one route. Query and Body are values of the types the
+// handler binds from the query string and from the JSON body, Response of the
+// type it answers with. Responses that are not JSON name their ContentType.
+type Operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Security    string
+	Query       any
+	Body        any
+	Response    any
+	ContentType string
+	// Status of a successful call, 200 when zero.
+	Status int
+}
+
+// standardMethods are the methods a path item can hold. Other methods, the
+// WebDAV ones, are listed as "x-" extensions of the path item.
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPut: true, http.MethodPost: true, http.MethodDelete: true,
+	http.MethodOptions: true, http.MethodHead: true, http.MethodPatch: true, http.MethodTrace: true,
+}
+
+var pathParameter = regexp.MustCompile(`[:*](\w+)`)
+
+// builder collects the component schemas while the operations are built.
+type builder struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+// Build returns the OpenAPI document of the operations.
+func Build(title, version string, operations []Operation) map[string]any {
+	b := &builder{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+
+	paths := map[string]map[string]any{}
+	for _, operation := range operations {
+		path := pathParameter.ReplaceAllString(operation.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		method := strings.ToLower(operation.Method)
+		if !standardMethods[operation.Method] {
+			method = "x-" + method
+		}
+		paths[path][method] = b.operation(operation)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				SecurityJwt: map[string]any{
+					"type": "apiKey", "in": "header", "name": "Authorization",
+					"description": "A jwt signed with the secret of the engine, naming the customer in its customerId claim.",
+				},
+				"apiToken": map[string]any{"type": "apiKey", "in": "header", "name": "x-api-token"},
+				"apiSecret": map[string]any{
+					"type": "apiKey", "in": "header", "name": "x-api-secret",
+					"description": "Sent with x-api-token. Basic auth with the token as user and the secret as password works as well.",
+				},
+			},
+		},
+	}
+}
+
+// Marshal returns the document the way it is embedded.
+func Marshal(document map[string]any) ([]byte, error) {
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (b *builder) operation(operation Operation) map[string]any {
+	res := map[string]any{
+		"operationId": operationId(operation),
+		"summary":     operation.Summary,
+	}
+	if operation.Tag != "" {
+		res["tags"] = []string{operation.Tag}
+	}
+
+	parameters := []any{}
+	for _, match := range pathParameter.FindAllStringSubmatch(operation.Path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	if operation.Query != nil {
+		parameters = append(parameters, b.queryParameters(reflect.TypeOf(operation.Query))...)
+	}
+	if len(parameters) > 0 {
+		res["parameters"] = parameters
+	}
+
+	if operation.Body != nil {
+		res["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(operation.Body))}},
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case operation.ContentType != "":
+		success["content"] = map[string]any{operation.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+	case operation.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(operation.Response))}}
+	}
+	responses := map[string]any{
+		strconv.Itoa(status): success,
+		"400":                map[string]any{"description": "The request was not valid or could not be carried out"},
+	}
+
+	switch operation.Security {
+	case SecurityJwt:
+		res["security"] = []any{map[string]any{SecurityJwt: []string{}}}
+		responses["401"] = map[string]any{"description": "The jwt is missing or not valid"}
+	case SecurityApiKey:
+		res["security"] = []any{map[string]any{"apiToken": []string{}, "apiSecret": []string{}}}
+		responses["401"] = map[string]any{"description": "The api token and secret are missing or not valid"}
+		responses["429"] = map[string]any{"description": "The rate limit of the plan of the customer is reached"}
+	}
+	res["responses"] = responses
+	return res
+}
+
+// operationId is the method followed by the path, "getApiV1SourcesId".
+func operationId(operation Operation) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(operation.Method))
+	for _, part := range strings.FieldsFunc(operation.Path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// queryParameters maps the fields of a type bound from the query string the
+// way gin does, by their form tag or else their name, descending into
+// structs.
+func (b *builder) queryParameters(t reflect.Type) []any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	parameters := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if name == "" && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			parameters = append(parameters, b.queryParameters(fieldType)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		parameter := map[string]any{"name": name, "in": "query", "schema": b.schema(fieldType)}
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			parameter["required"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// schema returns the schema of the type, a reference for named structs.
+func (b *builder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(b.schema(t.Elem()))
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64, reflect.Uint:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.component(t)
+	}
+	return map[string]any{}
+}
+
+// nullable lets the schema be null as well.
+func nullable(schema map[string]any) map[string]any {
+	if kind, ok := schema["type"].(string); ok {
+		res := map[string]any{}
+		for key, value := range schema {
+			res[key] = value
+		}
+		res["type"] = []string{kind, "null"}
+		return res
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// component adds the struct to the components once and refers to it.
+func (b *builder) component(t reflect.Type) map[string]any {
+	name, ok := b.names[t]
+	if !ok {
+		name = componentName(t)
+		b.names[t] = name
+		// The reference is known before the properties are built, so that
+		// types referring to themselves end.
+		b.schemas[name] = b.object(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName is the name of the type without its package, type arguments
+// follow it, "PaginationResponse_Source".
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	base, arguments, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+	var res strings.Builder
+	res.WriteString(base)
+	for _, argument := range strings.Split(strings.TrimSuffix(arguments, "]"), ",") {
+		res.WriteString("_" + argument[strings.LastIndex(argument, ".")+1:])
+	}
+	return res.String()
+}
+
+// object lists the properties the struct is encoded with, the way
+// encoding/json does.
+func (b *builder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	b.properties(t, properties, &required)
+
+	res := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		res["required"] = required
+	}
+	return res
+}
+
+func (b *builder) properties(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.properties(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
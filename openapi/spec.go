@@ -0,0 +1,14 @@
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI document of the REST API, rebuild it with
+// go test -run TestOpenAPISpec -update
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Docs is the page rendering Spec for people.
+//
+//go:embed docs.html
+var Docs []byte
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/openapi"
+	"github.com/lghtr35/reservation-engine/util"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi/openapi.json")
+
+// TestOpenAPIRoutes fails when a route is registered without an operation
+// describing it, or an operation describes a route that is gone.
+func TestOpenAPIRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	registerRoutes(g, &Handler{}, util.NewRateLimiter())
+
+	described := map[string]bool{}
+	for _, operation := range operations {
+		key := operation.Method + " " + operation.Path
+		if described[key] {
+			t.Errorf("%s is described twice", key)
+		}
+		described[key] = true
+	}
+	for _, route := range g.Routes() {
+		key := route.Method + " " + route.Path
+		if !described[key] {
+			t.Errorf("%s is not described in operations", key)
+		}
+		delete(described, key)
+	}
+	for key := range described {
+		t.Errorf("%s is described but not registered", key)
+	}
+}
+
+// TestOpenAPISpec fails when the embedded document is not the one the
+// operations and models build, run it with -update after changing them.
+func TestOpenAPISpec(t *testing.T) {
+	spec, err := openapi.Marshal(openapi.Build(apiTitle, apiVersion, operations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		err = os.WriteFile("openapi/openapi.json", spec, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if !bytes.Equal(spec, openapi.Spec) {
+		t.Error("openapi/openapi.json is out of date, run go test -run TestOpenAPISpec -update")
+	}
+}
@@ -0,0 +1,126 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// FakeDeclinedMethod is declined right away by the fake provider.
+	FakeDeclinedMethod = "fake_declined"
+	// FakePendingMethod leaves the authorization pending until a webhook
+	// decides it.
+	FakePendingMethod = "fake_pending"
+	// FakeSignatureHeader carries the hex HMAC-SHA256 of the webhook body.
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+type fakePayment struct {
+	reference string
+	amount    int64
+	captured  int64
+	refunded  int64
+}
+
+// FakeProvider keeps payments in memory and accepts every payment method
+// except the Fake* ones above. It is meant for tests and local development.
+type FakeProvider struct {
+	secret   string
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: secret, payments: map[string]*fakePayment{}}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(reference string, amount int64, currency, paymentMethod string) (Authorization, error) {
+	if amount <= 0 {
+		return Authorization{}, fmt.Errorf("can not authorize an amount of %d", amount)
+	}
+	if paymentMethod == FakeDeclinedMethod {
+		return Authorization{Status: AuthorizationDeclined, DeclineReason: "card declined"}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentID := "fake_" + reference
+	if _, ok := p.payments[paymentID]; !ok {
+		p.payments[paymentID] = &fakePayment{reference: reference, amount: amount}
+	}
+
+	status := AuthorizationAuthorized
+	if paymentMethod == FakePendingMethod {
+		status = AuthorizationPending
+	}
+	return Authorization{PaymentID: paymentID, Status: status}, nil
+}
+
+func (p *FakeProvider) Capture(paymentID string, amount int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", paymentID)
+	}
+	if amount > payment.amount {
+		return fmt.Errorf("can not capture %d of an authorization of %d", amount, payment.amount)
+	}
+	payment.captured = amount
+	return nil
+}
+
+func (p *FakeProvider) Refund(paymentID string, amount int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", paymentID)
+	}
+	if payment.refunded+amount > payment.captured {
+		return fmt.Errorf("can not refund %d of a payment with %d left", amount, payment.captured-payment.refunded)
+	}
+	payment.refunded += amount
+	return nil
+}
+
+// Sign returns the signature the fake provider expects for the payload, so
+// webhooks can be sent by hand during development.
+func (p *FakeProvider) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(p.Sign(payload))
+	if !hmac.Equal(signature, expected) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	err = json.Unmarshal(payload, &event)
+	if err != nil {
+		return WebhookEvent{}, err
+	}
+	if event.ID == "" || event.PaymentID == "" {
+		return WebhookEvent{}, fmt.Errorf("webhook event is missing its id or payment id")
+	}
+	return event, nil
+}
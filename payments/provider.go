@@ -0,0 +1,65 @@
+/*
+ * Payment providers charge and refund the price of reservations.
+ */
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	AuthorizationAuthorized = "authorized"
+	AuthorizationPending    = "pending"
+	AuthorizationDeclined   = "declined"
+)
+
+const (
+	WebhookPaymentCaptured = "payment.captured"
+	WebhookPaymentFailed   = "payment.failed"
+	WebhookRefundSucceeded = "refund.succeeded"
+)
+
+var ErrInvalidSignature = errors.New("webhook signature is not valid")
+
+// NewProvider returns the provider configured by name, nil for none.
+func NewProvider(name, webhookSecret string) (PaymentProvider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "fake":
+		return NewFakeProvider(webhookSecret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %s", name)
+}
+
+// Authorization is the answer of a provider to an authorization request. A
+// pending authorization is decided later through a webhook.
+type Authorization struct {
+	PaymentID     string
+	Status        string
+	DeclineReason string
+}
+
+// WebhookEvent is a verified notification of a provider. ID is unique per
+// event and is what makes handling a redelivered event a no-op. For refunds
+// Amount is the total refunded on the payment so far.
+type WebhookEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	PaymentID string `json:"paymentId"`
+	Amount    int64  `json:"amount"`
+}
+
+// PaymentProvider is implemented by every payment service the engine can
+// charge reservations with. Amounts are in minor units of the currency and
+// reference is the id of the reservation, so providers can use it as an
+// idempotency key.
+type PaymentProvider interface {
+	Name() string
+	Authorize(reference string, amount int64, currency, paymentMethod string) (Authorization, error)
+	Capture(paymentID string, amount int64) error
+	Refund(paymentID string, amount int64) error
+	VerifyWebhook(payload []byte, header http.Header) (WebhookEvent, error)
+}
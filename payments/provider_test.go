@@ -0,0 +1,16 @@
+package payments
+
+import "testing"
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider("", "secret")
+	if err != nil || provider != nil {
+		t.Errorf("returned %v, %v without a provider", provider, err)
+	}
+	if provider, err = NewProvider("fake", "secret"); err != nil || provider.Name() != "fake" {
+		t.Errorf("returned %v, %v for the fake provider", provider, err)
+	}
+	if _, err = NewProvider("cash", "secret"); err == nil {
+		t.Error("returned a provider that does not exist")
+	}
+}
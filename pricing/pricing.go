@@ -0,0 +1,263 @@
+/*
+ * Pricing of reservations from the rates of their source.
+ */
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidateSettings checks the pricing settings of a source.
+func ValidateSettings(currency, timezone string) error {
+	if !currencyPattern.MatchString(currency) {
+		return fmt.Errorf("currency %q is not an ISO 4217 code", currency)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("timezone %q is not known", timezone)
+	}
+	return nil
+}
+
+func ValidateRate(rate models.Rate) error {
+	switch rate.Kind {
+	case models.RateKindHourly, models.RateKindDaily, models.RateKindFlat:
+	default:
+		return fmt.Errorf("rate kind %q is not one of hourly, daily or flat", rate.Kind)
+	}
+	if rate.AmountMinor < 0 {
+		return errors.New("rate amount can not be negative")
+	}
+	for _, weekday := range rate.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return fmt.Errorf("weekday %d is not between 0 (Sunday) and 6 (Saturday)", weekday)
+		}
+	}
+	if _, err := minuteOfDay(rate.StartTime, 0); err != nil {
+		return err
+	}
+	if _, err := minuteOfDay(rate.EndTime, 24*60); err != nil {
+		return err
+	}
+	return nil
+}
+
+func minuteOfDay(clock string, fallback int) (int, error) {
+	if clock == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not in 15:04 format", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches tells whether the rate applies at the given local time. Windows
+// whose end is not after their start wrap around midnight.
+func matches(rate models.Rate, t time.Time, checkWindow bool) bool {
+	if len(rate.Weekdays) > 0 {
+		found := false
+		for _, weekday := range rate.Weekdays {
+			if time.Weekday(weekday) == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !checkWindow {
+		return true
+	}
+
+	start, _ := minuteOfDay(rate.StartTime, 0)
+	end, _ := minuteOfDay(rate.EndTime, 24*60)
+	m := t.Hour()*60 + t.Minute()
+	if start < end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// best returns the index of the matching rate with the highest priority, or -1.
+func best(rates []models.Rate, t time.Time, checkWindow bool) int {
+	for i, rate := range rates {
+		if matches(rate, t, checkWindow) {
+			return i
+		}
+	}
+	return -1
+}
+
+func byKind(rates []models.Rate, kind string) []models.Rate {
+	res := make([]models.Rate, 0, len(rates))
+	for _, rate := range rates {
+		if rate.Kind == kind {
+			res = append(res, rate)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Priority > res[j].Priority })
+	return res
+}
+
+func unitsOf(rate models.Rate, units int) int64 {
+	if rate.PerUnit {
+		return int64(units)
+	}
+	return 1
+}
+
+// Quote prices booking the given units of the source between from and to.
+// Every calendar day, in the timezone of the source, is charged the cheaper of
+// its hourly charges and its daily rate, and weekend days get the surcharge of
+// the source on top. A source without rates is free.
+func Quote(source models.Source, rates []models.Rate, from, to time.Time, units int) (models.Quote, error) {
+	quote := models.Quote{SourceID: source.ID, Currency: source.Currency, Lines: []models.PriceLine{}}
+	if !to.After(from) {
+		return quote, errors.New("the reservation does not end after it starts")
+	}
+	if units < 1 {
+		units = 1
+	}
+
+	timezone := source.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return quote, err
+	}
+	from = from.In(loc)
+	to = to.In(loc)
+
+	hourly := byKind(rates, models.RateKindHourly)
+	daily := byKind(rates, models.RateKindDaily)
+	flat := byKind(rates, models.RateKindFlat)
+
+	if i := best(flat, from, true); i >= 0 {
+		rate := flat[i]
+		quote.Lines = append(quote.Lines, models.PriceLine{
+			Description: rate.Name,
+			Quantity:    unitsOf(rate, units),
+			Unit:        "reservation",
+			UnitAmount:  rate.AmountMinor,
+			Amount:      rate.AmountMinor * unitsOf(rate, units),
+		})
+	}
+
+	if len(hourly) > 0 || len(daily) > 0 {
+		dayStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+		for dayStart.Before(to) {
+			dayEnd := dayStart.AddDate(0, 0, 1)
+			lines, err := quoteDay(hourly, daily, dayStart, maxTime(from, dayStart), minTime(to, dayEnd), units)
+			if err != nil {
+				return quote, err
+			}
+
+			dayTotal := total(lines)
+			weekday := dayStart.Weekday()
+			if source.WeekendSurchargePercent > 0 && (weekday == time.Saturday || weekday == time.Sunday) && dayTotal > 0 {
+				lines = append(lines, models.PriceLine{
+					Description: fmt.Sprintf("%s weekend surcharge %d%%", dayStart.Format(time.DateOnly), source.WeekendSurchargePercent),
+					Quantity:    1,
+					Unit:        "day",
+					UnitAmount:  PercentOf(dayTotal, source.WeekendSurchargePercent),
+					Amount:      PercentOf(dayTotal, source.WeekendSurchargePercent),
+				})
+			}
+
+			quote.Lines = append(quote.Lines, lines...)
+			dayStart = dayEnd
+		}
+	}
+
+	quote.Total = total(quote.Lines)
+	return quote, nil
+}
+
+func quoteDay(hourly, daily []models.Rate, day, from, to time.Time, units int) ([]models.PriceLine, error) {
+	minutes := make([]int64, len(hourly))
+	var uncovered int64
+	for t := from; t.Before(to); t = t.Add(time.Minute) {
+		if i := best(hourly, t, true); i >= 0 {
+			minutes[i]++
+		} else {
+			uncovered++
+		}
+	}
+
+	hourlyLines := []models.PriceLine{}
+	for i, rate := range hourly {
+		if minutes[i] == 0 {
+			continue
+		}
+		// The minutes of an hourly rate rarely cost a whole amount each, so
+		// the line charges them once per unit and names them instead
+		amount := (rate.AmountMinor*minutes[i] + 30) / 60
+		hourlyLines = append(hourlyLines, models.PriceLine{
+			Description: fmt.Sprintf("%s %s, %d minutes at %d per hour", day.Format(time.DateOnly), rate.Name, minutes[i], rate.AmountMinor),
+			Quantity:    unitsOf(rate, units),
+			Unit:        "reservation",
+			UnitAmount:  amount,
+			Amount:      amount * unitsOf(rate, units),
+		})
+	}
+
+	dailyIndex := best(daily, day, false)
+	if dailyIndex < 0 {
+		if uncovered > 0 {
+			return nil, fmt.Errorf("no rate covers %s", from.Format(time.RFC3339))
+		}
+		return hourlyLines, nil
+	}
+
+	rate := daily[dailyIndex]
+	dailyLine := models.PriceLine{
+		Description: fmt.Sprintf("%s %s", day.Format(time.DateOnly), rate.Name),
+		Quantity:    unitsOf(rate, units),
+		Unit:        "day",
+		UnitAmount:  rate.AmountMinor,
+		Amount:      rate.AmountMinor * unitsOf(rate, units),
+	}
+	if uncovered > 0 || dailyLine.Amount < total(hourlyLines) {
+		return []models.PriceLine{dailyLine}, nil
+	}
+	return hourlyLines, nil
+}
+
+func total(lines []models.PriceLine) int64 {
+	var sum int64
+	for _, line := range lines {
+		sum += line.Amount
+	}
+	return sum
+}
+
+// PercentOf rounds half up, amounts are always in minor units.
+func PercentOf(amount int64, percent int) int64 {
+	return (amount*int64(percent) + 50) / 100
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,58 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+func TestQuote(t *testing.T) {
+	monday := time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, time.October, 17, 9, 0, 0, 0, time.UTC)
+	hourly := models.Rate{Name: "standard", Kind: models.RateKindHourly, AmountMinor: 1000}
+	odd := models.Rate{Name: "odd", Kind: models.RateKindHourly, AmountMinor: 999}
+	perUnit := models.Rate{Name: "per seat", Kind: models.RateKindHourly, AmountMinor: 1000, PerUnit: true}
+	evening := models.Rate{Name: "evening", Kind: models.RateKindHourly, AmountMinor: 2000, StartTime: "18:00", Priority: 1}
+	daily := models.Rate{Name: "day", Kind: models.RateKindDaily, AmountMinor: 5000, PerUnit: true}
+	flat := models.Rate{Name: "cleaning", Kind: models.RateKindFlat, AmountMinor: 700}
+
+	for _, test := range []struct {
+		name      string
+		surcharge int
+		rates     []models.Rate
+		from      time.Time
+		duration  time.Duration
+		units     int
+		total     int64
+	}{
+		{name: "whole hours", rates: []models.Rate{hourly}, from: monday, duration: 2 * time.Hour, units: 1, total: 2000},
+		{name: "part of an hour", rates: []models.Rate{hourly}, from: monday, duration: 90 * time.Minute, units: 1, total: 1500},
+		{name: "rounded minutes", rates: []models.Rate{odd}, from: monday, duration: 50 * time.Minute, units: 1, total: 833},
+		{name: "per unit", rates: []models.Rate{perUnit}, from: monday, duration: 90 * time.Minute, units: 3, total: 4500},
+		{name: "two windows", rates: []models.Rate{hourly, evening}, from: monday.Add(8 * time.Hour), duration: 2 * time.Hour, units: 1, total: 3000},
+		{name: "daily per unit", rates: []models.Rate{hourly, daily}, from: monday, duration: 12 * time.Hour, units: 2, total: 10000},
+		{name: "flat", rates: []models.Rate{hourly, flat}, from: monday, duration: time.Hour, units: 1, total: 1700},
+		{name: "weekend", surcharge: 10, rates: []models.Rate{hourly}, from: saturday, duration: 90 * time.Minute, units: 1, total: 1650},
+		{name: "over midnight", rates: []models.Rate{odd}, from: monday.Add(14*time.Hour + 30*time.Minute), duration: time.Hour, units: 1, total: 1000},
+	} {
+		source := models.Source{Currency: "EUR", Timezone: "UTC", WeekendSurchargePercent: test.surcharge}
+		quote, err := Quote(source, test.rates, test.from, test.from.Add(test.duration), test.units)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if quote.Total != test.total {
+			t.Errorf("%s: total is %d, want %d in %+v", test.name, quote.Total, test.total, quote.Lines)
+		}
+		var sum int64
+		for _, line := range quote.Lines {
+			if line.Quantity*line.UnitAmount != line.Amount {
+				t.Errorf("%s: %d %s at %d is not %d", test.name, line.Quantity, line.Unit, line.UnitAmount, line.Amount)
+			}
+			sum += line.Amount
+		}
+		if sum != quote.Total {
+			t.Errorf("%s: lines add up to %d, the total is %d", test.name, sum, quote.Total)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+func ValidatePromotion(promotion models.Promotion) error {
+	switch promotion.Kind {
+	case models.PromotionKindPercentage:
+		if promotion.PercentOff <= 0 || promotion.PercentOff > 100 {
+			return fmt.Errorf("percent off %d is not between 1 and 100", promotion.PercentOff)
+		}
+	case models.PromotionKindFixed:
+		if promotion.AmountOffMinor <= 0 {
+			return errors.New("amount off has to be positive")
+		}
+		if !currencyPattern.MatchString(promotion.Currency) {
+			return fmt.Errorf("currency %q is not an ISO 4217 code", promotion.Currency)
+		}
+	default:
+		return fmt.Errorf("promotion kind %q is not one of percentage or fixed", promotion.Kind)
+	}
+	if promotion.MaxRedemptions < 0 {
+		return errors.New("max redemptions can not be negative")
+	}
+	if promotion.ValidFrom != nil && promotion.ValidUntil != nil && !promotion.ValidUntil.After(*promotion.ValidFrom) {
+		return errors.New("promotion is not valid until after it is valid from")
+	}
+	return nil
+}
+
+// CheckPromotion tells whether the promotion can be redeemed now for a
+// reservation of the source. firstTime is whether the reservee has never
+// reserved before.
+func CheckPromotion(promotion models.Promotion, sourceId string, now time.Time, firstTime bool) error {
+	if !promotion.Active {
+		return fmt.Errorf("promotion %s is not active", promotion.Code)
+	}
+	if promotion.ValidFrom != nil && now.Before(*promotion.ValidFrom) {
+		return fmt.Errorf("promotion %s is not valid yet", promotion.Code)
+	}
+	if promotion.ValidUntil != nil && !now.Before(*promotion.ValidUntil) {
+		return fmt.Errorf("promotion %s is not valid anymore", promotion.Code)
+	}
+	if len(promotion.SourceIDs) > 0 && !slices.Contains(promotion.SourceIDs, sourceId) {
+		return fmt.Errorf("promotion %s is not valid for source %s", promotion.Code, sourceId)
+	}
+	if promotion.FirstTimeOnly && !firstTime {
+		return fmt.Errorf("promotion %s is only valid for first-time reservees", promotion.Code)
+	}
+	if promotion.MaxRedemptions > 0 && promotion.RedemptionCount >= promotion.MaxRedemptions {
+		return fmt.Errorf("promotion %s has been redeemed too often", promotion.Code)
+	}
+	return nil
+}
+
+// ApplyPromotion adds the discount of the promotion to the quote as a negative
+// line and returns the discounted amount. The discount never exceeds the total.
+func ApplyPromotion(quote *models.Quote, promotion models.Promotion) (int64, error) {
+	var discount int64
+	switch promotion.Kind {
+	case models.PromotionKindPercentage:
+		discount = PercentOf(quote.Total, promotion.PercentOff)
+	case models.PromotionKindFixed:
+		if promotion.Currency != quote.Currency {
+			return 0, fmt.Errorf("promotion %s is in %s but the price is in %s", promotion.Code, promotion.Currency, quote.Currency)
+		}
+		discount = promotion.AmountOffMinor
+	}
+	discount = min(discount, quote.Total)
+
+	quote.Lines = append(quote.Lines, models.PriceLine{
+		Description: fmt.Sprintf("Discount %s", promotion.Code),
+		Quantity:    1,
+		Unit:        "reservation",
+		UnitAmount:  -discount,
+		Amount:      -discount,
+	})
+	quote.Total -= discount
+	return discount, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// maxAvailabilityWindow bounds the window one availability query looks at.
+const maxAvailabilityWindow = 93 * 24 * time.Hour
+
+// AvailabilityQuery returns the busy and free intervals of a source between
+// from and to. Every reservation that holds its slot makes the source busy,
+// the same as the overlap check of new reservations.
+type AvailabilityQuery struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	sourceId string
+	from     time.Time
+	to       time.Time
+}
+
+func NewAvailabilityQuery(db *gorm.DB, logger *zerolog.Logger, sourceId string, from, to time.Time) *AvailabilityQuery {
+	return &AvailabilityQuery{db: db, logger: logger, sourceId: sourceId, from: from, to: to}
+}
+
+func (s *AvailabilityQuery) Execute() (any, error) {
+	if s.sourceId == "" {
+		return models.Availability{}, errors.New("AvailabilityQuery: missing source id")
+	}
+	if !s.to.After(s.from) {
+		return models.Availability{}, errors.New("AvailabilityQuery: The window does not end after it starts")
+	}
+	if s.to.Sub(s.from) > maxAvailabilityWindow {
+		return models.Availability{}, fmt.Errorf("AvailabilityQuery: The window is longer than %s", maxAvailabilityWindow)
+	}
+	s.logger.Debug().Msg("AvailabilityQuery: Started")
+
+	var source models.Source
+	res := s.db.First(&source, "id = ?", s.sourceId)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return models.Availability{}, fmt.Errorf("AvailabilityQuery: Could not find the source with this id: %s", s.sourceId)
+		}
+		return models.Availability{}, res.Error
+	}
+
+	var reservations []models.Reservation
+	res = s.db.Where(`source_id = ? AND status NOT IN ? AND "from" < ? AND "to" > ?`, source.ID, models.ReleasedReservationStatuses, s.to, s.from).
+		Order(`"from"`).
+		Find(&reservations)
+	if res.Error != nil {
+		return models.Availability{}, res.Error
+	}
+
+	availability := models.Availability{SourceID: source.ID, From: s.from, To: s.to, Busy: []models.Interval{}, Free: []models.Interval{}}
+	for _, reservation := range reservations {
+		from, to := maxTime(reservation.From, s.from), minTime(reservation.To, s.to)
+		last := len(availability.Busy) - 1
+		if last >= 0 && !from.After(availability.Busy[last].To) {
+			availability.Busy[last].To = maxTime(availability.Busy[last].To, to)
+			continue
+		}
+		availability.Busy = append(availability.Busy, models.Interval{From: from, To: to})
+	}
+
+	free := s.from
+	for _, busy := range availability.Busy {
+		if busy.From.After(free) {
+			availability.Free = append(availability.Free, models.Interval{From: free, To: busy.From})
+		}
+		free = busy.To
+	}
+	if s.to.After(free) {
+		availability.Free = append(availability.Free, models.Interval{From: free, To: s.to})
+	}
+
+	s.logger.Debug().Msg("AvailabilityQuery: Finished with success")
+	return availability, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestAvailabilityQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		reservation := func(from, to int, status string) *models.Reservation {
+			return &models.Reservation{From: repotest.At(from), To: repotest.At(to), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: status}
+		}
+		// The first two overlap, the cancelled one does not hold its slot and
+		// the last one starts before the window.
+		f.Add(t,
+			reservation(9, 11, models.ReservationStatusConfirmed),
+			reservation(10, 12, models.ReservationStatusPendingApproval),
+			reservation(13, 14, models.ReservationStatusCancelled),
+			reservation(6, 9, models.ReservationStatusConfirmed),
+		)
+
+		res, err := NewAvailabilityQuery(f.DB, f.Logger, room.ID, repotest.At(8), repotest.At(16)).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		availability := res.(models.Availability)
+		interval := func(from, to int) models.Interval {
+			return models.Interval{From: repotest.At(from), To: repotest.At(to)}
+		}
+		equal := func(a, b models.Interval) bool { return a.From.Equal(b.From) && a.To.Equal(b.To) }
+		if want := []models.Interval{interval(8, 12)}; !slices.EqualFunc(availability.Busy, want, equal) {
+			t.Errorf("busy %v, want %v", availability.Busy, want)
+		}
+		if want := []models.Interval{interval(12, 16)}; !slices.EqualFunc(availability.Free, want, equal) {
+			t.Errorf("free %v, want %v", availability.Free, want)
+		}
+
+		for _, test := range []struct {
+			name     string
+			sourceId string
+			from, to time.Time
+		}{
+			{name: "no source", from: repotest.At(8), to: repotest.At(16)},
+			{name: "missing source", sourceId: models.NewUUID(), from: repotest.At(8), to: repotest.At(16)},
+			{name: "empty window", sourceId: room.ID, from: repotest.At(8), to: repotest.At(8)},
+			{name: "long window", sourceId: room.ID, from: repotest.At(0), to: repotest.At(94 * 24)},
+		} {
+			if _, err = NewAvailabilityQuery(f.DB, f.Logger, test.sourceId, test.from, test.to).Execute(); err == nil {
+				t.Errorf("%s: returned the availability", test.name)
+			}
+		}
+	})
+}
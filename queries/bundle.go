@@ -0,0 +1,42 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type ReadBundleQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadBundleQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadBundleQuery {
+	return &ReadBundleQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadBundleQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.Bundle{}, errors.New("ReadBundleQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadBundleQuery: ReadOne started")
+
+	var bundle models.Bundle
+	res := s.db.Model(models.Bundle{}).Preload("Reservations").First(&bundle, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadBundleQuery: Could not find the bundle with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadBundleQuery: ReadOne finished with success")
+	return bundle, nil
+}
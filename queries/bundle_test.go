@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestReadBundleQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		hall := f.Source(t, customer, "hall")
+		alice := f.Person(t, customer, "alice")
+		bundle := models.Bundle{}
+		f.Add(t, &bundle)
+		f.Add(t,
+			&models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed, BundleID: &bundle.ID},
+			&models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: hall.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed, BundleID: &bundle.ID},
+			&models.Reservation{From: repotest.At(11), To: repotest.At(12), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed},
+		)
+
+		res, err := NewReadBundleQuery(f.DB, f.Logger, bundle.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.Bundle); got.ID != bundle.ID || len(got.Reservations) != 2 {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadBundleQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read a bundle without an id")
+		}
+		if _, err = NewReadBundleQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read a bundle that does not exist")
+		}
+	})
+}
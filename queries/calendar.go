@@ -0,0 +1,143 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/calendar"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// calendarFeedHistory is how long past reservations stay in a feed.
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+type FilterCalendarFeedsQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID string
+	models.Pagination
+}
+
+func NewFilterCalendarFeedsQuery(db *gorm.DB, logger *zerolog.Logger, customerID string, pagination models.Pagination) *FilterCalendarFeedsQuery {
+	return &FilterCalendarFeedsQuery{db: db, logger: logger, customerID: customerID, Pagination: pagination}
+}
+
+func (s *FilterCalendarFeedsQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return models.NewPaginationResponse([]models.CalendarFeed{}, 0, 0), errors.New("FilterCalendarFeedsQuery: missing customer id")
+	}
+	s.logger.Debug().Msg("FilterCalendarFeedsQuery: Started")
+	q := s.db.Model(models.CalendarFeed{}).Where("customer_id = ?", s.customerID)
+	offset := s.Pagination.Offset()
+
+	var feeds []models.CalendarFeed
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&feeds)
+	if res.Error != nil {
+		return models.NewPaginationResponse(feeds, 0, 0), res.Error
+	}
+	for i := range feeds {
+		feeds[i].Path = models.CalendarFeedPath(feeds[i].Token)
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(feeds, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterCalendarFeedsQuery: Finished with success")
+	return models.NewPaginationResponse(feeds, totalCount, s.Page), nil
+}
+
+// CalendarFeedQuery renders the feed with the token as an iCalendar document.
+// It holds the reservations that ended less than 90 days before now,
+// cancelled ones included.
+type CalendarFeedQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	token  string
+	now    time.Time
+}
+
+func NewCalendarFeedQuery(db *gorm.DB, logger *zerolog.Logger, token string, now time.Time) *CalendarFeedQuery {
+	return &CalendarFeedQuery{db: db, logger: logger, token: token, now: now}
+}
+
+func (s *CalendarFeedQuery) Execute() (any, error) {
+	if s.token == "" {
+		return nil, errors.New("CalendarFeedQuery: missing token")
+	}
+	s.logger.Debug().Msg("CalendarFeedQuery: Started")
+
+	var feed models.CalendarFeed
+	res := s.db.Where("token = ?", s.token).Limit(1).Find(&feed)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("CalendarFeedQuery: Could not find a calendar feed with this token")
+	}
+
+	q := s.db.Model(models.Reservation{}).Where(`"to" >= ?`, s.now.Add(-calendarFeedHistory))
+	var name string
+	switch feed.Kind {
+	case models.CalendarFeedKindSource:
+		var source models.Source
+		res = s.db.First(&source, "id = ?", feed.SourceID)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		q = q.Where("source_id = ?", source.ID)
+		name = source.Name
+	case models.CalendarFeedKindReservee:
+		var person models.Person
+		res = s.db.First(&person, "id = ?", feed.PersonID)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		q = q.Where("reservee_id = ?", person.ID)
+		name = person.Name
+	default:
+		return nil, fmt.Errorf("CalendarFeedQuery: Unknown calendar feed kind %s", feed.Kind)
+	}
+
+	var reservations []models.Reservation
+	res = q.Order(`"from"`).Find(&reservations)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	sources := make(map[string]models.Source)
+	events := make([]calendar.Event, 0, len(reservations))
+	for _, reservation := range reservations {
+		source, ok := sources[reservation.SourceID]
+		if !ok {
+			res = s.db.First(&source, "id = ?", reservation.SourceID)
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			sources[source.ID] = source
+		}
+		event, err := calendar.ReservationEvent(reservation, source)
+		if err != nil {
+			return nil, fmt.Errorf("CalendarFeedQuery: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	var buffer bytes.Buffer
+	err := calendar.Write(&buffer, name, events, s.now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug().Msg("CalendarFeedQuery: Finished with success")
+	return buffer.Bytes(), nil
+}
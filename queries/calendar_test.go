@@ -0,0 +1,87 @@
+package queries
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/calendar"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterCalendarFeedsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		room := f.Source(t, acme, "room")
+		hall := f.Source(t, other, "hall")
+		f.Add(t,
+			&models.CalendarFeed{CustomerID: acme.ID, Kind: models.CalendarFeedKindSource, SourceID: &room.ID, Token: "acme-token"},
+			&models.CalendarFeed{CustomerID: other.ID, Kind: models.CalendarFeedKindSource, SourceID: &hall.ID, Token: "other-token"},
+		)
+
+		res, err := NewFilterCalendarFeedsQuery(f.DB, f.Logger, acme.ID, models.Pagination{Page: 1, Size: 10}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := res.(models.PaginationResponse[models.CalendarFeed])
+		if page.Total != 1 || len(page.Content) != 1 || page.Content[0].Token != "acme-token" || page.Content[0].Path != models.CalendarFeedPath("acme-token") {
+			t.Errorf("returned %+v", page)
+		}
+		if _, err = NewFilterCalendarFeedsQuery(f.DB, f.Logger, "", models.Pagination{Page: 1, Size: 10}).Execute(); err == nil {
+			t.Error("listed the feeds of every customer")
+		}
+	})
+}
+
+func TestCalendarFeedQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		hall := f.Source(t, customer, "hall")
+		alice := f.Person(t, customer, "alice")
+		bob := f.Person(t, customer, "bob")
+		reservation := func(source models.Source, reservee models.Person, from, to int, status string) *models.Reservation {
+			return &models.Reservation{From: repotest.At(from), To: repotest.At(to), SourceID: source.ID, ReserverID: alice.ID, ReserveeID: reservee.ID, Status: status}
+		}
+		confirmed := reservation(room, alice, 9, 10, models.ReservationStatusConfirmed)
+		cancelled := reservation(room, bob, 11, 12, models.ReservationStatusCancelled)
+		old := reservation(room, alice, -100*24, -100*24+1, models.ReservationStatusConfirmed)
+		elsewhere := reservation(hall, bob, 9, 10, models.ReservationStatusConfirmed)
+		f.Add(t, confirmed, cancelled, old, elsewhere,
+			&models.CalendarFeed{CustomerID: customer.ID, Kind: models.CalendarFeedKindSource, SourceID: &room.ID, Token: "room-token"},
+			&models.CalendarFeed{CustomerID: customer.ID, Kind: models.CalendarFeedKindReservee, PersonID: &bob.ID, Token: "bob-token"},
+		)
+
+		for _, test := range []struct {
+			token, name string
+			want, not   []*models.Reservation
+		}{
+			{token: "room-token", name: "room", want: []*models.Reservation{confirmed, cancelled}, not: []*models.Reservation{old, elsewhere}},
+			{token: "bob-token", name: "bob", want: []*models.Reservation{cancelled, elsewhere}, not: []*models.Reservation{confirmed, old}},
+		} {
+			res, err := NewCalendarFeedQuery(f.DB, f.Logger, test.token, repotest.At(0)).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			document := string(res.([]byte))
+			if !strings.Contains(document, "X-WR-CALNAME:"+test.name) {
+				t.Errorf("%s: rendered a feed of another name:\n%s", test.name, document)
+			}
+			for _, reservation := range test.want {
+				if !strings.Contains(document, "UID:"+calendar.UID(reservation.ID)) {
+					t.Errorf("%s: left out the reservation %s", test.name, reservation.ID)
+				}
+			}
+			for _, reservation := range test.not {
+				if strings.Contains(document, "UID:"+calendar.UID(reservation.ID)) {
+					t.Errorf("%s: held the reservation %s", test.name, reservation.ID)
+				}
+			}
+		}
+
+		if _, err := NewCalendarFeedQuery(f.DB, f.Logger, "unknown", repotest.At(0)).Execute(); err == nil {
+			t.Error("rendered a feed that does not exist")
+		}
+	})
+}
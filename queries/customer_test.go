@@ -0,0 +1,98 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// names returns the names of the rows in the page.
+func names[T models.Customer | models.Source | models.Person | models.Plan | models.Rate | models.CancellationPolicy](t *testing.T, res any, total int64) []string {
+	t.Helper()
+	page, ok := res.(models.PaginationResponse[T])
+	if !ok {
+		t.Fatalf("returned %T", res)
+	}
+	if page.Total != total || page.Count != len(page.Content) {
+		t.Errorf("returned %d of %d, want a total of %d", page.Count, page.Total, total)
+	}
+	var names []string
+	for _, row := range page.Content {
+		switch row := any(row).(type) {
+		case models.Customer:
+			names = append(names, row.Name)
+		case models.Source:
+			names = append(names, row.Name)
+		case models.Person:
+			names = append(names, row.Name)
+		case models.Plan:
+			names = append(names, row.Name)
+		case models.Rate:
+			names = append(names, row.Name)
+		case models.CancellationPolicy:
+			names = append(names, row.Name)
+		}
+	}
+	return names
+}
+
+func TestFilterCustomersQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		alice := f.Customer(t, "alice", nil)
+		f.Customer(t, "bob", nil)
+		carol := f.Customer(t, "carol", nil)
+
+		for _, test := range []struct {
+			name       string
+			ids        *[]string
+			search     *string
+			pagination models.Pagination
+			want       []string
+			total      int64
+		}{
+			{name: "everything", pagination: models.Pagination{Page: 1, Size: 10}, want: []string{"alice", "bob", "carol"}, total: 3},
+			{name: "ids", ids: &[]string{alice.ID, carol.ID}, pagination: models.Pagination{Page: 1, Size: 10}, want: []string{"alice", "carol"}, total: 2},
+			{name: "name", search: ptr("o"), pagination: models.Pagination{Page: 1, Size: 10}, want: []string{"bob", "carol"}, total: 2},
+			{name: "page", pagination: models.Pagination{Page: 2, Size: 2}, want: []string{"carol"}, total: 3},
+			{name: "past the end", pagination: models.Pagination{Page: 3, Size: 2}, total: 3},
+		} {
+			res, err := NewFilterCustomersQuery(f, f.Logger, test.ids, test.search, test.pagination).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.Customer](t, res, test.total); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+	})
+}
+
+func TestReadCustomerQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		f.Add(t, &models.Secret{CustomerID: customer.ID, Value: "secret"})
+
+		if _, err := NewReadCustomerQuery(f, f.Logger, "").Execute(); err == nil {
+			t.Error("read a customer without an id")
+		}
+		if _, err := NewReadCustomerQuery(f, f.Logger, "00000000-0000-0000-0000-000000000000").Execute(); err == nil {
+			t.Error("read a customer that does not exist")
+		}
+
+		res, err := NewReadCustomerQuery(f, f.Logger, customer.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := res.(models.Customer)
+		if read.ID != customer.ID || read.Name != "alice" || read.Secret.Value != "secret" || len(read.Sources) != 1 || read.Sources[0].ID != source.ID {
+			t.Errorf("returned %+v", read)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
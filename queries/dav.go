@@ -0,0 +1,197 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/calendar"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// CalendarCollectionsQuery returns the sources of the customer as calendar
+// collections, only the one with sourceId when it is given.
+type CalendarCollectionsQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	sourceId   string
+}
+
+func NewCalendarCollectionsQuery(db *gorm.DB, logger *zerolog.Logger, customerId, sourceId string) *CalendarCollectionsQuery {
+	return &CalendarCollectionsQuery{db: db, logger: logger, customerId: customerId, sourceId: sourceId}
+}
+
+func (s *CalendarCollectionsQuery) Execute() (any, error) {
+	if s.customerId == "" {
+		return nil, errors.New("CalendarCollectionsQuery: missing customer id")
+	}
+	s.logger.Debug().Msg("CalendarCollectionsQuery: Started")
+
+	q := s.db.Where("customer_id = ?", s.customerId)
+	if s.sourceId != "" {
+		if !util.IsUUID(s.sourceId) {
+			return []models.CalendarCollection{}, nil
+		}
+		q = q.Where("id = ?", s.sourceId)
+	}
+	var sources []models.Source
+	res := q.Order("name").Find(&sources)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	collections := make([]models.CalendarCollection, 0, len(sources))
+	for _, source := range sources {
+		var count int64
+		res = s.db.Model(models.Reservation{}).Where("source_id = ?", source.ID).Count(&count)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		// The latest change is read as a row rather than as MAX(updated_at),
+		// SQLite returns aggregates of times as text.
+		var changed []models.Reservation
+		res = s.db.Select("updated_at").Where("source_id = ?", source.ID).Order("updated_at DESC").Limit(1).Find(&changed)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		ctag := fmt.Sprintf("%d-%d", source.UpdatedAt.UnixMicro(), count)
+		if len(changed) > 0 {
+			ctag += fmt.Sprintf("-%d", changed[0].UpdatedAt.UnixMicro())
+		}
+		collections = append(collections, models.CalendarCollection{Source: source, CTag: ctag})
+	}
+
+	s.logger.Debug().Msg("CalendarCollectionsQuery: Finished with success")
+	return collections, nil
+}
+
+// CalendarObjectsQuery returns the reservations of a source that hold their
+// slot as calendar object resources. Names limits them to the resources with
+// these names, from and to to the ones overlapping the range. The iCalendar
+// data is only rendered when withData is set.
+type CalendarObjectsQuery struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	source   models.Source
+	names    []string
+	from     *time.Time
+	to       *time.Time
+	withData bool
+	now      time.Time
+}
+
+func NewCalendarObjectsQuery(db *gorm.DB, logger *zerolog.Logger, source models.Source, names []string, from, to *time.Time, withData bool, now time.Time) *CalendarObjectsQuery {
+	return &CalendarObjectsQuery{db: db, logger: logger, source: source, names: names, from: from, to: to, withData: withData, now: now}
+}
+
+func (s *CalendarObjectsQuery) Execute() (any, error) {
+	if s.source.ID == "" {
+		return nil, errors.New("CalendarObjectsQuery: missing source")
+	}
+	s.logger.Debug().Msg("CalendarObjectsQuery: Started")
+
+	q := s.db.Where("source_id = ? AND status NOT IN ?", s.source.ID, models.ReleasedReservationStatuses)
+	if s.names != nil {
+		ids, err := s.resolve()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return []models.CalendarObjectResource{}, nil
+		}
+		q = q.Where("id IN ?", ids)
+	}
+	if s.from != nil {
+		q = q.Where(`"to" > ?`, *s.from)
+	}
+	if s.to != nil {
+		q = q.Where(`"from" < ?`, *s.to)
+	}
+
+	var reservations []models.Reservation
+	res := q.Order(`"from"`).Find(&reservations)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	ids := make([]string, 0, len(reservations))
+	for _, reservation := range reservations {
+		ids = append(ids, reservation.ID)
+	}
+	var objects []models.CalendarObject
+	res = s.db.Where("reservation_id IN ?", ids).Find(&objects)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	byReservation := make(map[string]models.CalendarObject, len(objects))
+	for _, object := range objects {
+		byReservation[object.ReservationID] = object
+	}
+
+	resources := make([]models.CalendarObjectResource, 0, len(reservations))
+	for _, reservation := range reservations {
+		resource := models.CalendarObjectResource{
+			Name:          reservation.ID + ".ics",
+			UID:           calendar.UID(reservation.ID),
+			ETag:          reservation.ETag(),
+			ReservationID: reservation.ID,
+		}
+		if object, ok := byReservation[reservation.ID]; ok {
+			resource.Name = object.Name
+			resource.UID = object.UID
+		}
+		// A reservation with a remembered name is not served under its id.
+		if s.names != nil && !slices.Contains(s.names, resource.Name) {
+			continue
+		}
+
+		if s.withData {
+			event, err := calendar.ReservationEvent(reservation, s.source)
+			if err != nil {
+				return nil, fmt.Errorf("CalendarObjectsQuery: %w", err)
+			}
+			event.UID = resource.UID
+			var buffer bytes.Buffer
+			err = calendar.Write(&buffer, s.source.Name, []calendar.Event{event}, s.now)
+			if err != nil {
+				return nil, err
+			}
+			resource.Data = buffer.Bytes()
+		}
+		resources = append(resources, resource)
+	}
+
+	s.logger.Debug().Msg("CalendarObjectsQuery: Finished with success")
+	return resources, nil
+}
+
+// resolve returns the ids of the reservations the names stand for, a name is
+// either remembered from a client or the id of the reservation.
+func (s *CalendarObjectsQuery) resolve() ([]string, error) {
+	var objects []models.CalendarObject
+	res := s.db.Where("source_id = ? AND name IN ?", s.source.ID, s.names).Find(&objects)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	ids := make([]string, 0, len(s.names))
+	for _, object := range objects {
+		ids = append(ids, object.ReservationID)
+	}
+	for _, name := range s.names {
+		id, ok := strings.CutSuffix(name, ".ics")
+		if ok && util.IsUUID(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
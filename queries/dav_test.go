@@ -0,0 +1,115 @@
+package queries
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestCalendarCollectionsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		f.Source(t, customer, "hall")
+		alice := f.Person(t, customer, "alice")
+		other := f.Customer(t, "other", nil)
+		elsewhere := f.Source(t, other, "elsewhere")
+		collections := func(customerId, sourceId string) []models.CalendarCollection {
+			t.Helper()
+			res, err := NewCalendarCollectionsQuery(f.DB, f.Logger, customerId, sourceId).Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.([]models.CalendarCollection)
+		}
+
+		var got []string
+		for _, collection := range collections(customer.ID, "") {
+			got = append(got, collection.Source.Name)
+		}
+		if !slices.Equal(got, []string{"hall", "room"}) {
+			t.Errorf("returned %v", got)
+		}
+		for _, sourceId := range []string{elsewhere.ID, "room"} {
+			if got := collections(customer.ID, sourceId); len(got) != 0 {
+				t.Errorf("returned %+v for the source %s", got, sourceId)
+			}
+		}
+
+		// The CTag changes with the reservations of the source.
+		before := collections(customer.ID, room.ID)
+		f.Add(t, &models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed})
+		after := collections(customer.ID, room.ID)
+		if len(before) != 1 || len(after) != 1 || before[0].CTag == after[0].CTag {
+			t.Errorf("returned %+v before the reservation and %+v after it", before, after)
+		}
+
+		if _, err := NewCalendarCollectionsQuery(f.DB, f.Logger, "", "").Execute(); err == nil {
+			t.Error("returned the collections of every customer")
+		}
+	})
+}
+
+func TestCalendarObjectsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		reservation := func(from, to int, status string) *models.Reservation {
+			return &models.Reservation{From: repotest.At(from), To: repotest.At(to), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: status}
+		}
+		named := reservation(9, 10, models.ReservationStatusConfirmed)
+		unnamed := reservation(11, 12, models.ReservationStatusConfirmed)
+		cancelled := reservation(13, 14, models.ReservationStatusCancelled)
+		f.Add(t, named, unnamed, cancelled)
+		f.Add(t, &models.CalendarObject{SourceID: room.ID, Name: "client.ics", ReservationID: named.ID, UID: "client-uid"})
+		objects := func(names []string, from, to *time.Time, withData bool) []models.CalendarObjectResource {
+			t.Helper()
+			res, err := NewCalendarObjectsQuery(f.DB, f.Logger, room, names, from, to, withData, repotest.At(0)).Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.([]models.CalendarObjectResource)
+		}
+		names := func(resources []models.CalendarObjectResource) []string {
+			var names []string
+			for _, resource := range resources {
+				names = append(names, resource.Name)
+			}
+			return names
+		}
+
+		for _, test := range []struct {
+			name     string
+			names    []string
+			from, to *time.Time
+			want     []string
+		}{
+			{name: "everything", want: []string{"client.ics", unnamed.ID + ".ics"}},
+			{name: "remembered name", names: []string{"client.ics"}, want: []string{"client.ics"}},
+			{name: "id of a remembered name", names: []string{named.ID + ".ics"}},
+			{name: "id", names: []string{unnamed.ID + ".ics", cancelled.ID + ".ics", "unknown.ics"}, want: []string{unnamed.ID + ".ics"}},
+			{name: "range", from: ptr(repotest.At(10)), to: ptr(repotest.At(14)), want: []string{unnamed.ID + ".ics"}},
+		} {
+			if got := names(objects(test.names, test.from, test.to, false)); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v, want %v", test.name, got, test.want)
+			}
+		}
+
+		resources := objects([]string{"client.ics"}, nil, nil, true)
+		if len(resources) != 1 || resources[0].UID != "client-uid" || resources[0].ETag != named.ETag() || !strings.Contains(string(resources[0].Data), "UID:client-uid") {
+			t.Errorf("returned %+v", resources)
+		}
+		if resources = objects(nil, nil, nil, false); resources[0].Data != nil {
+			t.Error("rendered the data without being asked to")
+		}
+
+		if _, err := NewCalendarObjectsQuery(f.DB, f.Logger, models.Source{}, nil, nil, nil, false, repotest.At(0)).Execute(); err == nil {
+			t.Error("returned the objects of no source")
+		}
+	})
+}
@@ -0,0 +1,92 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// ExportQuery reads the data of the customers, of every customer when no
+// ids are given, as one models.Export.
+type ExportQuery struct {
+	db          *gorm.DB
+	logger      *zerolog.Logger
+	customerIds []string
+	now         time.Time
+}
+
+func NewExportQuery(db *gorm.DB, logger *zerolog.Logger, customerIds []string, now time.Time) *ExportQuery {
+	return &ExportQuery{db: db, logger: logger, customerIds: customerIds, now: now}
+}
+
+func (s *ExportQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("ExportQuery: Started")
+	export := models.Export{Version: models.ExportVersion, ExportedAt: s.now}
+
+	// A repeatable read keeps the tables consistent with each other while
+	// they are read one by one.
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Order("created_at")
+		if len(s.customerIds) > 0 {
+			q = q.Where("id IN ?", s.customerIds)
+		}
+		res := q.Find(&export.Customers)
+		if res.Error != nil {
+			return res.Error
+		}
+		customerIds := make([]string, 0, len(export.Customers))
+		for _, customer := range export.Customers {
+			customerIds = append(customerIds, customer.ID)
+		}
+
+		for _, table := range []any{&export.Secrets, &export.Persons, &export.CancellationPolicies, &export.Promotions, &export.Sources, &export.ApiTokens} {
+			res = tx.Where("customer_id IN ?", customerIds).Order("created_at").Find(table)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		sourceIds := make([]string, 0, len(export.Sources))
+		for _, source := range export.Sources {
+			sourceIds = append(sourceIds, source.ID)
+		}
+
+		for _, table := range []any{&export.Rates, &export.Reservations} {
+			res = tx.Where("source_id IN ?", sourceIds).Order("created_at").Find(table)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		reservationIds := make([]string, 0, len(export.Reservations))
+		bundleIds := []string{}
+		for _, reservation := range export.Reservations {
+			reservationIds = append(reservationIds, reservation.ID)
+			if reservation.BundleID != nil {
+				bundleIds = append(bundleIds, *reservation.BundleID)
+			}
+		}
+
+		res = tx.Where("id IN ?", bundleIds).Order("created_at").Find(&export.Bundles)
+		if res.Error != nil {
+			return res.Error
+		}
+		for _, table := range []any{&export.Participants, &export.Fees, &export.Payments} {
+			res = tx.Where("reservation_id IN ?", reservationIds).Order("created_at").Find(table)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return models.Export{}, err
+	}
+
+	s.logger.Debug().Msg("ExportQuery: Finished with success")
+	return export, nil
+}
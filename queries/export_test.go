@@ -0,0 +1,47 @@
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestExportQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		bundle := models.Bundle{}
+		f.Add(t, &bundle)
+		reservation := models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed, BundleID: &bundle.ID}
+		f.Add(t, &reservation, &models.Rate{SourceID: room.ID, Name: "hour", Kind: models.RateKindHourly, AmountMinor: 1500})
+		other := f.Customer(t, "other", nil)
+		f.Source(t, other, "hall")
+		f.Person(t, other, "mallory")
+		now := time.Now()
+
+		res, err := NewExportQuery(f.DB, f.Logger, []string{customer.ID}, now).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		export := res.(models.Export)
+		if export.Version != models.ExportVersion || !export.ExportedAt.Equal(now) {
+			t.Errorf("exported version %d at %s", export.Version, export.ExportedAt)
+		}
+		if len(export.Customers) != 1 || len(export.Persons) != 1 || len(export.Sources) != 1 || len(export.Rates) != 1 || len(export.Reservations) != 1 || len(export.Bundles) != 1 {
+			t.Errorf("exported %+v", export)
+		}
+		if len(export.Reservations) == 1 && export.Reservations[0].ID != reservation.ID {
+			t.Errorf("exported the reservation %s", export.Reservations[0].ID)
+		}
+
+		if res, err = NewExportQuery(f.DB, f.Logger, nil, now).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if export = res.(models.Export); len(export.Customers) != 2 || len(export.Sources) != 2 || len(export.Persons) != 2 {
+			t.Errorf("exported %d customers, %d sources and %d persons of everything", len(export.Customers), len(export.Sources), len(export.Persons))
+		}
+	})
+}
@@ -0,0 +1,85 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterInvoicesQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID *string
+	period     *string
+	kind       *string
+	models.Pagination
+}
+
+func NewFilterInvoicesQuery(db *gorm.DB, logger *zerolog.Logger, customerID, period, kind *string, pagination models.Pagination) *FilterInvoicesQuery {
+	return &FilterInvoicesQuery{db: db, logger: logger, customerID: customerID, period: period, kind: kind, Pagination: pagination}
+}
+
+func (s *FilterInvoicesQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterInvoicesQuery: Started")
+	q := s.db.Model(models.Invoice{})
+	if s.customerID != nil && *s.customerID != "" {
+		q = q.Where("customer_id = ?", *s.customerID)
+	}
+	if s.period != nil && *s.period != "" {
+		q = q.Where("period = ?", *s.period)
+	}
+	if s.kind != nil && *s.kind != "" {
+		q = q.Where("kind = ?", *s.kind)
+	}
+	offset := s.Pagination.Offset()
+
+	var invoices []models.Invoice
+	res := q.Order("created_at").Offset(offset).Limit(int(s.Size)).Find(&invoices)
+	if res.Error != nil {
+		return models.NewPaginationResponse(invoices, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(invoices, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterInvoicesQuery: Finished with success")
+	return models.NewPaginationResponse(invoices, totalCount, s.Page), nil
+}
+
+type ReadInvoiceQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadInvoiceQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadInvoiceQuery {
+	return &ReadInvoiceQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadInvoiceQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.Invoice{}, errors.New("ReadInvoiceQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadInvoiceQuery: ReadOne started")
+
+	var invoice models.Invoice
+	res := s.db.Model(models.Invoice{}).First(&invoice, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return models.Invoice{}, fmt.Errorf("ReadInvoiceQuery: Could not find the invoice with this id: %s", s.id)
+		}
+		return models.Invoice{}, res.Error
+	}
+
+	s.logger.Debug().Msg("ReadInvoiceQuery: ReadOne finished with success")
+	return invoice, nil
+}
@@ -0,0 +1,76 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterInvoicesQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		created := time.Now().Add(-time.Hour)
+		invoice := func(customer models.Customer, kind, period string) *models.Invoice {
+			created = created.Add(time.Minute)
+			return &models.Invoice{Base: models.Base{CreatedAt: created}, CustomerID: customer.ID, Kind: kind, Status: models.InvoiceStatusDraft, Period: period, Currency: "EUR"}
+		}
+		march := invoice(acme, models.InvoiceKindInvoice, "2026-03")
+		april := invoice(acme, models.InvoiceKindInvoice, "2026-04")
+		credit := invoice(acme, models.InvoiceKindCreditNote, "2026-03")
+		others := invoice(other, models.InvoiceKindInvoice, "2026-03")
+		f.Add(t, march, april, credit, others)
+
+		for _, test := range []struct {
+			name                     string
+			customerId, period, kind *string
+			want                     []string
+		}{
+			{name: "everything", want: []string{march.ID, april.ID, credit.ID, others.ID}},
+			{name: "customer", customerId: &acme.ID, want: []string{march.ID, april.ID, credit.ID}},
+			{name: "period", period: ptr("2026-03"), want: []string{march.ID, credit.ID, others.ID}},
+			{name: "kind", kind: ptr(models.InvoiceKindCreditNote), want: []string{credit.ID}},
+			{name: "combined", customerId: &acme.ID, period: ptr("2026-03"), kind: ptr(models.InvoiceKindInvoice), want: []string{march.ID}},
+		} {
+			res, err := NewFilterInvoicesQuery(f.DB, f.Logger, test.customerId, test.period, test.kind, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			page := res.(models.PaginationResponse[models.Invoice])
+			var got []string
+			for _, invoice := range page.Content {
+				got = append(got, invoice.ID)
+			}
+			if !slices.Equal(got, test.want) || page.Total != int64(len(test.want)) {
+				t.Errorf("%s: returned %v of %d", test.name, got, page.Total)
+			}
+		}
+	})
+}
+
+func TestReadInvoiceQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		number := "INV-2026-0001"
+		invoice := models.Invoice{CustomerID: customer.ID, Kind: models.InvoiceKindInvoice, Status: models.InvoiceStatusIssued, Number: &number, Period: "2026-03", Currency: "EUR",
+			Lines: []models.PriceLine{{Description: "Plan", Quantity: 1, UnitAmount: 900, Amount: 900}}, Subtotal: 900, Total: 900}
+		f.Add(t, &invoice)
+
+		res, err := NewReadInvoiceQuery(f.DB, f.Logger, invoice.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.Invoice); *got.Number != number || len(got.Lines) != 1 || got.Total != 900 {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadInvoiceQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read an invoice without an id")
+		}
+		if _, err = NewReadInvoiceQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read an invoice that does not exist")
+		}
+	})
+}
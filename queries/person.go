@@ -0,0 +1,94 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterPersonsQuery struct {
+	db          *gorm.DB
+	logger      *zerolog.Logger
+	customerID  string
+	ids         *[]string
+	externalRef *string
+	name        *string
+	email       *string
+	models.Pagination
+}
+
+func NewFilterPersonsQuery(db *gorm.DB, logger *zerolog.Logger, customerId string, ids *[]string, externalRef, name, email *string, pagination models.Pagination) *FilterPersonsQuery {
+	return &FilterPersonsQuery{db: db, logger: logger, customerID: customerId, ids: ids, externalRef: externalRef, name: name, email: email, Pagination: pagination}
+}
+
+func (s *FilterPersonsQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return models.NewPaginationResponse([]models.Person{}, 0, 0), errors.New("FilterPersonsQuery: missing customer id")
+	}
+	s.logger.Debug().Msg("FilterPersonsQuery: Started")
+	q := s.db.Model(models.Person{}).Where("customer_id = ?", s.customerID)
+	if s.ids != nil && len(*s.ids) > 0 {
+		q = q.Where("id IN ?", *s.ids)
+	}
+	if s.externalRef != nil && *s.externalRef != "" {
+		q = q.Where("external_ref = ?", *s.externalRef)
+	}
+	if s.name != nil && *s.name != "" {
+		q = q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", *s.name))
+	}
+	if s.email != nil && *s.email != "" {
+		q = q.Where("email = ?", *s.email)
+	}
+	offset := s.Pagination.Offset()
+
+	var persons []models.Person
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&persons)
+	if res.Error != nil {
+		return models.NewPaginationResponse(persons, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(persons, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterPersonsQuery: Finished with success")
+	return models.NewPaginationResponse(persons, totalCount, s.Page), nil
+}
+
+type ReadPersonQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	id         string
+}
+
+func NewReadPersonQuery(db *gorm.DB, logger *zerolog.Logger, customerId, id string) *ReadPersonQuery {
+	return &ReadPersonQuery{db: db, logger: logger, customerId: customerId, id: id}
+}
+
+func (s *ReadPersonQuery) Execute() (any, error) {
+	if s.id == "" || s.customerId == "" {
+		return models.Person{}, errors.New("ReadPersonQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadPersonQuery: ReadOne started")
+
+	var person models.Person
+	res := s.db.Model(models.Person{}).First(&person, "id = ? AND customer_id = ?", s.id, s.customerId)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadPersonQuery: Could not find the person with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadPersonQuery: ReadOne finished with success")
+	return person, nil
+}
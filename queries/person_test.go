@@ -0,0 +1,62 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterPersonsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		alice := f.Person(t, acme, "alice")
+		f.Person(t, acme, "bob")
+		mallory := f.Person(t, other, "mallory")
+		f.Person(t, other, "alice")
+
+		for _, test := range []struct {
+			name        string
+			ids         *[]string
+			externalRef *string
+			want        []string
+		}{
+			{name: "everything", want: []string{"alice", "bob"}},
+			{name: "ids", ids: &[]string{alice.ID, mallory.ID}, want: []string{"alice"}},
+			{name: "external reference", externalRef: ptr("alice"), want: []string{"alice"}},
+		} {
+			res, err := NewFilterPersonsQuery(f.DB, f.Logger, acme.ID, test.ids, test.externalRef, nil, nil, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.Person](t, res, int64(len(test.want))); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+
+		if _, err := NewFilterPersonsQuery(f.DB, f.Logger, "", nil, nil, nil, nil, models.Pagination{Page: 1, Size: 10}).Execute(); err == nil {
+			t.Error("listed the persons of every customer")
+		}
+	})
+}
+
+func TestReadPersonQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		alice := f.Person(t, acme, "alice")
+
+		res, err := NewReadPersonQuery(f.DB, f.Logger, acme.ID, alice.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if person := res.(models.Person); person.ID != alice.ID {
+			t.Errorf("read %+v", person)
+		}
+		if _, err = NewReadPersonQuery(f.DB, f.Logger, other.ID, alice.ID).Execute(); err == nil {
+			t.Error("read the person of another customer")
+		}
+	})
+}
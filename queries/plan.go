@@ -0,0 +1,150 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterPlansQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	name   *string
+	models.Pagination
+}
+
+func NewFilterPlansQuery(db *gorm.DB, logger *zerolog.Logger, name *string, pagination models.Pagination) *FilterPlansQuery {
+	return &FilterPlansQuery{db: db, logger: logger, name: name, Pagination: pagination}
+}
+
+func (s *FilterPlansQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterPlansQuery: Started")
+	q := s.db.Model(models.Plan{})
+	if s.name != nil && *s.name != "" {
+		q = q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", *s.name))
+	}
+	offset := s.Pagination.Offset()
+
+	var plans []models.Plan
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&plans)
+	if res.Error != nil {
+		return models.NewPaginationResponse(plans, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(plans, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterPlansQuery: Finished with success")
+	return models.NewPaginationResponse(plans, totalCount, s.Page), nil
+}
+
+type ReadPlanQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadPlanQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadPlanQuery {
+	return &ReadPlanQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadPlanQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.Plan{}, errors.New("ReadPlanQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadPlanQuery: ReadOne started")
+
+	var plan models.Plan
+	res := s.db.Model(models.Plan{}).First(&plan, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadPlanQuery: Could not find the plan with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadPlanQuery: ReadOne finished with success")
+	return plan, nil
+}
+
+// UsageQuery reports the metered usage of a customer for one month together
+// with the plan it is measured against.
+type UsageQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID string
+	period     string
+}
+
+func NewUsageQuery(db *gorm.DB, logger *zerolog.Logger, customerID, period string) *UsageQuery {
+	return &UsageQuery{db: db, logger: logger, customerID: customerID, period: period}
+}
+
+func (s *UsageQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return models.Usage{}, errors.New("UsageQuery: Tried to read usage with empty customer id")
+	}
+	s.logger.Debug().Msg("UsageQuery: Started")
+
+	period := s.period
+	if period == "" {
+		period = time.Now().UTC().Format(models.UsagePeriodFormat)
+	}
+	if _, err := time.Parse(models.UsagePeriodFormat, period); err != nil {
+		return models.Usage{}, fmt.Errorf("UsageQuery: Period %q is not in the YYYY-MM format", period)
+	}
+
+	var customer models.Customer
+	res := s.db.First(&customer, "id = ?", s.customerID)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return models.Usage{}, fmt.Errorf("UsageQuery: Could not find the customer with this id: %s", s.customerID)
+		}
+		return models.Usage{}, res.Error
+	}
+
+	plan := models.DefaultPlan
+	if customer.PlanID != nil {
+		res = s.db.First(&plan, "id = ?", *customer.PlanID)
+		if res.Error != nil {
+			return models.Usage{}, res.Error
+		}
+	}
+	usage := models.Usage{CustomerID: s.customerID, Period: period, Plan: plan}
+
+	res = s.db.Model(&models.Source{}).Where("customer_id = ?", s.customerID).Count(&usage.Sources)
+	if res.Error != nil {
+		return models.Usage{}, res.Error
+	}
+	res = s.db.Model(&models.ApiToken{}).Where("customer_id = ? AND valid_until > ?", s.customerID, time.Now()).Count(&usage.ApiTokens)
+	if res.Error != nil {
+		return models.Usage{}, res.Error
+	}
+
+	var counters []models.UsageCounter
+	res = s.db.Where("customer_id = ? AND period = ?", s.customerID, period).Find(&counters)
+	if res.Error != nil {
+		return models.Usage{}, res.Error
+	}
+	for _, counter := range counters {
+		switch counter.Metric {
+		case models.UsageMetricReservations:
+			usage.ReservationsCreated = counter.Count
+		case models.UsageMetricApiCalls:
+			usage.ApiCalls = counter.Count
+		}
+	}
+
+	s.logger.Debug().Msg("UsageQuery: Finished with success")
+	return usage, nil
+}
@@ -0,0 +1,97 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterPlansQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		f.Add(t, &models.Plan{Name: "small"}, &models.Plan{Name: "smaller"}, &models.Plan{Name: "large"})
+
+		for _, test := range []struct {
+			name string
+			like *string
+			want []string
+		}{
+			{name: "everything", want: []string{"small", "smaller", "large"}},
+			{name: "name", like: ptr("small"), want: []string{"small", "smaller"}},
+		} {
+			res, err := NewFilterPlansQuery(f.DB, f.Logger, test.like, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.Plan](t, res, int64(len(test.want))); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+	})
+}
+
+func TestReadPlanQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		plan := models.Plan{Name: "small", MaxSources: 2}
+		f.Add(t, &plan)
+
+		res, err := NewReadPlanQuery(f.DB, f.Logger, plan.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.Plan); got.Name != "small" || got.MaxSources != 2 {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadPlanQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read a plan without an id")
+		}
+		if _, err = NewReadPlanQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read a plan that does not exist")
+		}
+	})
+}
+
+func TestUsageQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		plan := models.Plan{Name: "small", MaxSources: 2}
+		customer := f.Customer(t, "alice", &plan)
+		room := f.Source(t, customer, "room")
+		f.Source(t, customer, "hall")
+		other := f.Customer(t, "other", nil)
+		f.Source(t, other, "hall")
+		now := time.Now()
+		f.Add(t,
+			&models.ApiToken{CustomerID: customer.ID, SourceID: room.ID, Token: "valid", ValidUntil: now.Add(time.Hour)},
+			&models.ApiToken{CustomerID: customer.ID, SourceID: room.ID, Token: "expired", ValidUntil: now.Add(-time.Hour)},
+			&models.UsageCounter{CustomerID: customer.ID, Period: "2026-03", Metric: models.UsageMetricReservations, Count: 12},
+			&models.UsageCounter{CustomerID: customer.ID, Period: "2026-03", Metric: models.UsageMetricApiCalls, Count: 340},
+			&models.UsageCounter{CustomerID: customer.ID, Period: "2026-04", Metric: models.UsageMetricApiCalls, Count: 7},
+		)
+
+		res, err := NewUsageQuery(f.DB, f.Logger, customer.ID, "2026-03").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage := res.(models.Usage); usage.CustomerID != customer.ID || usage.Period != "2026-03" || usage.Plan.ID != plan.ID ||
+			usage.Sources != 2 || usage.ApiTokens != 1 || usage.ReservationsCreated != 12 || usage.ApiCalls != 340 {
+			t.Errorf("returned %+v", usage)
+		}
+
+		res, err = NewUsageQuery(f.DB, f.Logger, other.ID, "").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage := res.(models.Usage); usage.Plan.Name != models.DefaultPlan.Name || usage.Period != time.Now().UTC().Format(models.UsagePeriodFormat) || usage.Sources != 1 {
+			t.Errorf("returned %+v for a customer on the default plan", usage)
+		}
+
+		if _, err = NewUsageQuery(f.DB, f.Logger, customer.ID, "March").Execute(); err == nil {
+			t.Error("returned the usage of a period that is not a month")
+		}
+		if _, err = NewUsageQuery(f.DB, f.Logger, models.NewUUID(), "2026-03").Execute(); err == nil {
+			t.Error("returned the usage of a customer that does not exist")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterCancellationPoliciesQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID *string
+	models.Pagination
+}
+
+func NewFilterCancellationPoliciesQuery(db *gorm.DB, logger *zerolog.Logger, customerID *string, pagination models.Pagination) *FilterCancellationPoliciesQuery {
+	return &FilterCancellationPoliciesQuery{db: db, logger: logger, customerID: customerID, Pagination: pagination}
+}
+
+func (s *FilterCancellationPoliciesQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterCancellationPoliciesQuery: Started")
+	q := s.db.Model(models.CancellationPolicy{})
+	if s.customerID != nil && *s.customerID != "" {
+		q = q.Where("customer_id = ?", *s.customerID)
+	}
+	offset := s.Pagination.Offset()
+
+	var policies []models.CancellationPolicy
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&policies)
+	if res.Error != nil {
+		return models.NewPaginationResponse(policies, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(policies, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterCancellationPoliciesQuery: Finished with success")
+	return models.NewPaginationResponse(policies, totalCount, s.Page), nil
+}
+
+type ReadCancellationPolicyQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadCancellationPolicyQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadCancellationPolicyQuery {
+	return &ReadCancellationPolicyQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadCancellationPolicyQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.CancellationPolicy{}, errors.New("ReadCancellationPolicyQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadCancellationPolicyQuery: ReadOne started")
+
+	var policy models.CancellationPolicy
+	res := s.db.Model(models.CancellationPolicy{}).First(&policy, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadCancellationPolicyQuery: Could not find the policy with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadCancellationPolicyQuery: ReadOne finished with success")
+	return policy, nil
+}
@@ -0,0 +1,60 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterCancellationPoliciesQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		f.Add(t,
+			&models.CancellationPolicy{CustomerID: acme.ID, Name: "flexible"},
+			&models.CancellationPolicy{CustomerID: acme.ID, Name: "strict"},
+			&models.CancellationPolicy{CustomerID: other.ID, Name: "lenient"},
+		)
+
+		for _, test := range []struct {
+			name       string
+			customerId *string
+			want       []string
+		}{
+			{name: "everything", want: []string{"flexible", "strict", "lenient"}},
+			{name: "customer", customerId: &acme.ID, want: []string{"flexible", "strict"}},
+		} {
+			res, err := NewFilterCancellationPoliciesQuery(f.DB, f.Logger, test.customerId, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.CancellationPolicy](t, res, int64(len(test.want))); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+	})
+}
+
+func TestReadCancellationPolicyQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		policy := models.CancellationPolicy{CustomerID: customer.ID, Name: "strict", CancellationRules: models.PolicyRules{{Before: "24h", FeePercent: 50}}}
+		f.Add(t, &policy)
+
+		res, err := NewReadCancellationPolicyQuery(f.DB, f.Logger, policy.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.CancellationPolicy); got.Name != "strict" || len(got.CancellationRules) != 1 || got.CancellationRules[0].FeePercent != 50 {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadCancellationPolicyQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read a policy without an id")
+		}
+		if _, err = NewReadCancellationPolicyQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read a policy that does not exist")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterPromotionsQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID *string
+	code       *string
+	models.Pagination
+}
+
+func NewFilterPromotionsQuery(db *gorm.DB, logger *zerolog.Logger, customerID, code *string, pagination models.Pagination) *FilterPromotionsQuery {
+	return &FilterPromotionsQuery{db: db, logger: logger, customerID: customerID, code: code, Pagination: pagination}
+}
+
+func (s *FilterPromotionsQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterPromotionsQuery: Started")
+	q := s.db.Model(models.Promotion{})
+	if s.customerID != nil && *s.customerID != "" {
+		q = q.Where("customer_id = ?", *s.customerID)
+	}
+	if s.code != nil && *s.code != "" {
+		q = q.Where("code = ?", strings.ToUpper(*s.code))
+	}
+	offset := s.Pagination.Offset()
+
+	var promotions []models.Promotion
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&promotions)
+	if res.Error != nil {
+		return models.NewPaginationResponse(promotions, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(promotions, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterPromotionsQuery: Finished with success")
+	return models.NewPaginationResponse(promotions, totalCount, s.Page), nil
+}
+
+type ReadPromotionQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadPromotionQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadPromotionQuery {
+	return &ReadPromotionQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadPromotionQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.Promotion{}, errors.New("ReadPromotionQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadPromotionQuery: ReadOne started")
+
+	var promotion models.Promotion
+	res := s.db.Model(models.Promotion{}).First(&promotion, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadPromotionQuery: Could not find the promotion with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadPromotionQuery: ReadOne finished with success")
+	return promotion, nil
+}
+
+// PromotionReportQuery counts the redemptions and the discounted amount of
+// every promotion code of a customer.
+type PromotionReportQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID string
+}
+
+func NewPromotionReportQuery(db *gorm.DB, logger *zerolog.Logger, customerID string) *PromotionReportQuery {
+	return &PromotionReportQuery{db: db, logger: logger, customerID: customerID}
+}
+
+func (s *PromotionReportQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return []models.PromotionReport{}, errors.New("PromotionReportQuery: Tried to report with empty customer id")
+	}
+	s.logger.Debug().Msg("PromotionReportQuery: Started")
+
+	reports := []models.PromotionReport{}
+	res := s.db.Model(&models.Promotion{}).
+		Select("promotions.id AS promotion_id, promotions.code, COUNT(pr.id) AS redemptions, COALESCE(SUM(pr.discount_amount), 0) AS discount_amount, COALESCE(pr.currency, promotions.currency) AS currency").
+		Joins("LEFT JOIN promotion_redemptions pr ON pr.promotion_id = promotions.id").
+		Where("promotions.customer_id = ?", s.customerID).
+		Group("promotions.id, promotions.code, COALESCE(pr.currency, promotions.currency)").
+		Order("promotions.code").
+		Scan(&reports)
+	if res.Error != nil {
+		return reports, res.Error
+	}
+
+	s.logger.Debug().Msg("PromotionReportQuery: Finished with success")
+	return reports, nil
+}
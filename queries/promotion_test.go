@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterPromotionsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		f.Add(t,
+			&models.Promotion{CustomerID: acme.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 10, Active: true},
+			&models.Promotion{CustomerID: acme.ID, Code: "SUMMER", Kind: models.PromotionKindPercentage, PercentOff: 20, Active: true},
+			&models.Promotion{CustomerID: other.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 30, Active: true},
+		)
+
+		for _, test := range []struct {
+			name       string
+			customerId *string
+			code       *string
+			want       []string
+		}{
+			{name: "everything", want: []string{"SPRING", "SUMMER", "SPRING"}},
+			{name: "customer", customerId: &acme.ID, want: []string{"SPRING", "SUMMER"}},
+			{name: "code of any case", customerId: &acme.ID, code: ptr("spring"), want: []string{"SPRING"}},
+		} {
+			res, err := NewFilterPromotionsQuery(f.DB, f.Logger, test.customerId, test.code, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			page := res.(models.PaginationResponse[models.Promotion])
+			var got []string
+			for _, promotion := range page.Content {
+				got = append(got, promotion.Code)
+			}
+			if !slices.Equal(got, test.want) || page.Total != int64(len(test.want)) {
+				t.Errorf("%s: returned %v of %d", test.name, got, page.Total)
+			}
+		}
+	})
+}
+
+func TestReadPromotionQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		promotion := models.Promotion{CustomerID: customer.ID, Code: "ROOM", Kind: models.PromotionKindFixed, AmountOffMinor: 500, Currency: "EUR", SourceIDs: []string{room.ID}, Active: true}
+		f.Add(t, &promotion)
+
+		res, err := NewReadPromotionQuery(f.DB, f.Logger, promotion.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.Promotion); got.Code != "ROOM" || !slices.Equal(got.SourceIDs, []string{room.ID}) {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadPromotionQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read a promotion without an id")
+		}
+		if _, err = NewReadPromotionQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read a promotion that does not exist")
+		}
+	})
+}
+
+func TestPromotionReportQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		other := f.Customer(t, "other", nil)
+		spring := models.Promotion{CustomerID: customer.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 10, Currency: "EUR", Active: true}
+		unused := models.Promotion{CustomerID: customer.ID, Code: "AUTUMN", Kind: models.PromotionKindPercentage, PercentOff: 10, Currency: "EUR", Active: true}
+		others := models.Promotion{CustomerID: other.ID, Code: "SPRING", Kind: models.PromotionKindPercentage, PercentOff: 10, Currency: "EUR", Active: true}
+		f.Add(t, &spring, &unused, &others)
+		f.Add(t,
+			&models.PromotionRedemption{PromotionID: spring.ID, ReservationID: models.NewUUID(), ReserveeID: models.NewUUID(), DiscountAmount: 300, Currency: "EUR"},
+			&models.PromotionRedemption{PromotionID: spring.ID, ReservationID: models.NewUUID(), ReserveeID: models.NewUUID(), DiscountAmount: 200, Currency: "EUR"},
+			&models.PromotionRedemption{PromotionID: others.ID, ReservationID: models.NewUUID(), ReserveeID: models.NewUUID(), DiscountAmount: 900, Currency: "EUR"},
+		)
+
+		res, err := NewPromotionReportQuery(f.DB, f.Logger, customer.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []models.PromotionReport{
+			{PromotionID: unused.ID, Code: "AUTUMN", Currency: "EUR"},
+			{PromotionID: spring.ID, Code: "SPRING", Redemptions: 2, DiscountAmount: 500, Currency: "EUR"},
+		}
+		if got := res.([]models.PromotionReport); !slices.Equal(got, want) {
+			t.Errorf("reported %+v, want %+v", got, want)
+		}
+		if _, err = NewPromotionReportQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("reported the promotions of every customer")
+		}
+	})
+}
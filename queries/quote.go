@@ -0,0 +1,101 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/pricing"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// QuoteQuery prices a prospective reservation without booking it.
+type QuoteQuery struct {
+	db            *gorm.DB
+	logger        *zerolog.Logger
+	sourceID      string
+	from          time.Time
+	to            time.Time
+	units         int
+	promotionCode string
+	reserveeID    string
+}
+
+func NewQuoteQuery(db *gorm.DB, logger *zerolog.Logger, sourceID string, from, to time.Time, units int, promotionCode, reserveeID string) *QuoteQuery {
+	return &QuoteQuery{db: db, logger: logger, sourceID: sourceID, from: from, to: to, units: units, promotionCode: promotionCode, reserveeID: reserveeID}
+}
+
+func (s *QuoteQuery) Execute() (any, error) {
+	if s.sourceID == "" {
+		return models.Quote{}, errors.New("QuoteQuery: Tried to quote with empty source id")
+	}
+	s.logger.Debug().Msg("QuoteQuery: Started")
+
+	var source models.Source
+	res := s.db.Preload("Rates").First(&source, "id = ?", s.sourceID)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return models.Quote{}, fmt.Errorf("QuoteQuery: Could not find the source with this id: %s", s.sourceID)
+		}
+		return models.Quote{}, res.Error
+	}
+
+	quote, err := pricing.Quote(source, source.Rates, s.from, s.to, s.units)
+	if err != nil {
+		return models.Quote{}, fmt.Errorf("QuoteQuery: %w", err)
+	}
+
+	if s.promotionCode != "" {
+		err = s.applyPromotion(source, &quote)
+		if err != nil {
+			return models.Quote{}, err
+		}
+	}
+
+	s.logger.Debug().Msg("QuoteQuery: Finished with success")
+	return quote, nil
+}
+
+// applyPromotion discounts the quote the way redeeming the code would, without
+// counting a redemption.
+func (s *QuoteQuery) applyPromotion(source models.Source, quote *models.Quote) error {
+	var promotion models.Promotion
+	res := s.db.Where("customer_id = ? AND code = ?", source.CustomerID, strings.ToUpper(s.promotionCode)).Limit(1).Find(&promotion)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("QuoteQuery: Could not find the promotion with code: %s", s.promotionCode)
+	}
+
+	firstTime := true
+	if s.reserveeID != "" {
+		persons := s.db.Model(&models.Person{}).Select("id").Where("customer_id = ? AND external_ref = ?", source.CustomerID, s.reserveeID)
+		if util.IsUUID(s.reserveeID) {
+			persons = persons.Or("customer_id = ? AND id = ?", source.CustomerID, s.reserveeID)
+		}
+		var countOfReservations int64
+		res = s.db.Model(&models.Reservation{}).Where("reservee_id IN (?)", persons).Count(&countOfReservations)
+		if res.Error != nil {
+			return res.Error
+		}
+		firstTime = countOfReservations == 0
+	}
+
+	err := pricing.CheckPromotion(promotion, source.ID, time.Now(), firstTime)
+	if err != nil {
+		return fmt.Errorf("QuoteQuery: %w", err)
+	}
+	_, err = pricing.ApplyPromotion(quote, promotion)
+	if err != nil {
+		return fmt.Errorf("QuoteQuery: %w", err)
+	}
+	return nil
+}
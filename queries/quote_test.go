@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestQuoteQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		f.Person(t, customer, "bob")
+		f.Add(t,
+			&models.Rate{SourceID: room.ID, Name: "hour", Kind: models.RateKindHourly, AmountMinor: 1500},
+			&models.Promotion{CustomerID: customer.ID, Code: "HALF", Kind: models.PromotionKindPercentage, PercentOff: 50, Active: true},
+			&models.Promotion{CustomerID: customer.ID, Code: "WELCOME", Kind: models.PromotionKindFixed, AmountOffMinor: 1000, Currency: "EUR", FirstTimeOnly: true, Active: true},
+			&models.Reservation{From: repotest.At(1), To: repotest.At(2), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed},
+		)
+		quote := func(code, reservee string) (models.Quote, error) {
+			res, err := NewQuoteQuery(f.DB, f.Logger, room.ID, repotest.At(9), repotest.At(11), 1, code, reservee).Execute()
+			return res.(models.Quote), err
+		}
+
+		for _, test := range []struct {
+			name, code, reservee string
+			total                int64
+		}{
+			{name: "no promotion", total: 3000},
+			{name: "code of any case", code: "half", total: 1500},
+			{name: "first reservation", code: "WELCOME", reservee: "bob", total: 2000},
+		} {
+			got, err := quote(test.code, test.reservee)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got.SourceID != room.ID || got.Currency != "EUR" || got.Total != test.total {
+				t.Errorf("%s: quoted %+v", test.name, got)
+			}
+		}
+
+		if _, err := quote("WELCOME", alice.ID); err == nil {
+			t.Error("applied a first-time promotion to a reservee with reservations")
+		}
+		if _, err := quote("UNKNOWN", ""); err == nil {
+			t.Error("applied a promotion that does not exist")
+		}
+		if _, err := NewQuoteQuery(f.DB, f.Logger, models.NewUUID(), repotest.At(9), repotest.At(11), 1, "", "").Execute(); err == nil {
+			t.Error("quoted a source that does not exist")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterRatesQuery struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	sourceID *string
+	models.Pagination
+}
+
+func NewFilterRatesQuery(db *gorm.DB, logger *zerolog.Logger, sourceID *string, pagination models.Pagination) *FilterRatesQuery {
+	return &FilterRatesQuery{db: db, logger: logger, sourceID: sourceID, Pagination: pagination}
+}
+
+func (s *FilterRatesQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterRatesQuery: Started")
+	q := s.db.Model(models.Rate{})
+	if s.sourceID != nil && *s.sourceID != "" {
+		q = q.Where("source_id = ?", *s.sourceID)
+	}
+	offset := s.Pagination.Offset()
+
+	var rates []models.Rate
+	res := q.Offset(offset).Limit(int(s.Size)).Find(&rates)
+	if res.Error != nil {
+		return models.NewPaginationResponse(rates, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(rates, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterRatesQuery: Finished with success")
+	return models.NewPaginationResponse(rates, totalCount, s.Page), nil
+}
+
+type ReadRateQuery struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	id     string
+}
+
+func NewReadRateQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadRateQuery {
+	return &ReadRateQuery{db: db, logger: logger, id: id}
+}
+
+func (s *ReadRateQuery) Execute() (any, error) {
+	if s.id == "" {
+		return models.Rate{}, errors.New("ReadRateQuery: Tried to read one with empty id")
+	}
+	s.logger.Debug().Msg("ReadRateQuery: ReadOne started")
+
+	var rate models.Rate
+	res := s.db.Model(models.Rate{}).First(&rate, "id = ?", s.id)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("ReadRateQuery: Could not find the rate with this id: %s", s.id)
+		}
+		return "", res.Error
+	}
+
+	s.logger.Debug().Msg("ReadRateQuery: ReadOne finished with success")
+	return rate, nil
+}
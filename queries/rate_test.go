@@ -0,0 +1,62 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterRatesQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		hall := f.Source(t, customer, "hall")
+		f.Add(t,
+			&models.Rate{SourceID: room.ID, Name: "hour", Kind: models.RateKindHourly, AmountMinor: 1500},
+			&models.Rate{SourceID: room.ID, Name: "day", Kind: models.RateKindDaily, AmountMinor: 9000},
+			&models.Rate{SourceID: hall.ID, Name: "event", Kind: models.RateKindFlat, AmountMinor: 50000},
+		)
+
+		for _, test := range []struct {
+			name     string
+			sourceId *string
+			want     []string
+		}{
+			{name: "everything", want: []string{"hour", "day", "event"}},
+			{name: "source", sourceId: &room.ID, want: []string{"hour", "day"}},
+		} {
+			res, err := NewFilterRatesQuery(f.DB, f.Logger, test.sourceId, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.Rate](t, res, int64(len(test.want))); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+	})
+}
+
+func TestReadRateQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		rate := models.Rate{SourceID: room.ID, Name: "weekend", Kind: models.RateKindHourly, AmountMinor: 1500, Weekdays: []int{0, 6}}
+		f.Add(t, &rate)
+
+		res, err := NewReadRateQuery(f.DB, f.Logger, rate.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(models.Rate); got.Name != "weekend" || !slices.Equal(got.Weekdays, []int{0, 6}) {
+			t.Errorf("read %+v", got)
+		}
+		if _, err = NewReadRateQuery(f.DB, f.Logger, "").Execute(); err == nil {
+			t.Error("read a rate without an id")
+		}
+		if _, err = NewReadRateQuery(f.DB, f.Logger, models.NewUUID()).Execute(); err == nil {
+			t.Error("read a rate that does not exist")
+		}
+	})
+}
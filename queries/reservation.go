@@ -8,53 +8,55 @@ import (
 	"fmt"
 
 	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
 	"github.com/rs/zerolog"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type FilterReservationsQuery struct {
-	db         *gorm.DB
-	logger     *zerolog.Logger
-	ids        *[]string
-	reserverID *string
-	reserveeID *string
-	sourceID   *string
+	store         repository.Store
+	logger        *zerolog.Logger
+	ids           *[]string
+	reserverID    *string
+	reserveeID    *string
+	sourceID      *string
+	participantID *string
+	status        *string
+	approverID    *string
 	models.Pagination
 }
 
-func NewFilterReservationsQuery(db *gorm.DB, logger *zerolog.Logger, ids *[]string, reserveeID, reserverID, sourceID *string, pagination models.Pagination) *FilterReservationsQuery {
-	return &FilterReservationsQuery{db: db, logger: logger, ids: ids, reserverID: reserverID, reserveeID: reserveeID, sourceID: sourceID, Pagination: pagination}
+func NewFilterReservationsQuery(store repository.Store, logger *zerolog.Logger, ids *[]string, reserveeID, reserverID, sourceID, participantID, status, approverID *string, pagination models.Pagination) *FilterReservationsQuery {
+	return &FilterReservationsQuery{store: store, logger: logger, ids: ids, reserverID: reserverID, reserveeID: reserveeID, sourceID: sourceID, participantID: participantID, status: status, approverID: approverID, Pagination: pagination}
 }
 
 func (s *FilterReservationsQuery) Execute() (any, error) {
 	s.logger.Debug().Msg("FilterReservationsQuery: Started")
-	q := s.db.Model(models.Reservation{})
-	if s.ids != nil && len(*s.ids) > 0 {
-		q = q.Where("id IN ?", *s.ids)
+	var filter repository.ReservationFilter
+	if s.ids != nil {
+		filter.IDs = *s.ids
 	}
-	if s.reserverID != nil && *s.reserverID != "" {
-		q = q.Where("reserverId = ?", *s.reserverID)
+	if s.reserverID != nil {
+		filter.ReserverID = *s.reserverID
 	}
-	if s.reserveeID != nil && *s.reserveeID != "" {
-		q = q.Where("reserveeId = ?", *s.reserveeID)
+	if s.reserveeID != nil {
+		filter.ReserveeID = *s.reserveeID
 	}
-	if s.sourceID != nil && *s.sourceID != "" {
-		q = q.Where("sourceId = ?", *s.sourceID)
+	if s.sourceID != nil {
+		filter.SourceID = *s.sourceID
 	}
-
-	offset := s.Pagination.Offset()
-
-	var reservations []models.Reservation
-	res := q.Offset(offset).Limit(int(s.Size)).Find(&reservations)
-	if res.Error != nil {
-		return models.NewPaginationResponse(reservations, 0, 0), res.Error
+	if s.participantID != nil {
+		filter.ParticipantID = *s.participantID
+	}
+	if s.status != nil {
+		filter.Status = *s.status
+	}
+	if s.approverID != nil {
+		filter.ApproverID = *s.approverID
 	}
 
-	var totalCount int64
-	res = q.Count(&totalCount)
-	if res.Error != nil {
-		return models.NewPaginationResponse(reservations, 0, 0), res.Error
+	reservations, totalCount, err := s.store.Reservations().Filter(filter, s.Pagination)
+	if err != nil {
+		return models.NewPaginationResponse(reservations, 0, 0), err
 	}
 
 	s.logger.Debug().Msg("FilterReservationsQuery: Finished with success")
@@ -63,13 +65,13 @@ func (s *FilterReservationsQuery) Execute() (any, error) {
 }
 
 type ReadReservationQuery struct {
-	db     *gorm.DB
+	store  repository.Store
 	logger *zerolog.Logger
 	id     string
 }
 
-func NewReadReservationQuery(db *gorm.DB, logger *zerolog.Logger, id string) *ReadReservationQuery {
-	return &ReadReservationQuery{db: db, logger: logger, id: id}
+func NewReadReservationQuery(store repository.Store, logger *zerolog.Logger, id string) *ReadReservationQuery {
+	return &ReadReservationQuery{store: store, logger: logger, id: id}
 }
 
 func (s *ReadReservationQuery) Execute() (any, error) {
@@ -78,13 +80,12 @@ func (s *ReadReservationQuery) Execute() (any, error) {
 	}
 	s.logger.Debug().Msg("ReadReservationQuery: ReadOne started")
 
-	var reservation models.Reservation
-	res := s.db.Model(models.Reservation{}).Preload(clause.Associations).First(&reservation, s.id)
-	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
+	reservation, err := s.store.Reservations().Load(s.id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return "", fmt.Errorf("ReadReservationQuery: Could not find the reservation with this id: %s", s.id)
 		}
-		return "", res.Error
+		return "", err
 	}
 
 	s.logger.Debug().Msg("ReadReservationQuery: ReadOne finished with success")
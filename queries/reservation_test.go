@@ -0,0 +1,89 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterReservationsQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		hall := f.Source(t, customer, "hall")
+		alice := f.Person(t, customer, "alice")
+		bob := f.Person(t, customer, "bob")
+		carol := f.Person(t, customer, "carol")
+
+		first := models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: bob.ID, Status: models.ReservationStatusConfirmed,
+			Participants: []models.Participant{{PersonID: carol.ID, Role: models.ParticipantRoleRequired, RSVP: models.RSVPPending}}}
+		second := models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: hall.ID, ReserverID: bob.ID, ReserveeID: alice.ID, Status: models.ReservationStatusPendingApproval, ApproverID: &carol.ID}
+		third := models.Reservation{From: repotest.At(11), To: repotest.At(12), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusCancelled}
+		f.Add(t, &first, &second, &third)
+
+		everything := models.Pagination{Page: 1, Size: 10}
+		for _, test := range []struct {
+			name                                                      string
+			ids                                                       *[]string
+			reservee, reserver, source, participant, status, approver *string
+			pagination                                                models.Pagination
+			want                                                      []string
+			total                                                     int64
+		}{
+			{name: "everything", pagination: everything, want: []string{first.ID, second.ID, third.ID}, total: 3},
+			{name: "ids", ids: &[]string{first.ID, third.ID}, pagination: everything, want: []string{first.ID, third.ID}, total: 2},
+			{name: "reserver", reserver: &alice.ID, pagination: everything, want: []string{first.ID, third.ID}, total: 2},
+			{name: "reservee", reservee: &alice.ID, pagination: everything, want: []string{second.ID, third.ID}, total: 2},
+			{name: "source", source: &hall.ID, pagination: everything, want: []string{second.ID}, total: 1},
+			{name: "participant", participant: &carol.ID, pagination: everything, want: []string{first.ID}, total: 1},
+			{name: "status", status: ptr(models.ReservationStatusCancelled), pagination: everything, want: []string{third.ID}, total: 1},
+			{name: "approver", approver: &carol.ID, pagination: everything, want: []string{second.ID}, total: 1},
+			{name: "combined", reserver: &alice.ID, source: &room.ID, status: ptr(models.ReservationStatusConfirmed), pagination: everything, want: []string{first.ID}, total: 1},
+			{name: "page", pagination: models.Pagination{Page: 2, Size: 2}, want: []string{third.ID}, total: 3},
+		} {
+			res, err := NewFilterReservationsQuery(f, f.Logger, test.ids, test.reservee, test.reserver, test.source, test.participant, test.status, test.approver, test.pagination).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			page := res.(models.PaginationResponse[models.Reservation])
+			var got []string
+			for _, reservation := range page.Content {
+				got = append(got, reservation.ID)
+			}
+			if !slices.Equal(got, test.want) || page.Total != test.total {
+				t.Errorf("%s: returned %v of %d", test.name, got, page.Total)
+			}
+		}
+	})
+}
+
+func TestReadReservationQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		bob := f.Person(t, customer, "bob")
+		reservation := models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: source.ID, ReserverID: alice.ID, ReserveeID: alice.ID, Status: models.ReservationStatusConfirmed,
+			Participants: []models.Participant{{PersonID: bob.ID, Role: models.ParticipantRoleOptional, RSVP: models.RSVPAccepted}}}
+		f.Add(t, &reservation)
+		f.Add(t, &models.Payment{ReservationID: reservation.ID, Provider: "fake", Status: models.PaymentStatusCaptured, Amount: 1000, Currency: "EUR"})
+
+		if _, err := NewReadReservationQuery(f, f.Logger, "").Execute(); err == nil {
+			t.Error("read a reservation without an id")
+		}
+		if _, err := NewReadReservationQuery(f, f.Logger, "00000000-0000-0000-0000-000000000000").Execute(); err == nil {
+			t.Error("read a reservation that does not exist")
+		}
+
+		res, err := NewReadReservationQuery(f, f.Logger, reservation.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := res.(models.Reservation)
+		if read.ID != reservation.ID || !read.From.Equal(reservation.From) || len(read.Participants) != 1 || read.Participants[0].PersonID != bob.ID || len(read.Payments) != 1 {
+			t.Errorf("returned %+v", read)
+		}
+	})
+}
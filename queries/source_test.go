@@ -0,0 +1,69 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterSourcesQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "small room")
+		f.Source(t, customer, "hall")
+		f.Source(t, customer, "large room")
+
+		for _, test := range []struct {
+			name       string
+			ids        *[]string
+			search     *string
+			pagination models.Pagination
+			want       []string
+			total      int64
+		}{
+			{name: "everything", pagination: models.Pagination{Page: 1, Size: 10}, want: []string{"small room", "hall", "large room"}, total: 3},
+			{name: "ids", ids: &[]string{room.ID}, pagination: models.Pagination{Page: 1, Size: 10}, want: []string{"small room"}, total: 1},
+			{name: "name", search: ptr("room"), pagination: models.Pagination{Page: 1, Size: 1}, want: []string{"small room"}, total: 2},
+		} {
+			res, err := NewFilterSourcesQuery(f, f.Logger, test.ids, test.search, test.pagination).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if got := names[models.Source](t, res, test.total); !slices.Equal(got, test.want) {
+				t.Errorf("%s: returned %v", test.name, got)
+			}
+		}
+	})
+}
+
+func TestReadSourceQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		source := f.Source(t, customer, "room")
+		person := f.Person(t, customer, "bob")
+		f.Add(t,
+			&models.Rate{SourceID: source.ID, Name: "hourly", Kind: models.RateKindHourly, AmountMinor: 1000},
+			&models.ApiToken{CustomerID: customer.ID, SourceID: source.ID, Token: "token", ValidUntil: time.Now().Add(time.Hour)},
+			&models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: source.ID, ReserverID: person.ID, ReserveeID: person.ID, Status: models.ReservationStatusConfirmed},
+		)
+
+		if _, err := NewReadSourceQuery(f, f.Logger, "").Execute(); err == nil {
+			t.Error("read a source without an id")
+		}
+		if _, err := NewReadSourceQuery(f, f.Logger, "00000000-0000-0000-0000-000000000000").Execute(); err == nil {
+			t.Error("read a source that does not exist")
+		}
+
+		res, err := NewReadSourceQuery(f, f.Logger, source.ID).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := res.(models.Source)
+		if read.ID != source.ID || len(read.Rates) != 1 || len(read.Tokens) != 1 || len(read.Reservations) != 1 {
+			t.Errorf("returned %+v", read)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// StreamEventsQuery returns the published reservation events of a customer
+// after the given published position, only the ones of the source when
+// sourceId is given. A negative position starts at the latest event, so a
+// stream without Last-Event-ID only gets what happens from now on.
+type StreamEventsQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerId string
+	sourceId   string
+	after      int64
+	limit      int
+}
+
+func NewStreamEventsQuery(db *gorm.DB, logger *zerolog.Logger, customerId, sourceId string, after int64, limit int) *StreamEventsQuery {
+	return &StreamEventsQuery{db: db, logger: logger, customerId: customerId, sourceId: sourceId, after: after, limit: limit}
+}
+
+func (s *StreamEventsQuery) Execute() (any, error) {
+	if s.customerId == "" || s.limit <= 0 {
+		return models.StreamPage{}, errors.New("StreamEventsQuery: missing arguments")
+	}
+	s.logger.Debug().Msg("StreamEventsQuery: Started")
+
+	if s.sourceId != "" {
+		var count int64
+		if util.IsUUID(s.sourceId) {
+			res := s.db.Model(models.Source{}).Where("id = ? AND customer_id = ?", s.sourceId, s.customerId).Count(&count)
+			if res.Error != nil {
+				return models.StreamPage{}, res.Error
+			}
+		}
+		if count == 0 {
+			return models.StreamPage{}, fmt.Errorf("StreamEventsQuery: Could not find the source with this id: %s", s.sourceId)
+		}
+	}
+
+	q := s.db.Model(models.OutboxEvent{}).Where("customer_id = ? AND type LIKE ? AND published_position IS NOT NULL", s.customerId, "reservation.%")
+	if s.after < 0 {
+		var position int64
+		res := q.Select("COALESCE(MAX(published_position), 0)").Scan(&position)
+		if res.Error != nil {
+			return models.StreamPage{}, res.Error
+		}
+		return models.StreamPage{Events: []models.OutboxEvent{}, Position: position}, nil
+	}
+
+	var rows []models.OutboxEvent
+	res := q.Where("published_position > ?", s.after).Order("published_position").Limit(s.limit).Find(&rows)
+	if res.Error != nil {
+		return models.StreamPage{}, res.Error
+	}
+
+	page := models.StreamPage{Events: make([]models.OutboxEvent, 0, len(rows)), Position: s.after}
+	for _, row := range rows {
+		page.Position = *row.PublishedPosition
+		if s.sourceId != "" {
+			var reservation struct {
+				SourceID string `json:"sourceId"`
+			}
+			err := json.Unmarshal([]byte(row.Payload), &reservation)
+			if err != nil || reservation.SourceID != s.sourceId {
+				continue
+			}
+		}
+		page.Events = append(page.Events, row)
+	}
+
+	s.logger.Debug().Msg("StreamEventsQuery: Finished with success")
+	return page, nil
+}
@@ -0,0 +1,79 @@
+package queries
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestStreamEventsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		hall := f.Source(t, customer, "hall")
+		other := f.Customer(t, "other", nil)
+		elsewhere := f.Source(t, other, "elsewhere")
+		published := int64(0)
+		event := func(customer models.Customer, eventType string, source models.Source, publish bool) *models.OutboxEvent {
+			event := &models.OutboxEvent{ID: models.NewUUID(), Type: eventType, CustomerID: customer.ID, AggregateID: models.NewUUID(),
+				Payload: fmt.Sprintf(`{"sourceId":%q}`, source.ID), OccurredAt: time.Now()}
+			if publish {
+				published++
+				position := published
+				event.PublishedPosition = &position
+			}
+			return event
+		}
+		created := event(customer, "reservation.created", room, true)
+		updated := event(customer, "source.updated", room, true)
+		others := event(other, "reservation.created", elsewhere, true)
+		cancelled := event(customer, "reservation.cancelled", hall, true)
+		f.Add(t, created, updated, others, cancelled, event(customer, "reservation.created", room, false))
+		stream := func(sourceId string, after int64, limit int) models.StreamPage {
+			t.Helper()
+			res, err := NewStreamEventsQuery(f.DB, f.Logger, customer.ID, sourceId, after, limit).Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.(models.StreamPage)
+		}
+
+		for _, test := range []struct {
+			name     string
+			sourceId string
+			after    int64
+			limit    int
+			want     []string
+			position int64
+		}{
+			{name: "everything", limit: 10, want: []string{created.ID, cancelled.ID}, position: 4},
+			{name: "after a position", after: 1, limit: 10, want: []string{cancelled.ID}, position: 4},
+			{name: "limit", limit: 1, want: []string{created.ID}, position: 1},
+			{name: "source", sourceId: hall.ID, limit: 10, want: []string{cancelled.ID}, position: 4},
+			{name: "latest", after: -1, limit: 10, position: 4},
+			{name: "nothing new", after: 4, limit: 10, position: 4},
+		} {
+			page := stream(test.sourceId, test.after, test.limit)
+			var got []string
+			for _, event := range page.Events {
+				got = append(got, event.ID)
+			}
+			if !slices.Equal(got, test.want) || page.Position != test.position {
+				t.Errorf("%s: returned %v up to %d, want %v up to %d", test.name, got, page.Position, test.want, test.position)
+			}
+		}
+
+		for _, sourceId := range []string{elsewhere.ID, "room"} {
+			if _, err := NewStreamEventsQuery(f.DB, f.Logger, customer.ID, sourceId, 0, 10).Execute(); err == nil {
+				t.Errorf("streamed the events of the source %s", sourceId)
+			}
+		}
+		if _, err := NewStreamEventsQuery(f.DB, f.Logger, customer.ID, "", 0, 0).Execute(); err == nil {
+			t.Error("streamed without a limit")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+)
+
+type FilterApiTokensQuery struct {
+	store      repository.Store
+	logger     *zerolog.Logger
+	customerId *string
+	sourceId   *string
+	valid      bool
+	now        time.Time
+	models.Pagination
+}
+
+func NewFilterApiTokensQuery(store repository.Store, logger *zerolog.Logger, customerId, sourceId *string, valid bool, now time.Time, pagination models.Pagination) *FilterApiTokensQuery {
+	return &FilterApiTokensQuery{store: store, logger: logger, customerId: customerId, sourceId: sourceId, valid: valid, now: now, Pagination: pagination}
+}
+
+func (s *FilterApiTokensQuery) Execute() (any, error) {
+	s.logger.Debug().Msg("FilterApiTokensQuery: Started")
+	var filter repository.ApiTokenFilter
+	if s.customerId != nil {
+		filter.CustomerID = *s.customerId
+	}
+	if s.sourceId != nil {
+		filter.SourceID = *s.sourceId
+	}
+	if s.valid {
+		filter.ValidAt = &s.now
+	}
+
+	tokens, totalCount, err := s.store.ApiTokens().Filter(filter, s.Pagination)
+	if err != nil {
+		return models.NewPaginationResponse(tokens, 0, 0), err
+	}
+
+	s.logger.Debug().Msg("FilterApiTokensQuery: Finished with success")
+	return models.NewPaginationResponse(tokens, totalCount, s.Page), nil
+}
@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterApiTokensQuery(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		now := time.Now()
+		alice := f.Customer(t, "alice", nil)
+		bob := f.Customer(t, "bob", nil)
+		room := f.Source(t, alice, "room")
+		hall := f.Source(t, bob, "hall")
+		valid := models.ApiToken{CustomerID: alice.ID, SourceID: room.ID, Token: "valid", ValidUntil: now.Add(time.Hour)}
+		expired := models.ApiToken{CustomerID: alice.ID, SourceID: room.ID, Token: "expired", ValidUntil: now.Add(-time.Hour)}
+		other := models.ApiToken{CustomerID: bob.ID, SourceID: hall.ID, Token: "other", ValidUntil: now.Add(time.Hour)}
+		f.Add(t, &valid, &expired, &other)
+
+		everything := models.Pagination{Page: 1, Size: 10}
+		for _, test := range []struct {
+			name       string
+			customer   *string
+			source     *string
+			valid      bool
+			pagination models.Pagination
+			want       []string
+			total      int64
+		}{
+			{name: "everything", pagination: everything, want: []string{"valid", "expired", "other"}, total: 3},
+			{name: "customer", customer: &alice.ID, pagination: everything, want: []string{"valid", "expired"}, total: 2},
+			{name: "source", source: &hall.ID, pagination: everything, want: []string{"other"}, total: 1},
+			{name: "valid", customer: &alice.ID, valid: true, pagination: everything, want: []string{"valid"}, total: 1},
+			{name: "page", pagination: models.Pagination{Page: 2, Size: 1}, want: []string{"expired"}, total: 3},
+		} {
+			res, err := NewFilterApiTokensQuery(f, f.Logger, test.customer, test.source, test.valid, now, test.pagination).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			page := res.(models.PaginationResponse[models.ApiToken])
+			var got []string
+			for _, token := range page.Content {
+				got = append(got, token.Token)
+			}
+			if !slices.Equal(got, test.want) || page.Total != test.total {
+				t.Errorf("%s: returned %v of %d", test.name, got, page.Total)
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+/*
+ * Any operation that does not mutate the database belongs to 'queries'.
+ */
+package queries
+
+import (
+	"errors"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type FilterWebhookEndpointsQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID string
+	models.Pagination
+}
+
+func NewFilterWebhookEndpointsQuery(db *gorm.DB, logger *zerolog.Logger, customerID string, pagination models.Pagination) *FilterWebhookEndpointsQuery {
+	return &FilterWebhookEndpointsQuery{db: db, logger: logger, customerID: customerID, Pagination: pagination}
+}
+
+// Execute lists the endpoints of the customer, their secrets are left out.
+func (s *FilterWebhookEndpointsQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return models.NewPaginationResponse([]models.WebhookEndpoint{}, 0, 0), errors.New("FilterWebhookEndpointsQuery: missing customer id")
+	}
+	s.logger.Debug().Msg("FilterWebhookEndpointsQuery: Started")
+	q := s.db.Model(models.WebhookEndpoint{}).Where("customer_id = ?", s.customerID)
+	offset := s.Pagination.Offset()
+
+	var endpoints []models.WebhookEndpoint
+	res := q.Omit("secret").Offset(offset).Limit(int(s.Size)).Find(&endpoints)
+	if res.Error != nil {
+		return models.NewPaginationResponse(endpoints, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(endpoints, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterWebhookEndpointsQuery: Finished with success")
+	return models.NewPaginationResponse(endpoints, totalCount, s.Page), nil
+}
+
+// FilterWebhookDeliveriesQuery is the delivery log of a customer. Filtering on
+// the dead status gives the dead letter list.
+type FilterWebhookDeliveriesQuery struct {
+	db         *gorm.DB
+	logger     *zerolog.Logger
+	customerID string
+	endpointID *string
+	eventID    *string
+	status     *string
+	models.Pagination
+}
+
+func NewFilterWebhookDeliveriesQuery(db *gorm.DB, logger *zerolog.Logger, customerID string, endpointID, eventID, status *string, pagination models.Pagination) *FilterWebhookDeliveriesQuery {
+	return &FilterWebhookDeliveriesQuery{db: db, logger: logger, customerID: customerID, endpointID: endpointID, eventID: eventID, status: status, Pagination: pagination}
+}
+
+func (s *FilterWebhookDeliveriesQuery) Execute() (any, error) {
+	if s.customerID == "" {
+		return models.NewPaginationResponse([]models.WebhookDelivery{}, 0, 0), errors.New("FilterWebhookDeliveriesQuery: missing customer id")
+	}
+	s.logger.Debug().Msg("FilterWebhookDeliveriesQuery: Started")
+	q := s.db.Model(models.WebhookDelivery{}).Where("customer_id = ?", s.customerID)
+	if s.endpointID != nil && *s.endpointID != "" {
+		q = q.Where("endpoint_id = ?", *s.endpointID)
+	}
+	if s.eventID != nil && *s.eventID != "" {
+		q = q.Where("event_id = ?", *s.eventID)
+	}
+	if s.status != nil && *s.status != "" {
+		q = q.Where("status = ?", *s.status)
+	}
+	offset := s.Pagination.Offset()
+
+	var deliveries []models.WebhookDelivery
+	res := q.Order("created_at DESC").Offset(offset).Limit(int(s.Size)).Find(&deliveries)
+	if res.Error != nil {
+		return models.NewPaginationResponse(deliveries, 0, 0), res.Error
+	}
+
+	var totalCount int64
+	res = q.Count(&totalCount)
+	if res.Error != nil {
+		return models.NewPaginationResponse(deliveries, 0, 0), res.Error
+	}
+
+	s.logger.Debug().Msg("FilterWebhookDeliveriesQuery: Finished with success")
+	return models.NewPaginationResponse(deliveries, totalCount, s.Page), nil
+}
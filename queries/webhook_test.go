@@ -0,0 +1,83 @@
+package queries
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestFilterWebhookEndpointsQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		f.Add(t,
+			&models.WebhookEndpoint{CustomerID: acme.ID, URL: "https://acme.example.com/hooks", Secret: "acme-secret", Active: true},
+			&models.WebhookEndpoint{CustomerID: other.ID, URL: "https://other.example.com/hooks", Secret: "other-secret", Active: true},
+		)
+
+		res, err := NewFilterWebhookEndpointsQuery(f.DB, f.Logger, acme.ID, models.Pagination{Page: 1, Size: 10}).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := res.(models.PaginationResponse[models.WebhookEndpoint])
+		if page.Total != 1 || len(page.Content) != 1 || page.Content[0].URL != "https://acme.example.com/hooks" {
+			t.Fatalf("returned %+v", page)
+		}
+		if page.Content[0].Secret != "" {
+			t.Error("returned the secret of the endpoint")
+		}
+		if _, err = NewFilterWebhookEndpointsQuery(f.DB, f.Logger, "", models.Pagination{Page: 1, Size: 10}).Execute(); err == nil {
+			t.Error("listed the endpoints of every customer")
+		}
+	})
+}
+
+func TestFilterWebhookDeliveriesQuery(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		acme := f.Customer(t, "acme", nil)
+		other := f.Customer(t, "other", nil)
+		endpoint := models.WebhookEndpoint{CustomerID: acme.ID, URL: "https://acme.example.com/hooks", Active: true}
+		second := models.WebhookEndpoint{CustomerID: acme.ID, URL: "https://acme.example.com/other", Active: true}
+		f.Add(t, &endpoint, &second)
+		created := time.Now().Add(-time.Hour)
+		delivery := func(customer models.Customer, endpoint models.WebhookEndpoint, eventId, status string) *models.WebhookDelivery {
+			created = created.Add(time.Minute)
+			return &models.WebhookDelivery{Base: models.Base{CreatedAt: created}, CustomerID: customer.ID, EndpointID: endpoint.ID, EventID: eventId, EventType: "reservation.created", Status: status}
+		}
+		first := delivery(acme, endpoint, "event-1", models.WebhookDeliveryStatusSucceeded)
+		dead := delivery(acme, endpoint, "event-2", models.WebhookDeliveryStatusDead)
+		fanned := delivery(acme, second, "event-1", models.WebhookDeliveryStatusPending)
+		f.Add(t, first, dead, fanned, delivery(other, endpoint, "event-3", models.WebhookDeliveryStatusDead))
+
+		for _, test := range []struct {
+			name                        string
+			endpointId, eventId, status *string
+			want                        []string
+		}{
+			{name: "newest first", want: []string{fanned.ID, dead.ID, first.ID}},
+			{name: "endpoint", endpointId: &endpoint.ID, want: []string{dead.ID, first.ID}},
+			{name: "event", eventId: ptr("event-1"), want: []string{fanned.ID, first.ID}},
+			{name: "dead letters", status: ptr(models.WebhookDeliveryStatusDead), want: []string{dead.ID}},
+		} {
+			res, err := NewFilterWebhookDeliveriesQuery(f.DB, f.Logger, acme.ID, test.endpointId, test.eventId, test.status, models.Pagination{Page: 1, Size: 10}).Execute()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			page := res.(models.PaginationResponse[models.WebhookDelivery])
+			var got []string
+			for _, delivery := range page.Content {
+				got = append(got, delivery.ID)
+			}
+			if !slices.Equal(got, test.want) || page.Total != int64(len(test.want)) {
+				t.Errorf("%s: returned %v of %d", test.name, got, page.Total)
+			}
+		}
+
+		if _, err := NewFilterWebhookDeliveriesQuery(f.DB, f.Logger, "", nil, nil, nil, models.Pagination{Page: 1, Size: 10}).Execute(); err == nil {
+			t.Error("listed the deliveries of every customer")
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package gormstore
+
+import (
+	"time"
+
+	"github.com/lghtr35/reservation-engine/billing"
+	"github.com/lghtr35/reservation-engine/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type plans struct {
+	db *gorm.DB
+}
+
+func (r plans) Create(plan *models.Plan) error {
+	return r.db.Create(plan).Error
+}
+
+func (r plans) Get(id string) (models.Plan, error) {
+	var plan models.Plan
+	err := first(r.db, &plan, "id = ?", id)
+	return plan, err
+}
+
+func (r plans) Save(plan *models.Plan) error {
+	return r.db.Save(plan).Error
+}
+
+func (r plans) Delete(id string) error {
+	return r.db.Delete(&models.Plan{}, "id = ?", id).Error
+}
+
+type invoices struct {
+	db *gorm.DB
+}
+
+func (r invoices) Create(invoice *models.Invoice) error {
+	return r.db.Create(invoice).Error
+}
+
+func (r invoices) Get(id string) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := first(r.db, &invoice, "id = ?", id)
+	return invoice, err
+}
+
+func (r invoices) GetForUpdate(id string) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := first(r.db.Clauses(clause.Locking{Strength: "UPDATE"}), &invoice, "id = ?", id)
+	return invoice, err
+}
+
+func (r invoices) Save(invoice *models.Invoice) error {
+	return r.db.Save(invoice).Error
+}
+
+func (r invoices) Delete(id string) error {
+	return r.db.Delete(&models.Invoice{}, "id = ?", id).Error
+}
+
+func (r invoices) DeleteDrafts(customerId, period string) error {
+	return r.db.Where("customer_id = ? AND period = ? AND kind = ? AND status = ?", customerId, period, models.InvoiceKindInvoice, models.InvoiceStatusDraft).
+		Delete(&models.Invoice{}).Error
+}
+
+func (r invoices) CountIssued(customerId, period string) (int64, error) {
+	var count int64
+	res := r.db.Model(&models.Invoice{}).
+		Where("customer_id = ? AND period = ? AND kind = ? AND status = ?", customerId, period, models.InvoiceKindInvoice, models.InvoiceStatusIssued).
+		Count(&count)
+	return count, res.Error
+}
+
+func (r invoices) Credited(invoiceId string) (int64, error) {
+	var credited int64
+	res := r.db.Model(&models.Invoice{}).
+		Where("corrects_invoice_id = ? AND kind = ?", invoiceId, models.InvoiceKindCreditNote).
+		Select("COALESCE(-SUM(subtotal), 0)").Scan(&credited)
+	return credited, res.Error
+}
+
+// NextNumber upserts the sequence row, which the transaction then holds.
+func (r invoices) NextNumber(prefix string) (int64, error) {
+	sequence := models.InvoiceSequence{Prefix: prefix, Last: 1}
+	res := r.db.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "prefix"}},
+			DoUpdates: clause.Assignments(map[string]any{"last": gorm.Expr("invoice_sequences.last + 1")}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "last"}}},
+	).Create(&sequence)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return sequence.Last, nil
+}
+
+func (r invoices) Billable(customerId string, start, end time.Time) ([]billing.SourceUsage, error) {
+	var sources []billing.SourceUsage
+	res := r.db.Model(&models.Source{}).
+		Select("sources.id AS source_id, sources.name, COUNT(r.id) AS reservations").
+		Joins("LEFT JOIN reservations r ON r.source_id = sources.id AND r.created_at >= ? AND r.created_at < ? AND r.status NOT IN ?", start, end, models.ReleasedReservationStatuses).
+		Where("sources.customer_id = ? AND sources.created_at < ?", customerId, end).
+		Group("sources.id, sources.name").
+		Scan(&sources)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	var deleted []models.DeletedSource
+	res = r.db.Where("customer_id = ? AND created_at < ? AND deleted_at >= ?", customerId, end, start).Find(&deleted)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	for _, source := range deleted {
+		// Sources with reservations can not be deleted, so there are none to
+		// count.
+		sources = append(sources, billing.SourceUsage{SourceID: source.ID, Name: source.Name})
+	}
+	return sources, nil
+}
+
+func (r invoices) Uninvoiced(period string, end time.Time) ([]string, error) {
+	invoiced := r.db.Model(&models.Invoice{}).Select("customer_id").
+		Where("period = ? AND kind = ? AND status = ?", period, models.InvoiceKindInvoice, models.InvoiceStatusIssued)
+	var customerIds []string
+	res := r.db.Model(&models.Customer{}).Where("created_at < ? AND id NOT IN (?)", end, invoiced).Order("created_at").Pluck("id", &customerIds)
+	return customerIds, res.Error
+}
@@ -0,0 +1,156 @@
+package gormstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type customers struct {
+	db *gorm.DB
+}
+
+func (r customers) Create(customer *models.Customer) error {
+	return r.db.Create(customer).Error
+}
+
+func (r customers) Get(id string) (models.Customer, error) {
+	var customer models.Customer
+	err := first(r.db, &customer, "id = ?", id)
+	return customer, err
+}
+
+func (r customers) Load(id string) (models.Customer, error) {
+	var customer models.Customer
+	err := first(r.db.Preload(clause.Associations), &customer, "id = ?", id)
+	return customer, err
+}
+
+func (r customers) Filter(filter repository.CustomerFilter, pagination models.Pagination) ([]models.Customer, int64, error) {
+	q := r.db.Model(models.Customer{})
+	if len(filter.IDs) > 0 {
+		q = q.Where("id IN ?", filter.IDs)
+	}
+	if filter.Name != "" {
+		q = q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", filter.Name))
+	}
+	return page[models.Customer](q.Order("created_at"), pagination)
+}
+
+func (r customers) Save(customer *models.Customer) error {
+	return r.db.Omit(clause.Associations).Save(customer).Error
+}
+
+func (r customers) Delete(id string) error {
+	return r.db.Delete(&models.Customer{}, "id = ?", id).Error
+}
+
+func (r customers) Plan(customerId string) (models.Plan, error) {
+	var customer models.Customer
+	err := first(r.db.Select("id", "plan_id", "max_source_limit"), &customer, "id = ?", customerId)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	if customer.PlanID == nil {
+		return models.DefaultPlan.ForCustomer(customer), nil
+	}
+
+	var plan models.Plan
+	err = first(r.db, &plan, "id = ?", *customer.PlanID)
+	return plan.ForCustomer(customer), err
+}
+
+// RecordUsage serialises concurrent calls on the counter row.
+func (r customers) RecordUsage(customerId, metric string, now time.Time, n int64) (int64, error) {
+	counter := models.UsageCounter{
+		CustomerID: customerId,
+		Period:     now.UTC().Format(models.UsagePeriodFormat),
+		Metric:     metric,
+		Count:      n,
+	}
+	res := r.db.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "customer_id"}, {Name: "period"}, {Name: "metric"}},
+			DoUpdates: clause.Assignments(map[string]any{"count": gorm.Expr("usage_counters.count + ?", n), "updated_at": now}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "count"}}},
+	).Create(&counter)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return counter.Count, nil
+}
+
+func (r customers) Usage(customerId, metric, period string) (int64, error) {
+	var counter models.UsageCounter
+	res := r.db.Where("customer_id = ? AND period = ? AND metric = ?", customerId, period, metric).Limit(1).Find(&counter)
+	return counter.Count, res.Error
+}
+
+func (r customers) CountForPlan(planId string) (int64, error) {
+	var count int64
+	res := r.db.Model(&models.Customer{}).Where("plan_id = ?", planId).Count(&count)
+	return count, res.Error
+}
+
+type secrets struct {
+	db *gorm.DB
+}
+
+func (r secrets) Create(secret *models.Secret) error {
+	return r.db.Create(secret).Error
+}
+
+func (r secrets) Get(id string) (models.Secret, error) {
+	var secret models.Secret
+	err := first(r.db, &secret, "id = ?", id)
+	return secret, err
+}
+
+func (r secrets) FindByValue(value string) (models.Secret, error) {
+	var secret models.Secret
+	err := first(r.db, &secret, "value = ?", value)
+	return secret, err
+}
+
+type apiTokens struct {
+	db *gorm.DB
+}
+
+func (r apiTokens) Create(token *models.ApiToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r apiTokens) Get(id string) (models.ApiToken, error) {
+	var token models.ApiToken
+	err := first(r.db, &token, "id = ?", id)
+	return token, err
+}
+
+func (r apiTokens) Filter(filter repository.ApiTokenFilter, pagination models.Pagination) ([]models.ApiToken, int64, error) {
+	q := r.db.Model(models.ApiToken{})
+	if filter.CustomerID != "" {
+		q = q.Where("customer_id = ?", filter.CustomerID)
+	}
+	if filter.SourceID != "" {
+		q = q.Where("source_id = ?", filter.SourceID)
+	}
+	if filter.ValidAt != nil {
+		q = q.Where("valid_until > ?", *filter.ValidAt)
+	}
+	return page[models.ApiToken](q.Order("created_at"), pagination)
+}
+
+func (r apiTokens) CountValid(customerId string, now time.Time) (int64, error) {
+	var count int64
+	res := r.db.Model(&models.ApiToken{}).Where("customer_id = ? AND valid_until > ?", customerId, now).Count(&count)
+	return count, res.Error
+}
+
+func (r apiTokens) Save(token *models.ApiToken) error {
+	return r.db.Save(token).Error
+}
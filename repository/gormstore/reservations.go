@@ -0,0 +1,262 @@
+package gormstore
+
+import (
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type reservations struct {
+	db *gorm.DB
+}
+
+func (r reservations) Create(reservation *models.Reservation) error {
+	return r.db.Create(reservation).Error
+}
+
+func (r reservations) Get(id string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := first(r.db, &reservation, "id = ?", id)
+	return reservation, err
+}
+
+func (r reservations) GetForUpdate(id string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := first(r.db.Clauses(clause.Locking{Strength: "UPDATE"}), &reservation, "id = ?", id)
+	return reservation, err
+}
+
+func (r reservations) Load(id string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := first(r.db.Preload(clause.Associations), &reservation, "id = ?", id)
+	return reservation, err
+}
+
+func (r reservations) Filter(filter repository.ReservationFilter, pagination models.Pagination) ([]models.Reservation, int64, error) {
+	q := r.db.Model(models.Reservation{})
+	if len(filter.IDs) > 0 {
+		q = q.Where("id IN ?", filter.IDs)
+	}
+	if filter.ReserverID != "" {
+		q = q.Where("reserver_id = ?", filter.ReserverID)
+	}
+	if filter.ReserveeID != "" {
+		q = q.Where("reservee_id = ?", filter.ReserveeID)
+	}
+	if filter.SourceID != "" {
+		q = q.Where("source_id = ?", filter.SourceID)
+	}
+	if filter.ParticipantID != "" {
+		q = q.Where("id IN (?)", r.db.Model(&models.Participant{}).Select("reservation_id").Where("person_id = ?", filter.ParticipantID))
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.ApproverID != "" {
+		q = q.Where("approver_id = ?", filter.ApproverID)
+	}
+	return page[models.Reservation](q.Order("created_at"), pagination)
+}
+
+func (r reservations) Save(reservation *models.Reservation) error {
+	return r.db.Omit(clause.Associations).Save(reservation).Error
+}
+
+// CountOverlapping counts the reservations that still hold their slot and
+// are on the same source, or have one of the persons as their reserver,
+// reservee or a participant who has not declined. The query is built rather
+// than written so that every dialect quotes "from" and "to" its own way, and
+// the person conditions are left out when there are none instead of
+// comparing with an empty list.
+func (r reservations) CountOverlapping(overlap repository.Overlap) (int64, error) {
+	q := r.db.Model(&models.Reservation{}).
+		Where(clause.Lt{Column: clause.Column{Table: clause.CurrentTable, Name: "from"}, Value: overlap.To}).
+		Where(clause.Gt{Column: clause.Column{Table: clause.CurrentTable, Name: "to"}, Value: overlap.From}).
+		Where("reservations.status NOT IN ?", models.ReleasedReservationStatuses)
+
+	holders := r.db.Where("reservations.source_id = ?", overlap.SourceID)
+	if len(overlap.PersonIDs) > 0 {
+		participants := r.db.Model(&models.Participant{}).Select("1").
+			Where("participants.reservation_id = reservations.id AND participants.person_id IN ? AND participants.rsvp != ?", overlap.PersonIDs, models.RSVPDeclined)
+		holders = holders.
+			Or("reservations.reservee_id IN ?", overlap.PersonIDs).
+			Or("reservations.reserver_id IN ?", overlap.PersonIDs).
+			Or("EXISTS (?)", participants)
+	}
+	q = q.Where(holders)
+	if len(overlap.ExcludeIDs) > 0 {
+		q = q.Where("reservations.id NOT IN ?", overlap.ExcludeIDs)
+	}
+
+	var count int64
+	res := q.Count(&count)
+	return count, res.Error
+}
+
+func (r reservations) CountForReservee(reserveeId string) (int64, error) {
+	var count int64
+	res := r.db.Model(&models.Reservation{}).Where("reservee_id = ?", reserveeId).Count(&count)
+	return count, res.Error
+}
+
+func (r reservations) Participants(reservationId string) ([]models.Participant, error) {
+	var participants []models.Participant
+	res := r.db.Where("reservation_id = ?", reservationId).Order("created_at").Find(&participants)
+	return participants, res.Error
+}
+
+func (r reservations) AddFee(fee *models.ReservationFee) error {
+	return r.db.Create(fee).Error
+}
+
+func (r reservations) FeesTotal(reservationId, kind string) (int64, error) {
+	var total int64
+	res := r.db.Model(&models.ReservationFee{}).
+		Where("reservation_id = ? AND kind = ?", reservationId, kind).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total)
+	return total, res.Error
+}
+
+func (r reservations) AddPayment(payment *models.Payment) error {
+	return r.db.Create(payment).Error
+}
+
+func (r reservations) Payments(reservationId string, statuses []string) ([]models.Payment, error) {
+	var payments []models.Payment
+	res := r.db.Where("reservation_id = ? AND status IN ?", reservationId, statuses).Order("created_at").Find(&payments)
+	return payments, res.Error
+}
+
+func (r reservations) SavePayment(payment *models.Payment) error {
+	return r.db.Save(payment).Error
+}
+
+type people struct {
+	db *gorm.DB
+}
+
+func (r people) Get(customerId, id string) (models.Person, error) {
+	var person models.Person
+	err := first(r.db, &person, "customer_id = ? AND id = ?", customerId, id)
+	return person, err
+}
+
+func (r people) GetForUpdate(customerId string, ids []string) ([]models.Person, error) {
+	var people []models.Person
+	if len(ids) == 0 {
+		return people, nil
+	}
+	// Postgres locks the rows in the order it returns them.
+	res := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_id = ? AND id IN ?", customerId, ids).
+		Order("id").
+		Find(&people)
+	return people, res.Error
+}
+
+func (r people) FindByExternalRef(customerId, ref string) (models.Person, error) {
+	var person models.Person
+	err := first(r.db, &person, "customer_id = ? AND external_ref = ?", customerId, ref)
+	return person, err
+}
+
+func (r people) FindByEmail(customerId, email string) (models.Person, error) {
+	var person models.Person
+	err := first(r.db.Order("created_at"), &person, "customer_id = ? AND LOWER(email) = LOWER(?)", customerId, email)
+	return person, err
+}
+
+type promotions struct {
+	db *gorm.DB
+}
+
+func (r promotions) Get(id string) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := first(r.db, &promotion, "id = ?", id)
+	return promotion, err
+}
+
+func (r promotions) FindByCode(customerId, code string) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := first(r.db, &promotion, "customer_id = ? AND code = ?", customerId, code)
+	return promotion, err
+}
+
+// Redeem increments the counter with a conditional update.
+func (r promotions) Redeem(id string) (bool, error) {
+	res := r.db.Model(&models.Promotion{}).
+		Where("id = ? AND (max_redemptions = 0 OR redemption_count < max_redemptions)", id).
+		UpdateColumn("redemption_count", gorm.Expr("redemption_count + 1"))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r promotions) CreateRedemption(redemption *models.PromotionRedemption) error {
+	return r.db.Create(redemption).Error
+}
+
+func (r promotions) Redemption(reservationId string) (models.PromotionRedemption, error) {
+	var redemption models.PromotionRedemption
+	err := first(r.db, &redemption, "reservation_id = ?", reservationId)
+	return redemption, err
+}
+
+func (r promotions) SetRedemptionDiscount(reservationId string, discount int64) error {
+	return r.db.Model(&models.PromotionRedemption{}).Where("reservation_id = ?", reservationId).UpdateColumn("discount_amount", discount).Error
+}
+
+type bundles struct {
+	db *gorm.DB
+}
+
+func (r bundles) Create(bundle *models.Bundle) error {
+	return r.db.Omit(clause.Associations).Create(bundle).Error
+}
+
+func (r bundles) Load(id string) (models.Bundle, error) {
+	var bundle models.Bundle
+	err := first(r.db.Preload("Reservations", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }), &bundle, "id = ?", id)
+	return bundle, err
+}
+
+func (r bundles) MembersForUpdate(id string) ([]models.Reservation, error) {
+	var members []models.Reservation
+	res := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("bundle_id = ? AND status NOT IN ?", id, models.ReleasedReservationStatuses).
+		Order("created_at").
+		Find(&members)
+	return members, res.Error
+}
+
+type calendarObjects struct {
+	db *gorm.DB
+}
+
+func (r calendarObjects) Create(object *models.CalendarObject) error {
+	return r.db.Create(object).Error
+}
+
+func (r calendarObjects) Find(sourceId, name string) (models.CalendarObject, error) {
+	var object models.CalendarObject
+	err := first(r.db, &object, "source_id = ? AND name = ?", sourceId, name)
+	return object, err
+}
+
+func (r calendarObjects) FindByUID(sourceId, uid string) (models.CalendarObject, error) {
+	var object models.CalendarObject
+	err := first(r.db, &object, "source_id = ? AND uid = ?", sourceId, uid)
+	return object, err
+}
+
+func (r calendarObjects) FindByReservation(reservationId string) (models.CalendarObject, error) {
+	var object models.CalendarObject
+	err := first(r.db, &object, "reservation_id = ?", reservationId)
+	return object, err
+}
+
+func (r calendarObjects) DeleteForReservation(reservationId string) error {
+	return r.db.Where("reservation_id = ?", reservationId).Delete(&models.CalendarObject{}).Error
+}
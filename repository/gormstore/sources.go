@@ -0,0 +1,83 @@
+package gormstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sources struct {
+	db *gorm.DB
+}
+
+func (r sources) Create(source *models.Source) error {
+	return r.db.Create(source).Error
+}
+
+func (r sources) Get(id string) (models.Source, error) {
+	var source models.Source
+	err := first(r.db, &source, "id = ?", id)
+	return source, err
+}
+
+func (r sources) GetForUpdate(id string) (models.Source, error) {
+	var source models.Source
+	err := first(r.db.Clauses(clause.Locking{Strength: "UPDATE"}), &source, "id = ?", id)
+	return source, err
+}
+
+func (r sources) Load(id string) (models.Source, error) {
+	var source models.Source
+	err := first(r.db.Preload(clause.Associations), &source, "id = ?", id)
+	return source, err
+}
+
+func (r sources) Filter(filter repository.SourceFilter, pagination models.Pagination) ([]models.Source, int64, error) {
+	q := r.db.Model(models.Source{})
+	if len(filter.IDs) > 0 {
+		q = q.Where("id IN ?", filter.IDs)
+	}
+	if filter.Name != "" {
+		q = q.Where("name LIKE ?", fmt.Sprintf("%%%s%%", filter.Name))
+	}
+	return page[models.Source](q.Order("created_at"), pagination)
+}
+
+func (r sources) Save(source *models.Source) error {
+	return r.db.Omit(clause.Associations).Save(source).Error
+}
+
+func (r sources) Delete(id string) error {
+	var source models.Source
+	err := first(r.db, &source, "id = ?", id)
+	if err != nil {
+		return err
+	}
+	res := r.db.Delete(&source)
+	if res.Error != nil {
+		return res.Error
+	}
+	return r.db.Create(&models.DeletedSource{ID: source.ID, CustomerID: source.CustomerID, Name: source.Name, CreatedAt: source.CreatedAt, DeletedAt: time.Now()}).Error
+}
+
+func (r sources) CountForCustomer(customerId string) (int64, error) {
+	var count int64
+	res := r.db.Model(&models.Source{}).Where("customer_id = ?", customerId).Count(&count)
+	return count, res.Error
+}
+
+func (r sources) Rates(sourceId string) ([]models.Rate, error) {
+	var rates []models.Rate
+	res := r.db.Where("source_id = ?", sourceId).Order("created_at").Find(&rates)
+	return rates, res.Error
+}
+
+func (r sources) CancellationPolicy(customerId, id string) (models.CancellationPolicy, error) {
+	var policy models.CancellationPolicy
+	err := first(r.db, &policy, "id = ? AND customer_id = ?", id, customerId)
+	return policy, err
+}
@@ -0,0 +1,123 @@
+// Package gormstore implements the repositories on the Postgres or SQLite
+// database of the engine through GORM.
+package gormstore
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"gorm.io/gorm"
+)
+
+var _ repository.Store = (*Store)(nil)
+
+type Store struct {
+	db *gorm.DB
+}
+
+// New returns the store of the database. Given a transaction, the
+// repositories work inside of it.
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Customers() repository.Customers {
+	return customers{db: s.db}
+}
+
+func (s *Store) Secrets() repository.Secrets {
+	return secrets{db: s.db}
+}
+
+func (s *Store) ApiTokens() repository.ApiTokens {
+	return apiTokens{db: s.db}
+}
+
+func (s *Store) Sources() repository.Sources {
+	return sources{db: s.db}
+}
+
+func (s *Store) Reservations() repository.Reservations {
+	return reservations{db: s.db}
+}
+
+func (s *Store) People() repository.People {
+	return people{db: s.db}
+}
+
+func (s *Store) Promotions() repository.Promotions {
+	return promotions{db: s.db}
+}
+
+func (s *Store) Bundles() repository.Bundles {
+	return bundles{db: s.db}
+}
+
+func (s *Store) CalendarObjects() repository.CalendarObjects {
+	return calendarObjects{db: s.db}
+}
+
+func (s *Store) Plans() repository.Plans {
+	return plans{db: s.db}
+}
+
+func (s *Store) Invoices() repository.Invoices {
+	return invoices{db: s.db}
+}
+
+func (s *Store) WebhookEndpoints() repository.WebhookEndpoints {
+	return webhookEndpoints{db: s.db}
+}
+
+func (s *Store) WebhookDeliveries() repository.WebhookDeliveries {
+	return webhookDeliveries{db: s.db}
+}
+
+func (s *Store) Record(event events.Event) error {
+	body, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+	return s.db.Create(&models.OutboxEvent{
+		ID:          event.ID,
+		Type:        event.Type,
+		CustomerID:  event.CustomerID,
+		AggregateID: event.AggregateID,
+		Payload:     string(body),
+		OccurredAt:  event.OccurredAt,
+	}).Error
+}
+
+func (s *Store) Transaction(f func(tx repository.Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return f(New(tx))
+	})
+}
+
+// first loads the first row matching the conditions into dest.
+func first(db *gorm.DB, dest any, conds ...any) error {
+	err := db.First(dest, conds...).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// page finds the rows of the page of q and counts every row q matches.
+func page[T any](q *gorm.DB, pagination models.Pagination) ([]T, int64, error) {
+	var totalCount int64
+	res := q.Session(&gorm.Session{}).Count(&totalCount)
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+
+	var rows []T
+	res = q.Offset(pagination.Offset()).Limit(int(pagination.Size)).Find(&rows)
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+	return rows, totalCount, nil
+}
@@ -0,0 +1,94 @@
+package gormstore
+
+import (
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookEndpoints struct {
+	db *gorm.DB
+}
+
+func (r webhookEndpoints) Create(endpoint *models.WebhookEndpoint) error {
+	return r.db.Create(endpoint).Error
+}
+
+func (r webhookEndpoints) Get(customerId, id string) (models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := first(r.db, &endpoint, "id = ? AND customer_id = ?", id, customerId)
+	return endpoint, err
+}
+
+func (r webhookEndpoints) Active(customerId string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	res := r.db.Where("customer_id = ? AND active = ?", customerId, true).Order("created_at").Find(&endpoints)
+	return endpoints, res.Error
+}
+
+func (r webhookEndpoints) Save(endpoint *models.WebhookEndpoint) error {
+	return r.db.Save(endpoint).Error
+}
+
+func (r webhookEndpoints) Delete(id string) error {
+	return r.db.Delete(&models.WebhookEndpoint{}, "id = ?", id).Error
+}
+
+type webhookDeliveries struct {
+	db *gorm.DB
+}
+
+// Enqueue leaves the deliveries that are already queued to the unique index
+// on their endpoint and event.
+func (r webhookDeliveries) Enqueue(deliveries []models.WebhookDelivery) (int64, error) {
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries)
+	return res.RowsAffected, res.Error
+}
+
+func (r webhookDeliveries) Requeue(customerId, id string, now time.Time) error {
+	res := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND customer_id = ?", id, customerId).
+		Updates(map[string]any{
+			"status":          models.WebhookDeliveryStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r webhookDeliveries) GetDueForUpdate(now time.Time) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := first(r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status IN ? AND next_attempt_at <= ?", []string{models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusRetrying, models.WebhookDeliveryStatusSending}, now).
+		Order("next_attempt_at"), &delivery)
+	return delivery, err
+}
+
+func (r webhookDeliveries) Save(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// SaveAttempt is a conditional update on the status and attempts the claim
+// left.
+func (r webhookDeliveries) SaveAttempt(delivery models.WebhookDelivery) (bool, error) {
+	res := r.db.Model(&delivery).
+		Where("status = ? AND attempts = ?", models.WebhookDeliveryStatusSending, delivery.Attempts).
+		Select("status", "next_attempt_at", "last_status_code", "last_error").
+		Updates(&delivery)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"slices"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/billing"
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+type plans struct {
+	s *Store
+}
+
+func (r plans) Create(plan *models.Plan) error {
+	defer r.s.lock()()
+	created(&plan.Base)
+	r.s.data.plans.put(plan.ID, *plan)
+	return nil
+}
+
+func (r plans) Get(id string) (models.Plan, error) {
+	defer r.s.lock()()
+	return r.s.data.plans.get(id)
+}
+
+func (r plans) Save(plan *models.Plan) error {
+	defer r.s.lock()()
+	plan.UpdatedAt = time.Now()
+	r.s.data.plans.put(plan.ID, *plan)
+	return nil
+}
+
+func (r plans) Delete(id string) error {
+	defer r.s.lock()()
+	r.s.data.plans.delete(id)
+	return nil
+}
+
+type invoices struct {
+	s *Store
+}
+
+func (r invoices) Create(invoice *models.Invoice) error {
+	defer r.s.lock()()
+	created(&invoice.Base)
+	r.s.data.invoices.put(invoice.ID, *invoice)
+	return nil
+}
+
+func (r invoices) Get(id string) (models.Invoice, error) {
+	defer r.s.lock()()
+	return r.s.data.invoices.get(id)
+}
+
+// GetForUpdate needs no lock of its own, transactions run one at a time.
+func (r invoices) GetForUpdate(id string) (models.Invoice, error) {
+	return r.Get(id)
+}
+
+func (r invoices) Save(invoice *models.Invoice) error {
+	defer r.s.lock()()
+	invoice.UpdatedAt = time.Now()
+	r.s.data.invoices.put(invoice.ID, *invoice)
+	return nil
+}
+
+func (r invoices) Delete(id string) error {
+	defer r.s.lock()()
+	r.s.data.invoices.delete(id)
+	return nil
+}
+
+func (r invoices) DeleteDrafts(customerId, period string) error {
+	defer r.s.lock()()
+	for _, invoice := range r.s.data.invoices.where(func(invoice models.Invoice) bool {
+		return invoice.CustomerID == customerId && invoice.Period == period && invoice.Kind == models.InvoiceKindInvoice && invoice.Status == models.InvoiceStatusDraft
+	}) {
+		r.s.data.invoices.delete(invoice.ID)
+	}
+	return nil
+}
+
+func (r invoices) CountIssued(customerId, period string) (int64, error) {
+	defer r.s.lock()()
+	return int64(len(r.s.data.issued(customerId, period))), nil
+}
+
+func (d *data) issued(customerId, period string) []models.Invoice {
+	return d.invoices.where(func(invoice models.Invoice) bool {
+		return invoice.CustomerID == customerId && invoice.Period == period && invoice.Kind == models.InvoiceKindInvoice && invoice.IsIssued()
+	})
+}
+
+func (r invoices) Credited(invoiceId string) (int64, error) {
+	defer r.s.lock()()
+	var credited int64
+	for _, creditNote := range r.s.data.invoices.where(func(invoice models.Invoice) bool {
+		return invoice.CorrectsInvoiceID != nil && *invoice.CorrectsInvoiceID == invoiceId && invoice.Kind == models.InvoiceKindCreditNote
+	}) {
+		credited -= creditNote.Subtotal
+	}
+	return credited, nil
+}
+
+func (r invoices) NextNumber(prefix string) (int64, error) {
+	defer r.s.lock()()
+	if r.s.data.sequences == nil {
+		r.s.data.sequences = map[string]int64{}
+	}
+	r.s.data.sequences[prefix]++
+	return r.s.data.sequences[prefix], nil
+}
+
+func (r invoices) Billable(customerId string, start, end time.Time) ([]billing.SourceUsage, error) {
+	defer r.s.lock()()
+	var usage []billing.SourceUsage
+	for _, source := range r.s.data.sources.where(func(source models.Source) bool {
+		return source.CustomerID == customerId && source.CreatedAt.Before(end)
+	}) {
+		reservations := r.s.data.reservations.where(func(reservation models.Reservation) bool {
+			return reservation.SourceID == source.ID && !reservation.CreatedAt.Before(start) && reservation.CreatedAt.Before(end) && !reservation.IsReleased()
+		})
+		usage = append(usage, billing.SourceUsage{SourceID: source.ID, Name: source.Name, Reservations: int64(len(reservations))})
+	}
+	for _, source := range r.s.data.deletedSources.where(func(source models.DeletedSource) bool {
+		return source.CustomerID == customerId && source.CreatedAt.Before(end) && !source.DeletedAt.Before(start)
+	}) {
+		usage = append(usage, billing.SourceUsage{SourceID: source.ID, Name: source.Name})
+	}
+	return usage, nil
+}
+
+func (r invoices) Uninvoiced(period string, end time.Time) ([]string, error) {
+	defer r.s.lock()()
+	customers := r.s.data.customers.where(func(customer models.Customer) bool {
+		return customer.CreatedAt.Before(end) && len(r.s.data.issued(customer.ID, period)) == 0
+	})
+	slices.SortStableFunc(customers, func(a, b models.Customer) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	customerIds := []string{}
+	for _, customer := range customers {
+		customerIds = append(customerIds, customer.ID)
+	}
+	return customerIds, nil
+}
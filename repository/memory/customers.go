@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+)
+
+type customers struct {
+	s *Store
+}
+
+func (r customers) Create(customer *models.Customer) error {
+	defer r.s.lock()()
+	created(&customer.Base)
+	r.s.data.customers.put(customer.ID, withoutCustomerAssociations(*customer))
+	return nil
+}
+
+func (r customers) Get(id string) (models.Customer, error) {
+	defer r.s.lock()()
+	return r.s.data.customers.get(id)
+}
+
+func (r customers) Load(id string) (models.Customer, error) {
+	defer r.s.lock()()
+	customer, err := r.s.data.customers.get(id)
+	if err != nil {
+		return customer, err
+	}
+	customer.Sources = r.s.data.sources.where(func(source models.Source) bool { return source.CustomerID == id })
+	customer.ApiTokens = r.s.data.apiTokens.where(func(token models.ApiToken) bool { return token.CustomerID == id })
+	// Like the has one association of the database, the newest secret wins.
+	if secrets := r.s.data.secrets.where(func(secret models.Secret) bool { return secret.CustomerID == id }); len(secrets) > 0 {
+		customer.Secret = secrets[len(secrets)-1]
+	}
+	return customer, nil
+}
+
+func (r customers) Filter(filter repository.CustomerFilter, pagination models.Pagination) ([]models.Customer, int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.customers.where(func(customer models.Customer) bool {
+		return (len(filter.IDs) == 0 || slices.Contains(filter.IDs, customer.ID)) &&
+			strings.Contains(customer.Name, filter.Name)
+	})
+	rows, total := paginate(rows, pagination)
+	return rows, total, nil
+}
+
+func (r customers) Save(customer *models.Customer) error {
+	defer r.s.lock()()
+	customer.UpdatedAt = time.Now()
+	r.s.data.customers.put(customer.ID, withoutCustomerAssociations(*customer))
+	return nil
+}
+
+func (r customers) Delete(id string) error {
+	defer r.s.lock()()
+	r.s.data.customers.delete(id)
+	return nil
+}
+
+func (r customers) Plan(customerId string) (models.Plan, error) {
+	defer r.s.lock()()
+	customer, err := r.s.data.customers.get(customerId)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	if customer.PlanID == nil {
+		return models.DefaultPlan.ForCustomer(customer), nil
+	}
+	plan, err := r.s.data.plans.get(*customer.PlanID)
+	return plan.ForCustomer(customer), err
+}
+
+func (r customers) RecordUsage(customerId, metric string, now time.Time, n int64) (int64, error) {
+	defer r.s.lock()()
+	if r.s.data.usage == nil {
+		r.s.data.usage = map[string]int64{}
+	}
+	key := usageKey(customerId, metric, now.UTC().Format(models.UsagePeriodFormat))
+	r.s.data.usage[key] += n
+	return r.s.data.usage[key], nil
+}
+
+func (r customers) Usage(customerId, metric, period string) (int64, error) {
+	defer r.s.lock()()
+	return r.s.data.usage[usageKey(customerId, metric, period)], nil
+}
+
+func usageKey(customerId, metric, period string) string {
+	return customerId + "/" + period + "/" + metric
+}
+
+func (r customers) CountForPlan(planId string) (int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.customers.where(func(customer models.Customer) bool {
+		return customer.PlanID != nil && *customer.PlanID == planId
+	})
+	return int64(len(rows)), nil
+}
+
+func withoutCustomerAssociations(customer models.Customer) models.Customer {
+	customer.Sources, customer.ApiTokens, customer.Secret = nil, nil, models.Secret{}
+	return customer
+}
+
+type secrets struct {
+	s *Store
+}
+
+func (r secrets) Create(secret *models.Secret) error {
+	defer r.s.lock()()
+	created(&secret.Base)
+	r.s.data.secrets.put(secret.ID, *secret)
+	return nil
+}
+
+func (r secrets) Get(id string) (models.Secret, error) {
+	defer r.s.lock()()
+	return r.s.data.secrets.get(id)
+}
+
+func (r secrets) FindByValue(value string) (models.Secret, error) {
+	defer r.s.lock()()
+	return r.s.data.secrets.find(func(secret models.Secret) bool { return secret.Value == value })
+}
+
+type apiTokens struct {
+	s *Store
+}
+
+func (r apiTokens) Create(token *models.ApiToken) error {
+	defer r.s.lock()()
+	created(&token.Base)
+	r.s.data.apiTokens.put(token.ID, *token)
+	return nil
+}
+
+func (r apiTokens) Get(id string) (models.ApiToken, error) {
+	defer r.s.lock()()
+	return r.s.data.apiTokens.get(id)
+}
+
+func (r apiTokens) Filter(filter repository.ApiTokenFilter, pagination models.Pagination) ([]models.ApiToken, int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.apiTokens.where(func(token models.ApiToken) bool {
+		return (filter.CustomerID == "" || token.CustomerID == filter.CustomerID) &&
+			(filter.SourceID == "" || token.SourceID == filter.SourceID) &&
+			(filter.ValidAt == nil || token.ValidUntil.After(*filter.ValidAt))
+	})
+	rows, total := paginate(rows, pagination)
+	return rows, total, nil
+}
+
+func (r apiTokens) CountValid(customerId string, now time.Time) (int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.apiTokens.where(func(token models.ApiToken) bool {
+		return token.CustomerID == customerId && token.ValidUntil.After(now)
+	})
+	return int64(len(rows)), nil
+}
+
+func (r apiTokens) Save(token *models.ApiToken) error {
+	defer r.s.lock()()
+	token.UpdatedAt = time.Now()
+	r.s.data.apiTokens.put(token.ID, *token)
+	return nil
+}
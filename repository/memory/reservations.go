@@ -0,0 +1,313 @@
+package memory
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+)
+
+type reservations struct {
+	s *Store
+}
+
+func (d *data) createReservation(reservation *models.Reservation) {
+	created(&reservation.Base)
+	for i := range reservation.Participants {
+		participant := &reservation.Participants[i]
+		participant.ReservationID = reservation.ID
+		created(&participant.Base)
+		d.participants.put(participant.ID, *participant)
+	}
+	d.reservations.put(reservation.ID, withoutReservationAssociations(*reservation))
+}
+
+func (r reservations) Create(reservation *models.Reservation) error {
+	defer r.s.lock()()
+	r.s.data.createReservation(reservation)
+	return nil
+}
+
+func (r reservations) Get(id string) (models.Reservation, error) {
+	defer r.s.lock()()
+	return r.s.data.reservations.get(id)
+}
+
+// GetForUpdate needs no lock of its own, transactions run one at a time.
+func (r reservations) GetForUpdate(id string) (models.Reservation, error) {
+	return r.Get(id)
+}
+
+func (r reservations) Load(id string) (models.Reservation, error) {
+	defer r.s.lock()()
+	reservation, err := r.s.data.reservations.get(id)
+	if err != nil {
+		return reservation, err
+	}
+	reservation.Participants = r.s.data.participants.where(func(participant models.Participant) bool { return participant.ReservationID == id })
+	reservation.Fees = r.s.data.fees.where(func(fee models.ReservationFee) bool { return fee.ReservationID == id })
+	reservation.Payments = r.s.data.payments.where(func(payment models.Payment) bool { return payment.ReservationID == id })
+	return reservation, nil
+}
+
+func (r reservations) Filter(filter repository.ReservationFilter, pagination models.Pagination) ([]models.Reservation, int64, error) {
+	defer r.s.lock()()
+	participating := map[string]bool{}
+	if filter.ParticipantID != "" {
+		for _, participant := range r.s.data.participants.where(func(participant models.Participant) bool { return participant.PersonID == filter.ParticipantID }) {
+			participating[participant.ReservationID] = true
+		}
+	}
+	rows := r.s.data.reservations.where(func(reservation models.Reservation) bool {
+		return (len(filter.IDs) == 0 || slices.Contains(filter.IDs, reservation.ID)) &&
+			(filter.ReserverID == "" || reservation.ReserverID == filter.ReserverID) &&
+			(filter.ReserveeID == "" || reservation.ReserveeID == filter.ReserveeID) &&
+			(filter.SourceID == "" || reservation.SourceID == filter.SourceID) &&
+			(filter.ParticipantID == "" || participating[reservation.ID]) &&
+			(filter.Status == "" || reservation.Status == filter.Status) &&
+			(filter.ApproverID == "" || (reservation.ApproverID != nil && *reservation.ApproverID == filter.ApproverID))
+	})
+	rows, total := paginate(rows, pagination)
+	return rows, total, nil
+}
+
+func (r reservations) Save(reservation *models.Reservation) error {
+	defer r.s.lock()()
+	reservation.UpdatedAt = time.Now()
+	r.s.data.reservations.put(reservation.ID, withoutReservationAssociations(*reservation))
+	return nil
+}
+
+func (r reservations) CountOverlapping(overlap repository.Overlap) (int64, error) {
+	defer r.s.lock()()
+	busy := func(personId string) bool { return slices.Contains(overlap.PersonIDs, personId) }
+	rows := r.s.data.reservations.where(func(reservation models.Reservation) bool {
+		if slices.Contains(overlap.ExcludeIDs, reservation.ID) || reservation.IsReleased() {
+			return false
+		}
+		if !reservation.From.Before(overlap.To) || !reservation.To.After(overlap.From) {
+			return false
+		}
+		if reservation.SourceID == overlap.SourceID || busy(reservation.ReserverID) || busy(reservation.ReserveeID) {
+			return true
+		}
+		participants := r.s.data.participants.where(func(participant models.Participant) bool {
+			return participant.ReservationID == reservation.ID && participant.RSVP != models.RSVPDeclined && busy(participant.PersonID)
+		})
+		return len(participants) > 0
+	})
+	return int64(len(rows)), nil
+}
+
+func (r reservations) CountForReservee(reserveeId string) (int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.reservations.where(func(reservation models.Reservation) bool { return reservation.ReserveeID == reserveeId })
+	return int64(len(rows)), nil
+}
+
+func (r reservations) Participants(reservationId string) ([]models.Participant, error) {
+	defer r.s.lock()()
+	return r.s.data.participants.where(func(participant models.Participant) bool { return participant.ReservationID == reservationId }), nil
+}
+
+func (r reservations) AddFee(fee *models.ReservationFee) error {
+	defer r.s.lock()()
+	created(&fee.Base)
+	r.s.data.fees.put(fee.ID, *fee)
+	return nil
+}
+
+func (r reservations) FeesTotal(reservationId, kind string) (int64, error) {
+	defer r.s.lock()()
+	var total int64
+	for _, fee := range r.s.data.fees.where(func(fee models.ReservationFee) bool { return fee.ReservationID == reservationId && fee.Kind == kind }) {
+		total += fee.Amount
+	}
+	return total, nil
+}
+
+func (r reservations) AddPayment(payment *models.Payment) error {
+	defer r.s.lock()()
+	created(&payment.Base)
+	r.s.data.payments.put(payment.ID, *payment)
+	return nil
+}
+
+func (r reservations) Payments(reservationId string, statuses []string) ([]models.Payment, error) {
+	defer r.s.lock()()
+	return r.s.data.payments.where(func(payment models.Payment) bool {
+		return payment.ReservationID == reservationId && slices.Contains(statuses, payment.Status)
+	}), nil
+}
+
+func (r reservations) SavePayment(payment *models.Payment) error {
+	defer r.s.lock()()
+	payment.UpdatedAt = time.Now()
+	r.s.data.payments.put(payment.ID, *payment)
+	return nil
+}
+
+func withoutReservationAssociations(reservation models.Reservation) models.Reservation {
+	reservation.Participants, reservation.Fees, reservation.Payments = nil, nil, nil
+	return reservation
+}
+
+type people struct {
+	s *Store
+}
+
+func (r people) Get(customerId, id string) (models.Person, error) {
+	defer r.s.lock()()
+	return r.s.data.people.find(func(person models.Person) bool {
+		return person.CustomerID == customerId && person.ID == id
+	})
+}
+
+// GetForUpdate needs no lock of its own, transactions run one at a time.
+func (r people) GetForUpdate(customerId string, ids []string) ([]models.Person, error) {
+	defer r.s.lock()()
+	people := r.s.data.people.where(func(person models.Person) bool {
+		return person.CustomerID == customerId && slices.Contains(ids, person.ID)
+	})
+	slices.SortFunc(people, func(a, b models.Person) int { return strings.Compare(a.ID, b.ID) })
+	return people, nil
+}
+
+func (r people) FindByExternalRef(customerId, ref string) (models.Person, error) {
+	defer r.s.lock()()
+	return r.s.data.people.find(func(person models.Person) bool {
+		return person.CustomerID == customerId && person.ExternalRef != nil && *person.ExternalRef == ref
+	})
+}
+
+func (r people) FindByEmail(customerId, email string) (models.Person, error) {
+	defer r.s.lock()()
+	people := r.s.data.people.where(func(person models.Person) bool {
+		return person.CustomerID == customerId && strings.EqualFold(person.Email, email)
+	})
+	if len(people) == 0 {
+		return models.Person{}, repository.ErrNotFound
+	}
+	return slices.MinFunc(people, func(a, b models.Person) int { return a.CreatedAt.Compare(b.CreatedAt) }), nil
+}
+
+type promotions struct {
+	s *Store
+}
+
+func (r promotions) Get(id string) (models.Promotion, error) {
+	defer r.s.lock()()
+	return r.s.data.promotions.get(id)
+}
+
+func (r promotions) FindByCode(customerId, code string) (models.Promotion, error) {
+	defer r.s.lock()()
+	return r.s.data.promotions.find(func(promotion models.Promotion) bool {
+		return promotion.CustomerID == customerId && promotion.Code == code
+	})
+}
+
+func (r promotions) Redeem(id string) (bool, error) {
+	defer r.s.lock()()
+	// Like the conditional update of the database, a promotion that is
+	// missing is not redeemed either.
+	promotion, err := r.s.data.promotions.get(id)
+	if err != nil || (promotion.MaxRedemptions != 0 && promotion.RedemptionCount >= promotion.MaxRedemptions) {
+		return false, nil
+	}
+	promotion.RedemptionCount++
+	r.s.data.promotions.put(promotion.ID, promotion)
+	return true, nil
+}
+
+func (r promotions) CreateRedemption(redemption *models.PromotionRedemption) error {
+	defer r.s.lock()()
+	created(&redemption.Base)
+	r.s.data.redemptions.put(redemption.ID, *redemption)
+	return nil
+}
+
+func (r promotions) Redemption(reservationId string) (models.PromotionRedemption, error) {
+	defer r.s.lock()()
+	return r.s.data.redemptions.find(func(redemption models.PromotionRedemption) bool { return redemption.ReservationID == reservationId })
+}
+
+func (r promotions) SetRedemptionDiscount(reservationId string, discount int64) error {
+	defer r.s.lock()()
+	for _, redemption := range r.s.data.redemptions.where(func(redemption models.PromotionRedemption) bool { return redemption.ReservationID == reservationId }) {
+		redemption.DiscountAmount = discount
+		r.s.data.redemptions.put(redemption.ID, redemption)
+	}
+	return nil
+}
+
+type bundles struct {
+	s *Store
+}
+
+func (r bundles) Create(bundle *models.Bundle) error {
+	defer r.s.lock()()
+	created(&bundle.Base)
+	r.s.data.bundles.put(bundle.ID, models.Bundle{Base: bundle.Base})
+	return nil
+}
+
+func (r bundles) Load(id string) (models.Bundle, error) {
+	defer r.s.lock()()
+	bundle, err := r.s.data.bundles.get(id)
+	if err != nil {
+		return bundle, err
+	}
+	bundle.Reservations = r.s.data.reservations.where(func(reservation models.Reservation) bool {
+		return reservation.BundleID != nil && *reservation.BundleID == id
+	})
+	return bundle, nil
+}
+
+// MembersForUpdate needs no lock of its own, transactions run one at a time.
+func (r bundles) MembersForUpdate(id string) ([]models.Reservation, error) {
+	defer r.s.lock()()
+	return r.s.data.reservations.where(func(reservation models.Reservation) bool {
+		return reservation.BundleID != nil && *reservation.BundleID == id && !reservation.IsReleased()
+	}), nil
+}
+
+type calendarObjects struct {
+	s *Store
+}
+
+func (r calendarObjects) Create(object *models.CalendarObject) error {
+	defer r.s.lock()()
+	created(&object.Base)
+	r.s.data.objects.put(object.ID, *object)
+	return nil
+}
+
+func (r calendarObjects) Find(sourceId, name string) (models.CalendarObject, error) {
+	defer r.s.lock()()
+	return r.s.data.objects.find(func(object models.CalendarObject) bool {
+		return object.SourceID == sourceId && object.Name == name
+	})
+}
+
+func (r calendarObjects) FindByUID(sourceId, uid string) (models.CalendarObject, error) {
+	defer r.s.lock()()
+	return r.s.data.objects.find(func(object models.CalendarObject) bool {
+		return object.SourceID == sourceId && object.UID == uid
+	})
+}
+
+func (r calendarObjects) FindByReservation(reservationId string) (models.CalendarObject, error) {
+	defer r.s.lock()()
+	return r.s.data.objects.find(func(object models.CalendarObject) bool { return object.ReservationID == reservationId })
+}
+
+func (r calendarObjects) DeleteForReservation(reservationId string) error {
+	defer r.s.lock()()
+	for _, object := range r.s.data.objects.where(func(object models.CalendarObject) bool { return object.ReservationID == reservationId }) {
+		r.s.data.objects.delete(object.ID)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+)
+
+type sources struct {
+	s *Store
+}
+
+func (r sources) Create(source *models.Source) error {
+	defer r.s.lock()()
+	created(&source.Base)
+	r.s.data.sources.put(source.ID, withoutSourceAssociations(*source))
+	return nil
+}
+
+func (r sources) Get(id string) (models.Source, error) {
+	defer r.s.lock()()
+	return r.s.data.sources.get(id)
+}
+
+// GetForUpdate needs no lock of its own, transactions run one at a time.
+func (r sources) GetForUpdate(id string) (models.Source, error) {
+	return r.Get(id)
+}
+
+func (r sources) Load(id string) (models.Source, error) {
+	defer r.s.lock()()
+	source, err := r.s.data.sources.get(id)
+	if err != nil {
+		return source, err
+	}
+	source.Tokens = r.s.data.apiTokens.where(func(token models.ApiToken) bool { return token.SourceID == id })
+	source.Reservations = r.s.data.reservations.where(func(reservation models.Reservation) bool { return reservation.SourceID == id })
+	source.Rates = r.s.data.rates.where(func(rate models.Rate) bool { return rate.SourceID == id })
+	return source, nil
+}
+
+func (r sources) Filter(filter repository.SourceFilter, pagination models.Pagination) ([]models.Source, int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.sources.where(func(source models.Source) bool {
+		return (len(filter.IDs) == 0 || slices.Contains(filter.IDs, source.ID)) &&
+			strings.Contains(source.Name, filter.Name)
+	})
+	rows, total := paginate(rows, pagination)
+	return rows, total, nil
+}
+
+func (r sources) Save(source *models.Source) error {
+	defer r.s.lock()()
+	source.UpdatedAt = time.Now()
+	r.s.data.sources.put(source.ID, withoutSourceAssociations(*source))
+	return nil
+}
+
+func (r sources) Delete(id string) error {
+	defer r.s.lock()()
+	source, err := r.s.data.sources.get(id)
+	if err != nil {
+		return err
+	}
+	r.s.data.sources.delete(id)
+	r.s.data.deletedSources.put(id, models.DeletedSource{ID: source.ID, CustomerID: source.CustomerID, Name: source.Name, CreatedAt: source.CreatedAt, DeletedAt: time.Now()})
+	return nil
+}
+
+func (r sources) CountForCustomer(customerId string) (int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.sources.where(func(source models.Source) bool { return source.CustomerID == customerId })
+	return int64(len(rows)), nil
+}
+
+func (r sources) Rates(sourceId string) ([]models.Rate, error) {
+	defer r.s.lock()()
+	return r.s.data.rates.where(func(rate models.Rate) bool { return rate.SourceID == sourceId }), nil
+}
+
+func (r sources) CancellationPolicy(customerId, id string) (models.CancellationPolicy, error) {
+	defer r.s.lock()()
+	return r.s.data.policies.find(func(policy models.CancellationPolicy) bool {
+		return policy.ID == id && policy.CustomerID == customerId
+	})
+}
+
+func withoutSourceAssociations(source models.Source) models.Source {
+	source.Tokens, source.Reservations, source.Rates = nil, nil, nil
+	return source
+}
@@ -0,0 +1,320 @@
+// Package memory implements the repositories in memory, which is what tests
+// run the commands and queries against. Records that only other parts of the
+// engine create, such as persons, rates and plans, are put in with Add.
+//
+// Transactions run one at a time and work on a copy of the data that
+// replaces it once they commit. Within f only the store passed to f may be
+// used, the one Transaction was called on waits for the transaction to end.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/util"
+)
+
+var _ repository.Store = (*Store)(nil)
+
+type Store struct {
+	mu   *sync.Mutex
+	data *data
+}
+
+func New() *Store {
+	return &Store{mu: &sync.Mutex{}, data: &data{}}
+}
+
+// data holds the rows of every table, each in the order it was inserted.
+type data struct {
+	customers      table[models.Customer]
+	secrets        table[models.Secret]
+	apiTokens      table[models.ApiToken]
+	sources        table[models.Source]
+	deletedSources table[models.DeletedSource]
+	reservations   table[models.Reservation]
+	participants   table[models.Participant]
+	fees           table[models.ReservationFee]
+	payments       table[models.Payment]
+	people         table[models.Person]
+	promotions     table[models.Promotion]
+	redemptions    table[models.PromotionRedemption]
+	rates          table[models.Rate]
+	policies       table[models.CancellationPolicy]
+	plans          table[models.Plan]
+	usage          map[string]int64
+	bundles        table[models.Bundle]
+	objects        table[models.CalendarObject]
+	invoices       table[models.Invoice]
+	sequences      map[string]int64
+	endpoints      table[models.WebhookEndpoint]
+	deliveries     table[models.WebhookDelivery]
+	events         []events.Event
+}
+
+func (d *data) clone() *data {
+	return &data{
+		customers:      d.customers.clone(),
+		secrets:        d.secrets.clone(),
+		apiTokens:      d.apiTokens.clone(),
+		sources:        d.sources.clone(),
+		deletedSources: d.deletedSources.clone(),
+		reservations:   d.reservations.clone(),
+		participants:   d.participants.clone(),
+		fees:           d.fees.clone(),
+		payments:       d.payments.clone(),
+		people:         d.people.clone(),
+		promotions:     d.promotions.clone(),
+		redemptions:    d.redemptions.clone(),
+		rates:          d.rates.clone(),
+		policies:       d.policies.clone(),
+		plans:          d.plans.clone(),
+		usage:          maps.Clone(d.usage),
+		bundles:        d.bundles.clone(),
+		objects:        d.objects.clone(),
+		invoices:       d.invoices.clone(),
+		sequences:      maps.Clone(d.sequences),
+		endpoints:      d.endpoints.clone(),
+		deliveries:     d.deliveries.clone(),
+		events:         slices.Clone(d.events),
+	}
+}
+
+// table keeps rows by id. Rows are copied on the way in and out, so that
+// callers never share memory with what is stored.
+type table[T any] struct {
+	rows  map[string]T
+	order []string
+}
+
+func (t table[T]) clone() table[T] {
+	rows := make(map[string]T, len(t.rows))
+	for id, row := range t.rows {
+		rows[id] = row
+	}
+	return table[T]{rows: rows, order: slices.Clone(t.order)}
+}
+
+func (t *table[T]) put(id string, row T) {
+	if t.rows == nil {
+		t.rows = map[string]T{}
+	}
+	if _, ok := t.rows[id]; !ok {
+		t.order = append(t.order, id)
+	}
+	t.rows[id] = deepCopy(row)
+}
+
+func (t *table[T]) get(id string) (T, error) {
+	row, ok := t.rows[id]
+	if !ok {
+		return row, repository.ErrNotFound
+	}
+	return deepCopy(row), nil
+}
+
+func (t *table[T]) delete(id string) {
+	if _, ok := t.rows[id]; !ok {
+		return
+	}
+	delete(t.rows, id)
+	t.order = slices.DeleteFunc(t.order, func(other string) bool { return other == id })
+}
+
+// where returns the rows matching the predicate in the order they were
+// inserted.
+func (t *table[T]) where(match func(T) bool) []T {
+	res := []T{}
+	for _, id := range t.order {
+		if row := t.rows[id]; match(row) {
+			res = append(res, deepCopy(row))
+		}
+	}
+	return res
+}
+
+func (t *table[T]) find(match func(T) bool) (T, error) {
+	rows := t.where(match)
+	if len(rows) == 0 {
+		var zero T
+		return zero, repository.ErrNotFound
+	}
+	return rows[0], nil
+}
+
+// deepCopy copies the row with everything it points to through JSON, which
+// every model is made for.
+func deepCopy[T any](row T) T {
+	data, err := json.Marshal(row)
+	if err != nil {
+		panic(err)
+	}
+	var res T
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// created prepares a row that is inserted, like the database does.
+func created(base *models.Base) {
+	now := time.Now()
+	if base.ID == "" {
+		base.ID = util.NewUUID()
+	}
+	if base.CreatedAt.IsZero() {
+		base.CreatedAt = now
+	}
+	base.UpdatedAt = now
+}
+
+// paginate returns the rows of the page and the number of rows.
+func paginate[T any](rows []T, pagination models.Pagination) ([]T, int64) {
+	offset := max(pagination.Offset(), 0)
+	end := min(offset+int(pagination.Size), len(rows))
+	if offset >= end {
+		return []T{}, int64(len(rows))
+	}
+	return rows[offset:end], int64(len(rows))
+}
+
+func (s *Store) lock() func() {
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) Customers() repository.Customers {
+	return customers{s}
+}
+
+func (s *Store) Secrets() repository.Secrets {
+	return secrets{s}
+}
+
+func (s *Store) ApiTokens() repository.ApiTokens {
+	return apiTokens{s}
+}
+
+func (s *Store) Sources() repository.Sources {
+	return sources{s}
+}
+
+func (s *Store) Reservations() repository.Reservations {
+	return reservations{s}
+}
+
+func (s *Store) People() repository.People {
+	return people{s}
+}
+
+func (s *Store) Promotions() repository.Promotions {
+	return promotions{s}
+}
+
+func (s *Store) Bundles() repository.Bundles {
+	return bundles{s}
+}
+
+func (s *Store) CalendarObjects() repository.CalendarObjects {
+	return calendarObjects{s}
+}
+
+func (s *Store) Plans() repository.Plans {
+	return plans{s}
+}
+
+func (s *Store) Invoices() repository.Invoices {
+	return invoices{s}
+}
+
+func (s *Store) WebhookEndpoints() repository.WebhookEndpoints {
+	return webhookEndpoints{s}
+}
+
+func (s *Store) WebhookDeliveries() repository.WebhookDeliveries {
+	return webhookDeliveries{s}
+}
+
+func (s *Store) Record(event events.Event) error {
+	defer s.lock()()
+	s.data.events = append(s.data.events, event)
+	return nil
+}
+
+func (s *Store) Transaction(f func(tx repository.Store) error) error {
+	defer s.lock()()
+	tx := &Store{mu: &sync.Mutex{}, data: s.data.clone()}
+	err := f(tx)
+	if err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// Events returns the events recorded so far, oldest first.
+func (s *Store) Events() []events.Event {
+	defer s.lock()()
+	return slices.Clone(s.data.events)
+}
+
+// Add stores records that the repositories read, ids and creation times are
+// set like the database would.
+func (s *Store) Add(records ...any) error {
+	defer s.lock()()
+	for _, record := range records {
+		switch r := record.(type) {
+		case *models.Customer:
+			created(&r.Base)
+			s.data.customers.put(r.ID, withoutCustomerAssociations(*r))
+		case *models.Secret:
+			created(&r.Base)
+			s.data.secrets.put(r.ID, *r)
+		case *models.ApiToken:
+			created(&r.Base)
+			s.data.apiTokens.put(r.ID, *r)
+		case *models.Source:
+			created(&r.Base)
+			s.data.sources.put(r.ID, withoutSourceAssociations(*r))
+		case *models.Reservation:
+			s.data.createReservation(r)
+		case *models.Person:
+			created(&r.Base)
+			s.data.people.put(r.ID, *r)
+		case *models.Promotion:
+			created(&r.Base)
+			s.data.promotions.put(r.ID, *r)
+		case *models.Rate:
+			created(&r.Base)
+			s.data.rates.put(r.ID, *r)
+		case *models.CancellationPolicy:
+			created(&r.Base)
+			s.data.policies.put(r.ID, *r)
+		case *models.Plan:
+			created(&r.Base)
+			s.data.plans.put(r.ID, *r)
+		case *models.Payment:
+			created(&r.Base)
+			s.data.payments.put(r.ID, *r)
+		case *models.DeletedSource:
+			s.data.deletedSources.put(r.ID, *r)
+		case *models.Invoice:
+			created(&r.Base)
+			s.data.invoices.put(r.ID, *r)
+		case *models.WebhookEndpoint:
+			created(&r.Base)
+			s.data.endpoints.put(r.ID, *r)
+		default:
+			return fmt.Errorf("memory: can not add a %T", record)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"slices"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+)
+
+type webhookEndpoints struct {
+	s *Store
+}
+
+func (r webhookEndpoints) Create(endpoint *models.WebhookEndpoint) error {
+	defer r.s.lock()()
+	created(&endpoint.Base)
+	r.s.data.endpoints.put(endpoint.ID, *endpoint)
+	return nil
+}
+
+func (r webhookEndpoints) Get(customerId, id string) (models.WebhookEndpoint, error) {
+	defer r.s.lock()()
+	return r.s.data.endpoints.find(func(endpoint models.WebhookEndpoint) bool {
+		return endpoint.ID == id && endpoint.CustomerID == customerId
+	})
+}
+
+func (r webhookEndpoints) Active(customerId string) ([]models.WebhookEndpoint, error) {
+	defer r.s.lock()()
+	return r.s.data.endpoints.where(func(endpoint models.WebhookEndpoint) bool {
+		return endpoint.CustomerID == customerId && endpoint.Active
+	}), nil
+}
+
+func (r webhookEndpoints) Save(endpoint *models.WebhookEndpoint) error {
+	defer r.s.lock()()
+	endpoint.UpdatedAt = time.Now()
+	r.s.data.endpoints.put(endpoint.ID, *endpoint)
+	return nil
+}
+
+func (r webhookEndpoints) Delete(id string) error {
+	defer r.s.lock()()
+	r.s.data.endpoints.delete(id)
+	return nil
+}
+
+type webhookDeliveries struct {
+	s *Store
+}
+
+func (r webhookDeliveries) Enqueue(deliveries []models.WebhookDelivery) (int64, error) {
+	defer r.s.lock()()
+	var queued int64
+	for _, delivery := range deliveries {
+		_, err := r.s.data.deliveries.find(func(other models.WebhookDelivery) bool {
+			return other.EndpointID == delivery.EndpointID && other.EventID == delivery.EventID
+		})
+		if err == nil {
+			continue
+		}
+		created(&delivery.Base)
+		r.s.data.deliveries.put(delivery.ID, delivery)
+		queued++
+	}
+	return queued, nil
+}
+
+func (r webhookDeliveries) Requeue(customerId, id string, now time.Time) error {
+	defer r.s.lock()()
+	delivery, err := r.s.data.deliveries.find(func(delivery models.WebhookDelivery) bool {
+		return delivery.ID == id && delivery.CustomerID == customerId
+	})
+	if err != nil {
+		return err
+	}
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt = models.WebhookDeliveryStatusPending, 0, &now
+	delivery.UpdatedAt = time.Now()
+	r.s.data.deliveries.put(delivery.ID, delivery)
+	return nil
+}
+
+// GetDueForUpdate needs no lock of its own, transactions run one at a time.
+func (r webhookDeliveries) GetDueForUpdate(now time.Time) (models.WebhookDelivery, error) {
+	defer r.s.lock()()
+	due := r.s.data.deliveries.where(func(delivery models.WebhookDelivery) bool {
+		return (delivery.Status == models.WebhookDeliveryStatusPending || delivery.Status == models.WebhookDeliveryStatusRetrying || delivery.Status == models.WebhookDeliveryStatusSending) &&
+			delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now)
+	})
+	if len(due) == 0 {
+		return models.WebhookDelivery{}, repository.ErrNotFound
+	}
+	return slices.MinFunc(due, func(a, b models.WebhookDelivery) int { return a.NextAttemptAt.Compare(*b.NextAttemptAt) }), nil
+}
+
+func (r webhookDeliveries) Save(delivery *models.WebhookDelivery) error {
+	defer r.s.lock()()
+	delivery.UpdatedAt = time.Now()
+	r.s.data.deliveries.put(delivery.ID, *delivery)
+	return nil
+}
+
+func (r webhookDeliveries) SaveAttempt(delivery models.WebhookDelivery) (bool, error) {
+	defer r.s.lock()()
+	stored, err := r.s.data.deliveries.get(delivery.ID)
+	if err != nil || stored.Status != models.WebhookDeliveryStatusSending || stored.Attempts != delivery.Attempts {
+		return false, nil
+	}
+	stored.Status, stored.NextAttemptAt = delivery.Status, delivery.NextAttemptAt
+	stored.LastStatusCode, stored.LastError = delivery.LastStatusCode, delivery.LastError
+	stored.UpdatedAt = time.Now()
+	r.s.data.deliveries.put(stored.ID, stored)
+	return true, nil
+}
@@ -0,0 +1,291 @@
+// Package repository describes how the commands and queries reach what is
+// stored, so that they run the same against the database and against memory.
+//
+// Lookups return ErrNotFound when nothing matches. Filters return the page of
+// the pagination together with the total number of matches.
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/billing"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// ErrNotFound is returned by lookups that match nothing.
+var ErrNotFound = errors.New("repository: not found")
+
+// Store gives access to every repository. The repositories of the store
+// passed to Transaction work inside of the transaction.
+type Store interface {
+	Customers() Customers
+	Secrets() Secrets
+	ApiTokens() ApiTokens
+	Sources() Sources
+	Reservations() Reservations
+	People() People
+	Promotions() Promotions
+	Bundles() Bundles
+	CalendarObjects() CalendarObjects
+	Plans() Plans
+	Invoices() Invoices
+	WebhookEndpoints() WebhookEndpoints
+	WebhookDeliveries() WebhookDeliveries
+
+	// Record writes the event of a mutation to the outbox. It has to run in
+	// the transaction of the mutation, the relay publishes the event once it
+	// is committed.
+	Record(event events.Event) error
+
+	// Transaction runs f with a store whose changes are committed when f
+	// returns nil and rolled back otherwise.
+	Transaction(f func(tx Store) error) error
+}
+
+type CustomerFilter struct {
+	IDs []string
+	// Name matches customers whose name contains it.
+	Name string
+}
+
+type Customers interface {
+	Create(customer *models.Customer) error
+	Get(id string) (models.Customer, error)
+	// Load returns the customer with its sources, api tokens and secret.
+	Load(id string) (models.Customer, error)
+	Filter(filter CustomerFilter, pagination models.Pagination) ([]models.Customer, int64, error)
+	Save(customer *models.Customer) error
+	Delete(id string) error
+
+	// Plan returns the plan whose limits apply to the customer, with the
+	// limits the customer overrides.
+	Plan(customerId string) (models.Plan, error)
+	// RecordUsage adds n to the counter of the metric for the month of now
+	// and returns the new count.
+	RecordUsage(customerId, metric string, now time.Time, n int64) (int64, error)
+	// Usage returns the counter of the metric for the period, 0 when nothing
+	// was counted.
+	Usage(customerId, metric, period string) (int64, error)
+	CountForPlan(planId string) (int64, error)
+}
+
+type Secrets interface {
+	Create(secret *models.Secret) error
+	Get(id string) (models.Secret, error)
+	FindByValue(value string) (models.Secret, error)
+}
+
+type ApiTokenFilter struct {
+	CustomerID string
+	SourceID   string
+	// ValidAt leaves out the tokens that expired or were revoked by then.
+	ValidAt *time.Time
+}
+
+type ApiTokens interface {
+	Create(token *models.ApiToken) error
+	Get(id string) (models.ApiToken, error)
+	// Filter returns the tokens in the order they were created.
+	Filter(filter ApiTokenFilter, pagination models.Pagination) ([]models.ApiToken, int64, error)
+	// CountValid counts the tokens of the customer still valid at now.
+	CountValid(customerId string, now time.Time) (int64, error)
+	Save(token *models.ApiToken) error
+}
+
+type SourceFilter struct {
+	IDs []string
+	// Name matches sources whose name contains it.
+	Name string
+}
+
+type Sources interface {
+	Create(source *models.Source) error
+	Get(id string) (models.Source, error)
+	// GetForUpdate returns the source and keeps concurrent transactions from
+	// changing or booking it until the transaction it runs in is done.
+	GetForUpdate(id string) (models.Source, error)
+	// Load returns the source with its api tokens, reservations and rates.
+	Load(id string) (models.Source, error)
+	Filter(filter SourceFilter, pagination models.Pagination) ([]models.Source, int64, error)
+	Save(source *models.Source) error
+	// Delete deletes the source and keeps it as a DeletedSource, which is
+	// what invoices bill it on.
+	Delete(id string) error
+	CountForCustomer(customerId string) (int64, error)
+
+	// Rates returns the rates of the source in the order they were created.
+	Rates(sourceId string) ([]models.Rate, error)
+	// CancellationPolicy returns the policy of the customer with the id.
+	CancellationPolicy(customerId, id string) (models.CancellationPolicy, error)
+}
+
+type ReservationFilter struct {
+	IDs        []string
+	ReserverID string
+	ReserveeID string
+	SourceID   string
+	// ParticipantID matches the reservations the person participates in.
+	ParticipantID string
+	Status        string
+	ApproverID    string
+}
+
+// Overlap is the window a reservation wants to hold. It overlaps every other
+// reservation that still holds its slot and shares some of the window, when
+// that one is on the same source or one of the persons is its reserver, its
+// reservee or one of its participants who has not declined. The window is
+// half open, a reservation ending at From does not overlap.
+type Overlap struct {
+	SourceID  string
+	From      time.Time
+	To        time.Time
+	PersonIDs []string
+	// ExcludeIDs are reservations that are not counted, which is what
+	// changing a reservation needs.
+	ExcludeIDs []string
+}
+
+type Reservations interface {
+	// Create stores the reservation together with its participants.
+	Create(reservation *models.Reservation) error
+	Get(id string) (models.Reservation, error)
+	// GetForUpdate returns the reservation and keeps concurrent transactions
+	// from changing it until the transaction it runs in is done.
+	GetForUpdate(id string) (models.Reservation, error)
+	// Load returns the reservation with its participants, fees and payments.
+	Load(id string) (models.Reservation, error)
+	Filter(filter ReservationFilter, pagination models.Pagination) ([]models.Reservation, int64, error)
+	// Save stores the fields of the reservation, its participants, fees and
+	// payments are left as they are.
+	Save(reservation *models.Reservation) error
+	CountOverlapping(overlap Overlap) (int64, error)
+	CountForReservee(reserveeId string) (int64, error)
+
+	Participants(reservationId string) ([]models.Participant, error)
+	AddFee(fee *models.ReservationFee) error
+	// FeesTotal sums the amounts of the fees of the kind.
+	FeesTotal(reservationId, kind string) (int64, error)
+	AddPayment(payment *models.Payment) error
+	// Payments returns the payments of the reservation in one of the
+	// statuses, in the order they were made.
+	Payments(reservationId string, statuses []string) ([]models.Payment, error)
+	SavePayment(payment *models.Payment) error
+}
+
+type People interface {
+	Get(customerId, id string) (models.Person, error)
+	// GetForUpdate returns the persons with the ids and keeps concurrent
+	// transactions from booking them until the transaction it runs in is
+	// done. They are locked in the order of their ids, so that transactions
+	// locking some of the same persons wait for each other instead of
+	// deadlocking.
+	GetForUpdate(customerId string, ids []string) ([]models.Person, error)
+	FindByExternalRef(customerId, ref string) (models.Person, error)
+	// FindByEmail returns the oldest person of the customer with the mail
+	// address, whatever its case.
+	FindByEmail(customerId, email string) (models.Person, error)
+}
+
+type Promotions interface {
+	Get(id string) (models.Promotion, error)
+	FindByCode(customerId, code string) (models.Promotion, error)
+	// Redeem counts a redemption of the promotion unless that goes over its
+	// maximum, which it reports as false. Concurrent redemptions can not go
+	// over the maximum.
+	Redeem(id string) (bool, error)
+	CreateRedemption(redemption *models.PromotionRedemption) error
+	// Redemption returns the redemption of the reservation.
+	Redemption(reservationId string) (models.PromotionRedemption, error)
+	// SetRedemptionDiscount changes the discount recorded for the
+	// redemption of the reservation.
+	SetRedemptionDiscount(reservationId string, discount int64) error
+}
+
+type Bundles interface {
+	// Create stores the bundle, its reservations are created on their own.
+	Create(bundle *models.Bundle) error
+	// Load returns the bundle with its reservations.
+	Load(id string) (models.Bundle, error)
+	// MembersForUpdate returns the reservations of the bundle that still
+	// hold their slot and keeps concurrent transactions from changing them
+	// until the transaction it runs in is done.
+	MembersForUpdate(id string) ([]models.Reservation, error)
+}
+
+type CalendarObjects interface {
+	Create(object *models.CalendarObject) error
+	Find(sourceId, name string) (models.CalendarObject, error)
+	FindByUID(sourceId, uid string) (models.CalendarObject, error)
+	FindByReservation(reservationId string) (models.CalendarObject, error)
+	DeleteForReservation(reservationId string) error
+}
+
+type Plans interface {
+	Create(plan *models.Plan) error
+	Get(id string) (models.Plan, error)
+	Save(plan *models.Plan) error
+	Delete(id string) error
+}
+
+type Invoices interface {
+	Create(invoice *models.Invoice) error
+	Get(id string) (models.Invoice, error)
+	// GetForUpdate returns the invoice and keeps concurrent transactions
+	// from changing it until the transaction it runs in is done.
+	GetForUpdate(id string) (models.Invoice, error)
+	Save(invoice *models.Invoice) error
+	Delete(id string) error
+	// DeleteDrafts deletes the draft invoices of the customer for the period.
+	DeleteDrafts(customerId, period string) error
+	// CountIssued counts the issued invoices of the customer for the period,
+	// credit notes are not counted.
+	CountIssued(customerId, period string) (int64, error)
+	// Credited sums what the credit notes of the invoice credit, as a
+	// positive amount.
+	Credited(invoiceId string) (int64, error)
+	// NextNumber counts the sequence of the prefix up and returns its new
+	// last number. Concurrent transactions wait for each other on the
+	// sequence, a number handed out in one that is rolled back is handed out
+	// again.
+	NextNumber(prefix string) (int64, error)
+	// Billable returns every source of the customer that existed between
+	// start and end, with the number of its reservations created in that
+	// window that still hold their slot. Sources deleted since are returned
+	// without reservations.
+	Billable(customerId string, start, end time.Time) ([]billing.SourceUsage, error)
+	// Uninvoiced returns the ids of the customers created before end that
+	// have no issued invoice for the period, oldest first.
+	Uninvoiced(period string, end time.Time) ([]string, error)
+}
+
+type WebhookEndpoints interface {
+	Create(endpoint *models.WebhookEndpoint) error
+	Get(customerId, id string) (models.WebhookEndpoint, error)
+	// Active returns the active endpoints of the customer in the order they
+	// were created.
+	Active(customerId string) ([]models.WebhookEndpoint, error)
+	Save(endpoint *models.WebhookEndpoint) error
+	Delete(id string) error
+}
+
+type WebhookDeliveries interface {
+	// Enqueue stores the deliveries whose event is not queued for their
+	// endpoint yet and returns how many it stored.
+	Enqueue(deliveries []models.WebhookDelivery) (int64, error)
+	// Requeue makes the delivery of the customer pending with a fresh set of
+	// attempts, due at now.
+	Requeue(customerId, id string, now time.Time) error
+	// GetDueForUpdate returns the pending, retrying or sending delivery that
+	// is due at now the longest and keeps concurrent transactions from
+	// claiming it until the transaction it runs in is done. Deliveries they
+	// hold are skipped rather than awaited, so that several engines deliver
+	// side by side.
+	GetDueForUpdate(now time.Time) (models.WebhookDelivery, error)
+	Save(delivery *models.WebhookDelivery) error
+	// SaveAttempt stores the status, the next attempt and the last result of
+	// the claimed delivery unless it was claimed again in the meantime, which
+	// it reports as false.
+	SaveAttempt(delivery models.WebhookDelivery) (bool, error)
+}
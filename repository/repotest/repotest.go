@@ -0,0 +1,292 @@
+// Package repotest runs tests of the commands and queries against every
+// implementation of the repositories, each test gets a store of its own.
+package repotest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/database"
+	"github.com/lghtr35/reservation-engine/migrations"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/repository/memory"
+	"github.com/lghtr35/reservation-engine/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Fixture is the store a test runs against, together with what the test
+// needs to put records in and look at the events that were recorded.
+type Fixture struct {
+	repository.Store
+	Logger *zerolog.Logger
+	Hasher *util.Hasher
+	// DB is the database under the store, nil for the memory store.
+	DB *gorm.DB
+
+	add    func(records ...any) error
+	events func() ([]string, error)
+}
+
+// Backend creates an empty store for a test.
+type Backend struct {
+	Name string
+	New  func(t *testing.T) *Fixture
+}
+
+// PostgresVariable names the environment variable with the connection
+// string of a Postgres database to run the tests on as well, the postgres
+// backend is skipped when it is not set. Every test migrates a schema of its
+// own in that database and drops it when it is done.
+const PostgresVariable = "RESERVATION_ENGINE_TEST_POSTGRES_DSN"
+
+// Databases are the backends that run the GORM store on a database.
+var Databases = []Backend{
+	{Name: "sqlite", New: newSQLite},
+	{Name: "postgres", New: newPostgres},
+}
+
+// Backends are the implementations the tests run against.
+var Backends = append([]Backend{{Name: "memory", New: newMemory}}, Databases...)
+
+func newMemory(t *testing.T) *Fixture {
+	store := memory.New()
+	return &Fixture{
+		Store: store,
+		add:   store.Add,
+		events: func() ([]string, error) {
+			var types []string
+			for _, event := range store.Events() {
+				types = append(types, event.Type)
+			}
+			return types, nil
+		},
+	}
+}
+
+// newSQLite runs the GORM store on a SQLite database of the test's own, it
+// then runs the same queries it runs on Postgres.
+func newSQLite(t *testing.T) *Fixture {
+	t.Helper()
+	return newGorm(SQLite(t))
+}
+
+// newPostgres runs the GORM store on Postgres, where it also takes advisory
+// locks and sends notifications.
+func newPostgres(t *testing.T) *Fixture {
+	t.Helper()
+	return newGorm(postgresFromEnvironment(t))
+}
+
+// postgresFromEnvironment returns a schema in the database PostgresVariable
+// names and skips the test when it is not set.
+func postgresFromEnvironment(t *testing.T) *gorm.DB {
+	t.Helper()
+	connectionString := os.Getenv(PostgresVariable)
+	if connectionString == "" {
+		t.Skipf("%s is not set", PostgresVariable)
+	}
+	return Postgres(t, connectionString)
+}
+
+func newGorm(db *gorm.DB) *Fixture {
+	return &Fixture{
+		Store: gormstore.New(db),
+		DB:    db,
+		add: func(records ...any) error {
+			for _, record := range records {
+				if err := db.Create(record).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		events: func() ([]string, error) {
+			var types []string
+			res := db.Model(&models.OutboxEvent{}).Order("position").Pluck("type", &types)
+			return types, res.Error
+		},
+	}
+}
+
+// Run runs test once for every backend.
+func Run(t *testing.T, test func(t *testing.T, f *Fixture)) {
+	run(t, Backends, test)
+}
+
+// RunDatabases runs test once on every database, for tests of commands that
+// need the database itself rather than a store. The DB of the fixture is set.
+func RunDatabases(t *testing.T, test func(t *testing.T, f *Fixture)) {
+	run(t, Databases, test)
+}
+
+// RunEmptyDatabases runs test once on an empty database of every kind, for
+// tests of the migrations that create the schema.
+func RunEmptyDatabases(t *testing.T, test func(t *testing.T, db *gorm.DB)) {
+	t.Run("sqlite", func(t *testing.T) { test(t, emptySQLite(t)) })
+	t.Run("postgres", func(t *testing.T) {
+		connectionString := os.Getenv(PostgresVariable)
+		if connectionString == "" {
+			t.Skipf("%s is not set", PostgresVariable)
+		}
+		test(t, emptyPostgres(t, connectionString))
+	})
+}
+
+func run(t *testing.T, backends []Backend, test func(t *testing.T, f *Fixture)) {
+	for _, backend := range backends {
+		t.Run(backend.Name, func(t *testing.T) {
+			f := backend.New(t)
+			logger := zerolog.Nop()
+			f.Logger = &logger
+			hasher, err := util.NewHasher(&models.Configuration{Secret: "test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Hasher = hasher
+			test(t, f)
+		})
+	}
+}
+
+// Add stores records that no command under test creates, like persons and
+// rates. Ids and creation times are filled in.
+func (f *Fixture) Add(t *testing.T, records ...any) {
+	t.Helper()
+	if err := f.add(records...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// EventTypes returns the types of the recorded events, oldest first.
+func (f *Fixture) EventTypes(t *testing.T) []string {
+	t.Helper()
+	types, err := f.events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+// Customer adds a customer on the given plan, nil for the default one.
+func (f *Fixture) Customer(t *testing.T, name string, plan *models.Plan) models.Customer {
+	t.Helper()
+	customer := models.Customer{Name: name, Company: name + " Ltd", Email: name + "@example.com"}
+	if plan != nil {
+		f.Add(t, plan)
+		customer.PlanID = &plan.ID
+	}
+	f.Add(t, &customer)
+	return customer
+}
+
+// Source adds a source of the customer that allows reservations of up to a
+// day and charges nothing.
+func (f *Fixture) Source(t *testing.T, customer models.Customer, name string) models.Source {
+	t.Helper()
+	source := models.Source{Name: name, CustomerID: customer.ID, MaxPossibleDuration: "24h", Currency: "EUR", Timezone: "UTC"}
+	f.Add(t, &source)
+	return source
+}
+
+// Person adds a person of the customer with the external reference ref.
+func (f *Fixture) Person(t *testing.T, customer models.Customer, ref string) models.Person {
+	t.Helper()
+	person := models.Person{CustomerID: customer.ID, ExternalRef: &ref, Name: ref}
+	f.Add(t, &person)
+	return person
+}
+
+// At returns the given hour of a fixed day in the future, so that windows of
+// tests read like a calendar.
+func At(hour int) time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year()+1, time.March, 2, 0, 0, 0, 0, time.UTC).Add(time.Duration(hour) * time.Hour)
+}
+
+// SQLite returns a migrated SQLite database of the test's own, for tests that
+// need the database itself rather than a store.
+func SQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := emptySQLite(t)
+	migrate(t, db)
+	return db
+}
+
+func emptySQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	return open(t, database.SQLite, filepath.Join(t.TempDir(), "engine.db"))
+}
+
+// Postgres returns a migrated schema of the test's own in the Postgres
+// database of the connection string, connections to it only see that schema.
+func Postgres(t *testing.T, connectionString string) *gorm.DB {
+	t.Helper()
+	db := emptyPostgres(t, connectionString)
+	migrate(t, db)
+	return db
+}
+
+func emptyPostgres(t *testing.T, connectionString string) *gorm.DB {
+	t.Helper()
+	admin := open(t, database.Postgres, connectionString)
+	schema := "test_" + strings.ReplaceAll(models.NewUUID(), "-", "")
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Error(err)
+		}
+	})
+
+	// pgx passes parameters it does not know on to the server.
+	if strings.Contains(connectionString, "://") {
+		separator := "?"
+		if strings.Contains(connectionString, "?") {
+			separator = "&"
+		}
+		connectionString += separator + "search_path=" + schema
+	} else {
+		connectionString += " search_path=" + schema
+	}
+	return open(t, database.Postgres, connectionString)
+}
+
+// open connects to the database, which is closed when the test is done.
+func open(t *testing.T, driver, connectionString string) *gorm.DB {
+	t.Helper()
+	dialector, err := database.Dialector(driver, connectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func migrate(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	logger := zerolog.Nop()
+	migrator, err := migrations.New(db, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/streams"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// Time is the zero time for an unset timestamp.
+func Time(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+// OptionalTime is nil for an unset timestamp.
+func OptionalTime(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := t.AsTime()
+	return &value
+}
+
+// StringsOrNil is nil when no value is given, which the filter queries read as
+// no filter.
+func StringsOrNil(values []string) *[]string {
+	if len(values) == 0 {
+		return nil
+	}
+	return &values
+}
+
+func FromPagination(p *Pagination) models.Pagination {
+	return models.Pagination{Page: p.GetPage(), Size: p.GetSize()}
+}
+
+func FromCustomer(customer models.Customer) *Customer {
+	return &Customer{
+		Id:        customer.ID,
+		CreatedAt: timestamp(customer.CreatedAt),
+		UpdatedAt: timestamp(customer.UpdatedAt),
+		Name:      customer.Name,
+		Company:   customer.Company,
+		Email:     customer.Email,
+		PlanId:    customer.PlanID,
+	}
+}
+
+func FromSource(source models.Source) *Source {
+	return &Source{
+		Id:                             source.ID,
+		CreatedAt:                      timestamp(source.CreatedAt),
+		UpdatedAt:                      timestamp(source.UpdatedAt),
+		Name:                           source.Name,
+		CustomerId:                     source.CustomerID,
+		MaxPossibleReservationDuration: source.MaxPossibleDuration,
+		RequiresApproval:               source.RequiresApproval,
+		ApproverId:                     source.ApproverID,
+		ApprovalTimeout:                source.ApprovalTimeout,
+		CancellationPolicyId:           source.CancellationPolicyID,
+		Pricing: &SourcePricing{
+			Currency:                source.Currency,
+			Timezone:                source.Timezone,
+			WeekendSurchargePercent: int32(source.WeekendSurchargePercent),
+			Capacity:                int32(source.Capacity),
+			RequiresPayment:         source.RequiresPayment,
+		},
+	}
+}
+
+// ToSourcePricing is nil when no pricing is given.
+func ToSourcePricing(pricing *SourcePricing) *models.SourcePricing {
+	if pricing == nil {
+		return nil
+	}
+	return &models.SourcePricing{
+		Currency:                pricing.Currency,
+		Timezone:                pricing.Timezone,
+		WeekendSurchargePercent: int(pricing.WeekendSurchargePercent),
+		Capacity:                int(pricing.Capacity),
+		RequiresPayment:         pricing.RequiresPayment,
+	}
+}
+
+func FromReservation(reservation models.Reservation) *Reservation {
+	participants := make([]*Participant, 0, len(reservation.Participants))
+	for _, participant := range reservation.Participants {
+		participants = append(participants, &Participant{
+			Id:       participant.ID,
+			PersonId: participant.PersonID,
+			Role:     participant.Role,
+			Rsvp:     participant.RSVP,
+		})
+	}
+	return &Reservation{
+		Id:           reservation.ID,
+		CreatedAt:    timestamp(reservation.CreatedAt),
+		UpdatedAt:    timestamp(reservation.UpdatedAt),
+		From:         timestamp(reservation.From),
+		To:           timestamp(reservation.To),
+		ReserverId:   reservation.ReserverID,
+		ReserveeId:   reservation.ReserveeID,
+		SourceId:     reservation.SourceID,
+		BundleId:     reservation.BundleID,
+		Status:       reservation.Status,
+		Units:        int32(reservation.Units),
+		Currency:     reservation.Currency,
+		TotalAmount:  reservation.TotalAmount,
+		Sequence:     int32(reservation.Sequence),
+		Participants: participants,
+	}
+}
+
+func ToReservationParticipants(participants []*ReservationParticipant) []models.ReservationParticipant {
+	res := make([]models.ReservationParticipant, 0, len(participants))
+	for _, participant := range participants {
+		res = append(res, models.ReservationParticipant{PersonID: participant.PersonId, Role: participant.Role})
+	}
+	return res
+}
+
+// FromReservationChange leaves the fee unset for a free change.
+func FromReservationChange(id string, fee *models.ReservationFee) *ReservationChange {
+	change := &ReservationChange{Id: id}
+	if fee != nil {
+		change.Fee = &Fee{Kind: fee.Kind, FeePercent: int32(fee.FeePercent), Amount: fee.Amount, Currency: fee.Currency}
+	}
+	return change
+}
+
+func fromIntervals(intervals []models.Interval) []*Interval {
+	res := make([]*Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		res = append(res, &Interval{From: timestamp(interval.From), To: timestamp(interval.To)})
+	}
+	return res
+}
+
+func FromAvailability(availability models.Availability) *Availability {
+	return &Availability{
+		SourceId: availability.SourceID,
+		From:     timestamp(availability.From),
+		To:       timestamp(availability.To),
+		Busy:     fromIntervals(availability.Busy),
+		Free:     fromIntervals(availability.Free),
+	}
+}
+
+func FromSlotChange(availability streams.Availability) *SlotChange {
+	return &SlotChange{
+		SourceId:      availability.SourceID,
+		ReservationId: availability.ReservationID,
+		From:          timestamp(availability.From),
+		To:            timestamp(availability.To),
+		Released:      availability.Released,
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/queries"
+	"github.com/lghtr35/reservation-engine/streams"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// streamPageSize is how many events a stream reads from the outbox at once.
+	streamPageSize = 100
+	// streamKeepalive is how often an idle stream sends a keepalive and looks
+	// for events it was not woken for.
+	streamKeepalive = 15 * time.Second
+)
+
+// streamCursor is where a stream resumes, from the Last-Event-ID header or
+// the lastEventId parameter that clients which can not set headers use. It is
+// negative for a new stream.
+func streamCursor(c *gin.Context) int64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	cursor, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || cursor < 0 {
+		return -1
+	}
+	return cursor
+}
+
+// stream sends the reservation events of the customer, of the source when
+// sourceId is given, until the context is done, the hub is closed or sending
+// fails.
+func (h *Handler) stream(ctx context.Context, customerId, sourceId string, cursor int64, send func(streams.Message) error, keepalive func() error) error {
+	subscription := h.hub.Subscribe(customerId)
+	defer h.hub.Unsubscribe(subscription)
+	ticker := time.NewTicker(streamKeepalive)
+	defer ticker.Stop()
+
+	for {
+		for {
+			q := queries.NewStreamEventsQuery(h.db, h.logger, customerId, sourceId, cursor, streamPageSize)
+
+			res, err := q.Execute()
+			if err != nil {
+				return err
+			}
+			page := res.(models.StreamPage)
+			for _, row := range page.Events {
+				messages, err := streams.Messages(row)
+				if err != nil {
+					return err
+				}
+				for _, message := range messages {
+					err = send(message)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if page.Position == cursor || len(page.Events) < streamPageSize {
+				cursor = page.Position
+				break
+			}
+			cursor = page.Position
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.hub.Closed():
+			return nil
+		case <-subscription.Wake():
+		case <-ticker.C:
+			err := keepalive()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StreamReservations pushes the reservation and availability events of the
+// customer, or of one of its sources, as Server-Sent Events. Requests asking
+// for a WebSocket upgrade get the same messages as JSON over a WebSocket.
+func (h *Handler) StreamReservations(c *gin.Context) {
+	// The api key routes name the source in the path, the jwt routes the
+	// customer and then the source.
+	customerId := c.GetString("customerId")
+	sourceId := c.Param("sourceId")
+	if customerId == "" {
+		customerId = c.Param("id")
+	} else {
+		sourceId = c.Param("id")
+	}
+	cursor := streamCursor(c)
+
+	// The source is checked before the response starts, so that a wrong one
+	// is still answered with a status.
+	_, err := queries.NewStreamEventsQuery(h.db, h.logger, customerId, sourceId, -1, 1).Execute()
+	if err != nil {
+		h.logger.Err(err)
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if c.IsWebsocket() {
+		server := websocket.Server{
+			// Clients authenticate with their api key or jwt, not with cookies,
+			// so the origin of the page does not matter.
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(conn *websocket.Conn) {
+				ctx, cancel := context.WithCancel(c.Request.Context())
+				defer cancel()
+				// Reading is only done to notice that the client went away.
+				go func() {
+					var ignored string
+					for websocket.Message.Receive(conn, &ignored) == nil {
+					}
+					cancel()
+				}()
+
+				err := h.stream(ctx, customerId, sourceId, cursor, func(message streams.Message) error {
+					return websocket.JSON.Send(conn, message)
+				}, func() error {
+					return websocket.JSON.Send(conn, streams.Message{Event: streams.Keepalive, Data: json.RawMessage("null")})
+				})
+				if err != nil {
+					h.logger.Error().Err(err).Msg("StreamReservations: stream ended with an error")
+				}
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	err = h.stream(c.Request.Context(), customerId, sourceId, cursor, func(message streams.Message) error {
+		err := streams.WriteEvent(c.Writer, message)
+		c.Writer.Flush()
+		return err
+	}, func() error {
+		err := streams.WriteKeepalive(c.Writer)
+		c.Writer.Flush()
+		return err
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Msg("StreamReservations: stream ended with an error")
+	}
+}
@@ -0,0 +1,167 @@
+// Package streams pushes the events of the outbox to the clients streaming
+// them. The outbox relay notifies Channel after it published events, every
+// engine listens on it and wakes its local subscriptions, which then read the
+// new events from the outbox. A subscription that misses a notification
+// catches up with the next one, or with its keepalive. On SQLite there are no
+// notifications, the hub wakes every subscription every pollInterval instead.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+// Channel is the Postgres channel the relay notifies with the JSON array of
+// the customer ids it published events of.
+const Channel = "reservation_engine_events"
+
+// reconnectDelay is how long the hub waits before listening again after it
+// lost its connection.
+const reconnectDelay = 5 * time.Second
+
+// pollInterval is how often a hub without a Postgres connection wakes its
+// subscriptions.
+const pollInterval = time.Second
+
+// Subscription is woken whenever the customer it belongs to has new events.
+type Subscription struct {
+	customerId string
+	wake       chan struct{}
+}
+
+// Wake is signalled at most once per batch of notifications.
+func (s *Subscription) Wake() <-chan struct{} {
+	return s.wake
+}
+
+type Hub struct {
+	logger           *zerolog.Logger
+	connectionString string
+	mutex            sync.Mutex
+	subscriptions    map[*Subscription]bool
+	closed           chan struct{}
+	closeOnce        sync.Once
+}
+
+// NewHub returns a hub listening on the Postgres database of the connection
+// string, or polling when it is empty.
+func NewHub(logger *zerolog.Logger, connectionString string) *Hub {
+	return &Hub{logger: logger, connectionString: connectionString, subscriptions: make(map[*Subscription]bool), closed: make(chan struct{})}
+}
+
+// Close tells the streams to end, so that a shutting down engine does not
+// wait for clients that would stream forever. They resume from their last
+// event on another engine.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// Closed is closed once Close was called.
+func (h *Hub) Closed() <-chan struct{} {
+	return h.closed
+}
+
+func (h *Hub) Subscribe(customerId string) *Subscription {
+	subscription := &Subscription{customerId: customerId, wake: make(chan struct{}, 1)}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscriptions[subscription] = true
+	return subscription
+}
+
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.subscriptions, subscription)
+}
+
+// Wake signals the subscriptions of the customers, all of them when
+// customerIds is nil.
+func (h *Hub) Wake(customerIds []string) {
+	wanted := make(map[string]bool, len(customerIds))
+	for _, id := range customerIds {
+		wanted[id] = true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for subscription := range h.subscriptions {
+		if customerIds != nil && !wanted[subscription.customerId] {
+			continue
+		}
+		select {
+		case subscription.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run listens on Channel until the context is done, reconnecting when the
+// connection is lost.
+func (h *Hub) Run(ctx context.Context) {
+	if h.connectionString == "" {
+		h.poll(ctx)
+		return
+	}
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		h.logger.Error().Err(err).Msg("Hub: lost the connection listening for events")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (h *Hub) poll(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Wake(nil)
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, h.connectionString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+	// Whatever was published while the hub was not listening is picked up now.
+	h.Wake(nil)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var customerIds []string
+		err = json.Unmarshal([]byte(notification.Payload), &customerIds)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Hub: could not read a notification")
+			h.Wake(nil)
+			continue
+		}
+		h.Wake(customerIds)
+	}
+}
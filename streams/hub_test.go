@@ -0,0 +1,66 @@
+package streams
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// woken reports whether the subscription was signalled.
+func woken(subscription *Subscription) bool {
+	select {
+	case <-subscription.Wake():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubWake(t *testing.T) {
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, "")
+	alice, bob := hub.Subscribe("alice"), hub.Subscribe("bob")
+
+	// Notifications of a batch are signalled once.
+	hub.Wake([]string{"alice"})
+	hub.Wake([]string{"alice"})
+	if !woken(alice) || woken(alice) || woken(bob) {
+		t.Error("did not wake only alice once")
+	}
+
+	hub.Unsubscribe(bob)
+	hub.Wake(nil)
+	if !woken(alice) || woken(bob) {
+		t.Error("did not wake only the subscriptions left")
+	}
+}
+
+func TestHubPolls(t *testing.T) {
+	logger := zerolog.Nop()
+	hub := NewHub(&logger, "")
+	subscription := hub.Subscribe("alice")
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		hub.Run(ctx)
+	}()
+
+	select {
+	case <-subscription.Wake():
+	case <-time.After(5 * pollInterval):
+		t.Error("a hub without a database did not poll")
+	}
+	cancel()
+	<-stopped
+
+	hub.Close()
+	hub.Close()
+	select {
+	case <-hub.Closed():
+	default:
+		t.Error("the hub is not closed")
+	}
+}
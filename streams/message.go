@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+// AvailabilityChanged follows every event that took or released a slot.
+const AvailabilityChanged = "availability.changed"
+
+// Keepalive is sent when nothing happened for a while, so that proxies keep
+// the connection open and clients notice when it is gone.
+const Keepalive = "keepalive"
+
+// Message is one event of a stream. ID is the published position of the
+// event, clients send the last one they got to resume.
+type Message struct {
+	ID    int64           `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Availability tells that the slot of a reservation was taken or released.
+// The source may still be busy in a released window because of other
+// reservations, clients refresh their view of it.
+type Availability struct {
+	SourceID      string    `json:"sourceId"`
+	ReservationID string    `json:"reservationId"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Released      bool      `json:"released"`
+}
+
+// changesAvailability tells whether events of the type take or release a
+// slot, approvals and payments only confirm a slot that is already held.
+func changesAvailability(eventType string) bool {
+	switch eventType {
+	case events.ReservationCreated, events.ReservationUpdated, events.ReservationCancelled,
+		events.ReservationRejected, events.ReservationApprovalExpired, events.ReservationPaymentFailed:
+		return true
+	}
+	return false
+}
+
+// Messages turns a published reservation event into the messages of a
+// stream.
+func Messages(row models.OutboxEvent) ([]Message, error) {
+	if row.PublishedPosition == nil {
+		return nil, fmt.Errorf("event %s is not published", row.ID)
+	}
+	messages := []Message{{ID: *row.PublishedPosition, Event: row.Type, Data: json.RawMessage(row.Payload)}}
+	if !changesAvailability(row.Type) {
+		return messages, nil
+	}
+
+	var reservation models.Reservation
+	err := json.Unmarshal([]byte(row.Payload), &reservation)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(Availability{
+		SourceID:      reservation.SourceID,
+		ReservationID: reservation.ID,
+		From:          reservation.From,
+		To:            reservation.To,
+		Released:      reservation.IsReleased(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, Message{ID: *row.PublishedPosition, Event: AvailabilityChanged, Data: data}), nil
+}
+
+// WriteEvent writes the message in the text/event-stream format.
+func WriteEvent(w io.Writer, message Message) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", message.ID, message.Event, strings.ReplaceAll(string(message.Data), "\n", "\ndata: "))
+	return err
+}
+
+// WriteKeepalive writes an event-stream comment, which EventSource ignores.
+func WriteKeepalive(w io.Writer) error {
+	_, err := io.WriteString(w, ": "+Keepalive+"\n\n")
+	return err
+}
@@ -1,27 +1,31 @@
 package util
 
 import (
-	"math/rand/v2"
+	"crypto/rand"
+	"math/big"
 )
 
+// GetRandString returns length letters and digits drawn from crypto/rand, so
+// that the result can serve as a credential.
 func GetRandString(length int) string {
-	alphanumericals := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-	maxRand := len(alphanumericals)
-	res := make([]rune, length)
-	for i := 0; i < length; i++ {
-		res[i] = alphanumericals[rand.IntN(maxRand)]
-	}
-
-	return string(res)
+	return randomOf("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", length)
 }
 
+// GetRandHexString returns elemCount hex digits drawn from crypto/rand.
 func GetRandHexString(elemCount int) string {
-	hexSymbols := []rune("abcdef0123456789")
-	maxRand := len(hexSymbols)
-	res := make([]rune, elemCount)
-	for i := 0; i < elemCount; i++ {
-		res[i] = hexSymbols[rand.IntN(maxRand)]
-	}
+	return randomOf("abcdef0123456789", elemCount)
+}
 
+func randomOf(symbols string, length int) string {
+	bound := big.NewInt(int64(len(symbols)))
+	res := make([]byte, length)
+	for i := range res {
+		n, err := rand.Int(rand.Reader, bound)
+		if err != nil {
+			// crypto/rand only fails when the system has no randomness to give.
+			panic(err)
+		}
+		res[i] = symbols[n.Int64()]
+	}
 	return string(res)
 }
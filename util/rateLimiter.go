@@ -0,0 +1,41 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter counts calls per key in fixed one minute windows. It is kept in
+// memory, so every instance of the engine limits on its own.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: map[string]*rateWindow{}}
+}
+
+// Allow counts a call for the key and tells whether it is within limit calls
+// for the current minute.
+func (l *RateLimiter) Allow(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Truncate(time.Minute)
+	window, ok := l.windows[key]
+	if !ok || window.start.Before(start) {
+		window = &rateWindow{start: start}
+		l.windows[key] = window
+	}
+	if window.count >= limit {
+		return false
+	}
+	window.count++
+	return true
+}
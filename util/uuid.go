@@ -0,0 +1,18 @@
+package util
+
+import (
+	"regexp"
+
+	"github.com/lghtr35/reservation-engine/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() string {
+	return models.NewUUID()
+}
@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	sentAt := time.Unix(1_760_000_000, 0)
+	body := []byte(`{"type":"reservation.created"}`)
+	header := Sign("secret", sentAt, body)
+
+	for _, test := range []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		now       time.Time
+		want      error
+	}{
+		{name: "signed", secret: "secret", header: header, body: body, tolerance: 5 * time.Minute, now: sentAt.Add(time.Minute)},
+		{name: "any age", secret: "secret", header: header, body: body, now: sentAt.Add(24 * time.Hour)},
+		{name: "other secret", secret: "other", header: header, body: body, want: ErrInvalidSignature},
+		{name: "changed body", secret: "secret", header: header, body: []byte(`{}`), want: ErrInvalidSignature},
+		{name: "no signature", secret: "secret", header: "t=1760000000", body: body, want: ErrInvalidSignature},
+		{name: "no timestamp", secret: "secret", header: "v1=00", body: body, want: ErrInvalidSignature},
+		{name: "too old", secret: "secret", header: header, body: body, tolerance: 5 * time.Minute, now: sentAt.Add(time.Hour), want: ErrExpiredSignature},
+	} {
+		if err := Verify(test.secret, test.header, test.body, test.tolerance, test.now); !errors.Is(err, test.want) {
+			t.Errorf("%s: verified with %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		10: 4*time.Hour + 16*time.Minute,
+		11: 6 * time.Hour,
+		50: 6 * time.Hour,
+	} {
+		if wait := Backoff(attempts); wait != want {
+			t.Errorf("waits %v after %d attempts, want %v", wait, attempts, want)
+		}
+	}
+}
+
+func TestSenderSend(t *testing.T) {
+	sentAt := time.Now()
+	body := []byte(`{"id":"evt"}`)
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err = Verify("secret", r.Header.Get(SignatureHeader), received, time.Minute, time.Now()); err != nil {
+			t.Errorf("received a delivery that does not verify: %v", err)
+		}
+		if r.Header.Get(EventHeader) != "reservation.created" || r.Header.Get(DeliveryHeader) != "d-1" {
+			t.Errorf("received the headers %v", r.Header)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sender := NewSender(time.Second)
+
+	if code, err := sender.Send(context.Background(), server.URL, "secret", "d-1", "reservation.created", body, sentAt); err != nil || code != http.StatusNoContent {
+		t.Errorf("sent with status %d: %v", code, err)
+	}
+	status = http.StatusGone
+	if code, err := sender.Send(context.Background(), server.URL, "secret", "d-1", "reservation.created", body, sentAt); err == nil || code != http.StatusGone {
+		t.Errorf("sent with status %d and no error", code)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sender.Send(cancelled, server.URL, "secret", "d-1", "reservation.created", body, sentAt); !errors.Is(err, context.Canceled) {
+		t.Errorf("sent with a cancelled context: %v", err)
+	}
+}
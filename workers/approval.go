@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/payments"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// ApprovalExpiryWorker periodically expires approval requests that were not
+// answered in time.
+type ApprovalExpiryWorker struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	provider payments.PaymentProvider
+	interval time.Duration
+}
+
+func NewApprovalExpiryWorker(db *gorm.DB, logger *zerolog.Logger, provider payments.PaymentProvider, interval time.Duration) *ApprovalExpiryWorker {
+	return &ApprovalExpiryWorker{db: db, logger: logger, provider: provider, interval: interval}
+}
+
+func (w *ApprovalExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			count, err := commands.NewExpireApprovalsCommand(w.db, w.logger, w.provider, now).Execute()
+			if err != nil {
+				w.logger.Error().Err(err).Msg("ApprovalExpiryWorker: could not expire approvals")
+				continue
+			}
+			w.logger.Debug().Msgf("ApprovalExpiryWorker: expired %s reservations", count)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestApprovalExpiryWorker(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		room := f.Source(t, customer, "room")
+		alice := f.Person(t, customer, "alice")
+		expired, waiting := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		overdue := models.Reservation{From: repotest.At(9), To: repotest.At(10), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID,
+			Status: models.ReservationStatusPendingApproval, ApproverID: &alice.ID, ApprovalExpiresAt: &expired}
+		pending := models.Reservation{From: repotest.At(11), To: repotest.At(12), SourceID: room.ID, ReserverID: alice.ID, ReserveeID: alice.ID,
+			Status: models.ReservationStatusPendingApproval, ApproverID: &alice.ID, ApprovalExpiresAt: &waiting}
+		f.Add(t, &overdue, &pending)
+		statusOf := func(id string) string {
+			reservation, err := f.Reservations().Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return reservation.Status
+		}
+
+		runUntil(t, NewApprovalExpiryWorker(f.DB, f.Logger, nil, 10*time.Millisecond), func() bool {
+			return statusOf(overdue.ID) == models.ReservationStatusExpired
+		})
+		if status := statusOf(pending.ID); status != models.ReservationStatusPendingApproval {
+			t.Errorf("the reservation whose approval is not due is %s", status)
+		}
+	})
+}
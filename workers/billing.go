@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/rs/zerolog"
+)
+
+// BillingCloseWorker periodically closes the previous billing period by
+// issuing the invoices that are still missing for it.
+type BillingCloseWorker struct {
+	store    repository.Store
+	logger   *zerolog.Logger
+	taxRate  int
+	interval time.Duration
+}
+
+func NewBillingCloseWorker(store repository.Store, logger *zerolog.Logger, taxRate int, interval time.Duration) *BillingCloseWorker {
+	return &BillingCloseWorker{store: store, logger: logger, taxRate: taxRate, interval: interval}
+}
+
+func (w *BillingCloseWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// The customers that could not be invoiced are tried again on the
+			// next tick, the ones that were are not invoiced twice.
+			count, err := commands.NewCloseBillingPeriodCommand(w.store, w.logger, w.taxRate, now).Execute()
+			if err != nil {
+				w.logger.Error().Err(err).Msg("BillingCloseWorker: could not close the billing period")
+			}
+			w.logger.Debug().Msgf("BillingCloseWorker: issued %s invoices", count)
+		}
+	}
+}
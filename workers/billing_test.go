@@ -0,0 +1,25 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+func TestBillingCloseWorker(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := models.Customer{Base: models.Base{CreatedAt: time.Now().AddDate(0, -2, 0)}, Name: "alice", Company: "alice Ltd", Email: "alice@example.com"}
+		f.Add(t, &customer)
+		period := time.Now().UTC().AddDate(0, 0, -time.Now().UTC().Day()).Format(models.UsagePeriodFormat)
+
+		runUntil(t, NewBillingCloseWorker(f, f.Logger, 1900, 10*time.Millisecond), func() bool {
+			issued, err := f.Invoices().CountIssued(customer.ID, period)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return issued == 1
+		})
+	})
+}
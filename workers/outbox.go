@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// OutboxRelayWorker periodically publishes the committed events of the outbox
+// to the sinks.
+type OutboxRelayWorker struct {
+	db       *gorm.DB
+	logger   *zerolog.Logger
+	sinks    []events.Sink
+	interval time.Duration
+}
+
+func NewOutboxRelayWorker(db *gorm.DB, logger *zerolog.Logger, sinks []events.Sink, interval time.Duration) *OutboxRelayWorker {
+	return &OutboxRelayWorker{db: db, logger: logger, sinks: sinks, interval: interval}
+}
+
+func (w *OutboxRelayWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := commands.NewRelayOutboxCommand(ctx, w.db, w.logger, w.sinks, 100).Execute()
+			if err != nil {
+				w.logger.Error().Err(err).Msg("OutboxRelayWorker: could not relay the outbox")
+				continue
+			}
+			w.logger.Debug().Msgf("OutboxRelayWorker: published %s events", count)
+		}
+	}
+}
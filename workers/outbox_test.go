@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+)
+
+// countingSink counts the events it was handed.
+type countingSink struct {
+	published map[string]int
+}
+
+func (s *countingSink) Name() string {
+	return "counting"
+}
+
+func (s *countingSink) Publish(event events.Event) error {
+	s.published[event.ID]++
+	return nil
+}
+
+// TestRelayOutboxCommand relays with a webhook endpoint, whose deliveries are
+// queued in the transaction of the relay.
+func TestRelayOutboxCommand(t *testing.T) {
+	repotest.RunDatabases(t, testRelayOutboxCommand)
+}
+
+func testRelayOutboxCommand(t *testing.T, f *repotest.Fixture) {
+	db := f.DB
+
+	id, err := commands.NewCreateCustomerCommand(f, f.Logger, "Alice", "Acme", "a@acme.io").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := models.WebhookEndpoint{CustomerID: id, URL: "https://example.com/hook", Secret: "secret", Active: true}
+	f.Add(t, &endpoint)
+
+	counting := &countingSink{published: map[string]int{}}
+	sinks := []events.Sink{NewWebhookSink(db, f.Logger), counting}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = commands.NewRelayOutboxCommand(cancelled, db, f.Logger, sinks, 10).Execute(); err == nil {
+		t.Error("relayed with a cancelled context")
+	}
+
+	for run, want := range []string{"1", "0"} {
+		count, err := commands.NewRelayOutboxCommand(context.Background(), db, f.Logger, sinks, 10).Execute()
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if count != want {
+			t.Errorf("run %d: published %s events, want %s", run, count, want)
+		}
+	}
+
+	var outbox []models.OutboxEvent
+	if err = db.Find(&outbox).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 || outbox[0].PublishedAt == nil || outbox[0].LastError != "" {
+		t.Fatalf("outbox is %+v", outbox)
+	}
+	if counting.published[outbox[0].ID] != 1 {
+		t.Errorf("published the event %d times", counting.published[outbox[0].ID])
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err = db.Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EndpointID != endpoint.ID || deliveries[0].EventID != outbox[0].ID {
+		t.Errorf("queued %+v", deliveries)
+	}
+}
+
+func TestOutboxRelayWorker(t *testing.T) {
+	repotest.RunDatabases(t, func(t *testing.T, f *repotest.Fixture) {
+		if _, err := commands.NewCreateCustomerCommand(f, f.Logger, "Alice", "Acme", "a@acme.io").Execute(); err != nil {
+			t.Fatal(err)
+		}
+		counting := &countingSink{published: map[string]int{}}
+
+		runUntil(t, NewOutboxRelayWorker(f.DB, f.Logger, []events.Sink{counting}, 10*time.Millisecond), func() bool {
+			var unpublished int64
+			if err := f.DB.Model(&models.OutboxEvent{}).Where("published_at IS NULL").Count(&unpublished).Error; err != nil {
+				t.Fatal(err)
+			}
+			return unpublished == 0
+		})
+		if len(counting.published) != 1 {
+			t.Errorf("published %v", counting.published)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/gormstore"
+	"github.com/lghtr35/reservation-engine/webhooks"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// WebhookSink queues every relayed event for the webhook endpoints of its
+// customer, in the transaction of the relay, see commands.TxSink.
+type WebhookSink struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+}
+
+func NewWebhookSink(db *gorm.DB, logger *zerolog.Logger) *WebhookSink {
+	return &WebhookSink{db: db, logger: logger}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhooks"
+}
+
+func (s *WebhookSink) Publish(event events.Event) error {
+	return s.PublishTx(s.db, event)
+}
+
+func (s *WebhookSink) PublishTx(tx *gorm.DB, event events.Event) error {
+	_, err := commands.NewEnqueueWebhookDeliveriesCommand(gormstore.New(tx), s.logger, event).Execute()
+	return err
+}
+
+// WebhookDeliveryWorker periodically sends the webhook deliveries that are due.
+type WebhookDeliveryWorker struct {
+	store    repository.Store
+	logger   *zerolog.Logger
+	sender   *webhooks.Sender
+	interval time.Duration
+}
+
+func NewWebhookDeliveryWorker(store repository.Store, logger *zerolog.Logger, sender *webhooks.Sender, interval time.Duration) *WebhookDeliveryWorker {
+	return &WebhookDeliveryWorker{store: store, logger: logger, sender: sender, interval: interval}
+}
+
+func (w *WebhookDeliveryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			count, err := commands.NewDeliverWebhooksCommand(ctx, w.store, w.logger, w.sender, now, 100).Execute()
+			if err != nil {
+				w.logger.Error().Err(err).Msg("WebhookDeliveryWorker: could not deliver webhooks")
+				continue
+			}
+			w.logger.Debug().Msgf("WebhookDeliveryWorker: attempted %s deliveries", count)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lghtr35/reservation-engine/commands"
+	"github.com/lghtr35/reservation-engine/events"
+	"github.com/lghtr35/reservation-engine/models"
+	"github.com/lghtr35/reservation-engine/repository"
+	"github.com/lghtr35/reservation-engine/repository/repotest"
+	"github.com/lghtr35/reservation-engine/webhooks"
+)
+
+func TestWebhookDeliveryWorker(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, f *repotest.Fixture) {
+		customer := f.Customer(t, "alice", nil)
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		if _, err := commands.NewCreateWebhookEndpointCommand(f, f.Logger, customer.ID, server.URL, nil).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		event := events.NewEvent(events.ReservationCreated, customer.ID, models.NewUUID(), map[string]string{"hello": "world"})
+		if queued, err := commands.NewEnqueueWebhookDeliveriesCommand(f, f.Logger, event).Execute(); err != nil || queued != "1" {
+			t.Fatalf("queued %s deliveries: %v", queued, err)
+		}
+
+		// A delivery that is sent is not due anymore, however late it is.
+		runUntil(t, NewWebhookDeliveryWorker(f, f.Logger, webhooks.NewSender(5*time.Second), 10*time.Millisecond), func() bool {
+			err := f.Transaction(func(tx repository.Store) error {
+				_, err := tx.WebhookDeliveries().GetDueForUpdate(time.Now().Add(24 * time.Hour))
+				return err
+			})
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				t.Fatal(err)
+			}
+			return errors.Is(err, repository.ErrNotFound)
+		})
+		if calls.Load() != 1 {
+			t.Errorf("called the endpoint %d times", calls.Load())
+		}
+	})
+}
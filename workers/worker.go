@@ -0,0 +1,99 @@
+/*
+ * Background jobs that run next to the HTTP server.
+ */
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+type Worker interface {
+	// Run blocks until the context is cancelled.
+	Run(ctx context.Context)
+}
+
+// Group runs workers until it is stopped and knows which of them are still
+// running, a worker that panicked is logged and stays stopped.
+type Group struct {
+	logger  *zerolog.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wait    sync.WaitGroup
+	mutex   sync.Mutex
+	running map[string]bool
+}
+
+func NewGroup(logger *zerolog.Logger) *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{logger: logger, ctx: ctx, cancel: cancel, running: make(map[string]bool)}
+}
+
+// Go runs the worker under the name in its own goroutine.
+func (g *Group) Go(name string, worker Worker) {
+	g.mutex.Lock()
+	g.running[name] = true
+	g.mutex.Unlock()
+
+	g.wait.Add(1)
+	go func() {
+		defer g.wait.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				g.logger.Error().Msgf("Group: worker %s panicked: %v", name, r)
+			}
+			g.mutex.Lock()
+			g.running[name] = false
+			g.mutex.Unlock()
+		}()
+		worker.Run(g.ctx)
+	}()
+}
+
+// Stopped returns the names of the workers that are not running, sorted.
+func (g *Group) Stopped() []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	stopped := []string{}
+	for name, running := range g.running {
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	sort.Strings(stopped)
+	return stopped
+}
+
+// Stop cancels the workers and waits for them to return, or for the context
+// to be done.
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wait.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Group: workers %v did not stop: %w", g.runningNames(), ctx.Err())
+	}
+}
+
+func (g *Group) runningNames() []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	names := []string{}
+	for name, running := range g.running {
+		if running {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package workers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// workerFunc runs a function as a worker.
+type workerFunc func(ctx context.Context)
+
+func (f workerFunc) Run(ctx context.Context) {
+	f(ctx)
+}
+
+// runUntil runs the worker until done returns true, and fails the test when
+// it does not within a few seconds or the worker does not stop once
+// cancelled.
+func runUntil(t *testing.T, worker Worker, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		worker.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Error("the worker did not get its work done")
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker did not stop once cancelled")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	logger := zerolog.Nop()
+	group := NewGroup(&logger)
+	panicked := make(chan struct{})
+	group.Go("waiting", workerFunc(func(ctx context.Context) { <-ctx.Done() }))
+	group.Go("panicking", workerFunc(func(ctx context.Context) {
+		close(panicked)
+		panic("boom")
+	}))
+	<-panicked
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(group.Stopped(), []string{"panicking"}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stopped %v, want the panicking worker", group.Stopped())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := group.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stopped := group.Stopped(); !slices.Equal(stopped, []string{"panicking", "waiting"}) {
+		t.Errorf("stopped %v after the group was stopped", stopped)
+	}
+}
+
+func TestGroupStopTimesOut(t *testing.T) {
+	logger := zerolog.Nop()
+	group := NewGroup(&logger)
+	release := make(chan struct{})
+	defer close(release)
+	group.Go("stubborn", workerFunc(func(ctx context.Context) { <-release }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := group.Stop(ctx); err == nil {
+		t.Error("stopped a worker that ignores its context")
+	}
+}